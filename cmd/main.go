@@ -6,12 +6,15 @@ import (
 	"github.com/google/uuid"
 	httpapi "github.com/tinoosan/ledger/internal/httpapi/v1"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+	"github.com/tinoosan/ledger/internal/service/webhook"
 	"github.com/tinoosan/ledger/internal/storage/memory"
 	pgstore "github.com/tinoosan/ledger/internal/storage/postgres"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -25,8 +28,18 @@ func main() {
 	logger := buildLoggerFromEnv()
 	slog.SetDefault(logger)
 
-	var srvMux http.Handler
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-balances" {
+		code := runRebuildBalances(ctx, logger, os.Args[2:])
+		stop()
+		os.Exit(code)
+	}
+
+	var api *httpapi.Server
 	var closeFn func()
+	var schedules schedule.Service
+	var webhooks webhook.Service
+	// WEBHOOK_ALLOW_PRIVATE_HOSTS lets webhooks reach loopback and private addresses, for development.
+	allowPrivate, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_HOSTS"))
 
 	if dsn := strings.TrimSpace(os.Getenv("DATABASE_URL")); dsn != "" {
 		// Use Postgres store when DATABASE_URL is provided
@@ -46,7 +59,10 @@ func main() {
 				printDevSeedBanner(user, accs)
 			}
 		}
-		srvMux = httpapi.New(pg, pg, pg, pg, pg, pg, pg, logger).Handler()
+		api = httpapi.New(pg, pg, pg, pg, pg, pg, pg, logger)
+		// Use the API's schedule service so scheduled postings reach the live stream.
+		schedules = api.Schedules()
+		webhooks = webhook.New(pg, pg, nil, allowPrivate)
 		logger.Info("storage backend: postgres")
 	} else {
 		// Default to in-memory store with a small dev seed
@@ -61,18 +77,30 @@ func main() {
 		store.SeedAccount(income)
 		logDevSeed(logger, "memory", user, []ledger.Account{opening, cash, income})
 		printDevSeedBanner(user, []ledger.Account{opening, cash, income})
-		srvMux = httpapi.New(store, store, store, store, store, store, store, logger).Handler()
+		api = httpapi.New(store, store, store, store, store, store, store, logger)
+		// Use the API's schedule service so scheduled postings reach the live stream.
+		schedules = api.Schedules()
+		webhooks = webhook.New(store, store, nil, allowPrivate)
 		logger.Info("storage backend: memory")
 	}
 
 	srv := &http.Server{
 		Addr:              ":8080",
-		Handler:           srvMux,
+		Handler:           api.Handler(),
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      10 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
+	// Shutdown waits for handlers to return; event streams would otherwise hold it open.
+	srv.RegisterOnShutdown(api.CloseStreams)
+
+	if every := schedulerIntervalFromEnv(logger); every > 0 {
+		go runScheduler(ctx, schedules, every, logger)
+	}
+	if every := durationFromEnv(logger, "WEBHOOK_DISPATCH_INTERVAL", 5*time.Second); every > 0 {
+		go runWebhookDispatcher(ctx, webhooks, every, logger)
+	}
 
 	errCh := make(chan error, 1)
 	go func() {
@@ -97,6 +125,76 @@ func main() {
 	}
 }
 
+// runScheduler posts due recurring entries every interval until ctx is done.
+// Runs lock each schedule while posting and occurrences are idempotent, so
+// overlapping instances or restarts never double post.
+func runScheduler(ctx context.Context, svc schedule.Service, every time.Duration, l *slog.Logger) {
+	l.Info("scheduler started", "interval", every.String())
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		res, err := svc.RunDue(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			l.Error("scheduler run failed", "err", err)
+		}
+		if len(res.Posted) > 0 {
+			l.Info("scheduler posted entries", "count", len(res.Posted))
+		}
+		for _, e := range res.Errors {
+			l.Warn("scheduled occurrence not posted", "schedule_id", e.ScheduleID.String(), "occurrence", e.Occurrence, "err", e.Err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runWebhookDispatcher delivers outbox events to webhook endpoints every interval until ctx is done.
+// Deliveries are leased while sent, so several instances can run it side by side.
+func runWebhookDispatcher(ctx context.Context, svc webhook.Service, every time.Duration, l *slog.Logger) {
+	l.Info("webhook dispatcher started", "interval", every.String())
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		n, err := svc.Dispatch(ctx, time.Now())
+		if err != nil && ctx.Err() == nil {
+			l.Error("webhook dispatch failed", "err", err)
+		}
+		if n > 0 {
+			l.Debug("webhook deliveries attempted", "count", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedulerIntervalFromEnv reads SCHEDULER_INTERVAL (Go duration, default 1m); 0 or "off" disables it.
+func schedulerIntervalFromEnv(l *slog.Logger) time.Duration {
+	return durationFromEnv(l, "SCHEDULER_INTERVAL", time.Minute)
+}
+
+// durationFromEnv reads a Go duration from key, falling back to def; 0 or "off" disables it.
+func durationFromEnv(l *slog.Logger, key string, def time.Duration) time.Duration {
+	raw := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	switch raw {
+	case "":
+		return def
+	case "0", "off", "false", "no":
+		return 0
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		l.Warn("invalid "+key+"; using "+def.String(), "value", raw)
+		return def
+	}
+	return d
+}
+
 // logDevSeed emits structured logs with useful IDs
 func logDevSeed(l *slog.Logger, backend string, user ledger.User, accs []ledger.Account) {
 	ids := map[string]string{}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	pgstore "github.com/tinoosan/ledger/internal/storage/postgres"
+)
+
+// runRebuildBalances implements `ledger rebuild-balances [-user <id>]`. It
+// recomputes the materialized account balances in DATABASE_URL from the stored
+// entries, for one user or for everyone, and returns the process exit code.
+func runRebuildBalances(ctx context.Context, l *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("rebuild-balances", flag.ContinueOnError)
+	user := fs.String("user", "", "only rebuild this user's balances (default: all users)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	userID := uuid.Nil
+	if *user != "" {
+		id, err := uuid.Parse(*user)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -user: %v\n", err)
+			return 2
+		}
+		userID = id
+	}
+	dsn := strings.TrimSpace(os.Getenv("DATABASE_URL"))
+	if dsn == "" {
+		// The memory store starts empty, so there is nothing to rebuild.
+		fmt.Fprintln(os.Stderr, "rebuild-balances needs DATABASE_URL")
+		return 2
+	}
+	pg, err := pgstore.Open(ctx, dsn)
+	if err != nil {
+		l.Error("failed to connect to postgres", "err", err)
+		return 1
+	}
+	defer pg.Close()
+	start := time.Now()
+	if err := pg.RebuildBalances(ctx, userID); err != nil {
+		l.Error("rebuild balances failed", "err", err)
+		return 1
+	}
+	l.Info("balances rebuilt", "user_id", *user, "took", time.Since(start).String())
+	return 0
+}
@@ -43,6 +43,10 @@ create table if not exists entries (
 );
 
 create index if not exists ix_entries_user_date_id on entries (user_id, date asc, id asc);
+-- Statement imports stamp an input hash; a user posts each hash at most once,
+-- so concurrent imports of one file cannot both post a row.
+create unique index if not exists ux_entries_user_input_hash on entries (user_id, (metadata->>'tracker.input_hash'))
+    where metadata ? 'tracker.input_hash';
 
 -- Lines
 create table if not exists entry_lines (
@@ -51,6 +55,13 @@ create table if not exists entry_lines (
     account_id uuid not null,
     side text not null check (side in ('debit','credit')),
     amount_minor bigint not null check (amount_minor > 0),
+    -- Cross-currency lines only: line currency and rate into the entry currency.
+    currency char(3),
+    exchange_rate numeric check (exchange_rate > 0),
+    -- Bank clearing state; reconciled lines block reversal of their entry.
+    status text not null default 'uncleared' check (status in ('uncleared','cleared','reconciled')),
+    -- Part of amount_minor undone by (partial) reversals so far.
+    reversed_minor bigint not null default 0 check (reversed_minor >= 0 and reversed_minor <= amount_minor),
     created_at timestamptz not null default now(),
     updated_at timestamptz not null default now(),
     constraint fk_lines_entries foreign key (entry_id) references entries(id) on delete cascade,
@@ -60,6 +71,21 @@ create table if not exists entry_lines (
 create index if not exists ix_lines_entry on entry_lines (entry_id);
 create index if not exists ix_lines_account on entry_lines (account_id);
 
+-- Links between entries (reversals, reclassifications, user-made links).
+-- Each entry owns the rows for its side; the inverse kind sits on the other entry.
+create table if not exists entry_relations (
+    entry_id uuid not null,
+    position int not null,
+    kind text not null check (kind in ('reverses','reversed_by','reclassified_from','reclassified_to','related')),
+    related_entry_id uuid not null,
+    created_at timestamptz not null default now(),
+    primary key (entry_id, position),
+    constraint fk_relations_entries foreign key (entry_id) references entries(id) on delete cascade,
+    constraint fk_relations_related foreign key (related_entry_id) references entries(id) on delete cascade
+);
+
+create index if not exists ix_relations_related on entry_relations (related_entry_id);
+
 -- Idempotency for entries
 create table if not exists entry_idempotency (
     user_id uuid not null,
@@ -70,6 +96,254 @@ create table if not exists entry_idempotency (
     constraint fk_idem_entry foreign key (entry_id) references entries(id) on delete cascade
 );
 
+-- Accounting periods: [start_at, end_at) per user; closed/locked periods reject postings
+create table if not exists periods (
+    id uuid primary key,
+    user_id uuid not null,
+    name text not null,
+    start_at timestamptz not null,
+    end_at timestamptz not null,
+    status text not null default 'open' check (status in ('open','closed','locked')),
+    closed_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_periods_users foreign key (user_id) references users(id) on delete cascade,
+    constraint ck_periods_range check (start_at < end_at)
+);
+
+create index if not exists ix_periods_user_start on periods (user_id, start_at);
+
+-- Exchange rates: 1 base = rate quote, effective from rate_date per user and pair
+create table if not exists fx_rates (
+    id uuid primary key,
+    user_id uuid not null,
+    base char(3) not null,
+    quote char(3) not null,
+    rate_date date not null,
+    rate numeric not null check (rate > 0),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_fx_rates_users foreign key (user_id) references users(id) on delete cascade,
+    constraint ck_fx_rates_pair check (base <> quote),
+    constraint uq_fx_rates_user_pair_date unique (user_id, base, quote, rate_date)
+);
+
+-- Budgets: amount_minor per account path prefix (expense:groceries) for one month or year
+create table if not exists budgets (
+    id uuid primary key,
+    user_id uuid not null,
+    path text not null,
+    period text not null check (period in ('monthly','yearly')),
+    start_at timestamptz not null,
+    currency char(3) not null,
+    amount_minor bigint not null check (amount_minor >= 0),
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_budgets_users foreign key (user_id) references users(id) on delete cascade,
+    constraint uq_budgets_user_path_period unique (user_id, path, period, start_at, currency)
+);
+
+-- Recurring entry schedules: template is the entry posted on every occurrence of rule;
+-- next_run_at is the earliest occurrence not yet posted (null once the rule is exhausted)
+create table if not exists schedules (
+    id uuid primary key,
+    user_id uuid not null,
+    name text not null,
+    rule text not null,
+    start_at timestamptz not null,
+    until_at timestamptz,
+    template jsonb not null,
+    active boolean not null default true,
+    next_run_at timestamptz,
+    last_error text not null default '',
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_schedules_users foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists ix_schedules_due on schedules (next_run_at) where active;
+
+-- Categorization rules: evaluated by priority against incoming entries;
+-- match and actions hold the conditions and rewrites as json
+create table if not exists rules (
+    id uuid primary key,
+    user_id uuid not null,
+    name text not null,
+    priority integer not null default 0,
+    active boolean not null default true,
+    stop boolean not null default false,
+    match jsonb not null default '{}'::jsonb,
+    actions jsonb not null default '{}'::jsonb,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_rules_users foreign key (user_id) references users(id) on delete cascade
+);
+
+create index if not exists ix_rules_user_priority on rules (user_id, priority);
+
+-- CSV import profiles: saved column mappings for bank exports
+create table if not exists import_profiles (
+    id uuid primary key,
+    user_id uuid not null,
+    name text not null,
+    counter_account_id uuid null,
+    mapping jsonb not null default '{}'::jsonb,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_import_profiles_users foreign key (user_id) references users(id) on delete cascade,
+    constraint fk_import_profiles_counter foreign key (counter_account_id) references accounts(id)
+);
+
+create index if not exists ix_import_profiles_user on import_profiles (user_id);
+
+-- Bank reconciliations: one statement of an asset or liability account matched
+-- against its journal lines. Balances are signed debit-positive (money in).
+create table if not exists reconciliations (
+    id uuid primary key,
+    user_id uuid not null,
+    account_id uuid not null,
+    statement_date timestamptz not null,
+    currency char(3) not null,
+    opening_balance_minor bigint not null,
+    statement_balance_minor bigint not null,
+    status text not null check (status in ('open','finished')),
+    reconciled_balance_minor bigint null,
+    finished_at timestamptz null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_reconciliations_users foreign key (user_id) references users(id) on delete cascade,
+    constraint fk_reconciliations_accounts foreign key (account_id) references accounts(id) on delete cascade
+);
+
+create index if not exists ix_reconciliations_account on reconciliations (user_id, account_id, statement_date);
+
+create table if not exists reconciliation_lines (
+    id uuid primary key,
+    reconciliation_id uuid not null,
+    position int not null,
+    date timestamptz not null,
+    amount_minor bigint not null,
+    description text not null default '',
+    reference text not null default '',
+    journal_line_id uuid null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_reconciliation_lines_reconciliations foreign key (reconciliation_id) references reconciliations(id) on delete cascade,
+    constraint fk_reconciliation_lines_entry_lines foreign key (journal_line_id) references entry_lines(id) on delete set null
+);
+
+create index if not exists ix_reconciliation_lines_reconciliation on reconciliation_lines (reconciliation_id, position);
+-- A journal line clears at most one statement line.
+create unique index if not exists uq_reconciliation_lines_journal_line on reconciliation_lines (journal_line_id) where journal_line_id is not null;
+
+-- Balance assertions: the account balance (debits minus credits) at the end of
+-- assert_date must equal amount_minor
+create table if not exists balance_assertions (
+    id uuid primary key,
+    user_id uuid not null,
+    account_id uuid not null,
+    assert_date date not null,
+    currency char(3) not null,
+    amount_minor bigint not null,
+    note text not null default '',
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    constraint fk_balance_assertions_users foreign key (user_id) references users(id) on delete cascade,
+    constraint fk_balance_assertions_accounts foreign key (account_id) references accounts(id) on delete cascade,
+    constraint uq_balance_assertions_account_date unique (account_id, assert_date)
+);
+
+-- Materialized balances: net (debits - credits) per account in the account
+-- currency, in total and per UTC day for as-of queries. Maintained by the
+-- transaction that writes each entry; `ledger rebuild-balances` recomputes them.
+create table if not exists account_balances (
+    account_id uuid primary key,
+    user_id uuid not null,
+    currency char(3) not null,
+    net_minor bigint not null,
+    constraint fk_account_balances_users foreign key (user_id) references users(id) on delete cascade,
+    constraint fk_account_balances_accounts foreign key (account_id) references accounts(id) on delete cascade
+);
+
+create index if not exists ix_account_balances_user on account_balances (user_id);
+
+create table if not exists account_daily_balances (
+    account_id uuid not null,
+    day date not null,
+    user_id uuid not null,
+    currency char(3) not null,
+    net_minor bigint not null,
+    primary key (account_id, day),
+    constraint fk_account_daily_balances_users foreign key (user_id) references users(id) on delete cascade,
+    constraint fk_account_daily_balances_accounts foreign key (account_id) references accounts(id) on delete cascade
+);
+
+create index if not exists ix_account_daily_balances_user_day on account_daily_balances (user_id, day);
+
+-- Audit log: one row per write to an entry or account, never updated or deleted.
+-- No foreign keys so the trail outlives the rows it describes.
+create table if not exists audit_log (
+    seq bigserial primary key,
+    id uuid not null unique,
+    user_id uuid not null,
+    actor text not null default '',
+    request_id text not null default '',
+    operation text not null,
+    entity text not null check (entity in ('entry','account')),
+    entity_id uuid not null,
+    before jsonb,
+    after jsonb,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists ix_audit_log_user_entity on audit_log (user_id, entity, entity_id, seq);
+create index if not exists ix_audit_log_user_actor on audit_log (user_id, actor, seq);
+
+-- Transactional outbox: ledger events written by the same transaction as the
+-- change they describe, fanned out to webhook deliveries by the dispatcher.
+create table if not exists outbox_events (
+    seq bigserial primary key,
+    id uuid not null unique,
+    user_id uuid not null references users(id) on delete cascade,
+    type text not null check (type in ('entry.created','entry.reversed','account.updated','account.deactivated')),
+    entity_id uuid not null,
+    payload jsonb not null,
+    created_at timestamptz not null default now(),
+    dispatched_at timestamptz
+);
+
+create index if not exists ix_outbox_events_undispatched on outbox_events (seq) where dispatched_at is null;
+
+create table if not exists webhook_endpoints (
+    id uuid primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    url text not null,
+    event_types text[] not null,
+    secret text not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists ix_webhook_endpoints_user on webhook_endpoints (user_id, created_at);
+
+-- Deliveries outlive their endpoint so a deleted endpoint's backlog is kept as dead.
+create table if not exists webhook_deliveries (
+    id uuid primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    event_id uuid not null references outbox_events(id) on delete cascade,
+    event_type text not null,
+    endpoint_id uuid not null,
+    status text not null check (status in ('pending','delivered','dead')),
+    attempts int not null default 0,
+    next_attempt_at timestamptz not null,
+    last_error text not null default '',
+    last_status_code int not null default 0,
+    delivered_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists ix_webhook_deliveries_due on webhook_deliveries (next_attempt_at) where status = 'pending';
+create index if not exists ix_webhook_deliveries_user on webhook_deliveries (user_id, created_at desc);
+
 -- Updated_at triggers to keep timestamps fresh on UPDATE
 create or replace function set_updated_at()
 returns trigger as $$
@@ -108,3 +382,111 @@ do $$ begin
     for each row execute procedure set_updated_at();
   end if;
 end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_periods_set_updated_at'
+  ) then
+    create trigger trg_periods_set_updated_at
+    before update on periods
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_fx_rates_set_updated_at'
+  ) then
+    create trigger trg_fx_rates_set_updated_at
+    before update on fx_rates
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_budgets_set_updated_at'
+  ) then
+    create trigger trg_budgets_set_updated_at
+    before update on budgets
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_schedules_set_updated_at'
+  ) then
+    create trigger trg_schedules_set_updated_at
+    before update on schedules
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_rules_set_updated_at'
+  ) then
+    create trigger trg_rules_set_updated_at
+    before update on rules
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_import_profiles_set_updated_at'
+  ) then
+    create trigger trg_import_profiles_set_updated_at
+    before update on import_profiles
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_reconciliations_set_updated_at'
+  ) then
+    create trigger trg_reconciliations_set_updated_at
+    before update on reconciliations
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_reconciliation_lines_set_updated_at'
+  ) then
+    create trigger trg_reconciliation_lines_set_updated_at
+    before update on reconciliation_lines
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_balance_assertions_set_updated_at'
+  ) then
+    create trigger trg_balance_assertions_set_updated_at
+    before update on balance_assertions
+    for each row execute procedure set_updated_at();
+  end if;
+end $$;
+
+-- Keep the audit log append-only.
+create or replace function audit_log_append_only()
+returns trigger as $$
+begin
+  raise exception 'audit_log is append-only';
+end;
+$$ language plpgsql;
+
+do $$ begin
+  if not exists (
+    select 1 from pg_trigger where tgname = 'trg_audit_log_append_only'
+  ) then
+    create trigger trg_audit_log_append_only
+    before update or delete on audit_log
+    for each row execute procedure audit_log_append_only();
+  end if;
+end $$;
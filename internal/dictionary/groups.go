@@ -11,6 +11,8 @@ type GroupDef struct {
 var curated = map[ledger.AccountType][]GroupDef{
 	ledger.AccountTypeEquity: {
 		{Code: "opening_balances", Label: "Opening Balances", Reserved: true},
+		{Code: "retained_earnings", Label: "Retained Earnings", Reserved: true},
+		{Code: "suspense", Label: "Suspense", Reserved: true},
 		{Code: "owner_equity", Label: "Owner Equity", Reserved: false},
 	},
 	ledger.AccountTypeAsset: {
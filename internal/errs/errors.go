@@ -21,4 +21,12 @@ var (
 	ErrUnbalancedEntry = errors.New("unbalanced_entry")
 	// ErrAlreadyReversed indicates an entry has already been reversed.
 	ErrAlreadyReversed = errors.New("already_reversed")
+	// ErrOverReversal indicates a reversal larger than the unreversed part of a line.
+	ErrOverReversal = errors.New("reversal exceeds the unreversed amount")
+	// ErrPeriodClosed indicates the entry date falls inside a closed or locked period.
+	ErrPeriodClosed = errors.New("period_closed")
+	// ErrReconciled indicates the entry has lines in a finished bank reconciliation.
+	ErrReconciled = errors.New("reconciled")
+	// ErrAssertionFailed indicates a write would break a passing balance assertion.
+	ErrAssertionFailed = errors.New("balance assertion failed")
 )
@@ -37,7 +37,7 @@ func (s *Server) postAccount(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, http.StatusInternalServerError, "could not create account", "")
 		return
 	}
-	resp := accountResponse{ID: createdAccount.ID, UserID: createdAccount.UserID, Name: createdAccount.Name, Currency: createdAccount.Currency, Type: createdAccount.Type, Group: createdAccount.Group, Vendor: createdAccount.Vendor, Path: createdAccount.Path(), Metadata: createdAccount.Metadata, System: createdAccount.System, Active: createdAccount.Active}
+	resp := toAccountResponse(createdAccount)
 	toJSON(w, http.StatusCreated, resp)
 }
 
@@ -1,12 +1,11 @@
-// Account balance and ledger endpoints. Running balance is computed per page.
+// Account balance and ledger endpoints. The ledger is paged with a keyset
+// cursor and its running balance starts from the store's materialized balance.
 package v1
 
 import (
-	"encoding/base64"
 	"net/http"
 	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"errors"
@@ -14,6 +13,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/govalues/money"
 	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
 )
 
 // GET /accounts/{id}/balance?user_id=&as_of=
@@ -82,7 +83,8 @@ func (s *Server) getAccountLedger(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// ensure account exists and owned by user
-	if _, err := s.accReader.GetAccount(r.Context(), userID, accountID); err != nil {
+	acc, err := s.accReader.GetAccount(r.Context(), userID, accountID)
+	if err != nil {
 		if errors.Is(err, errs.ErrNotFound) {
 			notFound(w)
 		} else {
@@ -115,101 +117,77 @@ func (s *Server) getAccountLedger(w http.ResponseWriter, r *http.Request) {
 			lim = n
 		}
 	}
-	cursor := r.URL.Query().Get("cursor")
-
-	// Build a flat list of lines for the account
-	entries, err := s.entryReader.ListEntries(r.Context(), userID)
-	if err != nil {
-		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load entries"})
-		return
-	}
-	type ledgerRecord struct {
-		date            time.Time
-		entryID, lineID uuid.UUID
-		side            string
-		amountMinor     int64
-	}
-	ledgerRecords := make([]ledgerRecord, 0, 64)
-	var currency string
-	for _, e := range entries {
-		if from != nil && e.Date.Before(*from) {
-			continue
-		}
-		if to != nil && e.Date.After(*to) {
-			continue
-		}
-		for lineID, line := range e.Lines.ByID {
-			if line.AccountID != accountID {
-				continue
-			}
-			amountMinor, _ := line.Amount.MinorUnits()
-			side := string(line.Side)
-			ledgerRecords = append(ledgerRecords, ledgerRecord{date: e.Date, entryID: e.ID, lineID: lineID, side: side, amountMinor: amountMinor})
-			if currency == "" {
-				currency = line.Amount.Curr().Code()
-			}
+	// Keyset paging: the cursor is the last line returned. Its entry's later
+	// lines come first, then the account's entries after it. Every entry has a
+	// line on the account, so lim+1 entries are enough to fill a page and tell
+	// whether another follows.
+	filter := journal.EntryFilter{UserID: userID, From: from, To: to, AccountID: accountID, Limit: lim + 1}
+	records := make([]ledgerRecord, 0, lim+1)
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		date, ids, ok := decodeCursor(raw, 2)
+		if !ok {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid cursor"})
+			return
 		}
-	}
-	sort.Slice(ledgerRecords, func(i, j int) bool {
-		if ledgerRecords[i].date.Equal(ledgerRecords[j].date) {
-			if ledgerRecords[i].entryID == ledgerRecords[j].entryID {
-				return ledgerRecords[i].lineID.String() < ledgerRecords[j].lineID.String()
-			}
-			return ledgerRecords[i].entryID.String() < ledgerRecords[j].entryID.String()
+		filter.After = &journal.EntryKey{Date: date, ID: ids[0]}
+		e, err := s.entryReader.GetEntry(r.Context(), userID, ids[0])
+		if err != nil && !errors.Is(err, errs.ErrNotFound) {
+			toJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load entries"})
+			return
 		}
-		return ledgerRecords[i].date.Before(ledgerRecords[j].date)
-	})
-	// Apply cursor
-	start := 0
-	if cursor != "" {
-		if b, err := base64.StdEncoding.DecodeString(cursor); err == nil {
-			parts := strings.Split(string(b), "|")
-			if len(parts) == 2 {
-				if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
-					cid, _ := uuid.Parse(parts[1])
-					for i := range ledgerRecords {
-						if ledgerRecords[i].date.After(ts) {
-							break
-						}
-						if ledgerRecords[i].date.Equal(ts) && ledgerRecords[i].lineID == cid {
-							start = i + 1
-							break
-						}
-					}
+		if err == nil && e.Date.Equal(date) {
+			for _, record := range accountLedgerRecords(e, accountID) {
+				if record.lineID.String() > ids[1].String() {
+					records = append(records, record)
 				}
 			}
 		}
 	}
-	// Running balance from beginning to current page start
-	balance, err := s.svc.AccountBalance(r.Context(), userID, accountID, to)
+	entries, err := s.entryReader.QueryEntries(r.Context(), filter)
 	if err != nil {
-		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "balance error"})
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load entries"})
 		return
 	}
-	// But we need balance up to just before page start; recompute if needed
-	// Simple approach: recompute from start zero to start index
-	if start > 0 {
-		// Get currency from bal
-		_, _ = balance.MinorUnits()
-		// recompute using ledgerRecords[:start]
-		for _, record := range ledgerRecords[:start] {
-			// Use sign: debit +, credit - (already using minor units)
-			if record.side == "debit" {
-				balance, _ = balance.Add(mustAmount(currency, record.amountMinor))
-			} else {
-				balance, _ = balance.Sub(mustAmount(currency, record.amountMinor))
-			}
+	for _, e := range entries {
+		if len(records) > lim {
+			break
 		}
-	} else {
-		// compute from zero up to start directly
-		balance = mustAmount(currency, 0)
+		records = append(records, accountLedgerRecords(e, accountID)...)
+	}
+	pageRecords := records
+	if len(pageRecords) > lim {
+		pageRecords = pageRecords[:lim]
+	}
+	currency := acc.Currency
+	if len(pageRecords) > 0 {
+		currency = pageRecords[0].currency
 	}
-	// Page results
-	end := start + lim
-	if end > len(ledgerRecords) {
-		end = len(ledgerRecords)
+	// Running balance up to the page start: the materialized balance before the
+	// first line's date plus the lines at that same instant sorting before it.
+	balance := mustAmount(currency, 0)
+	if len(pageRecords) > 0 {
+		first := pageRecords[0]
+		before := first.date.Add(-time.Nanosecond)
+		opening, err := s.svc.AccountBalance(r.Context(), userID, accountID, &before)
+		if err != nil {
+			toJSON(w, http.StatusInternalServerError, errorResponse{Error: "balance error"})
+			return
+		}
+		openingMinor, _ := opening.MinorUnits()
+		balance = mustAmount(currency, openingMinor)
+		same, err := s.entryReader.QueryEntries(r.Context(), journal.EntryFilter{UserID: userID, AccountID: accountID, From: &first.date, To: &first.date})
+		if err != nil {
+			toJSON(w, http.StatusInternalServerError, errorResponse{Error: "balance error"})
+			return
+		}
+		for _, e := range same {
+			for _, record := range accountLedgerRecords(e, accountID) {
+				if record.before(first) {
+					balance = record.apply(balance)
+				}
+			}
+		}
 	}
-	pageRecords := ledgerRecords[start:end]
 
 	// Build response with running balance
 	type item struct {
@@ -231,21 +209,63 @@ func (s *Server) getAccountLedger(w http.ResponseWriter, r *http.Request) {
 	}{UserID: userID, AccountID: accountID, Currency: currency}
 	for _, record := range pageRecords {
 		amt := mustAmount(currency, record.amountMinor)
-		if record.side == "debit" {
-			balance, _ = balance.Add(amt)
-		} else {
-			balance, _ = balance.Sub(amt)
-		}
+		balance = record.apply(balance)
 		runningMinor, _ := balance.MinorUnits()
 		resp.Items = append(resp.Items, item{Date: record.date, EntryID: record.entryID, LineID: record.lineID, Side: record.side, AmountMinor: record.amountMinor, Amount: amt.Decimal().String(), RunningMinor: runningMinor, Running: balance.Decimal().String()})
 	}
-	if end < len(ledgerRecords) {
-		c := base64.StdEncoding.EncodeToString([]byte(pageRecords[len(pageRecords)-1].date.Format(time.RFC3339Nano) + "|" + pageRecords[len(pageRecords)-1].lineID.String()))
+	if len(records) > lim {
+		last := pageRecords[len(pageRecords)-1]
+		c := encodeCursor(last.date, last.entryID, last.lineID)
 		resp.NextCursor = &c
 	}
 	toJSON(w, http.StatusOK, resp)
 }
 
+// ledgerRecord is one line of an account's ledger.
+type ledgerRecord struct {
+	date            time.Time
+	entryID, lineID uuid.UUID
+	side            string
+	amountMinor     int64
+	currency        string
+}
+
+// accountLedgerRecords returns e's lines on accountID in ledger order.
+func accountLedgerRecords(e ledger.JournalEntry, accountID uuid.UUID) []ledgerRecord {
+	out := make([]ledgerRecord, 0, 1)
+	for lineID, line := range e.Lines.ByID {
+		if line.AccountID != accountID {
+			continue
+		}
+		amountMinor, _ := line.Amount.MinorUnits()
+		out = append(out, ledgerRecord{date: e.Date, entryID: e.ID, lineID: lineID, side: string(line.Side), amountMinor: amountMinor, currency: line.Amount.Curr().Code()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].lineID.String() < out[j].lineID.String() })
+	return out
+}
+
+// before reports whether r sorts before o: by date, then entry ID, then line ID.
+func (r ledgerRecord) before(o ledgerRecord) bool {
+	if !r.date.Equal(o.date) {
+		return r.date.Before(o.date)
+	}
+	if r.entryID != o.entryID {
+		return r.entryID.String() < o.entryID.String()
+	}
+	return r.lineID.String() < o.lineID.String()
+}
+
+// apply adds r to balance: debits increase it, credits decrease it.
+func (r ledgerRecord) apply(balance money.Amount) money.Amount {
+	amt := mustAmount(balance.Curr().Code(), r.amountMinor)
+	if r.side == string(ledger.SideDebit) {
+		balance, _ = balance.Add(amt)
+	} else {
+		balance, _ = balance.Sub(amt)
+	}
+	return balance
+}
+
 func mustAmount(curr string, units int64) money.Amount {
 	a, _ := money.NewAmountFromMinorUnits(curr, units)
 	return a
@@ -111,7 +111,8 @@ func (s *Server) postAccountsBatch(w http.ResponseWriter, r *http.Request) {
 			Accounts []accountResponse `json:"accounts"`
 		}{Accounts: make([]accountResponse, 0, len(created))}
 		for _, a := range created {
-			resp.Accounts = append(resp.Accounts, accountResponse{ID: a.ID, UserID: a.UserID, Name: a.Name, Currency: a.Currency, Type: a.Type, Group: a.Group, Vendor: a.Vendor, Path: a.Path(), Metadata: a.Metadata, System: a.System, Active: a.Active})
+			ar := toAccountResponse(a)
+			resp.Accounts = append(resp.Accounts, ar)
 		}
 		toJSON(rw, http.StatusCreated, resp)
 		s.storeBatch(key, h, rw)
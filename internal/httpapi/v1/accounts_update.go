@@ -8,8 +8,10 @@ import (
 	chi "github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
 	"github.com/tinoosan/ledger/internal/meta"
 	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/audit"
 )
 
 // updateAccount handles PATCH /accounts/{id}
@@ -106,7 +108,7 @@ func (s *Server) updateAccount(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
-	resp := accountResponse{ID: acc.ID, UserID: acc.UserID, Name: acc.Name, Currency: acc.Currency, Type: acc.Type, Group: acc.Group, Vendor: acc.Vendor, Path: acc.Path(), Metadata: acc.Metadata, System: acc.System, Active: acc.Active}
+	resp := toAccountResponse(acc)
 	toJSON(w, http.StatusOK, resp)
 }
 
@@ -128,7 +130,8 @@ func (s *Server) deactivateAccount(w http.ResponseWriter, r *http.Request) {
 		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
 		return
 	}
-	if err := s.accountSvc.Deactivate(r.Context(), userID, id); err != nil {
+	ctx := audit.WithOperation(r.Context(), ledger.AuditDeactivate)
+	if err := s.accountSvc.Deactivate(ctx, userID, id); err != nil {
 		if errors.Is(err, errs.ErrSystemAccount) {
 			writeErr(w, http.StatusForbidden, "system_account", "system_account")
 			return
@@ -169,7 +172,8 @@ func (s *Server) reactivateAccount(w http.ResponseWriter, r *http.Request) {
 		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
 		return
 	}
-	acc, err := s.accountSvc.Reactivate(r.Context(), userID, id)
+	ctx := audit.WithOperation(r.Context(), ledger.AuditReactivate)
+	acc, err := s.accountSvc.Reactivate(ctx, userID, id)
 	if err != nil {
 		if errors.Is(err, errs.ErrSystemAccount) {
 			writeErr(w, http.StatusForbidden, "system_account", "system_account")
@@ -190,6 +194,6 @@ func (s *Server) reactivateAccount(w http.ResponseWriter, r *http.Request) {
 		writeErr(w, http.StatusBadRequest, err.Error(), "")
 		return
 	}
-	resp := accountResponse{ID: acc.ID, UserID: acc.UserID, Name: acc.Name, Currency: acc.Currency, Type: acc.Type, Group: acc.Group, Vendor: acc.Vendor, Path: acc.Path(), Metadata: acc.Metadata, System: acc.System, Active: acc.Active}
+	resp := toAccountResponse(acc)
 	toJSON(w, http.StatusOK, resp)
 }
@@ -4,7 +4,15 @@ import "github.com/tinoosan/ledger/internal/storage/memory"
 
 // Compile-time interface assertions for the in-memory Store against HTTP API interfaces.
 var (
-	_ AccountReader    = (*memory.Store)(nil)
-	_ EntryReader      = (*memory.Store)(nil)
-	_ IdempotencyStore = (*memory.Store)(nil)
+	_ AccountReader       = (*memory.Store)(nil)
+	_ EntryReader         = (*memory.Store)(nil)
+	_ IdempotencyStore    = (*memory.Store)(nil)
+	_ periodStore         = (*memory.Store)(nil)
+	_ fxStore             = (*memory.Store)(nil)
+	_ budgetStore         = (*memory.Store)(nil)
+	_ scheduleStore       = (*memory.Store)(nil)
+	_ ruleStore           = (*memory.Store)(nil)
+	_ importStore         = (*memory.Store)(nil)
+	_ reconciliationStore = (*memory.Store)(nil)
+	_ assertionStore      = (*memory.Store)(nil)
 )
@@ -0,0 +1,309 @@
+// Audit log: recording writes to entries and accounts and querying them.
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/audit"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/journalimport"
+)
+
+// actorFrom returns the JWT subject authenticated for the request, if any.
+func actorFrom(ctx context.Context) string {
+	if c, ok := ctx.Value(ctxKeyClaims).(JWTClaims); ok {
+		return c.Subject
+	}
+	return ""
+}
+
+// auditor records writes to entries and accounts. It wraps the writers the
+// services write through, so every path is covered: API handlers, statement
+// and journal imports, scheduled postings, year-end closes, revaluations and
+// reconciliation adjustments alike. Writes that changed nothing, such as
+// linking entries that were already related, are skipped.
+type auditor struct {
+	svc      audit.Service
+	entries  journal.Repo
+	accounts account.Repo
+	// log receives recording failures when the records are appended after the
+	// write has committed; returning them then would only invite a retry of a
+	// write that happened. Without a log they are returned, for transactions
+	// that append the records alongside the writes and can still roll back.
+	log *slog.Logger
+}
+
+func (a auditor) record(ctx context.Context, userID uuid.UUID, op ledger.AuditOperation, entity ledger.AuditEntity, id uuid.UUID, before, after json.RawMessage) error {
+	if before != nil && bytes.Equal(before, after) {
+		return nil
+	}
+	rec := ledger.AuditRecord{
+		UserID:    userID,
+		Actor:     actorFrom(ctx),
+		RequestID: chimw.GetReqID(ctx),
+		Operation: op,
+		Entity:    entity,
+		EntityID:  id,
+		Before:    before,
+		After:     after,
+	}
+	if _, err := a.svc.Record(ctx, rec); err != nil {
+		if a.log == nil {
+			return err
+		}
+		a.log.Error("audit record failed", "req_id", rec.RequestID, "operation", op, "entity", entity, "entity_id", id, "err", err.Error())
+	}
+	return nil
+}
+
+// auditJournalWriter records the entries written through a journal.Writer.
+type auditJournalWriter struct {
+	journal.Writer
+	a auditor
+}
+
+func (w auditJournalWriter) CreateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	created, err := w.Writer.CreateJournalEntry(ctx, e)
+	if err != nil {
+		return created, err
+	}
+	return created, w.a.record(ctx, created.UserID, ledger.AuditCreate, ledger.AuditEntityEntry, created.ID, nil, snapshot(toEntryResponse(created)))
+}
+
+func (w auditJournalWriter) UpdateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	var before json.RawMessage
+	if old, err := w.a.entries.GetEntry(ctx, e.UserID, e.ID); err == nil {
+		before = snapshot(toEntryResponse(old))
+	}
+	updated, err := w.Writer.UpdateJournalEntry(ctx, e)
+	if err != nil {
+		return updated, err
+	}
+	op := audit.OperationFrom(ctx, ledger.AuditUpdate)
+	return updated, w.a.record(ctx, updated.UserID, op, ledger.AuditEntityEntry, updated.ID, before, snapshot(toEntryResponse(updated)))
+}
+
+func (w auditJournalWriter) ReverseJournalEntry(ctx context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	created, err := w.Writer.ReverseJournalEntry(ctx, orig, upd, rev, corr)
+	if err != nil {
+		return created, err
+	}
+	op := audit.OperationFrom(ctx, ledger.AuditReverse)
+	if err := w.a.record(ctx, upd.UserID, op, ledger.AuditEntityEntry, upd.ID, snapshot(toEntryResponse(orig)), snapshot(toEntryResponse(upd))); err != nil {
+		return created, err
+	}
+	if err := w.a.record(ctx, created.UserID, ledger.AuditCreate, ledger.AuditEntityEntry, created.ID, nil, snapshot(toEntryResponse(created))); err != nil || corr == nil {
+		return created, err
+	}
+	return created, w.a.record(ctx, corr.UserID, ledger.AuditCreate, ledger.AuditEntityEntry, corr.ID, nil, snapshot(toEntryResponse(*corr)))
+}
+
+func (w auditJournalWriter) AddEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	return w.relink(ctx, userID, links, w.Writer.AddEntryRelations)
+}
+
+func (w auditJournalWriter) RemoveEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	return w.relink(ctx, userID, links, w.Writer.RemoveEntryRelations)
+}
+
+// relink applies write and records each entry whose links it changed.
+func (w auditJournalWriter) relink(ctx context.Context, userID uuid.UUID, links []journal.EntryLink, write func(context.Context, uuid.UUID, []journal.EntryLink) error) error {
+	before := make(map[uuid.UUID]json.RawMessage, len(links))
+	ids := make([]uuid.UUID, 0, len(links))
+	for _, l := range links {
+		if _, ok := before[l.From]; ok {
+			continue
+		}
+		ids = append(ids, l.From)
+		before[l.From] = nil
+		if old, err := w.a.entries.GetEntry(ctx, userID, l.From); err == nil {
+			before[l.From] = snapshot(toEntryResponse(old))
+		}
+	}
+	if err := write(ctx, userID, links); err != nil {
+		return err
+	}
+	op := audit.OperationFrom(ctx, ledger.AuditUpdate)
+	for _, id := range ids {
+		after, err := w.a.entries.GetEntry(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if err := w.a.record(ctx, userID, op, ledger.AuditEntityEntry, id, before[id], snapshot(toEntryResponse(after))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditAccountWriter records the accounts written through an account.Writer.
+type auditAccountWriter struct {
+	account.Writer
+	a auditor
+}
+
+func (w auditAccountWriter) CreateAccount(ctx context.Context, acc ledger.Account) (ledger.Account, error) {
+	created, err := w.Writer.CreateAccount(ctx, acc)
+	if err != nil {
+		return created, err
+	}
+	return created, w.a.record(ctx, created.UserID, ledger.AuditCreate, ledger.AuditEntityAccount, created.ID, nil, snapshot(toAccountResponse(created)))
+}
+
+func (w auditAccountWriter) UpdateAccount(ctx context.Context, acc ledger.Account) (ledger.Account, error) {
+	var before json.RawMessage
+	if old, err := w.a.accounts.GetAccount(ctx, acc.UserID, acc.ID); err == nil {
+		before = snapshot(toAccountResponse(old))
+	}
+	updated, err := w.Writer.UpdateAccount(ctx, acc)
+	if err != nil {
+		return updated, err
+	}
+	op := audit.OperationFrom(ctx, ledger.AuditUpdate)
+	return updated, w.a.record(ctx, updated.UserID, op, ledger.AuditEntityAccount, updated.ID, before, snapshot(toAccountResponse(updated)))
+}
+
+// auditedTx records the writes of a batch transaction in the transaction
+// itself, so they commit or roll back together.
+type auditedTx struct {
+	journalimport.Tx
+	entries  auditJournalWriter
+	accounts auditAccountWriter
+}
+
+// auditTx wraps tx when auditing is on and tx can append audit records.
+func (s *Server) auditTx(tx journalimport.Tx) journalimport.Tx {
+	as, ok := tx.(auditStore)
+	if s.auditSvc == nil || !ok {
+		return tx
+	}
+	a := auditor{svc: audit.New(as, as), entries: tx, accounts: tx}
+	return auditedTx{Tx: tx, entries: auditJournalWriter{Writer: tx, a: a}, accounts: auditAccountWriter{Writer: tx, a: a}}
+}
+
+func (t auditedTx) CreateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	return t.entries.CreateJournalEntry(ctx, e)
+}
+
+func (t auditedTx) UpdateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	return t.entries.UpdateJournalEntry(ctx, e)
+}
+
+func (t auditedTx) ReverseJournalEntry(ctx context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	return t.entries.ReverseJournalEntry(ctx, orig, upd, rev, corr)
+}
+
+func (t auditedTx) AddEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	return t.entries.AddEntryRelations(ctx, userID, links)
+}
+
+func (t auditedTx) RemoveEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	return t.entries.RemoveEntryRelations(ctx, userID, links)
+}
+
+func (t auditedTx) CreateAccount(ctx context.Context, a ledger.Account) (ledger.Account, error) {
+	return t.accounts.CreateAccount(ctx, a)
+}
+
+func (t auditedTx) UpdateAccount(ctx context.Context, a ledger.Account) (ledger.Account, error) {
+	return t.accounts.UpdateAccount(ctx, a)
+}
+
+// snapshot renders an API response as the JSON kept in audit records.
+func snapshot(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// GET /v1/audit?user_id=&entity=&entity_id=&actor=&from=&to=&limit=&cursor=
+func (s *Server) listAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	f := audit.Filter{UserID: userID, Entity: ledger.AuditEntity(q.Get("entity")), Actor: q.Get("actor"), Limit: 50}
+	if f.Entity != "" && f.Entity != ledger.AuditEntityEntry && f.Entity != ledger.AuditEntityAccount {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "entity must be entry or account"})
+		return
+	}
+	if raw := q.Get("entity_id"); raw != "" {
+		if f.EntityID, err = uuid.Parse(raw); err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid entity_id"})
+			return
+		}
+	}
+	if raw := q.Get("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from"})
+			return
+		}
+		t = t.UTC()
+		f.From = &t
+	}
+	if raw := q.Get("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to"})
+			return
+		}
+		t = t.UTC()
+		f.To = &t
+	}
+	if raw := q.Get("limit"); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n > 0 && n <= 200 {
+			f.Limit = n
+		}
+	}
+	if raw := q.Get("cursor"); raw != "" {
+		if f.AfterSeq, err = strconv.ParseInt(raw, 10, 64); err != nil || f.AfterSeq < 0 {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid cursor"})
+			return
+		}
+	}
+	// Fetch one extra record to learn whether another page follows.
+	limit := f.Limit
+	f.Limit++
+	recs, err := s.auditSvc.List(r.Context(), f)
+	if err != nil {
+		writeErr(w, http.StatusInternalServerError, "failed to load audit records", "")
+		return
+	}
+	resp := listAuditResponse{Items: make([]auditRecordResponse, 0, len(recs))}
+	if len(recs) > limit {
+		recs = recs[:limit]
+		c := strconv.FormatInt(recs[limit-1].Seq, 10)
+		resp.NextCursor = &c
+	}
+	for _, rec := range recs {
+		resp.Items = append(resp.Items, auditRecordResponse{
+			Seq:       rec.Seq,
+			ID:        rec.ID,
+			UserID:    rec.UserID,
+			Actor:     rec.Actor,
+			RequestID: rec.RequestID,
+			Operation: rec.Operation,
+			Entity:    rec.Entity,
+			EntityID:  rec.EntityID,
+			Before:    rec.Before,
+			After:     rec.After,
+			CreatedAt: rec.CreatedAt,
+		})
+	}
+	toJSON(w, http.StatusOK, resp)
+}
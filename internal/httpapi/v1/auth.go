@@ -28,6 +28,30 @@ type JWTClaims struct {
 	ExpiresAt int64  `json:"exp,omitempty"`
 	NotBefore int64  `json:"nbf,omitempty"`
 	IssuedAt  int64  `json:"iat,omitempty"`
+	// Roles grants access to admin endpoints ("admin").
+	Roles []string `json:"roles,omitempty"`
+}
+
+// hasRole reports whether the claims grant role.
+func (c JWTClaims) hasRole(role string) bool {
+	for _, r := range c.Roles {
+		if strings.EqualFold(r, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin rejects authenticated requests whose token lacks the admin
+// role with 403. With auth off there are no claims and every request passes.
+func requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := r.Context().Value(ctxKeyClaims).(JWTClaims); ok && !c.hasRole("admin") {
+			forbidden(w, "admin role required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func parseBearerToken(r *http.Request) (string, bool) {
@@ -337,7 +361,7 @@ func authJWTFromEnv() func(http.Handler) http.Handler {
 			}
 			// Successful auth at debug for traceability
 			logger.Debug("auth ok", "req_id", reqID, "path", r.URL.Path, "method", r.Method)
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKeyClaims, claims)))
 		})
 	}
 }
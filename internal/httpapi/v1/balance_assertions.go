@@ -0,0 +1,182 @@
+// Balance assertion handlers: define, list with pass/fail state, fetch and delete.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/fx"
+)
+
+// postAssertion handles POST /v1/balance-assertions
+func (s *Server) postAssertion(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postAssertionRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if req.AccountID == uuid.Nil {
+		badRequest(w, "account_id is required")
+		return
+	}
+	date, err := fx.ParseDate(req.Date)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	accs, err := s.accReader.FetchAccounts(r.Context(), req.UserID, []uuid.UUID{req.AccountID})
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch accounts"})
+		return
+	}
+	acc, ok := accs[req.AccountID]
+	if !ok {
+		notFound(w)
+		return
+	}
+	amt, err := money.NewAmountFromMinorUnits(acc.Currency, req.AmountMinor)
+	if err != nil {
+		badRequest(w, "invalid amount_minor")
+		return
+	}
+	res, err := s.assertionSvc.Create(r.Context(), ledger.BalanceAssertion{UserID: req.UserID, AccountID: req.AccountID, Date: date, Amount: amt, Note: req.Note})
+	if err != nil {
+		writeAssertionErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusCreated, toAssertionResponse(res))
+}
+
+// listAssertions handles GET /v1/balance-assertions?user_id=[&account_id=][&failing=true]
+func (s *Server) listAssertions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	var accountID *uuid.UUID
+	if raw := q.Get("account_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid account_id"})
+			return
+		}
+		accountID = &id
+	}
+	onlyFailing := false
+	switch q.Get("failing") {
+	case "", "false":
+	case "true":
+		onlyFailing = true
+	default:
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid failing; expected true or false"})
+		return
+	}
+	results, err := s.assertionSvc.List(r.Context(), userID, accountID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not evaluate balance assertions"})
+		return
+	}
+	out := assertionListResponse{UserID: userID, Items: make([]assertionResponse, 0, len(results))}
+	for _, res := range results {
+		out.Total++
+		if !res.Pass {
+			out.Failing++
+		} else if onlyFailing {
+			continue
+		}
+		out.Items = append(out.Items, toAssertionResponse(res))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getAssertion handles GET /v1/balance-assertions/{id}?user_id=
+func (s *Server) getAssertion(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := assertionParams(w, r)
+	if !ok {
+		return
+	}
+	res, err := s.assertionSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeAssertionErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toAssertionResponse(res))
+}
+
+// deleteAssertion handles DELETE /v1/balance-assertions/{id}?user_id=
+func (s *Server) deleteAssertion(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := assertionParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.assertionSvc.Delete(r.Context(), userID, id); err != nil {
+		writeAssertionErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func assertionParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid assertion id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeAssertionErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	case errors.Is(err, errs.ErrMixedCurrency):
+		unprocessable(w, "assertion currency must match the account currency", "currency_mismatch")
+	case errors.Is(err, assertion.ErrDuplicate):
+		writeErr(w, http.StatusConflict, err.Error(), "assertion_exists")
+	default:
+		writeErr(w, http.StatusInternalServerError, "could not save balance assertion", "")
+	}
+}
+
+func toAssertionResponse(res assertion.Result) assertionResponse {
+	a := res.Assertion
+	expected, _ := a.Amount.MinorUnits()
+	actual, _ := res.Actual.MinorUnits()
+	return assertionResponse{
+		ID:              a.ID,
+		UserID:          a.UserID,
+		AccountID:       a.AccountID,
+		Date:            a.Date.Format("2006-01-02"),
+		Currency:        a.Amount.Curr().Code(),
+		AmountMinor:     expected,
+		Note:            a.Note,
+		ActualMinor:     actual,
+		DifferenceMinor: actual - expected,
+		Passing:         res.Pass,
+	}
+}
@@ -0,0 +1,232 @@
+// Budget handlers: CRUD and the budget-vs-actual report.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/budget"
+)
+
+// postBudget handles POST /v1/budgets
+func (s *Server) postBudget(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postBudgetRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	path, curr := req.Path, strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.AccountID != nil {
+		if path != "" {
+			badRequest(w, "provide either path or account_id")
+			return
+		}
+		acc, err := s.accReader.GetAccount(r.Context(), req.UserID, *req.AccountID)
+		if err != nil {
+			if errors.Is(err, errs.ErrNotFound) {
+				notFound(w)
+				return
+			}
+			toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch account"})
+			return
+		}
+		if curr != "" && curr != acc.Currency {
+			badRequest(w, "currency does not match account")
+			return
+		}
+		path, curr = acc.Path(), acc.Currency
+	}
+	if path == "" {
+		badRequest(w, "path or account_id is required")
+		return
+	}
+	amt, err := money.NewAmountFromMinorUnits(curr, req.AmountMinor)
+	if err != nil {
+		badRequest(w, "invalid currency")
+		return
+	}
+	b, err := s.budgetSvc.Create(r.Context(), ledger.Budget{UserID: req.UserID, Path: path, Period: req.Period, Start: req.Start, Amount: amt})
+	if err != nil {
+		if errors.Is(err, budget.ErrDuplicate) {
+			writeErr(w, http.StatusConflict, err.Error(), "budget_exists")
+			return
+		}
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		badRequest(w, err.Error())
+		return
+	}
+	toJSON(w, http.StatusCreated, toBudgetResponse(b))
+}
+
+// listBudgets handles GET /v1/budgets?user_id=
+func (s *Server) listBudgets(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	budgets, err := s.budgetSvc.List(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch budgets"})
+		return
+	}
+	out := make([]budgetResponse, 0, len(budgets))
+	for _, b := range budgets {
+		out = append(out, toBudgetResponse(b))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getBudget handles GET /v1/budgets/{id}?user_id=
+func (s *Server) getBudget(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := budgetParams(w, r)
+	if !ok {
+		return
+	}
+	b, err := s.budgetSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeBudgetErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toBudgetResponse(b))
+}
+
+// updateBudget handles PATCH /v1/budgets/{id}?user_id=
+func (s *Server) updateBudget(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	userID, id, ok := budgetParams(w, r)
+	if !ok {
+		return
+	}
+	var req patchBudgetRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.AmountMinor == nil {
+		badRequest(w, "amount_minor is required")
+		return
+	}
+	b, err := s.budgetSvc.UpdateAmount(r.Context(), userID, id, *req.AmountMinor)
+	if err != nil {
+		writeBudgetErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toBudgetResponse(b))
+}
+
+// deleteBudget handles DELETE /v1/budgets/{id}?user_id=
+func (s *Server) deleteBudget(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := budgetParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.budgetSvc.Delete(r.Context(), userID, id); err != nil {
+		writeBudgetErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBudgetVsActual handles GET /v1/reports/budget-vs-actual?user_id=&as_of=
+func (s *Server) getBudgetVsActual(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	asOf := time.Now().UTC()
+	if raw := q.Get("as_of"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid as_of"})
+			return
+		}
+		asOf = t.UTC()
+	}
+	lines, err := s.budgetSvc.VsActual(r.Context(), userID, asOf)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not build budget report"})
+		return
+	}
+	resp := budgetVsActualResponse{UserID: userID, AsOf: asOf, Budgets: make([]budgetVsActualLine, 0, len(lines))}
+	for _, l := range lines {
+		curr := l.Budget.Amount.Curr().Code()
+		resp.Budgets = append(resp.Budgets, budgetVsActualLine{
+			budgetResponse: toBudgetResponse(l.Budget),
+			ActualMinor:    l.Actual,
+			Actual:         formatMinor(curr, l.Actual),
+			RemainingMinor: l.Remaining,
+			Remaining:      formatMinor(curr, l.Remaining),
+			PercentUsed:    l.PercentUsed,
+			OverBudget:     l.OverBudget,
+		})
+	}
+	toJSON(w, http.StatusOK, resp)
+}
+
+func budgetParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid budget id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeBudgetErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	default:
+		badRequest(w, err.Error())
+	}
+}
+
+func toBudgetResponse(b ledger.Budget) budgetResponse {
+	minor, _ := b.Amount.MinorUnits()
+	curr := b.Amount.Curr().Code()
+	return budgetResponse{
+		ID:          b.ID,
+		UserID:      b.UserID,
+		Path:        b.Path,
+		Period:      b.Period,
+		Start:       b.Start,
+		End:         b.End(),
+		Currency:    curr,
+		AmountMinor: minor,
+		Amount:      formatMinor(curr, minor),
+	}
+}
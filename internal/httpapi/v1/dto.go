@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -22,6 +23,10 @@ type postEntryLine struct {
 	AccountID   uuid.UUID   `json:"account_id"`
 	Side        ledger.Side `json:"side"`
 	AmountMinor int64       `json:"amount_minor"`
+	// Currency of the line (account currency); defaults to the entry currency.
+	Currency string `json:"currency,omitempty"`
+	// ExchangeRate converts the line currency into the entry currency (decimal string).
+	ExchangeRate string `json:"exchange_rate,omitempty"`
 }
 
 type entryResponse struct {
@@ -32,16 +37,36 @@ type entryResponse struct {
 	Memo       string          `json:"memo"`
 	Category   ledger.Category `json:"category"`
 	Metadata   meta.Metadata   `json:"metadata,omitempty"`
-	IsReversed bool            `json:"is_reversed"`
-	Lines      []lineResponse  `json:"lines"`
+	IsReversed bool            `json:"is_reversed"` // true once RemainingMinor is zero, not after a partial reversal
+	// ReversedMinor and RemainingMinor split the entry total (its debits, in
+	// the entry currency) into the part reversed so far and the rest.
+	ReversedMinor  int64          `json:"reversed_minor"`
+	RemainingMinor int64          `json:"remaining_minor"`
+	Lines          []lineResponse `json:"lines"`
+	// Relations link the entry to its reversals, reclassifications and related entries.
+	Relations []entryRelationResponse `json:"relations"`
+}
+
+type entryRelationResponse struct {
+	Kind    ledger.RelationKind `json:"kind"`
+	EntryID uuid.UUID           `json:"entry_id"`
 }
 
 type lineResponse struct {
-	ID          uuid.UUID   `json:"id"`
-	AccountID   uuid.UUID   `json:"account_id"`
-	Side        ledger.Side `json:"side"`
-	AmountMinor int64       `json:"amount_minor"`
-	Amount      string      `json:"amount"`
+	ID           uuid.UUID   `json:"id"`
+	AccountID    uuid.UUID   `json:"account_id"`
+	Side         ledger.Side `json:"side"`
+	AmountMinor  int64       `json:"amount_minor"`
+	Amount       string      `json:"amount"`
+	Currency     string      `json:"currency"`
+	ExchangeRate *string     `json:"exchange_rate,omitempty"`
+	// EntryAmountMinor is the line amount converted into the entry currency.
+	EntryAmountMinor int64 `json:"entry_amount_minor"`
+	// Status is the bank clearing state: uncleared, cleared or reconciled.
+	Status ledger.LineStatus `json:"status"`
+	// ReversedMinor and RemainingMinor split the line amount, in the line currency.
+	ReversedMinor  int64 `json:"reversed_minor"`
+	RemainingMinor int64 `json:"remaining_minor"`
 }
 
 // listEntriesQuery holds validated query params for GET /entries.
@@ -50,7 +75,12 @@ type listEntriesQuery struct {
 	Currency   string
 	Memo       string
 	Category   string
+	AccountID  uuid.UUID
 	IsReversed *bool
+	// Relation keeps entries with a relation of this kind; RelatedTo keeps
+	// entries linked to that entry. Both set means one relation must match both.
+	Relation  ledger.RelationKind
+	RelatedTo uuid.UUID
 }
 
 // listEntriesResponse wraps entries with cursor for pagination.
@@ -65,12 +95,24 @@ type reverseEntryRequest struct {
 	EntryID uuid.UUID `json:"entry_id"`
 	// optional date; if omitted handler sets time.Now()
 	Date *time.Time `json:"date,omitempty"`
+	// Lines reverses only the listed lines, by the given amounts.
+	Lines []reverseLineRequest `json:"lines,omitempty"`
+	// AmountMinor reverses this much of the entry, spread over its lines in proportion.
+	AmountMinor *int64 `json:"amount_minor,omitempty"`
+}
+
+type reverseLineRequest struct {
+	LineID uuid.UUID `json:"line_id"`
+	// AmountMinor is in the line currency.
+	AmountMinor int64 `json:"amount_minor"`
 }
 
 // Trial balance
 type trialBalanceQuery struct {
 	UserID uuid.UUID
 	AsOf   *time.Time
+	// ReportingCurrency, when set, adds a consolidated view translated at as_of rates.
+	ReportingCurrency string
 }
 
 type trialBalanceAccount struct {
@@ -91,9 +133,41 @@ type trialBalanceCurrencyGroup struct {
 }
 
 type trialBalanceResponse struct {
-	UserID uuid.UUID                   `json:"user_id"`
-	AsOf   *time.Time                  `json:"as_of,omitempty"`
-	Groups []trialBalanceCurrencyGroup `json:"groups"`
+	UserID            uuid.UUID                   `json:"user_id"`
+	AsOf              *time.Time                  `json:"as_of,omitempty"`
+	ReportingCurrency string                      `json:"reporting_currency,omitempty"`
+	Groups            []trialBalanceCurrencyGroup `json:"groups"`
+	Consolidated      *consolidatedTrialBalance   `json:"consolidated,omitempty"`
+}
+
+type consolidatedTrialBalanceAccount struct {
+	AccountID            uuid.UUID          `json:"account_id"`
+	Name                 string             `json:"name"`
+	Path                 string             `json:"path"`
+	Type                 ledger.AccountType `json:"type"`
+	Currency             string             `json:"currency"`
+	Rate                 string             `json:"rate"`
+	DebitMinor           int64              `json:"debit_minor"`
+	CreditMinor          int64              `json:"credit_minor"`
+	ReportingDebitMinor  int64              `json:"reporting_debit_minor"`
+	ReportingCreditMinor int64              `json:"reporting_credit_minor"`
+}
+
+type translationDifference struct {
+	Currency string `json:"currency"`
+	Rate     string `json:"rate"`
+	// NetMinor is the currency's own-currency net (debits - credits).
+	NetMinor    int64 `json:"net_minor"`
+	DebitMinor  int64 `json:"debit_minor"`
+	CreditMinor int64 `json:"credit_minor"`
+}
+
+type consolidatedTrialBalance struct {
+	Currency               string                            `json:"currency"`
+	Accounts               []consolidatedTrialBalanceAccount `json:"accounts"`
+	TranslationDifferences []translationDifference           `json:"translation_differences"`
+	TotalDebitMinor        int64                             `json:"total_debit_minor"`
+	TotalCreditMinor       int64                             `json:"total_credit_minor"`
 }
 
 // Accounts
@@ -123,6 +197,11 @@ type accountResponse struct {
 	Active   bool               `json:"active"`
 }
 
+// toAccountResponse renders an account for the API.
+func toAccountResponse(a ledger.Account) accountResponse {
+	return accountResponse{ID: a.ID, UserID: a.UserID, Name: a.Name, Currency: a.Currency, Type: a.Type, Group: a.Group, Vendor: a.Vendor, Path: a.Path(), Metadata: a.Metadata, System: a.System, Active: a.Active}
+}
+
 type listAccountsQuery struct {
 	UserID   uuid.UUID
 	Name     string
@@ -133,3 +212,619 @@ type listAccountsQuery struct {
 	System   *bool
 	Active   *bool
 }
+
+// Periods
+
+type postPeriodRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+type periodResponse struct {
+	ID       uuid.UUID           `json:"id"`
+	UserID   uuid.UUID           `json:"user_id"`
+	Name     string              `json:"name"`
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+	Status   ledger.PeriodStatus `json:"status"`
+	ClosedAt *time.Time          `json:"closed_at,omitempty"`
+}
+
+// Year-end close
+
+type yearEndCloseResponse struct {
+	UserID  uuid.UUID       `json:"user_id"`
+	Year    int             `json:"year"`
+	YearEnd time.Time       `json:"year_end"`
+	Created bool            `json:"created"`
+	Entries []entryResponse `json:"entries"`
+}
+
+// Exchange rates and revaluation
+
+type fxRateItem struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	// Date is the effective day (YYYY-MM-DD).
+	Date string `json:"date"`
+	Rate string `json:"rate"`
+}
+
+type postFXRatesRequest struct {
+	UserID uuid.UUID    `json:"user_id"`
+	Rates  []fxRateItem `json:"rates"`
+}
+
+type fxRateResponse struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"user_id"`
+	fxRateItem
+}
+
+type postRevaluationRequest struct {
+	UserID            uuid.UUID `json:"user_id"`
+	AsOf              time.Time `json:"as_of"`
+	ReportingCurrency string    `json:"reporting_currency"`
+	GainLossAccountID uuid.UUID `json:"gain_loss_account_id"`
+}
+
+type revaluationAdjustment struct {
+	AccountID          uuid.UUID `json:"account_id"`
+	CompanionAccountID uuid.UUID `json:"companion_account_id,omitempty"`
+	Currency           string    `json:"currency"`
+	Rate               string    `json:"rate"`
+	BalanceMinor       int64     `json:"balance_minor"`
+	ValueMinor         int64     `json:"value_minor"`
+	BookedMinor        int64     `json:"booked_minor"`
+	CarriedMinor       int64     `json:"carried_minor"`
+	AdjustmentMinor    int64     `json:"adjustment_minor"`
+}
+
+type revaluationMissingRate struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Currency  string    `json:"currency"`
+	Date      time.Time `json:"date"`
+}
+
+type revaluationResponse struct {
+	UserID            uuid.UUID                `json:"user_id"`
+	AsOf              time.Time                `json:"as_of"`
+	ReportingCurrency string                   `json:"reporting_currency"`
+	Adjustments       []revaluationAdjustment  `json:"adjustments"`
+	MissingRates      []revaluationMissingRate `json:"missing_rates"`
+	Entry             *entryResponse           `json:"entry,omitempty"`
+}
+
+type reverseRevaluationRequest struct {
+	UserID            uuid.UUID  `json:"user_id"`
+	AsOf              time.Time  `json:"as_of"`
+	ReportingCurrency string     `json:"reporting_currency"`
+	Date              *time.Time `json:"date,omitempty"`
+}
+
+// Financial statements
+
+type statementLineResponse struct {
+	AccountID    *uuid.UUID `json:"account_id,omitempty"`
+	Name         string     `json:"name"`
+	Path         string     `json:"path,omitempty"`
+	Vendor       string     `json:"vendor,omitempty"`
+	AmountMinor  int64      `json:"amount_minor"`
+	Amount       string     `json:"amount"`
+	ColumnsMinor []int64    `json:"columns_minor,omitempty"`
+	Computed     bool       `json:"computed,omitempty"`
+}
+
+type statementGroupResponse struct {
+	Group         string                  `json:"group"`
+	Lines         []statementLineResponse `json:"lines"`
+	SubtotalMinor int64                   `json:"subtotal_minor"`
+	Subtotal      string                  `json:"subtotal"`
+	ColumnsMinor  []int64                 `json:"columns_minor,omitempty"`
+}
+
+type statementSectionResponse struct {
+	Type         ledger.AccountType       `json:"type"`
+	Groups       []statementGroupResponse `json:"groups"`
+	TotalMinor   int64                    `json:"total_minor"`
+	Total        string                   `json:"total"`
+	ColumnsMinor []int64                  `json:"columns_minor,omitempty"`
+}
+
+type balanceSheetSectionResponse struct {
+	Currency                       string                   `json:"currency"`
+	Assets                         statementSectionResponse `json:"assets"`
+	Liabilities                    statementSectionResponse `json:"liabilities"`
+	Equity                         statementSectionResponse `json:"equity"`
+	TotalLiabilitiesAndEquityMinor int64                    `json:"total_liabilities_and_equity_minor"`
+	TotalLiabilitiesAndEquity      string                   `json:"total_liabilities_and_equity"`
+}
+
+type balanceSheetResponse struct {
+	UserID   uuid.UUID                     `json:"user_id"`
+	AsOf     time.Time                     `json:"as_of"`
+	Sections []balanceSheetSectionResponse `json:"sections"`
+}
+
+type statementColumnResponse struct {
+	Label string    `json:"label"`
+	Start time.Time `json:"start"`
+	// End is exclusive.
+	End time.Time `json:"end"`
+}
+
+type incomeStatementSectionResponse struct {
+	Currency              string                   `json:"currency"`
+	Revenue               statementSectionResponse `json:"revenue"`
+	Expenses              statementSectionResponse `json:"expenses"`
+	NetIncomeMinor        int64                    `json:"net_income_minor"`
+	NetIncome             string                   `json:"net_income"`
+	NetIncomeColumnsMinor []int64                  `json:"net_income_columns_minor,omitempty"`
+}
+
+type incomeStatementResponse struct {
+	UserID   uuid.UUID                        `json:"user_id"`
+	From     time.Time                        `json:"from"`
+	To       time.Time                        `json:"to"`
+	Columns  []statementColumnResponse        `json:"columns"`
+	Sections []incomeStatementSectionResponse `json:"sections"`
+}
+
+type cashFlowLineResponse struct {
+	AccountID   *uuid.UUID `json:"account_id,omitempty"`
+	Name        string     `json:"name"`
+	Path        string     `json:"path,omitempty"`
+	AmountMinor int64      `json:"amount_minor"`
+	Amount      string     `json:"amount"`
+}
+
+type cashFlowActivityResponse struct {
+	Activity   string                 `json:"activity"`
+	Lines      []cashFlowLineResponse `json:"lines"`
+	TotalMinor int64                  `json:"total_minor"`
+	Total      string                 `json:"total"`
+}
+
+type cashFlowSectionResponse struct {
+	Currency       string                     `json:"currency"`
+	OpeningMinor   int64                      `json:"opening_minor"`
+	Activities     []cashFlowActivityResponse `json:"activities"`
+	NetChangeMinor int64                      `json:"net_change_minor"`
+	ClosingMinor   int64                      `json:"closing_minor"`
+	Opening        string                     `json:"opening"`
+	NetChange      string                     `json:"net_change"`
+	Closing        string                     `json:"closing"`
+}
+
+type cashFlowResponse struct {
+	UserID     uuid.UUID                 `json:"user_id"`
+	From       time.Time                 `json:"from"`
+	To         time.Time                 `json:"to"`
+	CashGroups []string                  `json:"cash_groups"`
+	Sections   []cashFlowSectionResponse `json:"sections"`
+}
+
+// Budgets
+
+type postBudgetRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Path is an expense/revenue path or prefix (expense:groceries). Alternatively
+	// AccountID selects a single account; its path and currency are used.
+	Path        string              `json:"path,omitempty"`
+	AccountID   *uuid.UUID          `json:"account_id,omitempty"`
+	Period      ledger.BudgetPeriod `json:"period"`
+	Start       time.Time           `json:"start"`
+	Currency    string              `json:"currency,omitempty"`
+	AmountMinor int64               `json:"amount_minor"`
+}
+
+type patchBudgetRequest struct {
+	AmountMinor *int64 `json:"amount_minor"`
+}
+
+type budgetResponse struct {
+	ID          uuid.UUID           `json:"id"`
+	UserID      uuid.UUID           `json:"user_id"`
+	Path        string              `json:"path"`
+	Period      ledger.BudgetPeriod `json:"period"`
+	Start       time.Time           `json:"start"`
+	End         time.Time           `json:"end"`
+	Currency    string              `json:"currency"`
+	AmountMinor int64               `json:"amount_minor"`
+	Amount      string              `json:"amount"`
+}
+
+type budgetVsActualLine struct {
+	budgetResponse
+	ActualMinor    int64   `json:"actual_minor"`
+	Actual         string  `json:"actual"`
+	RemainingMinor int64   `json:"remaining_minor"`
+	Remaining      string  `json:"remaining"`
+	PercentUsed    float64 `json:"percent_used"`
+	OverBudget     bool    `json:"over_budget"`
+}
+
+type budgetVsActualResponse struct {
+	UserID  uuid.UUID            `json:"user_id"`
+	AsOf    time.Time            `json:"as_of"`
+	Budgets []budgetVsActualLine `json:"budgets"`
+}
+
+// Schedules
+
+type postScheduleRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	Name   string    `json:"name"`
+	// Rule is RRULE-like, e.g. FREQ=MONTHLY;BYMONTHDAY=1 or FREQ=WEEKLY;BYDAY=FR.
+	Rule     string            `json:"rule"`
+	Start    time.Time         `json:"start"`
+	Until    *time.Time        `json:"until,omitempty"`
+	Currency string            `json:"currency"`
+	Memo     string            `json:"memo"`
+	Category ledger.Category   `json:"category"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Lines    []postEntryLine   `json:"lines"`
+}
+
+type patchScheduleRequest struct {
+	Name     *string            `json:"name,omitempty"`
+	Rule     *string            `json:"rule,omitempty"`
+	Start    *time.Time         `json:"start,omitempty"`
+	Until    *time.Time         `json:"until,omitempty"`
+	Active   *bool              `json:"active,omitempty"`
+	Currency *string            `json:"currency,omitempty"`
+	Memo     *string            `json:"memo,omitempty"`
+	Category *ledger.Category   `json:"category,omitempty"`
+	Metadata *map[string]string `json:"metadata,omitempty"`
+	Lines    []postEntryLine    `json:"lines,omitempty"`
+}
+
+type scheduleResponse struct {
+	ID        uuid.UUID         `json:"id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Name      string            `json:"name"`
+	Rule      string            `json:"rule"`
+	Start     time.Time         `json:"start"`
+	Until     *time.Time        `json:"until,omitempty"`
+	Active    bool              `json:"active"`
+	NextRun   *time.Time        `json:"next_run,omitempty"`
+	LastError string            `json:"last_error,omitempty"`
+	Currency  string            `json:"currency"`
+	Memo      string            `json:"memo"`
+	Category  ledger.Category   `json:"category"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	Lines     []postEntryLine   `json:"lines"`
+}
+
+type schedulePreviewResponse struct {
+	ScheduleID  uuid.UUID   `json:"schedule_id"`
+	Occurrences []time.Time `json:"occurrences"`
+}
+
+// Categorization rules
+
+type ruleMatchBody struct {
+	MemoPattern     string            `json:"memo_pattern,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	MinAmountMinor  *int64            `json:"min_amount_minor,omitempty"`
+	MaxAmountMinor  *int64            `json:"max_amount_minor,omitempty"`
+	SourceAccountID *uuid.UUID        `json:"source_account_id,omitempty"`
+}
+
+type ruleActionsBody struct {
+	Category         ledger.Category   `json:"category,omitempty"`
+	CounterAccountID *uuid.UUID        `json:"counter_account_id,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type postRuleRequest struct {
+	UserID   uuid.UUID       `json:"user_id"`
+	Name     string          `json:"name"`
+	Priority int             `json:"priority"`
+	Active   *bool           `json:"active,omitempty"`
+	Stop     bool            `json:"stop"`
+	Match    ruleMatchBody   `json:"match"`
+	Actions  ruleActionsBody `json:"actions"`
+}
+
+type patchRuleRequest struct {
+	Name     *string          `json:"name,omitempty"`
+	Priority *int             `json:"priority,omitempty"`
+	Active   *bool            `json:"active,omitempty"`
+	Stop     *bool            `json:"stop,omitempty"`
+	Match    *ruleMatchBody   `json:"match,omitempty"`
+	Actions  *ruleActionsBody `json:"actions,omitempty"`
+}
+
+type ruleResponse struct {
+	ID       uuid.UUID       `json:"id"`
+	UserID   uuid.UUID       `json:"user_id"`
+	Name     string          `json:"name"`
+	Priority int             `json:"priority"`
+	Active   bool            `json:"active"`
+	Stop     bool            `json:"stop"`
+	Match    ruleMatchBody   `json:"match"`
+	Actions  ruleActionsBody `json:"actions"`
+}
+
+type ruleDryRunRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	// Rule, when set, is evaluated alone instead of the user's stored rules.
+	Rule *postRuleRequest `json:"rule,omitempty"`
+}
+
+type ruleLineChange struct {
+	LineID        uuid.UUID `json:"line_id"`
+	FromAccountID uuid.UUID `json:"from_account_id"`
+	ToAccountID   uuid.UUID `json:"to_account_id"`
+}
+
+type ruleDryRunMatch struct {
+	EntryID        uuid.UUID         `json:"entry_id"`
+	Date           time.Time         `json:"date"`
+	Memo           string            `json:"memo"`
+	RuleIDs        []uuid.UUID       `json:"rule_ids"`
+	CategoryBefore ledger.Category   `json:"category_before"`
+	CategoryAfter  ledger.Category   `json:"category_after"`
+	LineChanges    []ruleLineChange  `json:"line_changes,omitempty"`
+	MetadataAdded  map[string]string `json:"metadata_added,omitempty"`
+}
+
+type ruleDryRunResponse struct {
+	UserID  uuid.UUID         `json:"user_id"`
+	Matches []ruleDryRunMatch `json:"matches"`
+}
+
+// Statement imports
+
+type csvMappingBody struct {
+	Delimiter string `json:"delimiter,omitempty"`
+	// HasHeader defaults to true.
+	HasHeader       *bool             `json:"has_header,omitempty"`
+	SkipRows        int               `json:"skip_rows,omitempty"`
+	DateColumn      string            `json:"date_column"`
+	DateFormat      string            `json:"date_format,omitempty"`
+	AmountColumn    string            `json:"amount_column,omitempty"`
+	Sign            ledger.AmountSign `json:"sign,omitempty"`
+	InflowColumn    string            `json:"inflow_column,omitempty"`
+	OutflowColumn   string            `json:"outflow_column,omitempty"`
+	DecimalComma    bool              `json:"decimal_comma,omitempty"`
+	MemoColumns     []string          `json:"memo_columns,omitempty"`
+	ReferenceColumn string            `json:"reference_column,omitempty"`
+}
+
+type postImportProfileRequest struct {
+	UserID           uuid.UUID      `json:"user_id"`
+	Name             string         `json:"name"`
+	CounterAccountID *uuid.UUID     `json:"counter_account_id,omitempty"`
+	Mapping          csvMappingBody `json:"mapping"`
+}
+
+type importProfileResponse struct {
+	ID               uuid.UUID      `json:"id"`
+	UserID           uuid.UUID      `json:"user_id"`
+	Name             string         `json:"name"`
+	CounterAccountID *uuid.UUID     `json:"counter_account_id,omitempty"`
+	Mapping          csvMappingBody `json:"mapping"`
+}
+
+type importDuplicate struct {
+	Row         int       `json:"row"`
+	SourceTxnID string    `json:"source_txn_id"`
+	InputHash   string    `json:"input_hash"`
+	EntryID     uuid.UUID `json:"entry_id"`
+}
+
+type importRowError struct {
+	Row   int    `json:"row"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// importBalanceCheck compares a statement balance with the ledger after the import.
+type importBalanceCheck struct {
+	Kind            string    `json:"kind"`
+	AsOf            time.Time `json:"as_of"`
+	Currency        string    `json:"currency"`
+	StatementMinor  int64     `json:"statement_minor"`
+	LedgerMinor     int64     `json:"ledger_minor"`
+	DifferenceMinor int64     `json:"difference_minor"`
+	Matches         bool      `json:"matches"`
+}
+
+type importResponse struct {
+	Imported   []entryResponse      `json:"imported"`
+	Duplicates []importDuplicate    `json:"duplicates"`
+	Balances   []importBalanceCheck `json:"balances,omitempty"`
+}
+
+type importErrorsResponse struct {
+	Errors []importRowError `json:"errors"`
+}
+
+// journalImportResponse lists what a journal import created.
+type journalImportResponse struct {
+	Accounts   []accountResponse   `json:"accounts"`
+	Entries    []entryResponse     `json:"entries"`
+	Assertions []assertionResponse `json:"assertions"`
+}
+
+type journalImportLineError struct {
+	Line  int    `json:"line"`
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+type journalImportErrorsResponse struct {
+	Errors []journalImportLineError `json:"errors"`
+}
+
+// Reconciliations
+
+type reconciliationLineBody struct {
+	Date        time.Time `json:"date"`
+	AmountMinor int64     `json:"amount_minor"`
+	Description string    `json:"description,omitempty"`
+	Reference   string    `json:"reference,omitempty"`
+}
+
+type postReconciliationRequest struct {
+	UserID                uuid.UUID `json:"user_id"`
+	AccountID             uuid.UUID `json:"account_id"`
+	StatementDate         time.Time `json:"statement_date"`
+	StatementBalanceMinor int64     `json:"statement_balance_minor"`
+	// OpeningBalanceMinor defaults to the account's last reconciled balance.
+	OpeningBalanceMinor *int64                   `json:"opening_balance_minor,omitempty"`
+	Lines               []reconciliationLineBody `json:"lines"`
+}
+
+type addStatementLinesRequest struct {
+	UserID uuid.UUID                `json:"user_id"`
+	Lines  []reconciliationLineBody `json:"lines"`
+}
+
+type autoMatchRequest struct {
+	UserID         uuid.UUID `json:"user_id"`
+	DateWindowDays int       `json:"date_window_days,omitempty"`
+}
+
+type matchRequest struct {
+	UserID          uuid.UUID `json:"user_id"`
+	StatementLineID uuid.UUID `json:"statement_line_id"`
+	JournalLineID   uuid.UUID `json:"journal_line_id"`
+}
+
+type unmatchRequest struct {
+	UserID          uuid.UUID `json:"user_id"`
+	StatementLineID uuid.UUID `json:"statement_line_id"`
+}
+
+type reconciliationLineResponse struct {
+	ID            uuid.UUID  `json:"id"`
+	Date          time.Time  `json:"date"`
+	AmountMinor   int64      `json:"amount_minor"`
+	Description   string     `json:"description,omitempty"`
+	Reference     string     `json:"reference,omitempty"`
+	JournalLineID *uuid.UUID `json:"journal_line_id,omitempty"`
+	Matched       bool       `json:"matched"`
+}
+
+type reconciliationResponse struct {
+	ID                    uuid.UUID                   `json:"id"`
+	UserID                uuid.UUID                   `json:"user_id"`
+	AccountID             uuid.UUID                   `json:"account_id"`
+	StatementDate         time.Time                   `json:"statement_date"`
+	Currency              string                      `json:"currency"`
+	OpeningBalanceMinor   int64                       `json:"opening_balance_minor"`
+	StatementBalanceMinor int64                       `json:"statement_balance_minor"`
+	Status                ledger.ReconciliationStatus `json:"status"`
+	// ClearedBalanceMinor is the opening balance plus the matched statement lines.
+	ClearedBalanceMinor int64 `json:"cleared_balance_minor"`
+	// DifferenceMinor is the statement balance less the cleared balance.
+	DifferenceMinor        int64                        `json:"difference_minor"`
+	UnmatchedCount         int                          `json:"unmatched_count"`
+	ReconciledBalanceMinor *int64                       `json:"reconciled_balance_minor,omitempty"`
+	FinishedAt             *time.Time                   `json:"finished_at,omitempty"`
+	Lines                  []reconciliationLineResponse `json:"lines"`
+}
+
+type autoMatchResponse struct {
+	Matched        int                    `json:"matched"`
+	Reconciliation reconciliationResponse `json:"reconciliation"`
+}
+
+// Balance assertions
+
+type postAssertionRequest struct {
+	UserID    uuid.UUID `json:"user_id"`
+	AccountID uuid.UUID `json:"account_id"`
+	// Date is a calendar day (YYYY-MM-DD); entries dated on it count.
+	Date        string `json:"date"`
+	AmountMinor int64  `json:"amount_minor"`
+	Note        string `json:"note,omitempty"`
+}
+
+type assertionResponse struct {
+	ID          uuid.UUID `json:"id"`
+	UserID      uuid.UUID `json:"user_id"`
+	AccountID   uuid.UUID `json:"account_id"`
+	Date        string    `json:"date"`
+	Currency    string    `json:"currency"`
+	AmountMinor int64     `json:"amount_minor"`
+	Note        string    `json:"note,omitempty"`
+	// ActualMinor is the account balance at the end of Date as the ledger stands.
+	ActualMinor     int64 `json:"actual_minor"`
+	DifferenceMinor int64 `json:"difference_minor"`
+	Passing         bool  `json:"passing"`
+}
+
+type assertionListResponse struct {
+	UserID  uuid.UUID           `json:"user_id"`
+	Total   int                 `json:"total"`
+	Failing int                 `json:"failing"`
+	Items   []assertionResponse `json:"items"`
+}
+
+type auditRecordResponse struct {
+	Seq       int64                 `json:"seq"`
+	ID        uuid.UUID             `json:"id"`
+	UserID    uuid.UUID             `json:"user_id"`
+	Actor     string                `json:"actor"`
+	RequestID string                `json:"request_id"`
+	Operation ledger.AuditOperation `json:"operation"`
+	Entity    ledger.AuditEntity    `json:"entity"`
+	EntityID  uuid.UUID             `json:"entity_id"`
+	// Before and After are the entity as the API returned it; Before is null for creations.
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// listAuditResponse wraps audit records with a cursor for the next page.
+type listAuditResponse struct {
+	Items      []auditRecordResponse `json:"items"`
+	NextCursor *string               `json:"next_cursor,omitempty"`
+}
+
+type postWebhookRequest struct {
+	UserID     uuid.UUID          `json:"user_id"`
+	URL        string             `json:"url"`
+	EventTypes []ledger.EventType `json:"event_types"`
+}
+
+type webhookResponse struct {
+	ID         uuid.UUID          `json:"id"`
+	UserID     uuid.UUID          `json:"user_id"`
+	URL        string             `json:"url"`
+	EventTypes []ledger.EventType `json:"event_types"`
+	// Secret is only returned when the endpoint is created.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type webhookDeliveryResponse struct {
+	ID             uuid.UUID             `json:"id"`
+	UserID         uuid.UUID             `json:"user_id"`
+	EventID        uuid.UUID             `json:"event_id"`
+	EventType      ledger.EventType      `json:"event_type"`
+	EndpointID     uuid.UUID             `json:"endpoint_id"`
+	Status         ledger.DeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"next_attempt_at,omitempty"`
+	LastError      string                `json:"last_error,omitempty"`
+	LastStatusCode int                   `json:"last_status_code,omitempty"`
+	DeliveredAt    *time.Time            `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+}
+
+// streamEventResponse is the data of a server-sent event; the same shape is POSTed to webhooks.
+type streamEventResponse struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      ledger.EventType `json:"type"`
+	UserID    uuid.UUID        `json:"user_id"`
+	EntityID  uuid.UUID        `json:"entity_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      json.RawMessage  `json:"data"`
+}
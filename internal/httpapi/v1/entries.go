@@ -14,6 +14,8 @@ import (
 	"github.com/govalues/money"
 	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/report"
 )
 
 func (s *Server) postEntry(w http.ResponseWriter, r *http.Request) {
@@ -58,13 +60,51 @@ func (s *Server) reverseEntry(w http.ResponseWriter, r *http.Request) {
 	if req.Date != nil {
 		date = req.Date.UTC()
 	}
-	saved, err := s.svc.ReverseEntry(r.Context(), req.UserID, req.EntryID, date)
+	var saved ledger.JournalEntry
+	var err error
+	switch {
+	case req.AmountMinor != nil:
+		saved, err = s.svc.ReversePartial(r.Context(), req.UserID, req.EntryID, date, journal.Partial{AmountMinor: *req.AmountMinor})
+	case len(req.Lines) > 0:
+		p := journal.Partial{Lines: make(map[uuid.UUID]int64, len(req.Lines))}
+		for _, l := range req.Lines {
+			p.Lines[l.LineID] = l.AmountMinor
+		}
+		saved, err = s.svc.ReversePartial(r.Context(), req.UserID, req.EntryID, date, p)
+	default:
+		saved, err = s.svc.ReverseEntry(r.Context(), req.UserID, req.EntryID, date)
+	}
 	if err != nil {
 		// Map known sentinel errors
 		if errors.Is(err, errs.ErrAlreadyReversed) {
 			unprocessable(w, "already_reversed", "already_reversed")
 			return
 		}
+		if errors.Is(err, errs.ErrOverReversal) {
+			unprocessable(w, err.Error(), "exceeds_remaining")
+			return
+		}
+		if errors.Is(err, errs.ErrConflict) {
+			conflict(w, "the entry is being reversed by another request; retry")
+			return
+		}
+		if errors.Is(err, errs.ErrUnbalancedEntry) || errors.Is(err, errs.ErrTooFewLines) {
+			code, msg := mapValidationError(err)
+			unprocessable(w, "partial reversal must balance: "+msg, code)
+			return
+		}
+		if errors.Is(err, errs.ErrPeriodClosed) {
+			unprocessable(w, "period_closed", "period_closed")
+			return
+		}
+		if errors.Is(err, errs.ErrReconciled) {
+			unprocessable(w, "entry has reconciled lines; reopen the reconciliation first", "reconciled")
+			return
+		}
+		if errors.Is(err, errs.ErrAssertionFailed) {
+			unprocessable(w, err.Error(), "assertion_failed")
+			return
+		}
 		if errors.Is(err, errs.ErrInvalid) {
 			badRequest(w, "invalid")
 			return
@@ -153,9 +193,67 @@ func (s *Server) trialBalance(w http.ResponseWriter, r *http.Request) {
 	for _, c := range keys {
 		response.Groups = append(response.Groups, trialBalanceCurrencyGroup{Currency: c, Accounts: groupsMap[c]})
 	}
+	if query.ReportingCurrency != "" {
+		tb, err := s.reportSvc.ConsolidatedTrialBalance(r.Context(), query.UserID, query.AsOf, query.ReportingCurrency)
+		if err != nil {
+			code, msg := mapValidationError(err)
+			unprocessable(w, msg, code)
+			return
+		}
+		response.ReportingCurrency = tb.ReportingCurrency
+		response.Consolidated = toConsolidatedTrialBalance(tb)
+	}
 	toJSON(w, http.StatusOK, response)
 }
 
+// toConsolidatedTrialBalance splits signed nets into debit/credit columns and totals them.
+func toConsolidatedTrialBalance(tb report.ConsolidatedTrialBalance) *consolidatedTrialBalance {
+	out := &consolidatedTrialBalance{
+		Currency:               tb.ReportingCurrency,
+		Accounts:               make([]consolidatedTrialBalanceAccount, 0, len(tb.Accounts)),
+		TranslationDifferences: make([]translationDifference, 0, len(tb.Differences)),
+	}
+	for _, a := range tb.Accounts {
+		debit, credit := splitNet(a.Net)
+		rdebit, rcredit := splitNet(a.ReportingNet)
+		out.Accounts = append(out.Accounts, consolidatedTrialBalanceAccount{
+			AccountID:            a.Account.ID,
+			Name:                 a.Account.Name,
+			Path:                 a.Account.Path(),
+			Type:                 a.Account.Type,
+			Currency:             a.Account.Currency,
+			Rate:                 a.Rate.Decimal().String(),
+			DebitMinor:           debit,
+			CreditMinor:          credit,
+			ReportingDebitMinor:  rdebit,
+			ReportingCreditMinor: rcredit,
+		})
+		out.TotalDebitMinor += rdebit
+		out.TotalCreditMinor += rcredit
+	}
+	for _, d := range tb.Differences {
+		debit, credit := splitNet(d.Difference)
+		out.TranslationDifferences = append(out.TranslationDifferences, translationDifference{
+			Currency:    d.Currency,
+			Rate:        d.Rate.Decimal().String(),
+			NetMinor:    d.Net,
+			DebitMinor:  debit,
+			CreditMinor: credit,
+		})
+		out.TotalDebitMinor += debit
+		out.TotalCreditMinor += credit
+	}
+	return out
+}
+
+// splitNet maps a debit-positive net to (debit, credit) columns.
+func splitNet(net int64) (debit, credit int64) {
+	if net >= 0 {
+		return net, 0
+	}
+	return 0, -net
+}
+
 // listEntries handles GET /entries
 func (s *Server) listEntries(w http.ResponseWriter, r *http.Request) {
 	ctxVal := r.Context().Value(ctxKeyListEntries)
@@ -164,24 +262,20 @@ func (s *Server) listEntries(w http.ResponseWriter, r *http.Request) {
 		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "validated query missing"})
 		return
 	}
-	entries, err := s.svc.ListEntries(r.Context(), query.UserID)
-	if err != nil {
-		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch entries"})
-		return
+	filter := journal.EntryFilter{
+		UserID:     query.UserID,
+		Currency:   query.Currency,
+		Memo:       query.Memo,
+		Category:   query.Category,
+		AccountID:  query.AccountID,
+		IsReversed: query.IsReversed,
+		Relation:   query.Relation,
+		RelatedTo:  query.RelatedTo,
 	}
-	// ensure deterministic order: Date asc, ID asc
-	sort.Slice(entries, func(i, j int) bool {
-		if entries[i].Date.Equal(entries[j].Date) {
-			return entries[i].ID.String() < entries[j].ID.String()
-		}
-		return entries[i].Date.Before(entries[j].Date)
-	})
-	// parse optional params
-	var from, to *time.Time
 	if raw := r.URL.Query().Get("from"); raw != "" {
 		if t, err := time.Parse(time.RFC3339, raw); err == nil {
 			tt := t.UTC()
-			from = &tt
+			filter.From = &tt
 		} else {
 			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from"})
 			return
@@ -190,7 +284,7 @@ func (s *Server) listEntries(w http.ResponseWriter, r *http.Request) {
 	if raw := r.URL.Query().Get("to"); raw != "" {
 		if t, err := time.Parse(time.RFC3339, raw); err == nil {
 			tt := t.UTC()
-			to = &tt
+			filter.To = &tt
 		} else {
 			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to"})
 			return
@@ -202,88 +296,134 @@ func (s *Server) listEntries(w http.ResponseWriter, r *http.Request) {
 			lim = n
 		}
 	}
-	cursor := r.URL.Query().Get("cursor")
-	// build window by time and additional filters
-	window := make([]ledger.JournalEntry, 0, len(entries))
-	for _, entry := range entries {
-		if from != nil && entry.Date.Before(*from) {
-			continue
-		}
-		if to != nil && entry.Date.After(*to) {
-			continue
-		}
-		if query.Currency != "" && !equalsFold(entry.Currency, query.Currency) {
-			continue
-		}
-		if query.Memo != "" && !equalsFold(entry.Memo, query.Memo) {
-			continue
-		}
-		if query.Category != "" && !equalsFold(string(entry.Category), query.Category) {
-			continue
-		}
-		if query.IsReversed != nil && entry.IsReversed != *query.IsReversed {
-			continue
-		}
-		window = append(window, entry)
-	}
-	// start index from cursor
-	start := 0
-	if cursor != "" {
-		if b, err := base64.StdEncoding.DecodeString(cursor); err == nil {
-			parts := strings.Split(string(b), "|")
-			if len(parts) == 2 {
-				if ts, err := time.Parse(time.RFC3339Nano, parts[0]); err == nil {
-					cid, _ := uuid.Parse(parts[1])
-					for i := range window {
-						if window[i].Date.After(ts) {
-							break
-						}
-						if window[i].Date.Equal(ts) && window[i].ID == cid {
-							start = i + 1
-							break
-						}
-					}
-				}
-			}
+	if raw := r.URL.Query().Get("cursor"); raw != "" {
+		date, ids, ok := decodeCursor(raw, 1)
+		if !ok {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid cursor"})
+			return
 		}
+		filter.After = &journal.EntryKey{Date: date, ID: ids[0]}
 	}
-	end := start + lim
-	if end > len(window) {
-		end = len(window)
+	// One extra entry tells whether another page follows.
+	filter.Limit = lim + 1
+	page, err := s.entryReader.QueryEntries(r.Context(), filter)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch entries"})
+		return
 	}
-	page := window[start:end]
 	response := listEntriesResponse{Items: make([]entryResponse, 0, len(page))}
+	if len(page) > lim {
+		page = page[:lim]
+		c := encodeCursor(page[len(page)-1].Date, page[len(page)-1].ID)
+		response.NextCursor = &c
+	}
 	for _, entry := range page {
 		response.Items = append(response.Items, toEntryResponse(entry))
 	}
-	if end < len(window) {
-		c := base64.StdEncoding.EncodeToString([]byte(page[len(page)-1].Date.Format(time.RFC3339Nano) + "|" + page[len(page)-1].ID.String()))
-		response.NextCursor = &c
-	}
 	toJSON(w, http.StatusOK, response)
 }
 
+// encodeCursor returns an opaque keyset cursor for a position: a date and the
+// IDs that break ties at that date.
+func encodeCursor(date time.Time, ids ...uuid.UUID) string {
+	raw := date.Format(time.RFC3339Nano)
+	for _, id := range ids {
+		raw += "|" + id.String()
+	}
+	return base64.StdEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor from encodeCursor carrying n IDs; ok is false
+// for an empty, malformed or other-format cursor.
+func decodeCursor(cursor string, n int) (date time.Time, ids []uuid.UUID, ok bool) {
+	if cursor == "" {
+		return time.Time{}, nil, false
+	}
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, nil, false
+	}
+	parts := strings.Split(string(b), "|")
+	if len(parts) != n+1 {
+		return time.Time{}, nil, false
+	}
+	if date, err = time.Parse(time.RFC3339Nano, parts[0]); err != nil {
+		return time.Time{}, nil, false
+	}
+	for _, p := range parts[1:] {
+		id, err := uuid.Parse(p)
+		if err != nil {
+			return time.Time{}, nil, false
+		}
+		ids = append(ids, id)
+	}
+	return date, ids, true
+}
+
 func toEntryResponse(entry ledger.JournalEntry) entryResponse {
 	lines := make([]lineResponse, 0, len(entry.Lines.ByID))
+	var total, reversed int64
 	for lineID, line := range entry.Lines.ByID {
 		minorUnits, _ := line.Amount.MinorUnits()
-		lines = append(lines, lineResponse{
-			ID:          lineID,
-			AccountID:   line.AccountID,
-			Side:        line.Side,
-			AmountMinor: minorUnits,
-			Amount:      line.Amount.Decimal().String(),
-		})
+		lr := lineResponse{
+			ID:               lineID,
+			AccountID:        line.AccountID,
+			Side:             line.Side,
+			AmountMinor:      minorUnits,
+			Amount:           line.Amount.Decimal().String(),
+			Currency:         line.Amount.Curr().Code(),
+			EntryAmountMinor: minorUnits,
+			Status:           line.ClearStatus(),
+			ReversedMinor:    line.ReversedMinor(),
+			RemainingMinor:   line.RemainingMinor(),
+		}
+		if line.Rate != nil {
+			rate := line.Rate.Decimal().String()
+			lr.ExchangeRate = &rate
+			if conv, err := line.EntryAmount(); err == nil {
+				lr.EntryAmountMinor, _ = conv.MinorUnits()
+			}
+		}
+		lines = append(lines, lr)
+		if line.Side == ledger.SideDebit {
+			total += lr.EntryAmountMinor
+			reversed += entryMinor(line, line.ReversedMinor())
+		}
+	}
+	relations := make([]entryRelationResponse, 0, len(entry.Relations))
+	for _, rel := range entry.Relations {
+		relations = append(relations, entryRelationResponse{Kind: rel.Kind, EntryID: rel.EntryID})
 	}
 	return entryResponse{
-		ID:         entry.ID,
-		UserID:     entry.UserID,
-		Date:       entry.Date,
-		Currency:   entry.Currency,
-		Memo:       entry.Memo,
-		Category:   entry.Category,
-		Metadata:   entry.Metadata,
-		IsReversed: entry.IsReversed,
-		Lines:      lines,
+		ID:             entry.ID,
+		UserID:         entry.UserID,
+		Date:           entry.Date,
+		Currency:       entry.Currency,
+		Memo:           entry.Memo,
+		Category:       entry.Category,
+		Metadata:       entry.Metadata,
+		IsReversed:     reversed == total,
+		ReversedMinor:  reversed,
+		RemainingMinor: total - reversed,
+		Lines:          lines,
+		Relations:      relations,
+	}
+}
+
+// entryMinor converts minor units of a line's currency into the entry currency
+// at the line rate.
+func entryMinor(line *ledger.JournalLine, minor int64) int64 {
+	if line.Rate == nil || minor == 0 {
+		return minor
+	}
+	amt, err := money.NewAmountFromMinorUnits(line.Amount.Curr().Code(), minor)
+	if err != nil {
+		return 0
+	}
+	conv, err := line.Rate.Conv(amt)
+	if err != nil {
+		return 0
 	}
+	out, _ := conv.RoundToCurr().MinorUnits()
+	return out
 }
@@ -71,8 +71,18 @@ func (s *Server) postEntriesBatch(w http.ResponseWriter, r *http.Request) {
 		s.batchIdemMu.RUnlock()
 		rw := &captureWriter{ResponseWriter: w}
 		drafts := make([]ledger.JournalEntry, 0, len(req.Entries))
-		for _, e := range req.Entries {
-			drafts = append(drafts, toEntryDomain(e))
+		for i, e := range req.Entries {
+			d, err := toEntryDomain(e)
+			if err != nil {
+				writeErr(rw, http.StatusBadRequest, "entries["+itoa(i)+"]: "+err.Error(), "")
+				s.storeBatch(key, h, rw)
+				return
+			}
+			if d, err = s.applyRules(r, d); err != nil {
+				writeErr(rw, http.StatusInternalServerError, "could not apply rules", "")
+				return
+			}
+			drafts = append(drafts, d)
 		}
 		created, errsList, err := s.svc.CreateEntriesBatch(r.Context(), drafts)
 		if err != nil {
@@ -100,7 +110,8 @@ func (s *Server) postEntriesBatch(w http.ResponseWriter, r *http.Request) {
 			Entries []entryResponse `json:"entries"`
 		}{Entries: make([]entryResponse, 0, len(created))}
 		for _, e := range created {
-			resp.Entries = append(resp.Entries, toEntryResponse(e))
+			er := toEntryResponse(e)
+			resp.Entries = append(resp.Entries, er)
 		}
 		toJSON(rw, http.StatusCreated, resp)
 		s.storeBatch(key, h, rw)
@@ -10,6 +10,7 @@ import (
 	"github.com/govalues/money"
 	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/audit"
 )
 
 // POST /entries/reclassify
@@ -49,15 +50,44 @@ func (s *Server) reclassifyEntry(w http.ResponseWriter, r *http.Request) {
 	if body.Category != nil {
 		cat = *body.Category
 	}
+	// Cross-currency lines quote into the original entry's currency, so load it once.
+	var origCurr string
+	for _, ln := range body.Lines {
+		if ln.ExchangeRate == "" {
+			continue
+		}
+		orig, err := s.entryReader.GetEntry(r.Context(), body.UserID, body.EntryID)
+		if err != nil {
+			if errors.Is(err, errs.ErrNotFound) {
+				notFound(w)
+				return
+			}
+			writeErr(w, http.StatusInternalServerError, "failed to load entry", "")
+			return
+		}
+		origCurr = orig.Currency
+		break
+	}
 	// map lines to domain lines
 	domLines := make([]ledger.JournalLine, 0, len(body.Lines))
 	for _, ln := range body.Lines {
-		amt, _ := money.NewAmountFromMinorUnits("USD", ln.AmountMinor)
-		// currency will be validated against original entry's currency; amounts are attached per line
-		domLines = append(domLines, ledger.JournalLine{AccountID: ln.AccountID, Side: ln.Side, Amount: amt})
+		var jl ledger.JournalLine
+		if ln.ExchangeRate == "" {
+			// Same-currency line: the service denominates it in the original entry's currency.
+			amt, _ := money.NewAmountFromMinorUnits("USD", ln.AmountMinor)
+			jl = ledger.JournalLine{AccountID: ln.AccountID, Side: ln.Side, Amount: amt}
+		} else {
+			var err error
+			if jl, err = toLineDomain(ln, origCurr); err != nil {
+				badRequest(w, err.Error())
+				return
+			}
+		}
+		domLines = append(domLines, jl)
 	}
 	// call service
-	saved, err := s.svc.Reclassify(r.Context(), body.UserID, body.EntryID, when, memo, cat, domLines, body.Metadata)
+	ctx := audit.WithOperation(r.Context(), ledger.AuditReclassify)
+	saved, err := s.svc.Reclassify(ctx, body.UserID, body.EntryID, when, memo, cat, domLines, body.Metadata)
 	if err != nil {
 		// 404 detection
 		if errors.Is(err, errs.ErrNotFound) {
@@ -85,7 +115,8 @@ func (s *Server) reclassifyEntry(w http.ResponseWriter, r *http.Request) {
 		badRequest(w, err.Error())
 		return
 	}
-	toJSON(w, http.StatusCreated, toEntryResponse(saved))
+	resp := toEntryResponse(saved)
+	toJSON(w, http.StatusCreated, resp)
 }
 
 // no additional helpers
@@ -0,0 +1,97 @@
+// Entry relation endpoints: user-made links between entries.
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"errors"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/audit"
+)
+
+// POST /entries/{id}/relations
+// Body: { user_id, entry_id }
+// Links the two entries as related and returns the entry from the path.
+func (s *Server) postEntryRelation(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid entry id"})
+		return
+	}
+	var body struct {
+		UserID  uuid.UUID `json:"user_id"`
+		EntryID uuid.UUID `json:"entry_id"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if body.UserID == uuid.Nil || body.EntryID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id and entry_id are required"})
+		return
+	}
+	if body.EntryID == id {
+		badRequest(w, "an entry cannot be related to itself")
+		return
+	}
+	ctx := audit.WithOperation(r.Context(), ledger.AuditRelate)
+	if err := s.svc.Relate(ctx, body.UserID, id, body.EntryID); err != nil {
+		writeRelationErr(w, err)
+		return
+	}
+	e, err := s.entryReader.GetEntry(r.Context(), body.UserID, id)
+	if err != nil {
+		writeRelationErr(w, err)
+		return
+	}
+	resp := toEntryResponse(e)
+	toJSON(w, http.StatusOK, resp)
+}
+
+// DELETE /entries/{id}/relations/{related_id}?user_id=
+// Removes a related link from both entries.
+func (s *Server) deleteEntryRelation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid entry id"})
+		return
+	}
+	relatedID, err := uuid.Parse(chi.URLParam(r, "related_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid related entry id"})
+		return
+	}
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	ctx := audit.WithOperation(r.Context(), ledger.AuditUnrelate)
+	if err := s.svc.Unrelate(ctx, userID, id, relatedID); err != nil {
+		writeRelationErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeRelationErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrForbidden):
+		forbidden(w, "forbidden")
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	default:
+		writeErr(w, http.StatusInternalServerError, "failed to update relations", "")
+	}
+}
@@ -3,6 +3,7 @@ package v1
 import (
 	"errors"
 	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/service/fx"
 	"net/http"
 	"strings"
 )
@@ -42,6 +43,14 @@ func mapValidationError(err error) (code, msg string) {
 		return "currency_mismatch", msg
 	case errors.Is(err, errs.ErrUnbalancedEntry):
 		return "unbalanced_entry", msg
+	case errors.Is(err, errs.ErrPeriodClosed):
+		return "period_closed", msg
+	case errors.Is(err, errs.ErrReconciled):
+		return "reconciled", msg
+	case errors.Is(err, errs.ErrAssertionFailed):
+		return "assertion_failed", msg
+	case errors.Is(err, fx.ErrNoRate):
+		return "fx_rate_unavailable", msg
 	default:
 		code := "validation_error"
 		switch {
@@ -0,0 +1,177 @@
+// Plain-text journal export for ledger-cli, hledger and beancount.
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/plaintext"
+)
+
+// exportJournal handles GET /v1/export/journal?user_id=[&from=][&to=][&format=ledger|hledger|beancount]
+func (s *Server) exportJournal(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("user_id") == "" {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	format, err := plaintext.ParseFormat(q.Get("format"))
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	var from, to *time.Time
+	if v := q.Get("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from"})
+			return
+		}
+		t = t.UTC()
+		from = &t
+	}
+	if v := q.Get("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to"})
+			return
+		}
+		t = t.UTC()
+		to = &t
+	}
+	accounts, err := s.accReader.ListAccounts(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load accounts"})
+		return
+	}
+	all, err := s.entryReader.ListEntries(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "failed to load entries"})
+		return
+	}
+	entries := make([]ledger.JournalEntry, 0, len(all))
+	var before []ledger.JournalEntry
+	for _, e := range all {
+		if from != nil && e.Date.Before(*from) {
+			before = append(before, e)
+			continue
+		}
+		if to != nil && e.Date.After(*to) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	if from != nil {
+		opening, extra, err := openingEntries(userID, *from, before)
+		if err != nil {
+			writeErr(w, http.StatusInternalServerError, "could not export journal", "")
+			return
+		}
+		entries = append(opening, entries...)
+		accounts = append(accounts, extra...)
+	}
+	// Render into a buffer so a failure can still be reported as JSON.
+	var buf bytes.Buffer
+	if err := plaintext.Export(&buf, format, accounts, entries); err != nil {
+		writeErr(w, http.StatusInternalServerError, "could not export journal", "")
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="journal.`+format.Extension()+`"`)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// openingEntries carries the entries dated before from into a journal that
+// starts there: one "Opening balances" transaction per currency, dated at
+// from, posting each account's balance so far. Conversions between currencies
+// can leave a currency's balances not netting to zero; that difference is
+// posted to an equity:conversion:opening account, returned in extra for the
+// account directives.
+func openingEntries(userID uuid.UUID, from time.Time, before []ledger.JournalEntry) (entries []ledger.JournalEntry, extra []ledger.Account, err error) {
+	type net struct {
+		account uuid.UUID
+		minor   int64
+	}
+	nets := map[string]map[uuid.UUID]int64{}
+	for _, e := range before {
+		for _, ln := range e.Lines.ByID {
+			curr := ln.Amount.Curr().Code()
+			if nets[curr] == nil {
+				nets[curr] = map[uuid.UUID]int64{}
+			}
+			minor, _ := ln.Amount.MinorUnits()
+			if ln.Side == ledger.SideCredit {
+				minor = -minor
+			}
+			nets[curr][ln.AccountID] += minor
+		}
+	}
+	currencies := make([]string, 0, len(nets))
+	for c := range nets {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	for _, curr := range currencies {
+		var lines []net
+		var total int64
+		for id, minor := range nets[curr] {
+			if minor != 0 {
+				lines = append(lines, net{account: id, minor: minor})
+				total += minor
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		if total != 0 {
+			conv := ledger.Account{
+				ID:       uuid.NewSHA1(userID, []byte("export:conversion:"+curr)),
+				UserID:   userID,
+				Name:     "Opening Conversion",
+				Currency: curr,
+				Type:     ledger.AccountTypeEquity,
+				Group:    "conversion",
+				Vendor:   "opening",
+				System:   true,
+				Active:   true,
+			}
+			extra = append(extra, conv)
+			lines = append(lines, net{account: conv.ID, minor: -total})
+		}
+		e := ledger.JournalEntry{
+			ID:       uuid.NewSHA1(userID, []byte("export:opening:"+from.Format(time.RFC3339Nano)+":"+curr)),
+			UserID:   userID,
+			Date:     from,
+			Currency: curr,
+			Memo:     "Opening balances",
+			Category: ledger.CategoryGeneral,
+			Lines:    ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(lines))},
+		}
+		for _, n := range lines {
+			side, minor := ledger.SideDebit, n.minor
+			if minor < 0 {
+				side, minor = ledger.SideCredit, -minor
+			}
+			amt, err := money.NewAmountFromMinorUnits(curr, minor)
+			if err != nil {
+				return nil, nil, err
+			}
+			ln := &ledger.JournalLine{ID: uuid.NewSHA1(e.ID, n.account[:]), EntryID: e.ID, AccountID: n.account, Side: side, Amount: amt}
+			e.Lines.ByID[ln.ID] = ln
+		}
+		entries = append(entries, e)
+	}
+	return entries, extra, nil
+}
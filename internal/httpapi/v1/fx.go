@@ -0,0 +1,213 @@
+// Exchange-rate and FX revaluation handlers.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/revaluation"
+)
+
+// postFXRates handles POST /v1/fx/rates
+func (s *Server) postFXRates(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postFXRatesRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil || len(req.Rates) == 0 {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id and rates are required"})
+		return
+	}
+	rates := make([]ledger.FXRate, 0, len(req.Rates))
+	for i, it := range req.Rates {
+		date, err := fx.ParseDate(it.Date)
+		if err != nil {
+			badRequest(w, "rates["+itoa(i)+"]: "+err.Error())
+			return
+		}
+		rate, err := money.ParseExchRate(strings.ToUpper(it.Base), strings.ToUpper(it.Quote), it.Rate)
+		if err != nil {
+			badRequest(w, "rates["+itoa(i)+"]: invalid rate")
+			return
+		}
+		rates = append(rates, ledger.FXRate{Date: date, Rate: rate})
+	}
+	s.storeFXRates(w, r, req.UserID, rates)
+}
+
+// postFXRatesCSV handles POST /v1/fx/rates/csv?user_id= with a text/csv body.
+func (s *Server) postFXRatesCSV(w http.ResponseWriter, r *http.Request) {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+	if mime != "text/csv" {
+		writeErr(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported_media_type")
+		return
+	}
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	rates, err := fx.ParseCSV(r.Body)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	s.storeFXRates(w, r, userID, rates)
+}
+
+func (s *Server) storeFXRates(w http.ResponseWriter, r *http.Request, userID uuid.UUID, rates []ledger.FXRate) {
+	saved, err := s.fxSvc.Upsert(r.Context(), userID, rates)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		badRequest(w, err.Error())
+		return
+	}
+	out := make([]fxRateResponse, 0, len(saved))
+	for _, rt := range saved {
+		out = append(out, toFXRateResponse(rt))
+	}
+	toJSON(w, http.StatusCreated, out)
+}
+
+// listFXRates handles GET /v1/fx/rates?user_id=&base=&quote=
+func (s *Server) listFXRates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	rates, err := s.fxSvc.List(r.Context(), userID, q.Get("base"), q.Get("quote"))
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch rates"})
+		return
+	}
+	out := make([]fxRateResponse, 0, len(rates))
+	for _, rt := range rates {
+		out = append(out, toFXRateResponse(rt))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// postRevaluation handles POST /v1/fx/revaluations
+// 201 when an adjustment entry was posted; 200 when balances were already revalued at these rates.
+func (s *Server) postRevaluation(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postRevaluationRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil || req.AsOf.IsZero() || req.ReportingCurrency == "" || req.GainLossAccountID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id, as_of, reporting_currency and gain_loss_account_id are required"})
+		return
+	}
+	res, err := s.revaluationSvc.Run(r.Context(), revaluation.Request{
+		UserID:            req.UserID,
+		AsOf:              req.AsOf,
+		ReportingCurrency: req.ReportingCurrency,
+		GainLossAccountID: req.GainLossAccountID,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalid):
+			badRequest(w, "invalid")
+		case errors.Is(err, revaluation.ErrGainLossAccount):
+			unprocessable(w, err.Error(), "invalid_gain_loss_account")
+		default:
+			code, msg := mapValidationError(err)
+			unprocessable(w, msg, code)
+		}
+		return
+	}
+	resp := revaluationResponse{UserID: req.UserID, AsOf: res.AsOf, ReportingCurrency: res.ReportingCurrency, Adjustments: make([]revaluationAdjustment, 0, len(res.Adjustments)), MissingRates: make([]revaluationMissingRate, 0, len(res.MissingRates))}
+	for _, a := range res.Adjustments {
+		resp.Adjustments = append(resp.Adjustments, revaluationAdjustment{
+			AccountID:          a.AccountID,
+			CompanionAccountID: a.CompanionID,
+			Currency:           a.Currency,
+			Rate:               a.Rate.Decimal().String(),
+			BalanceMinor:       a.Balance,
+			ValueMinor:         a.Value,
+			BookedMinor:        a.Booked,
+			CarriedMinor:       a.Carried,
+			AdjustmentMinor:    a.Delta,
+		})
+	}
+	for _, m := range res.MissingRates {
+		resp.MissingRates = append(resp.MissingRates, revaluationMissingRate{AccountID: m.AccountID, Currency: m.Currency, Date: m.Date})
+	}
+	status := http.StatusOK
+	if res.Entry != nil {
+		er := toEntryResponse(*res.Entry)
+		resp.Entry = &er
+		status = http.StatusCreated
+	}
+	toJSON(w, status, resp)
+}
+
+// reverseRevaluation handles POST /v1/fx/revaluations/reverse
+func (s *Server) reverseRevaluation(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req reverseRevaluationRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	var date time.Time
+	if req.Date != nil {
+		date = req.Date.UTC()
+	}
+	reversals, err := s.revaluationSvc.Reverse(r.Context(), req.UserID, req.AsOf, req.ReportingCurrency, date)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrInvalid):
+			badRequest(w, "user_id, as_of and reporting_currency are required")
+		case errors.Is(err, errs.ErrNotFound):
+			notFound(w)
+		default:
+			code, msg := mapValidationError(err)
+			unprocessable(w, msg, code)
+		}
+		return
+	}
+	out := make([]entryResponse, 0, len(reversals))
+	for _, e := range reversals {
+		out = append(out, toEntryResponse(e))
+	}
+	toJSON(w, http.StatusCreated, out)
+}
+
+func toFXRateResponse(r ledger.FXRate) fxRateResponse {
+	return fxRateResponse{ID: r.ID, UserID: r.UserID, fxRateItem: fxRateItem{
+		Base:  r.Base(),
+		Quote: r.Quote(),
+		Date:  r.Date.Format("2006-01-02"),
+		Rate:  r.Rate.Decimal().String(),
+	}}
+}
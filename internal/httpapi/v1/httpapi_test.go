@@ -1,18 +1,32 @@
 package v1
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+	"github.com/tinoosan/ledger/internal/service/webhook"
 	"github.com/tinoosan/ledger/internal/storage/memory"
 )
 
@@ -64,6 +78,22 @@ func setup(t *testing.T) (*memory.Store, http.Handler, uuid.UUID, ledger.Account
 	return store, h, user.ID, cash, income
 }
 
+// doJSON sends body (JSON-encoded when non-nil) and returns the recorded response.
+func doJSON(h http.Handler, method, path string, body any) *httptest.ResponseRecorder {
+	var rd io.Reader
+	if body != nil {
+		b, _ := json.Marshal(body)
+		rd = bytes.NewReader(b)
+	}
+	req := httptest.NewRequest(method, path, rd)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
 func TestPostEntries_ValidAndInvalid(t *testing.T) {
 	_, h, userID, cash, income := setup(t)
 
@@ -734,6 +764,15 @@ func TestAccount_BalanceAndLedger(t *testing.T) {
 	if len(l1.Items) != 1 || l1.NextCursor == nil {
 		t.Fatalf("expected 1 item and next_cursor; got: %+v", l1)
 	}
+
+	// A cursor that doesn't decode is rejected rather than read as the first page
+	if rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String()+"&cursor=not-a-cursor", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("entries with malformed cursor expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// An entries cursor carries no line ID, so the ledger rejects it
+	if rec := doJSON(h, http.MethodGet, "/accounts/"+cash.ID.String()+"/ledger?user_id="+userID.String()+"&cursor="+url.QueryEscape(encodeCursor(time.Now(), uuid.New())), nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("ledger with entries cursor expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
 }
 
 func TestBalance_CurrencyMatchesAccount(t *testing.T) {
@@ -1245,3 +1284,3023 @@ func TestAccounts_Create_ConflictSoftDeleted_And_Reactivate(t *testing.T) {
 		t.Fatalf("expected account active after reactivation")
 	}
 }
+
+func TestPeriods_CloseRejectsPostingsAndReopen(t *testing.T) {
+	_, h, userID, cash, income := setup(t)
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	rec := doJSON(h, http.MethodPost, "/v1/periods", map[string]any{
+		"user_id": userID.String(), "name": "2025-09",
+		"start": start.Format(time.RFC3339), "end": start.AddDate(0, 1, 0).Format(time.RFC3339),
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create period expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var p periodResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &p)
+	if p.Status != ledger.PeriodStatusOpen {
+		t.Fatalf("expected open period, got %+v", p)
+	}
+	// Overlapping period conflicts
+	rec = doJSON(h, http.MethodPost, "/v1/periods", map[string]any{
+		"user_id": userID.String(), "name": "overlap",
+		"start": start.AddDate(0, 0, 15).Format(time.RFC3339), "end": start.AddDate(0, 2, 0).Format(time.RFC3339),
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("overlap expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	entry := map[string]any{
+		"user_id":  userID.String(),
+		"date":     start.AddDate(0, 0, 10).Format(time.RFC3339),
+		"currency": "USD",
+		"category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 100},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 100},
+		},
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("entry in open period expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created entryResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	base := "/v1/periods/" + p.ID.String()
+	if rec = doJSON(h, http.MethodPost, base+"/close?user_id="+userID.String(), nil); rec.Code != http.StatusOK {
+		t.Fatalf("close expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("entry in closed period expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var er errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &er)
+	if er.Code != "period_closed" {
+		t.Fatalf("expected period_closed, got %+v", er)
+	}
+	// Reversal dated inside the closed period is rejected too
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{
+		"user_id": userID.String(), "entry_id": created.ID, "date": start.AddDate(0, 0, 20).Format(time.RFC3339),
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reverse into closed period expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// Batch path enforces the same rule
+	req := httptest.NewRequest(http.MethodPost, "/v1/entries/batch", bytes.NewReader(mustJSON(map[string]any{"entries": []any{entry}})))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", "period-batch")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity || !bytes.Contains(rr.Body.Bytes(), []byte("period_closed")) {
+		t.Fatalf("batch into closed period expected 422 period_closed, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	if rec = doJSON(h, http.MethodPost, base+"/reopen?user_id="+userID.String(), nil); rec.Code != http.StatusOK {
+		t.Fatalf("reopen expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doJSON(h, http.MethodPost, "/v1/entries", entry); rec.Code != http.StatusCreated {
+		t.Fatalf("entry after reopen expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Locked periods cannot be reopened
+	if rec = doJSON(h, http.MethodPost, base+"/lock?user_id="+userID.String(), nil); rec.Code != http.StatusOK {
+		t.Fatalf("lock expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = doJSON(h, http.MethodPost, base+"/reopen?user_id="+userID.String(), nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reopen locked expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func mustJSON(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func TestYearEnd_CloseIdempotentAndReversible(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	groceries := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Groceries", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "General", Active: true}
+	store.SeedAccount(groceries)
+	post := func(debit, credit uuid.UUID, amt int64, dt time.Time) {
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": dt.Format(time.RFC3339), "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": debit.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": credit.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	post(cash.ID, income.ID, 1000, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	post(groceries.ID, cash.ID, 300, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	rec := doJSON(h, http.MethodPost, "/v1/year-end/close", map[string]any{"user_id": userID.String(), "year": 2024})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("close expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res yearEndCloseResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Entries) != 1 || len(res.Entries[0].Lines) != 3 || res.Entries[0].Metadata["ledger.fiscal_year"] != "2024" {
+		t.Fatalf("unexpected closing entries: %+v", res.Entries)
+	}
+	// Revenue and expense are zero at year end; retained earnings holds the 700 profit.
+	nets, _ := journal.New(store, store).TrialBalance(context.Background(), userID, &res.YearEnd)
+	for id, amt := range nets {
+		units, _ := amt.MinorUnits()
+		if (id == income.ID || id == groceries.ID) && units != 0 {
+			t.Fatalf("expected %s closed to zero, got %d", id, units)
+		}
+		if id != income.ID && id != groceries.ID && id != cash.ID && units != -700 {
+			t.Fatalf("expected retained earnings credit 700, got %d", units)
+		}
+	}
+
+	// Second run returns the same entry
+	rec = doJSON(h, http.MethodPost, "/v1/year-end/close", map[string]any{"user_id": userID.String(), "year": 2024})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("re-close expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var again yearEndCloseResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &again)
+	if len(again.Entries) != 1 || again.Entries[0].ID != res.Entries[0].ID {
+		t.Fatalf("expected idempotent close, got %+v", again.Entries)
+	}
+
+	// Reopen via ReverseEntry at the year end, then close again
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": res.Entries[0].ID, "date": res.YearEnd.Format(time.RFC3339)})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reverse closing expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/year-end/close", map[string]any{"user_id": userID.String(), "year": 2024})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("close after reopen expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestYearEnd_ReopenedYear(t *testing.T) {
+	_, h, userID, cash, income := setup(t)
+	post := func(amt int64, dt time.Time) {
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": dt.Format(time.RFC3339), "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	closeYear := func(year int) *httptest.ResponseRecorder {
+		return doJSON(h, http.MethodPost, "/v1/year-end/close", map[string]any{"user_id": userID.String(), "year": year})
+	}
+	netIncome := func() int64 {
+		rec := doJSON(h, http.MethodGet, "/v1/reports/income-statement?user_id="+userID.String()+"&from=2024-01-01T00:00:00Z&to=2024-12-31T23:59:59Z", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("income statement expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var is incomeStatementResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &is)
+		if len(is.Sections) != 1 {
+			t.Fatalf("expected one USD section: %s", rec.Body.String())
+		}
+		return is.Sections[0].NetIncomeMinor
+	}
+	post(1000, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	post(400, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	rec := closeYear(2024)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("close expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res yearEndCloseResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+
+	// The reversal that reopens the year is not activity either
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": res.Entries[0].ID, "date": res.YearEnd.Format(time.RFC3339)})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reverse closing expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := netIncome(); got != 1000 {
+		t.Fatalf("expected 2024 net income 1000 after reopening, got %d", got)
+	}
+
+	// 2025 cannot close while 2024's income is still open
+	rec = closeYear(2025)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "earlier_year_open") {
+		t.Fatalf("expected 422 earlier_year_open, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = closeYear(2024); rec.Code != http.StatusCreated {
+		t.Fatalf("re-close expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := netIncome(); got != 1000 {
+		t.Fatalf("expected 2024 net income 1000 after re-closing, got %d", got)
+	}
+
+	// 2025 closes its own activity only
+	rec = closeYear(2025)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("close 2025 expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res25 yearEndCloseResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res25)
+	for _, ln := range res25.Entries[0].Lines {
+		if ln.AccountID == income.ID && ln.AmountMinor != 400 {
+			t.Fatalf("expected 2025 close to zero 400 of income, got %d", ln.AmountMinor)
+		}
+	}
+}
+
+func TestEntries_CrossCurrencyWithRate(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	gbp := ledger.Account{ID: uuid.New(), UserID: userID, Name: "UK Bank", Currency: "GBP", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Barclays", Active: true}
+	store.SeedAccount(gbp)
+	entry := func(debitMinor int64, gbpLine map[string]any) map[string]any {
+		gbpLine["account_id"] = gbp.ID.String()
+		gbpLine["side"] = "credit"
+		gbpLine["amount_minor"] = 1000
+		return map[string]any{
+			"user_id": userID.String(), "date": time.Now().UTC().Format(time.RFC3339), "currency": "USD", "category": "transfers",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": debitMinor},
+				gbpLine,
+			},
+		}
+	}
+
+	// 10.00 GBP at 1.2705 converts to 12.70/12.71 USD; 1270 balances within tolerance
+	rec := doJSON(h, http.MethodPost, "/v1/entries", entry(1270, map[string]any{"currency": "GBP", "exchange_rate": "1.2705"}))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+	var found bool
+	for _, ln := range created.Lines {
+		if ln.AccountID == gbp.ID {
+			found = true
+			if ln.Currency != "GBP" || ln.AmountMinor != 1000 || ln.ExchangeRate == nil || ln.EntryAmountMinor < 1270 || ln.EntryAmountMinor > 1271 {
+				t.Fatalf("unexpected cross-currency line: %+v", ln)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("GBP line missing from response")
+	}
+
+	// outside tolerance -> unbalanced
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry(1280, map[string]any{"currency": "GBP", "exchange_rate": "1.2705"}))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for imbalance, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var e errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if e.Code != "unbalanced_entry" {
+		t.Fatalf("expected unbalanced_entry, got %q", e.Code)
+	}
+
+	// missing rate -> currency_mismatch
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry(1270, map[string]any{"currency": "GBP"}))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for missing rate, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if e.Code != "currency_mismatch" {
+		t.Fatalf("expected currency_mismatch, got %q", e.Code)
+	}
+
+	// malformed rate -> 400
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry(1270, map[string]any{"currency": "GBP", "exchange_rate": "abc"}))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad rate, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// unknown line currency -> 400
+	rec = doJSON(h, http.MethodPost, "/v1/entries", entry(1270, map[string]any{"currency": "G8P", "exchange_rate": "1.2705"}))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid currency") {
+		t.Fatalf("expected 400 invalid currency, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestFX_RevaluationRepeatableAndReversible(t *testing.T) {
+	store, h, userID, _, _ := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "UK Bank", Currency: "GBP", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Barclays", Active: true}
+	gbpIncome := ledger.Account{ID: uuid.New(), UserID: userID, Name: "UK Salary", Currency: "GBP", Type: ledger.AccountTypeRevenue, Group: "salary", Vendor: "UK Employer", Active: true}
+	fxGain := ledger.Account{ID: uuid.New(), UserID: userID, Name: "FX Gains", Currency: "USD", Type: ledger.AccountTypeRevenue, Group: "other_income", Vendor: "FX", Active: true}
+	store.SeedAccount(bank)
+	store.SeedAccount(gbpIncome)
+	store.SeedAccount(fxGain)
+
+	rec := doJSON(h, http.MethodPost, "/v1/fx/rates", map[string]any{
+		"user_id": userID.String(),
+		"rates":   []map[string]any{{"base": "GBP", "quote": "USD", "date": "2025-01-01", "rate": "1.25"}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post rates expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/fx/rates/csv?user_id="+userID.String(), strings.NewReader("date,base,quote,rate\n2025-01-31,GBP,USD,1.30\n"))
+	req.Header.Set("Content-Type", "text/csv")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("csv upload expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-01-10T00:00:00Z", "currency": "GBP", "category": "income",
+		"lines": []map[string]any{
+			{"account_id": bank.ID.String(), "side": "debit", "amount_minor": 100000},
+			{"account_id": gbpIncome.ID.String(), "side": "credit", "amount_minor": 100000},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	run := map[string]any{"user_id": userID.String(), "as_of": "2025-01-31T23:59:59Z", "reporting_currency": "USD", "gain_loss_account_id": fxGain.ID.String()}
+	rec = doJSON(h, http.MethodPost, "/v1/fx/revaluations", run)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("revaluation expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res revaluationResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Adjustments) != 1 || res.Adjustments[0].AccountID != bank.ID || res.Adjustments[0].BookedMinor != 125000 || res.Adjustments[0].ValueMinor != 130000 || res.Adjustments[0].AdjustmentMinor != 5000 {
+		t.Fatalf("unexpected adjustments: %+v", res.Adjustments)
+	}
+	if res.Entry == nil {
+		t.Fatalf("expected revaluation entry")
+	}
+	for _, ln := range res.Entry.Lines {
+		if ln.AccountID == fxGain.ID && (ln.Side != ledger.SideCredit || ln.AmountMinor != 5000) {
+			t.Fatalf("expected 50.00 credit to gain account, got %+v", ln)
+		}
+	}
+
+	// re-running for the same date posts nothing
+	rec = doJSON(h, http.MethodPost, "/v1/fx/revaluations", run)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rerun expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rerun revaluationResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rerun)
+	if rerun.Entry != nil || rerun.Adjustments[0].AdjustmentMinor != 0 {
+		t.Fatalf("rerun should not post: %+v", rerun)
+	}
+
+	// reverse at the start of the next period (defaults to Feb 1)
+	rec = doJSON(h, http.MethodPost, "/v1/fx/revaluations/reverse", map[string]any{"user_id": userID.String(), "as_of": "2025-01-31T23:59:59Z", "reporting_currency": "USD"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reverse expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var reversals []entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &reversals)
+	if len(reversals) != 1 || !reversals[0].Date.Equal(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected reversals: %+v", reversals)
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/fx/revaluations/reverse", map[string]any{"user_id": userID.String(), "as_of": "2025-01-31T23:59:59Z", "reporting_currency": "USD"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("second reverse expected 404, got %d", rec.Code)
+	}
+
+	// nothing to revalue before the first posting
+	run["as_of"] = "2024-12-31T00:00:00Z"
+	rec = doJSON(h, http.MethodPost, "/v1/fx/revaluations", run)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("revaluation before any postings expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// a foreign balance without a rate is reported and skipped; the others still run
+	eur := ledger.Account{ID: uuid.New(), UserID: userID, Name: "EU Bank", Currency: "EUR", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "ING", Active: true}
+	eurIncome := ledger.Account{ID: uuid.New(), UserID: userID, Name: "EU Salary", Currency: "EUR", Type: ledger.AccountTypeRevenue, Group: "salary", Vendor: "EU Employer", Active: true}
+	store.SeedAccount(eur)
+	store.SeedAccount(eurIncome)
+	rec = doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-01-15T00:00:00Z", "currency": "EUR", "category": "income",
+		"lines": []map[string]any{
+			{"account_id": eur.ID.String(), "side": "debit", "amount_minor": 500},
+			{"account_id": eurIncome.ID.String(), "side": "credit", "amount_minor": 500},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create EUR entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	run["as_of"] = "2025-01-31T23:59:59Z"
+	missing := func(wantDay int) {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/fx/revaluations", run)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("revaluation with a missing rate expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var res revaluationResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &res)
+		if len(res.Adjustments) != 1 || res.Adjustments[0].AccountID != bank.ID {
+			t.Fatalf("expected the GBP account to be revalued, got %+v", res.Adjustments)
+		}
+		want := time.Date(2025, 1, wantDay, 0, 0, 0, 0, time.UTC)
+		if len(res.MissingRates) != 1 || res.MissingRates[0].AccountID != eur.ID || res.MissingRates[0].Currency != "EUR" || !res.MissingRates[0].Date.Equal(want) {
+			t.Fatalf("expected EUR rate missing from %s, got %+v", want, res.MissingRates)
+		}
+	}
+	missing(15)
+	// a closing rate alone still leaves the booking date uncovered
+	rec = doJSON(h, http.MethodPost, "/v1/fx/rates", map[string]any{
+		"user_id": userID.String(),
+		"rates":   []map[string]any{{"base": "EUR", "quote": "USD", "date": "2025-01-20", "rate": "1.10"}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post rates expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	missing(15)
+}
+
+func TestTrialBalance_ReportingCurrencyTiesOut(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "UK Bank", Currency: "GBP", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Barclays", Active: true}
+	gbpIncome := ledger.Account{ID: uuid.New(), UserID: userID, Name: "UK Salary", Currency: "GBP", Type: ledger.AccountTypeRevenue, Group: "salary", Vendor: "UK Employer", Active: true}
+	store.SeedAccount(bank)
+	store.SeedAccount(gbpIncome)
+	post := func(body map[string]any) {
+		t.Helper()
+		body["user_id"] = userID.String()
+		body["date"] = "2025-03-01T00:00:00Z"
+		body["category"] = "general"
+		if rec := doJSON(h, http.MethodPost, "/v1/entries", body); rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	post(map[string]any{"currency": "USD", "lines": []map[string]any{
+		{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 5000},
+		{"account_id": income.ID.String(), "side": "credit", "amount_minor": 5000},
+	}})
+	post(map[string]any{"currency": "GBP", "lines": []map[string]any{
+		{"account_id": bank.ID.String(), "side": "debit", "amount_minor": 10000},
+		{"account_id": gbpIncome.ID.String(), "side": "credit", "amount_minor": 10000},
+	}})
+	// transfer 12.50 USD into 10.00 GBP at the booked rate of 1.25
+	post(map[string]any{"currency": "USD", "lines": []map[string]any{
+		{"account_id": bank.ID.String(), "side": "debit", "amount_minor": 1000, "currency": "GBP", "exchange_rate": "1.25"},
+		{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 1250},
+	}})
+
+	url := "/v1/trial-balance?user_id=" + userID.String() + "&as_of=2025-03-31T00:00:00Z&reporting_currency=usd"
+	if rec := doJSON(h, http.MethodGet, url, nil); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 without rates, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := doJSON(h, http.MethodPost, "/v1/fx/rates", map[string]any{
+		"user_id": userID.String(),
+		"rates":   []map[string]any{{"base": "GBP", "quote": "USD", "date": "2025-03-31", "rate": "1.30"}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post rates expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodGet, url, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var tb trialBalanceResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &tb)
+	if tb.ReportingCurrency != "USD" || tb.Consolidated == nil || len(tb.Groups) != 2 {
+		t.Fatalf("unexpected response: %s", rec.Body.String())
+	}
+	c := tb.Consolidated
+	if c.TotalDebitMinor != c.TotalCreditMinor {
+		t.Fatalf("consolidated totals do not tie: %d != %d", c.TotalDebitMinor, c.TotalCreditMinor)
+	}
+	for _, a := range c.Accounts {
+		if a.AccountID == bank.ID && (a.DebitMinor != 11000 || a.ReportingDebitMinor != 14300) {
+			t.Fatalf("unexpected bank translation: %+v", a)
+		}
+	}
+	diffs := map[string]int64{}
+	for _, d := range c.TranslationDifferences {
+		diffs[d.Currency] = d.DebitMinor - d.CreditMinor
+	}
+	// GBP nets +10.00 (-13.00 USD translated); USD nets -12.50 (+12.50): a 0.50 translation gain (net credit) overall
+	if diffs["GBP"] != -1300 || diffs["USD"] != 1250 {
+		t.Fatalf("unexpected translation differences: %+v", c.TranslationDifferences)
+	}
+}
+
+func TestReports_BalanceSheetBalancesWithCurrentEarnings(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	owner := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Owner", Currency: "USD", Type: ledger.AccountTypeEquity, Group: "owner_equity", Vendor: "Me", Active: true}
+	loan := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Loan", Currency: "USD", Type: ledger.AccountTypeLiability, Group: "loan", Vendor: "Bank", Active: true}
+	groceries := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Groceries", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Market", Active: true}
+	for _, a := range []ledger.Account{owner, loan, groceries} {
+		store.SeedAccount(a)
+	}
+	post := func(debit, credit uuid.UUID, amt int64) {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-02-01T00:00:00Z", "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": debit.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": credit.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	post(cash.ID, owner.ID, 10000)
+	post(cash.ID, income.ID, 5000)
+	post(groceries.ID, cash.ID, 2000)
+	post(cash.ID, loan.ID, 3000)
+
+	rec := doJSON(h, http.MethodGet, "/v1/reports/balance-sheet?user_id="+userID.String()+"&as_of=2025-02-28T00:00:00Z", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var bs balanceSheetResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &bs)
+	if len(bs.Sections) != 1 || bs.Sections[0].Currency != "USD" {
+		t.Fatalf("expected one USD section: %s", rec.Body.String())
+	}
+	sec := bs.Sections[0]
+	if sec.Assets.TotalMinor != 16000 || sec.Liabilities.TotalMinor != 3000 || sec.Equity.TotalMinor != 13000 {
+		t.Fatalf("unexpected totals: assets=%d liabilities=%d equity=%d", sec.Assets.TotalMinor, sec.Liabilities.TotalMinor, sec.Equity.TotalMinor)
+	}
+	if sec.TotalLiabilitiesAndEquityMinor != sec.Assets.TotalMinor {
+		t.Fatalf("balance sheet does not balance: %s", rec.Body.String())
+	}
+	var earnings *statementLineResponse
+	for _, g := range sec.Equity.Groups {
+		if g.Group == "current_earnings" {
+			earnings = &g.Lines[0]
+		}
+	}
+	if earnings == nil || !earnings.Computed || earnings.AmountMinor != 3000 || earnings.AccountID != nil {
+		t.Fatalf("expected computed current earnings of 3000: %+v", sec.Equity.Groups)
+	}
+
+	// before any activity: no sections
+	rec = doJSON(h, http.MethodGet, "/v1/reports/balance-sheet?user_id="+userID.String()+"&as_of=2025-01-01T00:00:00Z", nil)
+	_ = json.Unmarshal(rec.Body.Bytes(), &bs)
+	if rec.Code != http.StatusOK || len(bs.Sections) != 0 {
+		t.Fatalf("expected empty balance sheet, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReports_IncomeStatementQuarterlyWithReclassify(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	groceries := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Groceries", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Market", Active: true}
+	dining := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Dining", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "eating_out", Vendor: "Cafe", Active: true}
+	store.SeedAccount(groceries)
+	store.SeedAccount(dining)
+	post := func(debit, credit uuid.UUID, amt int64, date string) uuid.UUID {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": debit.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": credit.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e.ID
+	}
+	post(cash.ID, income.ID, 5000, "2025-01-15T00:00:00Z")
+	post(groceries.ID, cash.ID, 1000, "2025-02-10T00:00:00Z")
+	wrong := post(groceries.ID, cash.ID, 800, "2025-04-20T00:00:00Z")
+	// move the April purchase to dining; the reversal and correction both land in Q2
+	rec := doJSON(h, http.MethodPost, "/v1/entries/reclassify", map[string]any{
+		"user_id": userID.String(), "entry_id": wrong.String(), "date": "2025-04-21T00:00:00Z",
+		"lines": []map[string]any{
+			{"account_id": dining.ID.String(), "side": "debit", "amount_minor": 800},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 800},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reclassify expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// outside the range
+	post(groceries.ID, cash.ID, 300, "2025-07-01T00:00:00Z")
+
+	rec = doJSON(h, http.MethodGet, "/v1/reports/income-statement?user_id="+userID.String()+"&from=2025-01-01T00:00:00Z&to=2025-06-30T23:59:59Z&columns=quarterly", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var is incomeStatementResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &is)
+	if len(is.Columns) != 2 || is.Columns[0].Label != "2025-Q1" || is.Columns[1].Label != "2025-Q2" {
+		t.Fatalf("unexpected columns: %+v", is.Columns)
+	}
+	if len(is.Sections) != 1 {
+		t.Fatalf("expected one USD section: %s", rec.Body.String())
+	}
+	sec := is.Sections[0]
+	if sec.Revenue.TotalMinor != 5000 || sec.Expenses.TotalMinor != 1800 || sec.NetIncomeMinor != 3200 {
+		t.Fatalf("unexpected totals: revenue=%d expenses=%d net=%d", sec.Revenue.TotalMinor, sec.Expenses.TotalMinor, sec.NetIncomeMinor)
+	}
+	if len(sec.NetIncomeColumnsMinor) != 2 || sec.NetIncomeColumnsMinor[0] != 4000 || sec.NetIncomeColumnsMinor[1] != -800 {
+		t.Fatalf("unexpected net income columns: %v", sec.NetIncomeColumnsMinor)
+	}
+	for _, g := range sec.Expenses.Groups {
+		if g.Group == "groceries" && g.SubtotalMinor != 1000 {
+			t.Fatalf("reclassified groceries should net out, got %d", g.SubtotalMinor)
+		}
+		if g.Group == "eating_out" && g.SubtotalMinor != 800 {
+			t.Fatalf("expected 800 dining, got %d", g.SubtotalMinor)
+		}
+	}
+
+	if rec := doJSON(h, http.MethodGet, "/v1/reports/income-statement?user_id="+userID.String()+"&from=2025-01-01T00:00:00Z&to=2025-06-30T00:00:00Z&columns=weekly", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown columns, got %d", rec.Code)
+	}
+}
+
+func TestReports_CashFlowClassifiesAndReconciles(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Checking", Currency: "USD", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Chase", Active: true}
+	broker := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Brokerage", Currency: "USD", Type: ledger.AccountTypeAsset, Group: "investment", Vendor: "Vanguard", Active: true}
+	loan := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Loan", Currency: "USD", Type: ledger.AccountTypeLiability, Group: "loan", Vendor: "Bank", Active: true}
+	rent := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Rent", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "rent", Vendor: "Landlord", Active: true}
+	for _, a := range []ledger.Account{bank, broker, loan, rent} {
+		store.SeedAccount(a)
+	}
+	post := func(date string, lines ...map[string]any) {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "category": "general", "lines": lines,
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	line := func(acc ledger.Account, side string, amt int64) map[string]any {
+		return map[string]any{"account_id": acc.ID.String(), "side": side, "amount_minor": amt}
+	}
+	post("2025-01-05T00:00:00Z", line(cash, "debit", 1000), line(income, "credit", 1000)) // before the range
+	post("2025-02-01T00:00:00Z", line(bank, "debit", 5000), line(income, "credit", 5000))
+	post("2025-02-02T00:00:00Z", line(bank, "debit", 2000), line(loan, "credit", 2000))
+	post("2025-02-03T00:00:00Z", line(broker, "debit", 1500), line(bank, "credit", 1500))
+	// one payment covering rent and a loan repayment
+	post("2025-02-04T00:00:00Z", line(rent, "debit", 1200), line(loan, "debit", 300), line(bank, "credit", 1500))
+	post("2025-02-05T00:00:00Z", line(cash, "debit", 400), line(bank, "credit", 400))
+
+	rec := doJSON(h, http.MethodGet, "/v1/reports/cash-flow?user_id="+userID.String()+"&from=2025-02-01T00:00:00Z&to=2025-02-28T23:59:59Z", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var cf cashFlowResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &cf)
+	if len(cf.Sections) != 1 {
+		t.Fatalf("expected one USD section: %s", rec.Body.String())
+	}
+	sec := cf.Sections[0]
+	totals := map[string]int64{}
+	for _, a := range sec.Activities {
+		totals[a.Activity] = a.TotalMinor
+	}
+	if totals["operating"] != 3800 || totals["investing"] != -1500 || totals["financing"] != 1700 || totals["transfers"] != 0 {
+		t.Fatalf("unexpected activity totals: %+v", totals)
+	}
+	if sec.OpeningMinor != 1000 || sec.NetChangeMinor != 4000 || sec.ClosingMinor != 5000 || sec.OpeningMinor+sec.NetChangeMinor != sec.ClosingMinor {
+		t.Fatalf("cash does not reconcile: opening=%d net=%d closing=%d", sec.OpeningMinor, sec.NetChangeMinor, sec.ClosingMinor)
+	}
+}
+
+func TestBudgets_VsActualCoversPathPrefix(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	market := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Market", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Market", Active: true}
+	bakery := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Bakery", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Bakery", Active: true}
+	rent := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Rent", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "rent", Vendor: "Landlord", Active: true}
+	for _, a := range []ledger.Account{market, bakery, rent} {
+		store.SeedAccount(a)
+	}
+	post := func(date string, acc ledger.Account, amt int64) {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": acc.ID.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": cash.ID.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	post("2025-02-27T00:00:00Z", market, 9999) // previous month
+	post("2025-03-03T00:00:00Z", market, 4000)
+	post("2025-03-10T00:00:00Z", bakery, 2000)
+	post("2025-03-11T00:00:00Z", rent, 5000)
+
+	rec := doJSON(h, http.MethodPost, "/v1/budgets", map[string]any{
+		"user_id": userID.String(), "path": "Expense:Groceries", "period": "monthly", "start": "2025-03-15T00:00:00Z", "currency": "USD", "amount_minor": 5000,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create budget expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var groceries budgetResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &groceries)
+	if groceries.Path != "expense:groceries" || !groceries.Start.Equal(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected normalized path and start: %+v", groceries)
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/budgets", map[string]any{
+		"user_id": userID.String(), "path": "expense:groceries", "period": "monthly", "start": "2025-03-01T00:00:00Z", "currency": "USD", "amount_minor": 1,
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate budget expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/budgets", map[string]any{
+		"user_id": userID.String(), "account_id": rent.ID.String(), "period": "yearly", "start": "2025-01-01T00:00:00Z", "amount_minor": 60000,
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create account budget expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/budgets", map[string]any{
+		"user_id": userID.String(), "path": "asset:cash", "period": "monthly", "start": "2025-03-01T00:00:00Z", "currency": "USD", "amount_minor": 1,
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("asset budget expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(h, http.MethodGet, "/v1/reports/budget-vs-actual?user_id="+userID.String()+"&as_of=2025-03-31T00:00:00Z", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rep budgetVsActualResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rep)
+	byPath := map[string]budgetVsActualLine{}
+	for _, l := range rep.Budgets {
+		byPath[l.Path] = l
+	}
+	g := byPath["expense:groceries"]
+	if g.ActualMinor != 6000 || g.RemainingMinor != -1000 || !g.OverBudget || g.PercentUsed != 120 {
+		t.Fatalf("unexpected groceries line: %+v", g)
+	}
+	r := byPath["expense:rent:landlord"]
+	if r.ActualMinor != 5000 || r.RemainingMinor != 55000 || r.OverBudget {
+		t.Fatalf("unexpected rent line: %+v", r)
+	}
+
+	rec = doJSON(h, http.MethodPatch, "/v1/budgets/"+groceries.ID.String()+"?user_id="+userID.String(), map[string]any{"amount_minor": 8000})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch budget expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodDelete, "/v1/budgets/"+groceries.ID.String()+"?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete budget expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodGet, "/v1/budgets/"+groceries.ID.String()+"?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted budget expected 404, got %d", rec.Code)
+	}
+}
+
+func TestSchedules_PreviewAndIdempotentRuns(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	rec := doJSON(h, http.MethodPost, "/v1/schedules", map[string]any{
+		"user_id": userID.String(), "name": "Salary", "rule": "FREQ=MONTHLY;BYMONTHDAY=-1",
+		"start": "2025-01-01T09:00:00Z", "currency": "USD", "memo": "salary", "category": "income",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 300000},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 300000},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create schedule expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var sc scheduleResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &sc)
+	if sc.NextRun == nil || !sc.NextRun.Equal(time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next_run: %+v", sc.NextRun)
+	}
+
+	rec = doJSON(h, http.MethodGet, "/v1/schedules/"+sc.ID.String()+"/preview?user_id="+userID.String()+"&from=2025-02-01T00:00:00Z&count=2", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("preview expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var pv schedulePreviewResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &pv)
+	if len(pv.Occurrences) != 2 || pv.Occurrences[0].Day() != 28 || pv.Occurrences[1].Day() != 31 {
+		t.Fatalf("unexpected preview: %+v", pv.Occurrences)
+	}
+
+	// Unbalanced templates are rejected like entry posts.
+	rec = doJSON(h, http.MethodPost, "/v1/schedules", map[string]any{
+		"user_id": userID.String(), "name": "Bad", "rule": "weekly", "start": "2025-01-01T00:00:00Z", "currency": "USD", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 100},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 90},
+		},
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unbalanced template expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/schedules", map[string]any{
+		"user_id": userID.String(), "name": "Bad", "rule": "FREQ=HOURLY", "start": "2025-01-01T00:00:00Z", "currency": "USD", "category": "general",
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid rule expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// The scheduler in cmd/main.go runs a separate service over the same store.
+	runner := schedule.New(store, store, journal.New(store, store), store)
+	now := time.Date(2025, 3, 31, 12, 0, 0, 0, time.UTC)
+	res, err := runner.RunDue(context.Background(), now)
+	if err != nil || len(res.Posted) != 3 || len(res.Errors) != 0 {
+		t.Fatalf("first run: posted=%d errs=%v err=%v", len(res.Posted), res.Errors, err)
+	}
+	// A restart that lost the cursor must not double post.
+	cur, _ := store.GetSchedule(context.Background(), userID, sc.ID)
+	rewind := time.Date(2025, 1, 31, 9, 0, 0, 0, time.UTC)
+	cur.NextRun = &rewind
+	_, _ = store.UpdateSchedule(context.Background(), cur)
+	res, err = runner.RunDue(context.Background(), now)
+	if err != nil || len(res.Posted) != 0 {
+		t.Fatalf("rerun posted %d entries (err=%v)", len(res.Posted), err)
+	}
+	entries, _ := store.ListEntries(context.Background(), userID)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 posted entries, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if e.Metadata[schedule.MetaScheduleID] != sc.ID.String() {
+			t.Fatalf("entry missing schedule tag: %+v", e.Metadata)
+		}
+	}
+
+	rec = doJSON(h, http.MethodPatch, "/v1/schedules/"+sc.ID.String()+"?user_id="+userID.String(), map[string]any{"active": false})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	res, _ = runner.RunDue(context.Background(), now.AddDate(0, 2, 0))
+	if len(res.Posted) != 0 {
+		t.Fatalf("inactive schedule posted %d entries", len(res.Posted))
+	}
+	rec = doJSON(h, http.MethodDelete, "/v1/schedules/"+sc.ID.String()+"?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSchedules_ConcurrentRunsPostOnce(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	rec := doJSON(h, http.MethodPost, "/v1/schedules", map[string]any{
+		"user_id": userID.String(), "name": "Rent", "rule": "FREQ=MONTHLY;BYMONTHDAY=1",
+		"start": "2025-01-01T09:00:00.750Z", "currency": "USD", "memo": "rent", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 1000},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 1000},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create schedule expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var sc scheduleResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &sc)
+	// A fractional start must not skip the first occurrence.
+	if sc.NextRun == nil || !sc.NextRun.Equal(time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next_run: %+v", sc.NextRun)
+	}
+
+	// Several scheduler instances over one store race for the same occurrences.
+	now := time.Date(2025, 6, 15, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runner := schedule.New(store, store, journal.New(store, store), store)
+			if _, err := runner.RunDue(context.Background(), now); err != nil {
+				t.Errorf("run: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	entries, _ := store.ListEntries(context.Background(), userID)
+	seen := map[string]bool{}
+	for _, e := range entries {
+		occ := e.Metadata[schedule.MetaOccurrence]
+		if seen[occ] {
+			t.Fatalf("occurrence %s posted twice", occ)
+		}
+		seen[occ] = true
+	}
+	if len(entries) != 6 {
+		t.Fatalf("expected 6 posted entries, got %d", len(entries))
+	}
+
+	// A rule that no longer parses is reported and recorded, not skipped silently.
+	cur, _ := store.GetSchedule(context.Background(), userID, sc.ID)
+	cur.Rule = "FREQ=HOURLY"
+	_, _ = store.UpdateSchedule(context.Background(), cur)
+	runner := schedule.New(store, store, journal.New(store, store), store)
+	res, err := runner.RunDue(context.Background(), now.AddDate(0, 1, 0))
+	if err != nil || len(res.Errors) != 1 || res.Errors[0].ScheduleID != sc.ID {
+		t.Fatalf("expected a run error for the bad rule, got %+v (err=%v)", res.Errors, err)
+	}
+	cur, _ = store.GetSchedule(context.Background(), userID, sc.ID)
+	if cur.LastError == "" {
+		t.Fatalf("expected last_error to be set")
+	}
+}
+
+func TestRules_RewriteEntriesAndDryRun(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	suspense := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Suspense", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "uncategorized", Vendor: "Suspense", Active: true}
+	groceries := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Groceries", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Tesco", Active: true}
+	store.SeedAccount(suspense)
+	store.SeedAccount(groceries)
+	spend := func(memo string, amt int64) entryResp {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-01-10T00:00:00Z", "currency": "USD", "memo": memo, "category": "uncategorized",
+			"metadata": map[string]string{"tracker.rule_id": "bank-feed"},
+			"lines": []map[string]any{
+				{"account_id": suspense.ID.String(), "side": "debit", "amount_minor": amt},
+				{"account_id": cash.ID.String(), "side": "credit", "amount_minor": amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResp
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	before := spend("TESCO STORES 1234", 2500)
+
+	rule := map[string]any{
+		"name": "Tesco", "priority": 10,
+		"match": map[string]any{
+			"memo_pattern": "^tesco", "metadata": map[string]string{"tracker.rule_id": ""},
+			"max_amount_minor": 10000, "source_account_id": cash.ID.String(),
+		},
+		"actions": map[string]any{"category": "groceries", "counter_account_id": groceries.ID.String(), "metadata": map[string]string{"merchant": "tesco"}},
+	}
+	rec := doJSON(h, http.MethodPost, "/v1/rules/dry-run", map[string]any{"user_id": userID.String(), "rule": rule})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("dry-run expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var dr ruleDryRunResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &dr)
+	if len(dr.Matches) != 1 || dr.Matches[0].EntryID.String() != before.ID || dr.Matches[0].CategoryAfter != "groceries" ||
+		len(dr.Matches[0].LineChanges) != 1 || dr.Matches[0].LineChanges[0].ToAccountID != groceries.ID {
+		t.Fatalf("unexpected dry-run: %s", rec.Body.String())
+	}
+
+	rule["user_id"] = userID.String()
+	rec = doJSON(h, http.MethodPost, "/v1/rules", rule)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create rule expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var created ruleResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &created)
+
+	e := spend("Tesco Express", 1800)
+	if e.Category != "groceries" {
+		t.Fatalf("expected category rewritten, got %q", e.Category)
+	}
+	for _, ln := range e.Lines {
+		if ln.Side == "debit" && ln.AccountID != groceries.ID.String() {
+			t.Fatalf("expected counter line moved to groceries, got %s", ln.AccountID)
+		}
+	}
+	stored, _ := store.GetEntry(context.Background(), userID, uuid.MustParse(e.ID))
+	if stored.Metadata["merchant"] != "tesco" || stored.Metadata["ledger.rule_ids"] != created.ID.String() {
+		t.Fatalf("unexpected metadata: %+v", stored.Metadata)
+	}
+	// Above the amount range: untouched.
+	if big := spend("TESCO STORES", 20000); big.Category != "uncategorized" {
+		t.Fatalf("rule should not match large amount, got %q", big.Category)
+	}
+	// A split spend has two counter lines: the rule cannot pick one, so the
+	// lines stay put while its other actions apply.
+	rec = doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-01-11T00:00:00Z", "currency": "USD", "memo": "TESCO split", "category": "uncategorized",
+		"metadata": map[string]string{"tracker.rule_id": "bank-feed"},
+		"lines": []map[string]any{
+			{"account_id": suspense.ID.String(), "side": "debit", "amount_minor": 600},
+			{"account_id": income.ID.String(), "side": "debit", "amount_minor": 400},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 1000},
+		},
+	})
+	var split entryResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &split)
+	if rec.Code != http.StatusCreated || split.Category != "groceries" {
+		t.Fatalf("split entry expected 201 with the category rewritten, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, ln := range split.Lines {
+		if ln.AccountID == groceries.ID.String() {
+			t.Fatalf("split entry lines should not move: %+v", split.Lines)
+		}
+	}
+
+	rec = doJSON(h, http.MethodPost, "/v1/rules", map[string]any{
+		"user_id": userID.String(), "name": "Bad", "actions": map[string]any{"counter_account_id": income.ID.String()},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("counter without source expected 400, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPatch, "/v1/rules/"+created.ID.String()+"?user_id="+userID.String(), map[string]any{"active": false})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if off := spend("TESCO", 100); off.Category != "uncategorized" {
+		t.Fatalf("inactive rule applied")
+	}
+}
+
+func TestImports_CSVUsesProfileRulesAndDetectsReimport(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	rec := doJSON(h, http.MethodPost, "/v1/imports/csv/profiles", map[string]any{
+		"user_id": userID.String(), "name": "Bank",
+		"mapping": map[string]any{
+			"date_column": "Date", "date_format": "DD/MM/YYYY", "amount_column": "Amount",
+			"memo_columns": []string{"Description"}, "reference_column": "Ref",
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create profile expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var profile importProfileResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &profile)
+	rec = doJSON(h, http.MethodPost, "/v1/rules", map[string]any{
+		"user_id": userID.String(), "name": "Salary",
+		"match":   map[string]any{"memo_pattern": "payroll", "source_account_id": cash.ID.String()},
+		"actions": map[string]any{"category": "income", "counter_account_id": income.ID.String()},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create rule expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	upload := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports/csv?user_id="+userID.String()+"&profile_id="+profile.ID.String()+"&account_id="+cash.ID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	file := "Date,Description,Amount,Ref\n" +
+		"31/01/2025,ACME PAYROLL,\"1,000.00\",TX1\n" +
+		"02/02/2025,Coffee,-3.50,\n" +
+		"02/02/2025,Coffee,-3.50,\n"
+	rec = upload(file)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Imported   []entryResp       `json:"imported"`
+		Duplicates []importDuplicate `json:"duplicates"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 3 || len(res.Duplicates) != 0 {
+		t.Fatalf("expected 3 imported rows, got %s", rec.Body.String())
+	}
+	accs, _ := store.ListAccounts(context.Background(), userID)
+	var suspense ledger.Account
+	for _, a := range accs {
+		if a.Path() == "equity:suspense:system" {
+			suspense = a
+		}
+	}
+	if suspense.ID == uuid.Nil {
+		t.Fatalf("expected suspense account to be created")
+	}
+	hashes := map[string]bool{}
+	for _, e := range res.Imported {
+		stored, _ := store.GetEntry(context.Background(), userID, uuid.MustParse(e.ID))
+		hashes[stored.Metadata["tracker.input_hash"]] = true
+		counter := suspense.ID.String()
+		if e.Memo == "ACME PAYROLL" {
+			counter = income.ID.String()
+			if stored.Metadata["tracker.source_txn_id"] != "TX1" || e.Category != "income" {
+				t.Fatalf("unexpected salary entry: %+v %+v", e, stored.Metadata)
+			}
+		}
+		for _, ln := range e.Lines {
+			if ln.AccountID != cash.ID.String() && ln.AccountID != counter {
+				t.Fatalf("%s: unexpected counter account %s", e.Memo, ln.AccountID)
+			}
+			if ln.AccountID == cash.ID.String() && (ln.Side == "debit") != (e.Memo == "ACME PAYROLL") {
+				t.Fatalf("%s: wrong bank side %s", e.Memo, ln.Side)
+			}
+		}
+	}
+	if len(hashes) != 3 {
+		t.Fatalf("identical rows must get distinct input hashes, got %v", hashes)
+	}
+
+	// Re-importing the same file posts nothing.
+	rec = upload(file)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("re-import expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	res.Imported, res.Duplicates = nil, nil
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 0 || len(res.Duplicates) != 3 {
+		t.Fatalf("expected 3 duplicates, got %s", rec.Body.String())
+	}
+
+	// A bad row rejects the whole file with its line number.
+	rec = upload("Date,Description,Amount,Ref\n03/02/2025,Lunch,-12.00,\n2025-02-04,Dinner,-20.00,\n")
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("bad row expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var bad importErrorsResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &bad)
+	if len(bad.Errors) != 1 || bad.Errors[0].Row != 3 || bad.Errors[0].Code != "invalid_date" {
+		t.Fatalf("unexpected errors: %s", rec.Body.String())
+	}
+	if entries, _ := store.ListEntries(context.Background(), userID); len(entries) != 3 {
+		t.Fatalf("expected no entries posted from rejected file, have %d", len(entries))
+	}
+}
+
+func TestImports_ConcurrentUploadsPostEachRowOnce(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	rec := doJSON(h, http.MethodPost, "/v1/imports/csv/profiles", map[string]any{
+		"user_id": userID.String(), "name": "Bank",
+		"mapping": map[string]any{"date_column": "Date", "amount_column": "Amount", "memo_columns": []string{"Description"}},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create profile expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var profile importProfileResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &profile)
+	file := "Date,Description,Amount\n2025-03-01,Shop,-1.00\n2025-03-02,Shop,-2.00\n2025-03-03,Shop,-3.00\n2025-03-04,Shop,-4.00\n2025-03-05,Shop,-5.00\n"
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/v1/imports/csv?user_id="+userID.String()+"&profile_id="+profile.ID.String()+"&account_id="+cash.ID.String(), strings.NewReader(file))
+			req.Header.Set("Content-Type", "text/csv")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != http.StatusCreated && rec.Code != http.StatusOK && rec.Code != http.StatusConflict {
+				t.Errorf("concurrent import got %d: %s", rec.Code, rec.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	entries, _ := store.ListEntries(context.Background(), userID)
+	if len(entries) != 5 {
+		t.Fatalf("expected each of 5 rows posted once, have %d entries", len(entries))
+	}
+}
+
+func TestImports_OFXDedupesByFITIDAndChecksLedgerBalance(t *testing.T) {
+	_, h, userID, cash, _ := setup(t)
+	ofxFile := func(memo string) string {
+		return "OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\n\n<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>USD\n" +
+			"<BANKACCTFROM><ACCTID>999<ACCTTYPE>CHECKING</BANKACCTFROM><BANKTRANLIST>\n" +
+			"<STMTTRN><TRNTYPE>CREDIT<DTPOSTED>20250110<TRNAMT>1000.00<FITID>F1<NAME>Payroll</STMTTRN>\n" +
+			"<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250112<TRNAMT>-12.50<FITID>F2<NAME>Cafe<MEMO>" + memo + "</STMTTRN>\n" +
+			"</BANKTRANLIST><LEDGERBAL><BALAMT>1000.00<DTASOF>20250131</LEDGERBAL></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>\n"
+	}
+	upload := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports/ofx?user_id="+userID.String()+"&account_id="+cash.ID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ofx")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	var res importResponse
+	rec := upload(ofxFile("card 1234"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 2 || len(res.Balances) != 1 {
+		t.Fatalf("unexpected import: %s", rec.Body.String())
+	}
+	if b := res.Balances[0]; b.Kind != "closing" || b.StatementMinor != 100000 || b.LedgerMinor != 98750 || b.DifferenceMinor != 1250 || b.Matches {
+		t.Fatalf("expected LEDGERBAL difference to be reported, got %+v", b)
+	}
+
+	// The bank rewrote a memo between downloads; FITIDs still match.
+	res = importResponse{}
+	rec = upload(ofxFile("CARD PAYMENT 1234"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("re-import expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 0 || len(res.Duplicates) != 2 || res.Duplicates[0].SourceTxnID != "F1" {
+		t.Fatalf("expected FITID duplicates, got %s", rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/imports/ofx?user_id="+userID.String()+"&account_id="+cash.ID.String(), strings.NewReader(strings.Replace(ofxFile(""), "USD", "EUR", 1)))
+	req.Header.Set("Content-Type", "application/x-ofx")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("currency mismatch expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestImports_Camt053MapsAccountByIBANAndChecksBalances(t *testing.T) {
+	store, h, userID, _, _ := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Operating", Currency: "USD", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Bank",
+		Metadata: map[string]string{"tracker.bank_account": "DE89 3704 0044 0532 0130 00"}}
+	store.SeedAccount(bank)
+	camt := func(iban string) string {
+		return `<?xml version="1.0"?><Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><Stmt>
+<Acct><Id><IBAN>` + iban + `</IBAN></Id><Ccy>USD</Ccy></Acct>
+<Bal><Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp><Amt Ccy="USD">0.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-02</Dt></Dt></Bal>
+<Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="USD">250.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-31</Dt></Dt></Bal>
+<Ntry><Amt Ccy="USD">300.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2025-01-02</Dt></BookgDt><AcctSvcrRef>R1</AcctSvcrRef>
+<NtryDtls><TxDtls><Refs><EndToEndId>INV-7</EndToEndId></Refs><RltdPties><Dbtr><Nm>Acme</Nm></Dbtr></RltdPties></TxDtls></NtryDtls></Ntry>
+<Ntry><Amt Ccy="USD">50.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2025-01-15</Dt></BookgDt><AcctSvcrRef>R2</AcctSvcrRef></Ntry>
+<Ntry><Amt Ccy="USD">20.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>PDNG</Sts><BookgDt><Dt>2025-01-31</Dt></BookgDt></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+	}
+	upload := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/imports/camt053?user_id="+userID.String(), strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/xml")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	rec := upload(camt("DE89370400440532013000"))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res importResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 2 {
+		t.Fatalf("expected the two booked entries, got %s", rec.Body.String())
+	}
+	first := res.Imported[0]
+	onBank := false
+	for _, l := range first.Lines {
+		onBank = onBank || l.AccountID == bank.ID
+	}
+	if first.Metadata["tracker.end_to_end_id"] != "INV-7" || first.Metadata["tracker.bank_ref"] != "R1" || first.Memo != "Acme" || !onBank {
+		t.Fatalf("unexpected imported entry: %+v", first)
+	}
+	if len(res.Balances) != 2 || !res.Balances[0].Matches || !res.Balances[1].Matches || res.Balances[1].LedgerMinor != 25000 {
+		t.Fatalf("expected matching opening and closing balances, got %+v", res.Balances)
+	}
+
+	rec = upload(camt("GB29NWBK60161331926819"))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unmapped account expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var e errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if e.Code != "unmapped_account" {
+		t.Fatalf("expected code unmapped_account, got %q", e.Code)
+	}
+}
+
+func TestImports_MT940PostsToAccount(t *testing.T) {
+	_, h, userID, cash, _ := setup(t)
+	file := ":20:S1\n:25:12345678\n:60F:C250101USD0,00\n:61:2501030103C42,00NTRFNONREF//B1\n:86:/EREF/E1/REMI/USTD//Refund/\n:62F:C250103USD42,00\n-\n"
+	req := httptest.NewRequest(http.MethodPost, "/v1/imports/mt940?user_id="+userID.String()+"&account_id="+cash.ID.String(), strings.NewReader(file))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res importResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &res)
+	if len(res.Imported) != 1 || res.Imported[0].Metadata["tracker.end_to_end_id"] != "E1" || len(res.Balances) != 2 || !res.Balances[1].Matches {
+		t.Fatalf("unexpected import: %s", rec.Body.String())
+	}
+}
+
+func TestReconciliations_MatchFinishAndLockReconciledLines(t *testing.T) {
+	_, h, userID, cash, income := setup(t)
+	post := func(date string, cashSide string, minor int64, md map[string]string) entryResponse {
+		t.Helper()
+		incomeSide := "credit"
+		if cashSide == "credit" {
+			incomeSide = "debit"
+		}
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "memo": "e", "category": "general", "metadata": md,
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": cashSide, "amount_minor": minor},
+				{"account_id": income.ID.String(), "side": incomeSide, "amount_minor": minor},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	cashLine := func(e entryResponse) lineResponse {
+		for _, l := range e.Lines {
+			if l.AccountID == cash.ID {
+				return l
+			}
+		}
+		t.Fatalf("entry %s has no cash line", e.ID)
+		return lineResponse{}
+	}
+	salary := post("2025-01-05T00:00:00Z", "debit", 5000, nil)
+	nearer := post("2025-01-10T00:00:00Z", "debit", 2000, nil)
+	tagged := post("2025-01-12T00:00:00Z", "debit", 2000, map[string]string{"tracker.bank_ref": "INV-9"})
+	fee := post("2025-01-12T00:00:00Z", "credit", 700, nil)
+
+	rec := doJSON(h, http.MethodPost, "/v1/reconciliations", map[string]any{
+		"user_id": userID.String(), "account_id": cash.ID.String(), "statement_date": "2025-01-31T00:00:00Z", "statement_balance_minor": 6300,
+		"lines": []map[string]any{
+			{"date": "2025-01-06T00:00:00Z", "amount_minor": 5000, "description": "Salary"},
+			{"date": "2025-01-10T00:00:00Z", "amount_minor": 2000, "reference": "INV-9"},
+			{"date": "2025-01-25T00:00:00Z", "amount_minor": -700, "description": "Fee"},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create reconciliation expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rc reconciliationResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rc)
+	base := "/v1/reconciliations/" + rc.ID.String()
+
+	rec = doJSON(h, http.MethodPost, "/v1/reconciliations", map[string]any{
+		"user_id": userID.String(), "account_id": cash.ID.String(), "statement_date": "2025-02-28T00:00:00Z", "statement_balance_minor": 0,
+	})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("second open reconciliation expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(h, http.MethodPost, base+"/auto-match", map[string]any{"user_id": userID.String()})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("auto-match expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var am autoMatchResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &am)
+	if am.Matched != 2 || am.Reconciliation.UnmatchedCount != 1 || am.Reconciliation.DifferenceMinor != -700 {
+		t.Fatalf("unexpected auto-match: %s", rec.Body.String())
+	}
+	// The reference wins over the closer date.
+	if id := am.Reconciliation.Lines[1].JournalLineID; id == nil || *id != cashLine(tagged).ID {
+		t.Fatalf("expected the tagged entry to match, got %v", id)
+	}
+	getEntry := func(id uuid.UUID) entryResponse {
+		rec := doJSON(h, http.MethodGet, "/v1/entries/"+id.String()+"?user_id="+userID.String(), nil)
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	if st := cashLine(getEntry(salary.ID)).Status; st != ledger.LineStatusCleared {
+		t.Fatalf("matched line status = %q, want cleared", st)
+	}
+	if st := cashLine(getEntry(nearer.ID)).Status; st != ledger.LineStatusUncleared {
+		t.Fatalf("unmatched line status = %q, want uncleared", st)
+	}
+
+	rec = doJSON(h, http.MethodPost, base+"/finish?user_id="+userID.String(), nil)
+	var e errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if rec.Code != http.StatusUnprocessableEntity || e.Code != "unmatched_lines" {
+		t.Fatalf("finish with unmatched lines expected 422 unmatched_lines, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	feeLine := am.Reconciliation.Lines[2].ID
+	rec = doJSON(h, http.MethodPost, base+"/match", map[string]any{"user_id": userID.String(), "statement_line_id": feeLine, "journal_line_id": cashLine(nearer).ID})
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if rec.Code != http.StatusUnprocessableEntity || e.Code != "not_matchable" {
+		t.Fatalf("mismatched amount expected 422 not_matchable, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, base+"/match", map[string]any{"user_id": userID.String(), "statement_line_id": feeLine, "journal_line_id": cashLine(fee).ID})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("match expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, base+"/unmatch", map[string]any{"user_id": userID.String(), "statement_line_id": feeLine})
+	if rec.Code != http.StatusOK || cashLine(getEntry(fee.ID)).Status != ledger.LineStatusUncleared {
+		t.Fatalf("unmatch expected 200 and an uncleared line, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = doJSON(h, http.MethodPost, base+"/match", map[string]any{"user_id": userID.String(), "statement_line_id": feeLine, "journal_line_id": cashLine(fee).ID})
+
+	rec = doJSON(h, http.MethodPost, base+"/finish?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("finish expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &rc)
+	if rc.Status != ledger.ReconciliationStatusFinished || rc.ReconciledBalanceMinor == nil || *rc.ReconciledBalanceMinor != 6300 {
+		t.Fatalf("unexpected finished reconciliation: %s", rec.Body.String())
+	}
+	if st := cashLine(getEntry(salary.ID)).Status; st != ledger.LineStatusReconciled {
+		t.Fatalf("finished line status = %q, want reconciled", st)
+	}
+
+	reverse := func(id uuid.UUID) *httptest.ResponseRecorder {
+		return doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": id.String()})
+	}
+	rec = reverse(salary.ID)
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if rec.Code != http.StatusUnprocessableEntity || e.Code != "reconciled" {
+		t.Fatalf("reversing a reconciled entry expected 422 reconciled, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = reverse(nearer.ID); rec.Code != http.StatusCreated {
+		t.Fatalf("reversing an uncleared entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doJSON(h, http.MethodPost, base+"/reopen?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reopen expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if st := cashLine(getEntry(salary.ID)).Status; st != ledger.LineStatusCleared {
+		t.Fatalf("reopened line status = %q, want cleared", st)
+	}
+	if rec = reverse(salary.ID); rec.Code != http.StatusCreated {
+		t.Fatalf("reverse after reopen expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBalanceAssertions_RejectBreakingWritesAndReportState(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	bonus := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Bonus", Currency: "USD", Type: ledger.AccountTypeRevenue, Group: "bonus", Vendor: "Employer"}
+	store.SeedAccount(bonus)
+	post := func(date string, minor int64) *httptest.ResponseRecorder {
+		return doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "memo": "pay", "category": "income",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": minor},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": minor},
+			},
+		})
+	}
+	rec := post("2025-01-10T12:00:00Z", 5000)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var salary entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &salary)
+
+	rec = doJSON(h, http.MethodPost, "/v1/balance-assertions", map[string]any{"user_id": userID.String(), "account_id": cash.ID.String(), "date": "2025-01-31", "amount_minor": 5000})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create assertion expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var jan assertionResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &jan)
+	if !jan.Passing || jan.ActualMinor != 5000 {
+		t.Fatalf("expected a passing assertion, got %+v", jan)
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/balance-assertions", map[string]any{"user_id": userID.String(), "account_id": cash.ID.String(), "date": "2025-01-31", "amount_minor": 1})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate assertion expected 409, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Entries dated on the assertion day count; later ones do not.
+	rec = post("2025-01-31T23:00:00Z", 100)
+	var e errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if rec.Code != http.StatusUnprocessableEntity || e.Code != "assertion_failed" {
+		t.Fatalf("breaking entry expected 422 assertion_failed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = post("2025-02-01T00:00:00Z", 100); rec.Code != http.StatusCreated {
+		t.Fatalf("later entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": salary.ID.String(), "date": "2025-01-20T00:00:00Z"})
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if rec.Code != http.StatusUnprocessableEntity || e.Code != "assertion_failed" {
+		t.Fatalf("breaking reversal expected 422 assertion_failed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	// A reclassification that leaves cash untouched is allowed.
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reclassify", map[string]any{
+		"user_id": userID.String(), "entry_id": salary.ID.String(), "date": "2025-01-20T00:00:00Z",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 5000},
+			{"account_id": bonus.ID.String(), "side": "credit", "amount_minor": 5000},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("neutral reclassify expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// An assertion that already fails accepts writes that move it closer...
+	rec = doJSON(h, http.MethodPost, "/v1/balance-assertions", map[string]any{"user_id": userID.String(), "account_id": cash.ID.String(), "date": "2025-02-28", "amount_minor": 9999, "note": "bank says"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create assertion expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = post("2025-02-10T00:00:00Z", 50); rec.Code != http.StatusCreated {
+		t.Fatalf("entry against a failing assertion expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodGet, "/v1/balance-assertions?user_id="+userID.String()+"&failing=true", nil)
+	var list assertionListResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || list.Total != 2 || list.Failing != 1 || len(list.Items) != 1 {
+		t.Fatalf("unexpected assertion list: %d %s", rec.Code, rec.Body.String())
+	}
+	if it := list.Items[0]; it.Passing || it.ActualMinor != 5150 || it.DifferenceMinor != 5150-9999 {
+		t.Fatalf("unexpected failing assertion: %+v", it)
+	}
+	// ...but a write that moves it further off is rejected.
+	rec = doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-02-10T00:00:00Z", "currency": "USD", "memo": "refund", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": income.ID.String(), "side": "debit", "amount_minor": 10},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 10},
+		},
+	})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("entry moving a failing assertion further off expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A batch is checked as a whole: drafts that each pass can break an
+	// assertion together, and drafts that cancel out leave it alone.
+	batch := func(dates []string, minors []int64) *httptest.ResponseRecorder {
+		var entries []map[string]any
+		for i, minor := range minors {
+			debit, credit := cash, income
+			if minor < 0 {
+				debit, credit, minor = income, cash, -minor
+			}
+			entries = append(entries, map[string]any{
+				"user_id": userID.String(), "date": dates[i], "currency": "USD", "category": "general",
+				"lines": []map[string]any{
+					{"account_id": debit.ID.String(), "side": "debit", "amount_minor": minor},
+					{"account_id": credit.ID.String(), "side": "credit", "amount_minor": minor},
+				},
+			})
+		}
+		r := httptest.NewRequest(http.MethodPost, "/v1/entries/batch", bytes.NewReader(mustJSON(map[string]any{"entries": entries})))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Idempotency-Key", uuid.NewString())
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, r)
+		return rr
+	}
+	if rec = batch([]string{"2025-02-11T00:00:00Z", "2025-02-12T00:00:00Z"}, []int64{8000, 8000}); rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "assertion_failed") {
+		t.Fatalf("batch breaking an assertion together expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = batch([]string{"2025-01-20T00:00:00Z", "2025-01-21T00:00:00Z"}, []int64{100, -100}); rec.Code != http.StatusCreated {
+		t.Fatalf("batch netting to zero expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodDelete, "/v1/balance-assertions/"+jan.ID.String()+"?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec = post("2025-01-31T23:00:00Z", 100); rec.Code != http.StatusCreated {
+		t.Fatalf("entry after deleting the assertion expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestExportJournal_BalancesMatchTrialBalance(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Bank GBP", Currency: "GBP", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Monzo"}
+	store.SeedAccount(bank)
+	post := func(date string, body map[string]any) {
+		t.Helper()
+		body["user_id"], body["date"], body["memo"], body["category"] = userID.String(), date, "export me", "general"
+		if rec := doJSON(h, http.MethodPost, "/v1/entries", body); rec.Code != http.StatusCreated {
+			t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	post("2025-01-10T12:00:00Z", map[string]any{"currency": "USD", "metadata": map[string]string{"tracker.bank_ref": "R1"}, "lines": []map[string]any{
+		{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 5000},
+		{"account_id": income.ID.String(), "side": "credit", "amount_minor": 5000},
+	}})
+	post("2025-02-01T08:00:00Z", map[string]any{"currency": "USD", "lines": []map[string]any{
+		{"account_id": bank.ID.String(), "side": "debit", "amount_minor": 1270, "currency": "GBP", "exchange_rate": "1.2705"},
+		{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 1614},
+	}})
+
+	rec := doJSON(h, http.MethodGet, "/v1/trial-balance?user_id="+userID.String(), nil)
+	var tb trialBalanceResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &tb)
+	want := map[string]int64{}
+	for _, g := range tb.Groups {
+		for _, a := range g.Accounts {
+			want[a.Path+" "+a.Currency] = a.DebitMinor - a.CreditMinor
+		}
+	}
+	if len(want) != 3 {
+		t.Fatalf("unexpected trial balance %s", rec.Body.String())
+	}
+
+	// Sum the postings the way the tools do: per account and commodity.
+	balances := func(out string) map[string]int64 {
+		got := map[string]int64{}
+		for _, raw := range strings.Split(out, "\n") {
+			f := strings.Fields(raw)
+			if !strings.HasPrefix(raw, "    ") || len(f) < 3 || f[0] == ";" || f[0] == "check" {
+				continue
+			}
+			if f[0] == "*" || f[0] == "!" {
+				f = f[1:]
+			}
+			amt, err := money.ParseAmount(f[2], f[1])
+			if err != nil {
+				t.Fatalf("bad posting %q: %v", raw, err)
+			}
+			minor, _ := amt.MinorUnits()
+			got[f[0]+" "+f[2]] += minor
+		}
+		return got
+	}
+	for _, format := range []string{"ledger", "hledger"} {
+		rec = doJSON(h, http.MethodGet, "/v1/export/journal?user_id="+userID.String()+"&format="+format, nil)
+		if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+			t.Fatalf("%s export expected 200 text/plain, got %d %q: %s", format, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+		out := rec.Body.String()
+		if !strings.Contains(out, "; tracker.bank_ref: R1") || !strings.Contains(out, "12.70 GBP @@ 16.14 USD") {
+			t.Fatalf("%s export missing tags or cost:\n%s", format, out)
+		}
+		got := balances(out)
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("%s: %s = %d, trial balance says %d\n%s", format, k, got[k], v, out)
+			}
+		}
+	}
+
+	// Starting later carries the earlier entries as opening balances; a
+	// conversion left in them is balanced against equity:conversion:opening.
+	for _, from := range []string{"2025-01-20T00:00:00Z", "2025-03-01T00:00:00Z"} {
+		rec = doJSON(h, http.MethodGet, "/v1/export/journal?user_id="+userID.String()+"&format=ledger&from="+from, nil)
+		out := rec.Body.String()
+		if rec.Code != http.StatusOK || !strings.Contains(out, from[:10]+" (") || !strings.Contains(out, "Opening balances") {
+			t.Fatalf("export from %s expected an opening transaction, got %d:\n%s", from, rec.Code, out)
+		}
+		got := balances(out)
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("from %s: %s = %d, trial balance says %d\n%s", from, k, got[k], v, out)
+			}
+		}
+		if conv := got["equity:conversion:opening USD"]; (from == "2025-03-01T00:00:00Z") != (conv != 0) {
+			t.Fatalf("from %s: unexpected conversion balance %d\n%s", from, conv, out)
+		}
+	}
+
+	rec = doJSON(h, http.MethodGet, "/v1/export/journal?user_id="+userID.String()+"&format=beancount&to=2025-01-31T23:59:59Z", nil)
+	out := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(out, "2025-01-10 open Assets:Bank:Monzo GBP") || !strings.Contains(out, "tracker-bank_ref: \"R1\"") || strings.Contains(out, "@@") {
+		t.Fatalf("unexpected beancount export %d:\n%s", rec.Code, out)
+	}
+	if got := rec.Header().Get("Content-Disposition"); !strings.Contains(got, "journal.beancount") {
+		t.Fatalf("unexpected content disposition %q", got)
+	}
+	rec = doJSON(h, http.MethodGet, "/v1/export/journal?user_id="+userID.String()+"&format=gnucash", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format expected 400, got %d", rec.Code)
+	}
+}
+
+// postText sends a plain-text body and returns the recorded response.
+func postText(h http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// trialBalances returns debit-minus-credit balances keyed by path and currency, skipping zero rows.
+func trialBalances(t *testing.T, h http.Handler, userID uuid.UUID) map[string]int64 {
+	t.Helper()
+	rec := doJSON(h, http.MethodGet, "/v1/trial-balance?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("trial balance expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var tb trialBalanceResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &tb)
+	out := map[string]int64{}
+	for _, g := range tb.Groups {
+		for _, a := range g.Accounts {
+			if n := a.DebitMinor - a.CreditMinor; n != 0 {
+				out[a.Path+" "+a.Currency] = n
+			}
+		}
+	}
+	return out
+}
+
+const beancountJournal = `2024-01-01 open Assets:Bank:Chase USD
+2024-01-01 open Income:Salary:Acme USD
+2024-01-01 open Expenses:Food:Groceries USD
+2024-01-01 open Equity:OpeningBalances USD
+
+2024-01-01 * "Opening balance"
+  Assets:Bank:Chase  500.00 USD
+  Equity:OpeningBalances
+
+2024-01-15 * "Acme" "January salary" #payroll
+  category: "income"
+  Assets:Bank:Chase  2,000.00 USD
+  Income:Salary:Acme
+
+2024-01-20 * "Whole Foods" "Groceries"
+  Expenses:Food:Groceries  42.10 USD
+  Assets:Bank:Chase
+
+2024-02-01 balance Assets:Bank:Chase  2457.90 USD
+`
+
+func TestImportJournal_Beancount(t *testing.T) {
+	_, h, _, _, _ := setup(t)
+	userID := uuid.New()
+	rec := postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", beancountJournal)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var out journalImportResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if len(out.Accounts) != 4 || len(out.Entries) != 3 || len(out.Assertions) != 1 || !out.Assertions[0].Passing {
+		t.Fatalf("unexpected import result %s", rec.Body.String())
+	}
+	paths := map[string]bool{}
+	for _, a := range out.Accounts {
+		paths[a.Path] = true
+	}
+	for _, p := range []string{"asset:bank:chase", "revenue:salary:acme", "expense:food:groceries", "equity:opening_balances"} {
+		if !paths[p] {
+			t.Fatalf("missing account %s in %v", p, paths)
+		}
+	}
+	for _, e := range out.Entries {
+		if e.Memo == "January salary" && (e.Category != ledger.CategoryIncome || e.Metadata["payee"] != "Acme" || e.Metadata["tags"] != "payroll") {
+			t.Fatalf("unexpected salary entry %+v", e)
+		}
+	}
+	if got := trialBalances(t, h, userID)["asset:bank:chase USD"]; got != 245790 {
+		t.Fatalf("chase balance = %d, want 245790", got)
+	}
+	// Importing again would break the balance assertion the first import kept.
+	rec = postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", beancountJournal)
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), `"line":6,"code":"assertion_failed"`) {
+		t.Fatalf("re-import expected 422 at line 6, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestMemoryBatchTx_SnapshotsOneUserAndDetectsTheirWrites(t *testing.T) {
+	store, _, userID, cash, income := setup(t)
+	ctx := context.Background()
+	entry := func(user uuid.UUID, debit, credit ledger.Account) ledger.JournalEntry {
+		e := ledger.JournalEntry{ID: uuid.New(), UserID: user, Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Currency: "USD", Category: "general",
+			Lines: ledger.JournalLines{ByID: map[uuid.UUID]*ledger.JournalLine{}}}
+		for _, ln := range []ledger.JournalLine{{AccountID: debit.ID, Side: ledger.SideDebit}, {AccountID: credit.ID, Side: ledger.SideCredit}} {
+			ln.ID, ln.EntryID, ln.Amount = uuid.New(), e.ID, money.MustNewAmount("USD", 1, 0)
+			e.Lines.ByID[ln.ID] = &ln
+		}
+		return e
+	}
+	other := uuid.New()
+	otherCash := ledger.Account{ID: uuid.New(), UserID: other, Name: "Wallet", Currency: "USD", Type: ledger.AccountTypeAsset, Group: "cash", Vendor: "Wallet", Active: true}
+	store.SeedAccount(otherCash)
+
+	tx, _ := store.BeginTx(ctx, userID)
+	if accs, _ := tx.FetchAccounts(ctx, other, []uuid.UUID{otherCash.ID}); len(accs) != 0 {
+		t.Fatalf("batch of one user must not see another's accounts")
+	}
+	if _, err := tx.CreateJournalEntry(ctx, entry(userID, cash, income)); err != nil {
+		t.Fatalf("create in batch: %v", err)
+	}
+	// Another user's writes do not conflict; the batch user's own do.
+	if _, err := store.CreateJournalEntry(ctx, entry(other, otherCash, otherCash)); err != nil {
+		t.Fatalf("create for other user: %v", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		t.Fatalf("commit after another user's write: %v", err)
+	}
+	tx, _ = store.BeginTx(ctx, userID)
+	_, _ = tx.CreateJournalEntry(ctx, entry(userID, cash, income))
+	_, _ = store.CreateJournalEntry(ctx, entry(userID, cash, income))
+	if err := tx.Commit(ctx); !errors.Is(err, errs.ErrConflict) {
+		t.Fatalf("commit after a concurrent write of the user expected conflict, got %v", err)
+	}
+	if entries, _ := store.ListEntries(ctx, userID); len(entries) != 2 {
+		t.Fatalf("expected the first batch and the direct write only, have %d entries", len(entries))
+	}
+}
+
+func TestImportJournal_FailureRollsBack(t *testing.T) {
+	store, h, _, _, _ := setup(t)
+	userID := uuid.New()
+	bad := strings.Replace(beancountJournal, "2457.90 USD", "2500.00 USD", 1)
+	bad = strings.Replace(bad, "Expenses:Food:Groceries  42.10", "Expenses:Food:Grocery  42.10", 1)
+	rec := postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", bad)
+	var er journalImportErrorsResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &er)
+	if rec.Code != http.StatusUnprocessableEntity || len(er.Errors) != 1 || er.Errors[0].Line != 16 || er.Errors[0].Code != "unknown_account" {
+		t.Fatalf("expected unknown account at line 16, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", strings.Replace(beancountJournal, "2457.90 USD", "2500.00 USD", 1))
+	_ = json.Unmarshal(rec.Body.Bytes(), &er)
+	if rec.Code != http.StatusUnprocessableEntity || len(er.Errors) != 1 || er.Errors[0].Line != 19 || er.Errors[0].Code != "balance_failed" {
+		t.Fatalf("expected failed balance at line 19, got %d: %s", rec.Code, rec.Body.String())
+	}
+	accounts, _ := store.ListAccounts(context.Background(), userID)
+	entries, _ := store.ListEntries(context.Background(), userID)
+	assertions, _ := store.ListAssertions(context.Background(), userID)
+	if len(accounts)+len(entries)+len(assertions) != 0 {
+		t.Fatalf("failed import left %d accounts, %d entries, %d assertions", len(accounts), len(entries), len(assertions))
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/imports/journal?user_id="+userID.String(), map[string]any{})
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("JSON body expected 415, got %d", rec.Code)
+	}
+}
+
+func TestImportJournal_RoundTripsExport(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	bank := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Bank GBP", Currency: "GBP", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "Monzo"}
+	store.SeedAccount(bank)
+	for _, body := range []map[string]any{
+		{"currency": "USD", "date": "2025-01-10T12:00:00Z", "metadata": map[string]string{"tracker.bank_ref": "R1"}, "lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 5000},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 5000},
+		}},
+		{"currency": "USD", "date": "2025-02-01T08:00:00Z", "lines": []map[string]any{
+			{"account_id": bank.ID.String(), "side": "debit", "amount_minor": 1270, "currency": "GBP", "exchange_rate": "1.2705"},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 1614},
+		}},
+	} {
+		body["user_id"], body["memo"], body["category"] = userID.String(), "round trip", "general"
+		if rec := doJSON(h, http.MethodPost, "/v1/entries", body); rec.Code != http.StatusCreated {
+			t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	want := trialBalances(t, h, userID)
+	for _, format := range []string{"ledger", "hledger", "beancount"} {
+		rec := doJSON(h, http.MethodGet, "/v1/export/journal?user_id="+userID.String()+"&format="+format, nil)
+		target := uuid.New()
+		rec = postText(h, "/v1/imports/journal?user_id="+target.String()+"&format="+format, rec.Body.String())
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s import expected 201, got %d: %s", format, rec.Code, rec.Body.String())
+		}
+		got := trialBalances(t, h, target)
+		if len(got) != len(want) {
+			t.Fatalf("%s: balances %v, want %v", format, got, want)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Fatalf("%s: %s = %d, want %d", format, k, got[k], v)
+			}
+		}
+	}
+}
+
+// errCode decodes the error code of a JSON error response.
+func errCode(rec *httptest.ResponseRecorder) string {
+	var e errResp
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	return e.Code
+}
+
+func TestReverseEntry_Partial(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	food := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Food", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Market"}
+	home := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Home", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "household", Vendor: "Store"}
+	store.SeedAccount(food)
+	store.SeedAccount(home)
+	rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-03-01T12:00:00Z", "currency": "USD", "memo": "Shop", "category": "shopping",
+		"lines": []map[string]any{
+			{"account_id": food.ID.String(), "side": "debit", "amount_minor": 6000},
+			{"account_id": home.ID.String(), "side": "debit", "amount_minor": 4000},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 10000},
+		},
+	})
+	var orig entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &orig)
+	lineOf := func(accountID uuid.UUID) string {
+		for _, l := range orig.Lines {
+			if l.AccountID == accountID {
+				return l.ID.String()
+			}
+		}
+		t.Fatalf("no line for %s", accountID)
+		return ""
+	}
+	reverse := func(extra map[string]any) *httptest.ResponseRecorder {
+		body := map[string]any{"user_id": userID.String(), "entry_id": orig.ID.String(), "date": "2025-03-05T12:00:00Z"}
+		for k, v := range extra {
+			body[k] = v
+		}
+		return doJSON(h, http.MethodPost, "/v1/entries/reverse", body)
+	}
+	current := func() entryResponse {
+		var e entryResponse
+		rec := doJSON(h, http.MethodGet, "/v1/entries/"+orig.ID.String()+"?user_id="+userID.String(), nil)
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+
+	// Refund the household part only.
+	rec = reverse(map[string]any{"lines": []map[string]any{
+		{"line_id": lineOf(home.ID), "amount_minor": 4000},
+		{"line_id": lineOf(cash.ID), "amount_minor": 4000},
+	}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("line reversal expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if e := current(); e.IsReversed || e.ReversedMinor != 4000 || e.RemainingMinor != 6000 {
+		t.Fatalf("after line reversal: reversed=%d remaining=%d is_reversed=%v", e.ReversedMinor, e.RemainingMinor, e.IsReversed)
+	}
+	// The household line has nothing left; an unbalanced subset is rejected.
+	rec = reverse(map[string]any{"lines": []map[string]any{{"line_id": lineOf(home.ID), "amount_minor": 1}, {"line_id": lineOf(cash.ID), "amount_minor": 1}}})
+	if rec.Code != http.StatusUnprocessableEntity || errCode(rec) != "exceeds_remaining" {
+		t.Fatalf("over-reversal expected 422 exceeds_remaining, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = reverse(map[string]any{"lines": []map[string]any{{"line_id": lineOf(food.ID), "amount_minor": 1000}, {"line_id": lineOf(cash.ID), "amount_minor": 900}}})
+	if rec.Code != http.StatusUnprocessableEntity || errCode(rec) != "unbalanced_entry" {
+		t.Fatalf("unbalanced subset expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A proportional amount beyond what is left fails; a smaller one succeeds.
+	rec = reverse(map[string]any{"amount_minor": 10000})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("proportional over-reversal expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = reverse(map[string]any{"lines": []map[string]any{{"line_id": lineOf(food.ID), "amount_minor": 1500}, {"line_id": lineOf(cash.ID), "amount_minor": 1500}}})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("second refund expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if e := current(); e.ReversedMinor != 5500 || e.RemainingMinor != 4500 {
+		t.Fatalf("after second refund: reversed=%d remaining=%d", e.ReversedMinor, e.RemainingMinor)
+	}
+
+	// A full reversal undoes only what is left.
+	rec = reverse(nil)
+	var rev entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rev)
+	if rec.Code != http.StatusCreated || len(rev.Lines) != 2 {
+		t.Fatalf("full reversal expected 201 with 2 lines, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, l := range rev.Lines {
+		if l.AmountMinor != 4500 {
+			t.Fatalf("full reversal line %+v, want 4500", l)
+		}
+	}
+	if e := current(); !e.IsReversed || e.RemainingMinor != 0 {
+		t.Fatalf("expected fully reversed, got reversed=%d remaining=%d", e.ReversedMinor, e.RemainingMinor)
+	}
+	if rec = reverse(nil); rec.Code != http.StatusUnprocessableEntity || errCode(rec) != "already_reversed" {
+		t.Fatalf("reversing again expected 422 already_reversed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if tb := trialBalances(t, h, userID); len(tb) != 0 {
+		t.Fatalf("expected every balance back to zero, got %v", tb)
+	}
+}
+
+func TestReverseEntry_ProportionalBalancesAfterRounding(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	accounts := make([]ledger.Account, 3)
+	lines := []map[string]any{{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 10000}}
+	for i, minor := range []int64{3333, 3333, 3334} {
+		accounts[i] = ledger.Account{ID: uuid.New(), UserID: userID, Name: "Split", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "bills", Vendor: "Vendor " + strconv.Itoa(i)}
+		store.SeedAccount(accounts[i])
+		lines = append(lines, map[string]any{"account_id": accounts[i].ID.String(), "side": "debit", "amount_minor": minor})
+	}
+	rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{"user_id": userID.String(), "date": "2025-03-01T12:00:00Z", "currency": "USD", "memo": "Split", "category": "bills", "lines": lines})
+	var orig entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &orig)
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": orig.ID.String(), "amount_minor": 1000})
+	var rev entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rev)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("proportional reversal expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var debits, credits int64
+	for _, l := range rev.Lines {
+		if l.Side == ledger.SideDebit {
+			debits += l.AmountMinor
+		} else {
+			credits += l.AmountMinor
+		}
+	}
+	if debits != 1000 || credits != 1000 {
+		t.Fatalf("proportional reversal debits=%d credits=%d, want 1000 each", debits, credits)
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": orig.ID.String(), "amount_minor": 100, "lines": []map[string]any{{"line_id": orig.Lines[0].ID.String(), "amount_minor": 1}}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("lines with amount_minor expected 400, got %d", rec.Code)
+	}
+}
+
+func TestReverseEntry_ProportionalMeetsTargetPastConvertedRounding(t *testing.T) {
+	store, h, userID, cash, _ := setup(t)
+	yen := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Tokyo", Currency: "JPY", Type: ledger.AccountTypeExpense, Group: "travel", Vendor: "Hotel"}
+	food := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Food", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "groceries", Vendor: "Market"}
+	store.SeedAccount(yen)
+	store.SeedAccount(food)
+	rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-03-01T12:00:00Z", "currency": "USD", "memo": "Trip", "category": "travel",
+		"lines": []map[string]any{
+			{"account_id": yen.ID.String(), "side": "debit", "amount_minor": 7, "currency": "JPY", "exchange_rate": "0.1"},
+			{"account_id": food.ID.String(), "side": "debit", "amount_minor": 30},
+			{"account_id": cash.ID.String(), "side": "credit", "amount_minor": 100},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var orig entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &orig)
+	// Half of 7 yen rounds up to 4 (0.40 USD), so the food line gives back
+	// more than one unit of its proportional 15 to keep the sides at 50.
+	rec = doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": orig.ID.String(), "amount_minor": 50})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("proportional reversal expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rev entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rev)
+	for _, l := range rev.Lines {
+		if l.AccountID == food.ID && l.AmountMinor != 10 {
+			t.Fatalf("food line reversed %d, want 10", l.AmountMinor)
+		}
+	}
+}
+
+func TestReverseEntry_ConcurrentPartialsNeverExceedTheEntry(t *testing.T) {
+	_, h, userID, cash, income := setup(t)
+	rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-03-01T12:00:00Z", "currency": "USD", "memo": "Sale", "category": "income",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 10000},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 10000},
+		},
+	})
+	var orig entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &orig)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": orig.ID.String(), "date": "2025-03-05T12:00:00Z", "amount_minor": 3000})
+			if rec.Code == http.StatusCreated {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// At most three refunds of 3000 fit in 10000, and the entry records each.
+	if created == 0 || created > 3 {
+		t.Fatalf("expected 1 to 3 refunds, got %d", created)
+	}
+	rec = doJSON(h, http.MethodGet, "/v1/entries/"+orig.ID.String()+"?user_id="+userID.String(), nil)
+	var e entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &e)
+	if e.ReversedMinor != int64(3000*created) {
+		t.Fatalf("entry records %d reversed for %d refunds", e.ReversedMinor, created)
+	}
+	if got := trialBalances(t, h, userID)["asset:cash:wallet USD"]; got != int64(10000-3000*created) {
+		t.Fatalf("cash balance = %d after %d refunds", got, created)
+	}
+}
+
+func TestReclassify_FailedCorrectionLeavesOriginalUntouched(t *testing.T) {
+	_, h, userID, cash, income := setup(t)
+	post := func(md map[string]string) entryResponse {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-04-01T12:00:00Z", "currency": "USD", "category": "general", "metadata": md,
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 500},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": 500},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	post(map[string]string{"tracker.input_hash": "h1"})
+	orig := post(nil)
+	reclassify := func(md map[string]string) *httptest.ResponseRecorder {
+		return doJSON(h, http.MethodPost, "/v1/entries/reclassify", map[string]any{
+			"user_id": userID.String(), "entry_id": orig.ID.String(), "date": "2025-04-02T12:00:00Z", "metadata": md,
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 500},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": 500},
+			},
+		})
+	}
+	// The correction clashes with the imported entry's input hash, so the
+	// reversal must not land either.
+	if rec := reclassify(map[string]string{"tracker.input_hash": "h1"}); rec.Code < 400 {
+		t.Fatalf("clashing correction expected an error, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String(), nil)
+	var list listEntriesResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list.Items) != 2 {
+		t.Fatalf("failed reclassify left %d entries, want 2", len(list.Items))
+	}
+	for _, e := range list.Items {
+		if e.ID == orig.ID && (e.IsReversed || len(e.Relations) != 0) {
+			t.Fatalf("failed reclassify changed the original: %+v", e)
+		}
+	}
+	if rec := reclassify(nil); rec.Code != http.StatusCreated {
+		t.Fatalf("retried reclassify expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEntryRelations_CorrectionChain(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	other := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Other", Currency: "USD", Type: ledger.AccountTypeRevenue, Group: "other", Vendor: "Misc"}
+	store.SeedAccount(other)
+	post := func(memo string) entryResponse {
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-04-01T12:00:00Z", "currency": "USD", "memo": memo, "category": "general",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 500},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": 500},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	get := func(id uuid.UUID) entryResponse {
+		var e entryResponse
+		rec := doJSON(h, http.MethodGet, "/v1/entries/"+id.String()+"?user_id="+userID.String(), nil)
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	list := func(q string) []entryResponse {
+		rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String()+q, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("list %s expected 200, got %d: %s", q, rec.Code, rec.Body.String())
+		}
+		var resp listEntriesResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.Items
+	}
+	only := func(e entryResponse, kind ledger.RelationKind, id uuid.UUID) {
+		t.Helper()
+		if len(e.Relations) != 1 || e.Relations[0].Kind != kind || e.Relations[0].EntryID != id {
+			t.Fatalf("entry %s: want %s %s, got %+v", e.Memo, kind, id, e.Relations)
+		}
+	}
+
+	orig := post("Sale")
+	if len(orig.Relations) != 0 {
+		t.Fatalf("new entry should have no relations: %+v", orig.Relations)
+	}
+	rec := doJSON(h, http.MethodPost, "/v1/entries/reclassify", map[string]any{
+		"user_id": userID.String(), "entry_id": orig.ID.String(), "date": "2025-04-02T12:00:00Z",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 500},
+			{"account_id": other.ID.String(), "side": "credit", "amount_minor": 500},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reclassify expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var corr entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &corr)
+	only(corr, ledger.RelationReclassifiedFrom, orig.ID)
+
+	reversals := list("&relation=reverses&related_to=" + orig.ID.String())
+	if len(reversals) != 1 {
+		t.Fatalf("want one reversal of the original, got %d", len(reversals))
+	}
+	only(reversals[0], ledger.RelationReverses, orig.ID)
+	got := get(orig.ID)
+	if len(got.Relations) != 2 || got.Relations[0] != (entryRelationResponse{Kind: ledger.RelationReversedBy, EntryID: reversals[0].ID}) ||
+		got.Relations[1] != (entryRelationResponse{Kind: ledger.RelationReclassifiedTo, EntryID: corr.ID}) {
+		t.Fatalf("unexpected original relations %+v", got.Relations)
+	}
+	if n := len(list("&related_to=" + orig.ID.String())); n != 2 {
+		t.Fatalf("want reversal and correction linked to the original, got %d", n)
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String()+"&relation=undoes", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown relation expected 400, got %d", rec.Code)
+	}
+
+	// User-made links are recorded on both entries and can be removed.
+	note := post("Invoice")
+	path := "/v1/entries/" + note.ID.String() + "/relations"
+	if rec := doJSON(h, http.MethodPost, path, map[string]any{"user_id": userID.String(), "entry_id": note.ID.String()}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("self link expected 400, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodPost, path, map[string]any{"user_id": userID.String(), "entry_id": uuid.NewString()}); rec.Code != http.StatusNotFound {
+		t.Fatalf("unknown entry expected 404, got %d", rec.Code)
+	}
+	for i := 0; i < 2; i++ {
+		rec = doJSON(h, http.MethodPost, path, map[string]any{"user_id": userID.String(), "entry_id": corr.ID.String()})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("relate expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	var linked entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &linked)
+	only(linked, ledger.RelationRelated, corr.ID)
+	if c := get(corr.ID); len(c.Relations) != 2 || c.Relations[1] != (entryRelationResponse{Kind: ledger.RelationRelated, EntryID: note.ID}) {
+		t.Fatalf("related link missing on the other entry: %+v", c.Relations)
+	}
+	if rec := doJSON(h, http.MethodDelete, "/v1/entries/"+corr.ID.String()+"/relations/"+orig.ID.String()+"?user_id="+userID.String(), nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("removing a reclassification link expected 404, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodDelete, "/v1/entries/"+corr.ID.String()+"/relations/"+note.ID.String()+"?user_id="+userID.String(), nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("unrelate expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := len(get(note.ID).Relations); n != 0 {
+		t.Fatalf("related link should be gone, %d left", n)
+	}
+	only(get(corr.ID), ledger.RelationReclassifiedFrom, orig.ID)
+}
+
+// signHS256 builds a token the deprecated HS256 verifier accepts.
+func signHS256(t *testing.T, secret, sub string, roles ...string) string {
+	t.Helper()
+	enc := base64.RawURLEncoding
+	payload, _ := json.Marshal(JWTClaims{Subject: sub, Roles: roles})
+	unsigned := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestPeriods_ChangesNeedAdminRole(t *testing.T) {
+	t.Setenv("JWT_HS256_SECRET", "test-secret")
+	_, h, userID, _, _ := setup(t)
+	as := func(roles ...string) http.Handler {
+		tok := signHS256(t, "test-secret", "alice", roles...)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+tok)
+			h.ServeHTTP(w, r)
+		})
+	}
+	user, admin := as(), as("viewer", "ADMIN")
+	start := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+	body := map[string]any{
+		"user_id": userID.String(), "name": "2025-09",
+		"start": start.Format(time.RFC3339), "end": start.AddDate(0, 1, 0).Format(time.RFC3339),
+	}
+	if rec := doJSON(user, http.MethodPost, "/v1/periods", body); rec.Code != http.StatusForbidden {
+		t.Fatalf("create without admin expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := doJSON(admin, http.MethodPost, "/v1/periods", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create as admin expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var p periodResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &p)
+	if rec := doJSON(user, http.MethodGet, "/v1/periods?user_id="+userID.String(), nil); rec.Code != http.StatusOK {
+		t.Fatalf("list expected 200 for any token, got %d", rec.Code)
+	}
+	for _, action := range []string{"close", "reopen", "lock"} {
+		url := "/v1/periods/" + p.ID.String() + "/" + action + "?user_id=" + userID.String()
+		if rec := doJSON(user, http.MethodPost, url, nil); rec.Code != http.StatusForbidden {
+			t.Fatalf("%s without admin expected 403, got %d: %s", action, rec.Code, rec.Body.String())
+		}
+		if rec := doJSON(admin, http.MethodPost, url, nil); rec.Code != http.StatusOK {
+			t.Fatalf("%s as admin expected 200, got %d: %s", action, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestAudit_RecordsWritesWithActorAndSnapshots(t *testing.T) {
+	t.Setenv("JWT_HS256_SECRET", "test-secret")
+	_, h, userID, cash, income := setup(t)
+	as := func(sub string) http.Handler {
+		tok := signHS256(t, "test-secret", sub)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+tok)
+			h.ServeHTTP(w, r)
+		})
+	}
+	alice, bob := as("alice"), as("bob")
+	list := func(q string) listAuditResponse {
+		t.Helper()
+		rec := doJSON(alice, http.MethodGet, "/v1/audit?user_id="+userID.String()+q, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("audit %s expected 200, got %d: %s", q, rec.Code, rec.Body.String())
+		}
+		var resp listAuditResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp
+	}
+
+	rec := doJSON(alice, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-05-01T12:00:00Z", "currency": "USD", "memo": "Pay", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 900},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 900},
+		},
+	})
+	var entry entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &entry)
+	rec = doJSON(bob, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": entry.ID.String()})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("reverse expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var rev entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &rev)
+	if rec := doJSON(bob, http.MethodPatch, "/v1/accounts/"+cash.ID.String()+"?user_id="+userID.String(), map[string]any{"name": "Wallet"}); rec.Code != http.StatusOK {
+		t.Fatalf("patch expected 200, got %d", rec.Code)
+	}
+	// Seeded accounts start inactive.
+	if rec := doJSON(bob, http.MethodPost, "/v1/accounts/"+income.ID.String()+"/reactivate?user_id="+userID.String(), nil); rec.Code != http.StatusOK {
+		t.Fatalf("reactivate expected 200, got %d", rec.Code)
+	}
+	if rec := doJSON(bob, http.MethodDelete, "/v1/accounts/"+income.ID.String()+"?user_id="+userID.String(), nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("deactivate expected 204, got %d", rec.Code)
+	}
+
+	// The original entry: created by alice, reversed by bob.
+	got := list("&entity=entry&entity_id=" + entry.ID.String())
+	if len(got.Items) != 2 {
+		t.Fatalf("want 2 records for the entry, got %+v", got.Items)
+	}
+	created, reversed := got.Items[0], got.Items[1]
+	if created.Operation != ledger.AuditCreate || created.Actor != "alice" || string(created.Before) != "null" || created.RequestID == "" {
+		t.Fatalf("unexpected create record %+v", created)
+	}
+	if reversed.Operation != ledger.AuditReverse || reversed.Actor != "bob" || reversed.RequestID == created.RequestID {
+		t.Fatalf("unexpected reverse record %+v", reversed)
+	}
+	var before, after entryResponse
+	_ = json.Unmarshal(reversed.Before, &before)
+	_ = json.Unmarshal(reversed.After, &after)
+	if before.IsReversed || !after.IsReversed || len(after.Relations) != 1 || after.Relations[0].EntryID != rev.ID {
+		t.Fatalf("snapshots do not show the reversal: before %+v after %+v", before, after)
+	}
+	if n := len(list("&entity_id=" + rev.ID.String()).Items); n != 1 {
+		t.Fatalf("want the reversal entry's creation recorded, got %d", n)
+	}
+
+	// Bob's account writes, filtered by actor and entity.
+	got = list("&actor=bob&entity=account")
+	if len(got.Items) != 3 || got.Items[0].Operation != ledger.AuditUpdate || got.Items[1].Operation != ledger.AuditReactivate || got.Items[2].Operation != ledger.AuditDeactivate {
+		t.Fatalf("unexpected account records %+v", got.Items)
+	}
+	var acctBefore, acctAfter accountResponse
+	_ = json.Unmarshal(got.Items[0].Before, &acctBefore)
+	_ = json.Unmarshal(got.Items[0].After, &acctAfter)
+	if acctBefore.Name != "Cash" || acctAfter.Name != "Wallet" {
+		t.Fatalf("unexpected patch snapshots %s -> %s", acctBefore.Name, acctAfter.Name)
+	}
+	_ = json.Unmarshal(got.Items[2].After, &acctAfter)
+	if acctAfter.Active {
+		t.Fatal("deactivation snapshot should be inactive")
+	}
+
+	// Paging and time bounds.
+	page := list("&limit=3")
+	if len(page.Items) != 3 || page.NextCursor == nil {
+		t.Fatalf("want a first page of 3 with a cursor, got %d", len(page.Items))
+	}
+	if rest := list("&limit=3&cursor=" + *page.NextCursor); len(rest.Items) != 3 || rest.NextCursor != nil || rest.Items[0].Seq != page.Items[2].Seq+1 {
+		t.Fatalf("unexpected second page %+v", rest)
+	}
+	if n := len(list("&to=2000-01-01T00:00:00Z").Items); n != 0 {
+		t.Fatalf("want no records before 2000, got %d", n)
+	}
+	if rec := doJSON(alice, http.MethodGet, "/v1/audit?user_id="+userID.String()+"&entity=budget", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown entity expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAudit_RecordsImportsSchedulesAndYearEnd(t *testing.T) {
+	store := memory.New()
+	api := New(store, store, store, store, store, store, store, testLogger())
+	h := api.Handler()
+	list := func(userID uuid.UUID, q string) []auditRecordResponse {
+		t.Helper()
+		rec := doJSON(h, http.MethodGet, "/v1/audit?user_id="+userID.String()+q, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("audit expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp listAuditResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+		return resp.Items
+	}
+
+	// A journal import records its accounts and entries in its transaction;
+	// a rejected one leaves nothing behind.
+	userID := uuid.New()
+	bad := strings.Replace(beancountJournal, "2457.90 USD", "2500.00 USD", 1)
+	if rec := postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", bad); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("failing import expected 422, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n := len(list(userID, "")); n != 0 {
+		t.Fatalf("rejected import left %d audit records", n)
+	}
+	rec := postText(h, "/v1/imports/journal?user_id="+userID.String()+"&format=beancount", beancountJournal)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("import expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var out journalImportResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &out)
+	if n := len(list(userID, "&entity=account")); n != len(out.Accounts) {
+		t.Fatalf("want %d account records, got %d", len(out.Accounts), n)
+	}
+	entries := list(userID, "&entity=entry")
+	if len(entries) != len(out.Entries) || entries[0].Operation != ledger.AuditCreate || entries[0].RequestID == "" {
+		t.Fatalf("want %d entry creations, got %+v", len(out.Entries), entries)
+	}
+
+	// Scheduled postings run outside any request.
+	var cash, income accountResponse
+	for _, a := range out.Accounts {
+		switch a.Path {
+		case "asset:bank:chase":
+			cash = a
+		case "revenue:salary:acme":
+			income = a
+		}
+	}
+	if cash.ID == uuid.Nil || income.ID == uuid.Nil {
+		t.Fatalf("imported accounts not found in %+v", out.Accounts)
+	}
+	rec = doJSON(h, http.MethodPost, "/v1/schedules", map[string]any{
+		"user_id": userID.String(), "name": "Pay", "rule": "monthly", "start": "2024-03-01T00:00:00Z", "currency": "USD", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 100},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 100},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("schedule expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	res, err := api.Schedules().RunDue(context.Background(), time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC))
+	if err != nil || len(res.Posted) != 2 {
+		t.Fatalf("want 2 scheduled postings, got %+v (%v)", res, err)
+	}
+	for _, e := range res.Posted {
+		got := list(userID, "&entity_id="+e.ID.String())
+		if len(got) != 1 || got[0].Operation != ledger.AuditCreate || got[0].RequestID != "" {
+			t.Fatalf("scheduled posting %s not recorded: %+v", e.ID, got)
+		}
+	}
+
+	// A year-end close records its closing entries and the retained earnings account.
+	before := len(list(userID, ""))
+	rec = doJSON(h, http.MethodPost, "/v1/year-end/close", map[string]any{"user_id": userID.String(), "year": 2024})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("close expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if after := list(userID, ""); len(after) <= before || after[len(after)-1].Operation != ledger.AuditCreate {
+		t.Fatalf("year-end close not recorded: %d records before, %+v after", before, after[before:])
+	}
+}
+
+func TestWebhooks_SignedDeliveryRetryDeadAndRedeliver(t *testing.T) {
+	_, strict, strictUser, _, _ := setup(t)
+	// The receiver listens on loopback, which endpoints may only use when allowed.
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_HOSTS", "true")
+	store, h, userID, cash, income := setup(t)
+	type received struct {
+		header http.Header
+		body   []byte
+	}
+	var mu sync.Mutex
+	var got []received
+	failing := false
+	recv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, received{header: r.Header.Clone(), body: b})
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer recv.Close()
+
+	register := func(types ...string) webhookResponse {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/webhooks", map[string]any{"user_id": userID.String(), "url": recv.URL, "event_types": types})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("register expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var ep webhookResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &ep)
+		return ep
+	}
+	for _, u := range []string{recv.URL, "http://localhost:8080/hook", "http://10.1.2.3/hook", "http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://0.0.0.0/hook"} {
+		rec := doJSON(strict, http.MethodPost, "/v1/webhooks", map[string]any{"user_id": strictUser.String(), "url": u, "event_types": []string{"entry.created"}})
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "private") {
+			t.Fatalf("private url %s expected 400, got %d: %s", u, rec.Code, rec.Body.String())
+		}
+	}
+	entries := register("entry.created", "entry.reversed")
+	accounts := register("account.updated")
+	if entries.Secret == "" || entries.Secret == accounts.Secret {
+		t.Fatalf("expected distinct secrets, got %q and %q", entries.Secret, accounts.Secret)
+	}
+	if rec := doJSON(h, http.MethodPost, "/v1/webhooks", map[string]any{"user_id": userID.String(), "url": recv.URL, "event_types": []string{"entry.deleted"}}); rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown event type expected 400, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/webhooks/"+entries.ID.String()+"?user_id="+userID.String(), nil); rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), entries.Secret) {
+		t.Fatalf("get should succeed without the secret, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/webhooks/"+entries.ID.String()+"?user_id="+uuid.New().String(), nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("other user's webhook expected 404, got %d", rec.Code)
+	}
+
+	post := func(amount int64) entryResponse {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-05-01T12:00:00Z", "currency": "USD", "memo": "Pay", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": amount},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": amount},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var e entryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &e)
+		return e
+	}
+	entry := post(900)
+	if rec := doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": entry.ID.String()}); rec.Code != http.StatusCreated {
+		t.Fatalf("reverse expected 201, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodPatch, "/v1/accounts/"+cash.ID.String()+"?user_id="+userID.String(), map[string]any{"name": "Wallet"}); rec.Code != http.StatusOK {
+		t.Fatalf("patch expected 200, got %d", rec.Code)
+	}
+
+	dispatcher := webhook.New(store, store, recv.Client(), true)
+	now := time.Now()
+	if n, err := dispatcher.Dispatch(context.Background(), now); err != nil || n != 4 {
+		t.Fatalf("dispatch: n=%d err=%v", n, err)
+	}
+	types := map[string]int{}
+	mu.Lock()
+	first := append([]received(nil), got...)
+	mu.Unlock()
+	for _, r := range first {
+		var ev struct {
+			Type     string    `json:"type"`
+			EntityID uuid.UUID `json:"entity_id"`
+		}
+		_ = json.Unmarshal(r.body, &ev)
+		types[ev.Type]++
+		secret := entries.Secret
+		if ev.Type == "account.updated" {
+			secret = accounts.Secret
+		}
+		var ts int64
+		var sig string
+		for _, part := range strings.Split(r.header.Get(webhook.HeaderSignature), ",") {
+			k, v, _ := strings.Cut(part, "=")
+			switch k {
+			case "t":
+				ts, _ = strconv.ParseInt(v, 10, 64)
+			case "v1":
+				sig = v
+			}
+		}
+		if !hmac.Equal([]byte(sig), []byte(webhook.Sign(secret, ts, r.body))) || r.header.Get(webhook.HeaderEvent) != ev.Type {
+			t.Fatalf("bad signature or headers for %s: %v", ev.Type, r.header)
+		}
+		if ev.Type == "entry.reversed" && ev.EntityID != entry.ID {
+			t.Fatalf("entry.reversed should name the original entry, got %s", ev.EntityID)
+		}
+	}
+	if types["entry.created"] != 2 || types["entry.reversed"] != 1 || types["account.updated"] != 1 {
+		t.Fatalf("unexpected deliveries %v", types)
+	}
+	if n, _ := dispatcher.Dispatch(context.Background(), now); n != 0 {
+		t.Fatalf("events should be delivered once, got %d more attempts", n)
+	}
+
+	// A failing endpoint is retried with backoff until the delivery goes dead.
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	post(500)
+	for i := 0; i < webhook.MaxAttempts; i++ {
+		if n, err := dispatcher.Dispatch(context.Background(), now); err != nil || n != 1 {
+			t.Fatalf("attempt %d: n=%d err=%v", i+1, n, err)
+		}
+		if n, _ := dispatcher.Dispatch(context.Background(), now); n != 0 {
+			t.Fatalf("retry should wait for its backoff")
+		}
+		now = now.Add(webhook.MaxBackoff)
+	}
+	listDeliveries := func(status string) []webhookDeliveryResponse {
+		t.Helper()
+		rec := doJSON(h, http.MethodGet, "/v1/webhooks/deliveries?user_id="+userID.String()+"&status="+status, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("deliveries expected 200, got %d", rec.Code)
+		}
+		var out []webhookDeliveryResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return out
+	}
+	dead := listDeliveries("dead")
+	if len(dead) != 1 || dead[0].Attempts != webhook.MaxAttempts || dead[0].LastStatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected one dead delivery, got %+v", dead)
+	}
+
+	// Manual redelivery gets a fresh set of attempts.
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	rec := doJSON(h, http.MethodPost, "/v1/webhooks/deliveries/"+dead[0].ID.String()+"/redeliver?user_id="+userID.String(), nil)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("redeliver expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if n, err := dispatcher.Dispatch(context.Background(), now); err != nil || n != 1 {
+		t.Fatalf("redelivery dispatch: n=%d err=%v", n, err)
+	}
+	if delivered := listDeliveries("delivered"); len(delivered) != 5 {
+		t.Fatalf("expected 5 delivered, got %d", len(delivered))
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/webhooks/deliveries?user_id="+userID.String()+"&status=lost", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid status expected 400, got %d", rec.Code)
+	}
+}
+
+func TestWebhooks_DispatchSendsRoundsConcurrently(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	now := time.Now().UTC()
+	var mu sync.Mutex
+	inFlight, maxInFlight, maxLeased := 0, 0, 0
+	recv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ds, _ := store.ListWebhookDeliveries(r.Context(), userID, ledger.DeliveryPending)
+		leased := 0
+		for _, d := range ds {
+			if d.NextAttemptAt.After(now) {
+				leased++
+			}
+		}
+		mu.Lock()
+		inFlight++
+		maxInFlight, maxLeased = max(maxInFlight, inFlight), max(maxLeased, leased)
+		mu.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer recv.Close()
+	dispatcher := webhook.New(store, store, recv.Client(), true)
+	if _, err := dispatcher.CreateEndpoint(context.Background(), ledger.WebhookEndpoint{UserID: userID, URL: recv.URL, EventTypes: []ledger.EventType{ledger.EventEntryCreated}}); err != nil {
+		t.Fatalf("create endpoint: %v", err)
+	}
+	for i := 0; i < 25; i++ {
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": "2025-05-01T12:00:00Z", "currency": "USD", "memo": "Pay", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 100 + i},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": 100 + i},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("post entry expected 201, got %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if n, err := dispatcher.Dispatch(context.Background(), now); err != nil || n != 25 {
+		t.Fatalf("dispatch: n=%d err=%v", n, err)
+	}
+	// Deliveries go out a round at a time: several at once, and only the
+	// round being sent is leased.
+	if maxInFlight < 2 || maxLeased > 10 {
+		t.Fatalf("want concurrent rounds of at most 10 leases, got %d in flight and %d leased", maxInFlight, maxLeased)
+	}
+	if ds, _ := store.ListWebhookDeliveries(context.Background(), userID, ledger.DeliveryDelivered); len(ds) != 25 {
+		t.Fatalf("want 25 delivered, got %d", len(ds))
+	}
+}
+
+// sseEvent is one server-sent event read from a stream.
+type sseEvent struct {
+	id, event, data string
+}
+
+// openStream GETs path on srv and sends each event (heartbeats as event "heartbeat") to the returned channel.
+func openStream(t *testing.T, srv *httptest.Server, path, lastEventID string) <-chan sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+path, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream expected 200 text/event-stream, got %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	out := make(chan sseEvent, 64)
+	go func() {
+		defer resp.Body.Close()
+		defer close(out)
+		var ev sseEvent
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				if ev != (sseEvent{}) {
+					out <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, ": heartbeat"):
+				ev.event = "heartbeat"
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return out
+}
+
+// nextEvent returns the next non-heartbeat event, failing after a timeout.
+func nextEvent(t *testing.T, ch <-chan sseEvent) sseEvent {
+	t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			if ev.event != "heartbeat" {
+				return ev
+			}
+		case <-timeout:
+			t.Fatal("timed out waiting for event")
+		}
+	}
+}
+
+func TestStream_PushesChangesPerUserAndResumes(t *testing.T) {
+	store, _, userID, cash, income := setup(t)
+	api := New(store, store, store, store, store, store, store, testLogger())
+	api.streamHeartbeat = 20 * time.Millisecond
+	h := api.Handler()
+	// Short server timeouts must not cut the stream off.
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.ReadTimeout = 100 * time.Millisecond
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	// Cleanups run last-in first-out: streams are cancelled before the server closes.
+	t.Cleanup(srv.Close)
+
+	mine := openStream(t, srv, "/v1/stream?user_id="+userID.String(), "")
+	other := openStream(t, srv, "/v1/stream?user_id="+uuid.New().String(), "")
+	if rec := doJSON(h, http.MethodGet, "/v1/stream?user_id=nope", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid user_id expected 400, got %d", rec.Code)
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+		"user_id": userID.String(), "date": "2025-05-01T12:00:00Z", "currency": "USD", "memo": "Pay", "category": "general",
+		"lines": []map[string]any{
+			{"account_id": cash.ID.String(), "side": "debit", "amount_minor": 900},
+			{"account_id": income.ID.String(), "side": "credit", "amount_minor": 900},
+		},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("post entry expected 201, got %d", rec.Code)
+	}
+	var entry entryResponse
+	_ = json.Unmarshal(rec.Body.Bytes(), &entry)
+	created := nextEvent(t, mine)
+	var data streamEventResponse
+	if err := json.Unmarshal([]byte(created.data), &data); err != nil || created.event != "entry.created" || data.EntityID != entry.ID {
+		t.Fatalf("unexpected event %+v (%v)", created, err)
+	}
+	if rec := doJSON(h, http.MethodPost, "/v1/entries/reverse", map[string]any{"user_id": userID.String(), "entry_id": entry.ID.String()}); rec.Code != http.StatusCreated {
+		t.Fatalf("reverse expected 201, got %d", rec.Code)
+	}
+	if rec := doJSON(h, http.MethodPatch, "/v1/accounts/"+cash.ID.String()+"?user_id="+userID.String(), map[string]any{"name": "Wallet"}); rec.Code != http.StatusOK {
+		t.Fatalf("patch expected 200, got %d", rec.Code)
+	}
+	var got []string
+	for len(got) < 3 {
+		got = append(got, nextEvent(t, mine).event)
+	}
+	if strings.Join(got, ",") != "entry.created,entry.reversed,account.updated" {
+		t.Fatalf("unexpected events %v", got)
+	}
+
+	// The other user's stream only ever sees heartbeats.
+	select {
+	case ev := <-other:
+		if ev.event != "heartbeat" {
+			t.Fatalf("other user received %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a heartbeat on an idle stream")
+	}
+	for len(other) > 0 {
+		if ev := <-other; ev.event != "heartbeat" {
+			t.Fatalf("other user received %+v", ev)
+		}
+	}
+
+	// Resuming replays what followed Last-Event-ID.
+	resumed := openStream(t, srv, "/v1/stream?user_id="+userID.String(), created.id)
+	got = got[:0]
+	for len(got) < 3 {
+		got = append(got, nextEvent(t, resumed).event)
+	}
+	if strings.Join(got, ",") != "entry.created,entry.reversed,account.updated" {
+		t.Fatalf("unexpected replay %v", got)
+	}
+	// An ID the buffer cannot account for starts with a reset.
+	if ev := nextEvent(t, openStream(t, srv, "/v1/stream?user_id="+userID.String(), "999999")); ev.event != "reset" {
+		t.Fatalf("expected reset, got %+v", ev)
+	}
+}
+
+func TestStream_TokenMayOnlyStreamItsSubject(t *testing.T) {
+	t.Setenv("JWT_HS256_SECRET", "test-secret")
+	_, h, userID, _, _ := setup(t)
+	stream := func(sub string) *httptest.ResponseRecorder {
+		// A cancelled request returns as soon as the stream has opened.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest(http.MethodGet, "/v1/stream?user_id="+userID.String(), nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+signHS256(t, "test-secret", sub))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := stream(uuid.New().String()); rec.Code != http.StatusForbidden {
+		t.Fatalf("another subject's stream expected 403, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := stream(userID.String()); rec.Code != http.StatusOK {
+		t.Fatalf("own stream expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestBalances_MaterializedAsOfLedgerAndRebuild(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	for _, p := range []struct {
+		date string
+		amt  int64
+	}{{"2025-01-10T09:00:00Z", 1000}, {"2025-01-10T18:00:00Z", 200}, {"2025-02-01T12:00:00Z", 50}} {
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": p.date, "currency": "USD", "category": "general",
+			"lines": []map[string]any{
+				{"account_id": cash.ID.String(), "side": "debit", "amount_minor": p.amt},
+				{"account_id": income.ID.String(), "side": "credit", "amount_minor": p.amt},
+			},
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create failed: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+	balance := func(asOf string) int64 {
+		t.Helper()
+		path := "/v1/accounts/" + cash.ID.String() + "/balance?user_id=" + userID.String()
+		if asOf != "" {
+			path += "&as_of=" + asOf
+		}
+		rec := doJSON(h, http.MethodGet, path, nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("balance expected 200, got %d", rec.Code)
+		}
+		var br struct {
+			BalanceMinor int64 `json:"balance_minor"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &br)
+		return br.BalanceMinor
+	}
+	check := func() {
+		t.Helper()
+		// Whole days, part of a day, before any entry and everything.
+		for asOf, want := range map[string]int64{"2025-01-10T12:00:00Z": 1000, "2025-01-31T00:00:00Z": 1200, "2025-01-01T00:00:00Z": 0, "": 1250} {
+			if got := balance(asOf); got != want {
+				t.Fatalf("balance as of %q = %d, want %d", asOf, got, want)
+			}
+		}
+		if tb := trialBalances(t, h, userID); len(tb) != 2 {
+			t.Fatalf("unexpected trial balance: %v", tb)
+		}
+	}
+	check()
+
+	// The ledger's running balance starts from the balance before the window.
+	rec := doJSON(h, http.MethodGet, "/v1/accounts/"+cash.ID.String()+"/ledger?user_id="+userID.String()+"&from=2025-01-10T12:00:00Z&limit=1", nil)
+	var page struct {
+		Items []struct {
+			Running int64 `json:"running_balance_minor"`
+		} `json:"items"`
+		NextCursor *string `json:"next_cursor"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.Items[0].Running != 1200 || page.NextCursor == nil {
+		t.Fatalf("unexpected first ledger page: %s", rec.Body.String())
+	}
+	rec = doJSON(h, http.MethodGet, "/v1/accounts/"+cash.ID.String()+"/ledger?user_id="+userID.String()+"&from=2025-01-10T12:00:00Z&limit=1&cursor="+url.QueryEscape(*page.NextCursor), nil)
+	_ = json.Unmarshal(rec.Body.Bytes(), &page)
+	if len(page.Items) != 1 || page.Items[0].Running != 1250 {
+		t.Fatalf("unexpected second ledger page: %s", rec.Body.String())
+	}
+
+	// Rebuilding from the entries gives the same balances.
+	if err := store.RebuildBalances(context.Background(), userID); err != nil {
+		t.Fatalf("rebuild: %v", err)
+	}
+	check()
+}
+
+func TestEntries_KeysetPagingByAccountAndLedgerAtSharedInstants(t *testing.T) {
+	store, h, userID, cash, income := setup(t)
+	fees := ledger.Account{ID: uuid.New(), UserID: userID, Name: "Fees", Currency: "USD", Type: ledger.AccountTypeExpense, Group: "fees", Vendor: "Bank"}
+	store.SeedAccount(fees)
+	post := func(date, category string, lines ...map[string]any) {
+		t.Helper()
+		rec := doJSON(h, http.MethodPost, "/v1/entries", map[string]any{
+			"user_id": userID.String(), "date": date, "currency": "USD", "category": category, "lines": lines,
+		})
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create failed: %d %s", rec.Code, rec.Body.String())
+		}
+	}
+	line := func(acc ledger.Account, side string, amt int64) map[string]any {
+		return map[string]any{"account_id": acc.ID.String(), "side": side, "amount_minor": amt}
+	}
+	// Three entries share an instant; one has two lines on cash. Fees never touch cash.
+	post("2025-04-01T10:00:00Z", "income", line(cash, "debit", 100), line(income, "credit", 100))
+	post("2025-04-01T10:00:00Z", "income", line(cash, "debit", 20), line(cash, "debit", 30), line(income, "credit", 50))
+	post("2025-04-01T10:00:00Z", "general", line(fees, "debit", 5), line(income, "credit", 5))
+	post("2025-04-02T10:00:00Z", "general", line(cash, "credit", 40), line(fees, "debit", 40))
+	post("2025-04-03T10:00:00Z", "general", line(fees, "debit", 7), line(income, "credit", 7))
+
+	// Entries touching cash, one per page, follow (date, id) order without repeats.
+	seen := map[uuid.UUID]bool{}
+	cursor := ""
+	for pages := 0; ; pages++ {
+		path := "/v1/entries?user_id=" + userID.String() + "&account_id=" + cash.ID.String() + "&limit=1"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		rec := doJSON(h, http.MethodGet, path, nil)
+		var page struct {
+			Items []entryResponse `json:"items"`
+			Next  *string         `json:"next_cursor"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		if rec.Code != http.StatusOK || len(page.Items) != 1 || seen[page.Items[0].ID] || pages > 3 {
+			t.Fatalf("unexpected page %d: %d %s", pages, rec.Code, rec.Body.String())
+		}
+		seen[page.Items[0].ID] = true
+		if page.Next == nil {
+			break
+		}
+		cursor = *page.Next
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected 3 cash entries, got %d", len(seen))
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String()+"&account_id="+cash.ID.String()+"&category=INCOME&to=2025-04-01T23:59:59Z", nil); !strings.Contains(rec.Body.String(), `"category":"income"`) || strings.Count(rec.Body.String(), `"date"`) != 2 {
+		t.Fatalf("unexpected filtered list: %s", rec.Body.String())
+	}
+	if rec := doJSON(h, http.MethodGet, "/v1/entries?user_id="+userID.String()+"&account_id=nope", nil); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid account_id, got %d", rec.Code)
+	}
+
+	// The ledger pages line by line, splitting the two-line entry, and every
+	// page's running balance continues from the lines before it.
+	var running []int64
+	cursor = ""
+	for {
+		path := "/v1/accounts/" + cash.ID.String() + "/ledger?user_id=" + userID.String() + "&limit=1"
+		if cursor != "" {
+			path += "&cursor=" + url.QueryEscape(cursor)
+		}
+		rec := doJSON(h, http.MethodGet, path, nil)
+		var page struct {
+			Items []struct {
+				AmountMinor  int64 `json:"amount_minor"`
+				RunningMinor int64 `json:"running_balance_minor"`
+			} `json:"items"`
+			Next *string `json:"next_cursor"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &page)
+		if rec.Code != http.StatusOK || len(page.Items) != 1 || len(running) > 4 {
+			t.Fatalf("unexpected ledger page: %d %s", rec.Code, rec.Body.String())
+		}
+		running = append(running, page.Items[0].RunningMinor)
+		if page.Next == nil {
+			break
+		}
+		cursor = *page.Next
+	}
+	if len(running) != 4 || running[3] != 110 {
+		t.Fatalf("unexpected running balances: %v", running)
+	}
+	// The same instant's lines total 150 before the credit of 40, in any order.
+	if running[2] != 150 {
+		t.Fatalf("unexpected running balances: %v", running)
+	}
+}
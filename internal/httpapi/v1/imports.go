@@ -0,0 +1,322 @@
+// Statement import handlers: CSV column-mapping profiles and CSV, OFX,
+// camt.053 and MT940 uploads.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/imports"
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+// postImportProfile handles POST /v1/imports/csv/profiles
+func (s *Server) postImportProfile(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postImportProfileRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	hasHeader := true
+	if req.Mapping.HasHeader != nil {
+		hasHeader = *req.Mapping.HasHeader
+	}
+	m := req.Mapping
+	created, err := s.importSvc.CreateProfile(r.Context(), ledger.ImportProfile{
+		UserID:           req.UserID,
+		Name:             req.Name,
+		CounterAccountID: req.CounterAccountID,
+		Mapping: ledger.CSVMapping{
+			Delimiter:       m.Delimiter,
+			HasHeader:       hasHeader,
+			SkipRows:        m.SkipRows,
+			DateColumn:      m.DateColumn,
+			DateFormat:      m.DateFormat,
+			AmountColumn:    m.AmountColumn,
+			Sign:            m.Sign,
+			InflowColumn:    m.InflowColumn,
+			OutflowColumn:   m.OutflowColumn,
+			DecimalComma:    m.DecimalComma,
+			MemoColumns:     m.MemoColumns,
+			ReferenceColumn: m.ReferenceColumn,
+		},
+	})
+	if err != nil {
+		writeImportErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusCreated, toImportProfileResponse(created))
+}
+
+// listImportProfiles handles GET /v1/imports/csv/profiles?user_id=
+func (s *Server) listImportProfiles(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	list, err := s.importSvc.ListProfiles(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch import profiles"})
+		return
+	}
+	out := make([]importProfileResponse, 0, len(list))
+	for _, p := range list {
+		out = append(out, toImportProfileResponse(p))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getImportProfile handles GET /v1/imports/csv/profiles/{id}?user_id=
+func (s *Server) getImportProfile(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := importProfileParams(w, r)
+	if !ok {
+		return
+	}
+	p, err := s.importSvc.GetProfile(r.Context(), userID, id)
+	if err != nil {
+		writeImportErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toImportProfileResponse(p))
+}
+
+// deleteImportProfile handles DELETE /v1/imports/csv/profiles/{id}?user_id=
+func (s *Server) deleteImportProfile(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := importProfileParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.importSvc.DeleteProfile(r.Context(), userID, id); err != nil {
+		writeImportErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postCSVImport handles POST /v1/imports/csv?user_id=&profile_id=&account_id=[&counter_account_id=]
+// with a text/csv body. 201 when rows were posted; 200 when every row was a duplicate;
+// 422 with per-row errors when any row failed, in which case nothing is posted.
+func (s *Server) postCSVImport(w http.ResponseWriter, r *http.Request) {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+	if mime != "text/csv" {
+		writeErr(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported_media_type")
+		return
+	}
+	q := r.URL.Query()
+	t, ok := importTarget(w, r, true)
+	if !ok {
+		return
+	}
+	profileID, err := uuid.Parse(q.Get("profile_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid profile_id"})
+		return
+	}
+	res, err := s.importSvc.ImportCSV(r.Context(), t, profileID, r.Body)
+	if err != nil {
+		writeImportErr(w, err)
+		return
+	}
+	writeImportResult(w, res)
+}
+
+// ofxMediaTypes are the content types banks and clients use for OFX/QFX downloads.
+var ofxMediaTypes = map[string]bool{
+	"application/x-ofx": true, "application/ofx": true, "application/vnd.intu.qfx": true, "application/x-qfx": true,
+	"application/xml": true, "text/xml": true, "text/plain": true, "application/octet-stream": true,
+}
+
+// xmlMediaTypes are accepted for camt.053 uploads.
+var xmlMediaTypes = map[string]bool{"application/xml": true, "text/xml": true, "application/octet-stream": true}
+
+// mt940MediaTypes are accepted for MT940 uploads, which have no registered type.
+var mt940MediaTypes = map[string]bool{"text/plain": true, "application/octet-stream": true, "application/x-mt940": true}
+
+// postStatementImport returns the handler for POST /v1/imports/{format}?user_id=[&account_id=][&counter_account_id=][&bank_account=]
+// for a statement format. Without account_id the statement is posted to the
+// account whose tracker.bank_account metadata matches the statement's account.
+// Statuses as for CSV imports; the response also compares the balances the
+// statement reports with the account balance.
+func (s *Server) postStatementImport(parse statement.ParserFunc, mediaTypes map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mime := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+		if !mediaTypes[mime] {
+			writeErr(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported_media_type")
+			return
+		}
+		t, ok := importTarget(w, r, false)
+		if !ok {
+			return
+		}
+		stmts, err := parse.Parse(r.Body)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		st, ok := pickStatement(w, stmts, r.URL.Query().Get("bank_account"))
+		if !ok {
+			return
+		}
+		res, err := s.importSvc.ImportStatement(r.Context(), t, st)
+		if err != nil {
+			writeImportErr(w, err)
+			return
+		}
+		writeImportResult(w, res)
+	}
+}
+
+// pickStatement selects the statement for bank_account, or the only one in the file.
+func pickStatement(w http.ResponseWriter, stmts []statement.Statement, bankAccount string) (statement.Statement, bool) {
+	if bankAccount == "" {
+		if len(stmts) != 1 {
+			badRequest(w, "file has "+itoa(len(stmts))+" statements; pass bank_account to choose one")
+			return statement.Statement{}, false
+		}
+		return stmts[0], true
+	}
+	for _, st := range stmts {
+		if strings.EqualFold(strings.ReplaceAll(st.Account, " ", ""), strings.ReplaceAll(bankAccount, " ", "")) {
+			return st, true
+		}
+	}
+	badRequest(w, "no statement for bank_account in file")
+	return statement.Statement{}, false
+}
+
+// importTarget reads user_id, account_id and the optional counter_account_id
+// query params. account_id may be omitted unless requireAccount is set.
+func importTarget(w http.ResponseWriter, r *http.Request, requireAccount bool) (imports.Target, bool) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return imports.Target{}, false
+	}
+	t := imports.Target{UserID: userID}
+	if v := q.Get("account_id"); v != "" || requireAccount {
+		if t.AccountID, err = uuid.Parse(v); err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid account_id"})
+			return imports.Target{}, false
+		}
+	}
+	if v := q.Get("counter_account_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid counter_account_id"})
+			return imports.Target{}, false
+		}
+		t.CounterAccountID = &id
+	}
+	return t, true
+}
+
+func writeImportResult(w http.ResponseWriter, res imports.Result) {
+	if len(res.Errors) > 0 {
+		out := importErrorsResponse{Errors: make([]importRowError, 0, len(res.Errors))}
+		for _, e := range res.Errors {
+			out.Errors = append(out.Errors, importRowError{Row: e.Row, Code: e.Code, Error: e.Err.Error()})
+		}
+		toJSON(w, http.StatusUnprocessableEntity, out)
+		return
+	}
+	out := importResponse{Imported: make([]entryResponse, 0, len(res.Entries)), Duplicates: make([]importDuplicate, 0, len(res.Duplicates))}
+	for _, e := range res.Entries {
+		out.Imported = append(out.Imported, toEntryResponse(e))
+	}
+	for _, d := range res.Duplicates {
+		out.Duplicates = append(out.Duplicates, importDuplicate{Row: d.Row, SourceTxnID: d.SourceID, InputHash: d.InputHash, EntryID: d.EntryID})
+	}
+	for _, b := range res.Balances {
+		out.Balances = append(out.Balances, importBalanceCheck{
+			Kind:            b.Kind,
+			AsOf:            b.AsOf,
+			Currency:        b.Currency,
+			StatementMinor:  b.StatementMinor,
+			LedgerMinor:     b.LedgerMinor,
+			DifferenceMinor: b.DifferenceMinor(),
+			Matches:         b.DifferenceMinor() == 0,
+		})
+	}
+	status := http.StatusCreated
+	if len(out.Imported) == 0 {
+		status = http.StatusOK
+	}
+	toJSON(w, status, out)
+}
+
+func toImportProfileResponse(p ledger.ImportProfile) importProfileResponse {
+	m := p.Mapping
+	hasHeader := m.HasHeader
+	return importProfileResponse{
+		ID:               p.ID,
+		UserID:           p.UserID,
+		Name:             p.Name,
+		CounterAccountID: p.CounterAccountID,
+		Mapping: csvMappingBody{
+			Delimiter:       m.Delimiter,
+			HasHeader:       &hasHeader,
+			SkipRows:        m.SkipRows,
+			DateColumn:      m.DateColumn,
+			DateFormat:      m.DateFormat,
+			AmountColumn:    m.AmountColumn,
+			Sign:            m.Sign,
+			InflowColumn:    m.InflowColumn,
+			OutflowColumn:   m.OutflowColumn,
+			DecimalComma:    m.DecimalComma,
+			MemoColumns:     m.MemoColumns,
+			ReferenceColumn: m.ReferenceColumn,
+		},
+	}
+}
+
+func importProfileParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid profile id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeImportErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	case errors.Is(err, errs.ErrConflict):
+		conflict(w, "rows are being imported by another request; retry to skip those already posted")
+	case errors.Is(err, errs.ErrMixedCurrency):
+		unprocessable(w, "counter account currency must match the account currency", "mixed_currency")
+	case errors.Is(err, imports.ErrStatementCurrency):
+		unprocessable(w, err.Error(), "mixed_currency")
+	case errors.Is(err, imports.ErrUnmappedAccount):
+		unprocessable(w, err.Error(), "unmapped_account")
+	case errors.Is(err, imports.ErrNotBankAccount):
+		unprocessable(w, err.Error(), "invalid_account")
+	default:
+		badRequest(w, err.Error())
+	}
+}
@@ -5,6 +5,17 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/audit"
+	"github.com/tinoosan/ledger/internal/service/budget"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/imports"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/period"
+	"github.com/tinoosan/ledger/internal/service/reconciliation"
+	"github.com/tinoosan/ledger/internal/service/rules"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+	"github.com/tinoosan/ledger/internal/service/webhook"
 )
 
 // AccountReader abstracts account read operations.
@@ -21,6 +32,8 @@ type AccountReader interface {
 type EntryReader interface {
 	// ListEntries returns entries for a given user.
 	ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.JournalEntry, error)
+	// QueryEntries returns a page of entries matching the filter, in (date, id) order.
+	QueryEntries(ctx context.Context, f journal.EntryFilter) ([]ledger.JournalEntry, error)
 	// GetEntry returns an entry by id for the user.
 	GetEntry(ctx context.Context, userID, entryID uuid.UUID) (ledger.JournalEntry, error)
 }
@@ -33,6 +46,66 @@ type IdempotencyStore interface {
 	SaveIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, entryID uuid.UUID) error
 }
 
+// periodStore is optionally implemented by stores that persist accounting periods.
+type periodStore interface {
+	period.Repo
+	period.Writer
+}
+
+// fxStore is optionally implemented by stores that persist exchange rates.
+type fxStore interface {
+	fx.Repo
+	fx.Writer
+}
+
+// budgetStore is optionally implemented by stores that persist budgets.
+type budgetStore interface {
+	budget.Repo
+	budget.Writer
+}
+
+// scheduleStore is optionally implemented by stores that persist recurring entry schedules.
+type scheduleStore interface {
+	schedule.Repo
+	schedule.Writer
+}
+
+// ruleStore is optionally implemented by stores that persist categorization rules.
+type ruleStore interface {
+	rules.Repo
+	rules.Writer
+}
+
+// importStore is optionally implemented by stores that persist statement import profiles.
+type importStore interface {
+	imports.Repo
+	imports.Writer
+}
+
+// reconciliationStore is optionally implemented by stores that persist bank reconciliations.
+type reconciliationStore interface {
+	reconciliation.Repo
+	reconciliation.Writer
+}
+
+// assertionStore is optionally implemented by stores that persist balance assertions.
+type assertionStore interface {
+	assertion.Repo
+	assertion.Writer
+}
+
+// auditStore is optionally implemented by stores that keep an audit log.
+type auditStore interface {
+	audit.Repo
+	audit.Writer
+}
+
+// webhookStore is optionally implemented by stores with an event outbox and webhook endpoints.
+type webhookStore interface {
+	webhook.Repo
+	webhook.Writer
+}
+
 // ReadyChecker is optionally implemented by stores to indicate readiness.
 type ReadyChecker interface {
 	Ready(ctx context.Context) error
@@ -0,0 +1,84 @@
+// Plain-text journal import from ledger-cli, hledger and beancount.
+package v1
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/plaintext"
+	"github.com/tinoosan/ledger/internal/service/journalimport"
+)
+
+// journalMediaTypes are accepted for plain-text journal uploads.
+var journalMediaTypes = map[string]bool{"text/plain": true, "application/octet-stream": true}
+
+// postJournalImport handles POST /v1/imports/journal?user_id=[&format=ledger|hledger|beancount]
+// The body is the journal. Accounts, entries and balance assertions are
+// created together or not at all: any problem yields 422 with every error
+// found, by line.
+func (s *Server) postJournalImport(w http.ResponseWriter, r *http.Request) {
+	mime := strings.ToLower(strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0]))
+	if !journalMediaTypes[mime] {
+		writeErr(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "unsupported_media_type")
+		return
+	}
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	format, err := plaintext.ParseFormat(q.Get("format"))
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	res, err := s.journalImportSvc.Import(r.Context(), userID, format, r.Body)
+	switch {
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+		return
+	case errors.Is(err, journalimport.ErrUnreadable):
+		badRequest(w, err.Error())
+		return
+	case err != nil:
+		writeErr(w, http.StatusInternalServerError, "could not import journal", "")
+		return
+	}
+	if len(res.Errors) > 0 {
+		out := journalImportErrorsResponse{Errors: make([]journalImportLineError, 0, len(res.Errors))}
+		for _, e := range res.Errors {
+			out.Errors = append(out.Errors, journalImportLineError{Line: e.Line, Code: e.Code, Error: e.Err.Error()})
+		}
+		toJSON(w, http.StatusUnprocessableEntity, out)
+		return
+	}
+	// The import commits its own transaction, bypassing svc, so its entries are published here.
+	for _, e := range res.Entries {
+		s.hub.Publish(ledger.EntryEvents(e)...)
+	}
+	out := journalImportResponse{
+		Accounts:   make([]accountResponse, 0, len(res.Accounts)),
+		Entries:    make([]entryResponse, 0, len(res.Entries)),
+		Assertions: make([]assertionResponse, 0, len(res.Assertions)),
+	}
+	for _, a := range res.Accounts {
+		out.Accounts = append(out.Accounts, accountResponse{ID: a.ID, UserID: a.UserID, Name: a.Name, Currency: a.Currency, Type: a.Type, Group: a.Group, Vendor: a.Vendor, Path: a.Path(), Metadata: a.Metadata, System: a.System, Active: a.Active})
+	}
+	for _, e := range res.Entries {
+		out.Entries = append(out.Entries, toEntryResponse(e))
+	}
+	for _, a := range res.Assertions {
+		out.Assertions = append(out.Assertions, toAssertionResponse(a))
+	}
+	status := http.StatusCreated
+	if len(out.Accounts)+len(out.Entries)+len(out.Assertions) == 0 {
+		status = http.StatusOK
+	}
+	toJSON(w, status, out)
+}
@@ -4,6 +4,7 @@ package v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -23,6 +24,9 @@ const ctxKeyListAccounts ctxKey = "validatedListAccounts"
 const ctxKeyReverseEntry ctxKey = "validatedReverseEntry"
 const ctxKeyTrialBalance ctxKey = "validatedTrialBalance"
 
+// ctxKeyClaims holds the verified JWT claims of an authenticated request.
+const ctxKeyClaims ctxKey = "jwtClaims"
+
 // validatePostEntry ensures the POST /entries request adheres to business invariants
 // and stores the validated request struct in the request context for the handler to use.
 func (s *Server) validatePostEntry() func(http.Handler) http.Handler {
@@ -48,7 +52,16 @@ func (s *Server) validatePostEntry() func(http.Handler) http.Handler {
 				}
 			}
 			// Convert to service EntryInput and validate via service layer
-			e := toEntryDomain(req)
+			e, err := toEntryDomain(req)
+			if err != nil {
+				toJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+				return
+			}
+			// Categorization rules rewrite the draft before it is validated.
+			if e, err = s.applyRules(r, e); err != nil {
+				toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not apply rules"})
+				return
+			}
 			if err := s.svc.ValidateEntry(r.Context(), e); err != nil {
 				code, msg := mapValidationError(err)
 				unprocessable(w, msg, code)
@@ -86,6 +99,12 @@ func (s *Server) validateListEntries() func(http.Handler) http.Handler {
 			if cat := r.URL.Query().Get("category"); cat != "" {
 				leq.Category = cat
 			}
+			if raw := vals.Get("account_id"); raw != "" {
+				if leq.AccountID, err = uuid.Parse(raw); err != nil {
+					toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid account_id"})
+					return
+				}
+			}
 			if ir := r.URL.Query().Get("is_reversed"); ir != "" {
 				if ir == "true" || ir == "1" {
 					b := true
@@ -96,6 +115,19 @@ func (s *Server) validateListEntries() func(http.Handler) http.Handler {
 					leq.IsReversed = &b
 				}
 			}
+			if rel := vals.Get("relation"); rel != "" {
+				leq.Relation = ledger.RelationKind(rel)
+				if !leq.Relation.IsValid() {
+					toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid relation"})
+					return
+				}
+			}
+			if raw := vals.Get("related_to"); raw != "" {
+				if leq.RelatedTo, err = uuid.Parse(raw); err != nil {
+					toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid related_to"})
+					return
+				}
+			}
 			ctx := context.WithValue(r.Context(), ctxKeyListEntries, leq)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -117,6 +149,22 @@ func (s *Server) validateReverseEntry() func(http.Handler) http.Handler {
 				toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id and entry_id are required"})
 				return
 			}
+			if len(req.Lines) > 0 && req.AmountMinor != nil {
+				toJSON(w, http.StatusBadRequest, errorResponse{Error: "lines and amount_minor are mutually exclusive"})
+				return
+			}
+			if req.AmountMinor != nil && *req.AmountMinor <= 0 {
+				toJSON(w, http.StatusBadRequest, errorResponse{Error: "amount_minor must be > 0"})
+				return
+			}
+			seen := make(map[uuid.UUID]bool, len(req.Lines))
+			for i, l := range req.Lines {
+				if l.LineID == uuid.Nil || l.AmountMinor <= 0 || seen[l.LineID] {
+					toJSON(w, http.StatusBadRequest, errorResponse{Error: "lines[" + itoa(i) + "]: line_id must be unique and amount_minor > 0"})
+					return
+				}
+				seen[l.LineID] = true
+			}
 			ctx := context.WithValue(r.Context(), ctxKeyReverseEntry, req)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
@@ -148,7 +196,12 @@ func (s *Server) validateTrialBalance() func(http.Handler) http.Handler {
 					return
 				}
 			}
-			ctx := context.WithValue(r.Context(), ctxKeyTrialBalance, trialBalanceQuery{UserID: userID, AsOf: asOf})
+			rc := strings.ToUpper(strings.TrimSpace(q.Get("reporting_currency")))
+			if rc != "" && len(rc) != 3 {
+				toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid reporting_currency"})
+				return
+			}
+			ctx := context.WithValue(r.Context(), ctxKeyTrialBalance, trialBalanceQuery{UserID: userID, AsOf: asOf, ReportingCurrency: rc})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
@@ -267,13 +320,18 @@ func toAccountDomain(req postAccountRequest) ledger.Account {
 	}
 }
 
-func toEntryDomain(req postEntryRequest) ledger.JournalEntry {
+func toEntryDomain(req postEntryRequest) (ledger.JournalEntry, error) {
 	// Construct domain JournalEntry with money.Amount lines
+	entryCurr := strings.ToUpper(req.Currency)
 	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(req.Lines))}
-	for _, line := range req.Lines {
-		amt, _ := money.NewAmountFromMinorUnits(strings.ToUpper(req.Currency), line.AmountMinor)
+	for i, line := range req.Lines {
+		jl, err := toLineDomain(line, entryCurr)
+		if err != nil {
+			return ledger.JournalEntry{}, errors.New("lines[" + itoa(i) + "]: " + err.Error())
+		}
 		id := uuid.New()
-		lines.ByID[id] = &ledger.JournalLine{ID: id, AccountID: line.AccountID, Side: line.Side, Amount: amt}
+		jl.ID = id
+		lines.ByID[id] = &jl
 	}
 	return ledger.JournalEntry{
 		UserID:   req.UserID,
@@ -283,5 +341,27 @@ func toEntryDomain(req postEntryRequest) ledger.JournalEntry {
 		Category: req.Category,
 		Metadata: meta.New(req.Metadata),
 		Lines:    lines,
+	}, nil
+}
+
+// toLineDomain builds a domain line; the line currency defaults to the entry currency
+// and an exchange rate (line currency -> entry currency) is attached when provided.
+func toLineDomain(line postEntryLine, entryCurr string) (ledger.JournalLine, error) {
+	lineCurr := entryCurr
+	if line.Currency != "" {
+		lineCurr = strings.ToUpper(line.Currency)
+	}
+	amt, err := money.NewAmountFromMinorUnits(lineCurr, line.AmountMinor)
+	if err != nil {
+		return ledger.JournalLine{}, errors.New("invalid currency")
+	}
+	jl := ledger.JournalLine{AccountID: line.AccountID, Side: line.Side, Amount: amt}
+	if line.ExchangeRate != "" {
+		rate, err := money.ParseExchRate(lineCurr, entryCurr, line.ExchangeRate)
+		if err != nil {
+			return ledger.JournalLine{}, errors.New("invalid exchange_rate")
+		}
+		jl.Rate = &rate
 	}
+	return jl, nil
 }
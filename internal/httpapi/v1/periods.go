@@ -0,0 +1,117 @@
+// Accounting period handlers: define, list, close, reopen and lock. Everything
+// but listing is routed behind requireAdmin.
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"errors"
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/period"
+)
+
+// postPeriod handles POST /v1/periods
+func (s *Server) postPeriod(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postPeriodRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	p, err := s.periodSvc.Create(r.Context(), ledger.Period{UserID: req.UserID, Name: req.Name, Start: req.Start, End: req.End})
+	if err != nil {
+		if errors.Is(err, period.ErrOverlap) {
+			writeErr(w, http.StatusConflict, err.Error(), "period_overlap")
+			return
+		}
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		badRequest(w, err.Error())
+		return
+	}
+	toJSON(w, http.StatusCreated, toPeriodResponse(p))
+}
+
+// listPeriods handles GET /v1/periods?user_id=
+func (s *Server) listPeriods(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	periods, err := s.periodSvc.List(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch periods"})
+		return
+	}
+	out := make([]periodResponse, 0, len(periods))
+	for _, p := range periods {
+		out = append(out, toPeriodResponse(p))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// closePeriod handles POST /v1/periods/{id}/close?user_id=
+func (s *Server) closePeriod(w http.ResponseWriter, r *http.Request) {
+	s.transitionPeriod(w, r, s.periodSvc.Close)
+}
+
+// reopenPeriod handles POST /v1/periods/{id}/reopen?user_id=
+func (s *Server) reopenPeriod(w http.ResponseWriter, r *http.Request) {
+	s.transitionPeriod(w, r, s.periodSvc.Reopen)
+}
+
+// lockPeriod handles POST /v1/periods/{id}/lock?user_id=
+func (s *Server) lockPeriod(w http.ResponseWriter, r *http.Request) {
+	s.transitionPeriod(w, r, s.periodSvc.Lock)
+}
+
+// transitionPeriod parses the common path/query params and applies a status change.
+func (s *Server) transitionPeriod(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error)) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid period id"})
+		return
+	}
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	p, err := apply(r.Context(), userID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, errs.ErrNotFound):
+			notFound(w)
+		case errors.Is(err, errs.ErrForbidden):
+			forbidden(w, "forbidden")
+		case errors.Is(err, errs.ErrImmutable):
+			unprocessable(w, "period_locked", "period_locked")
+		case errors.Is(err, errs.ErrInvalid):
+			badRequest(w, "invalid")
+		default:
+			writeErr(w, http.StatusInternalServerError, "could not update period", "")
+		}
+		return
+	}
+	toJSON(w, http.StatusOK, toPeriodResponse(p))
+}
+
+func toPeriodResponse(p ledger.Period) periodResponse {
+	return periodResponse{ID: p.ID, UserID: p.UserID, Name: p.Name, Start: p.Start, End: p.End, Status: p.Status, ClosedAt: p.ClosedAt}
+}
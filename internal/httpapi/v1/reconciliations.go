@@ -0,0 +1,379 @@
+// Bank reconciliation handlers: statement upload, matching, finish and reopen.
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/reconciliation"
+)
+
+// postReconciliation handles POST /v1/reconciliations
+func (s *Server) postReconciliation(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postReconciliationRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if req.AccountID == uuid.Nil {
+		badRequest(w, "account_id is required")
+		return
+	}
+	if req.StatementDate.IsZero() {
+		badRequest(w, "statement_date is required")
+		return
+	}
+	accs, err := s.accReader.FetchAccounts(r.Context(), req.UserID, []uuid.UUID{req.AccountID})
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch accounts"})
+		return
+	}
+	acc, ok := accs[req.AccountID]
+	if !ok {
+		notFound(w)
+		return
+	}
+	rec := ledger.Reconciliation{UserID: req.UserID, AccountID: req.AccountID, StatementDate: req.StatementDate}
+	if rec.StatementBalance, err = money.NewAmountFromMinorUnits(acc.Currency, req.StatementBalanceMinor); err != nil {
+		badRequest(w, "invalid statement_balance_minor")
+		return
+	}
+	if req.OpeningBalanceMinor != nil {
+		if rec.OpeningBalance, err = money.NewAmountFromMinorUnits(acc.Currency, *req.OpeningBalanceMinor); err != nil {
+			badRequest(w, "invalid opening_balance_minor")
+			return
+		}
+	}
+	if rec.Lines, err = toStatementLines(acc.Currency, req.Lines); err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	created, err := s.reconciliationSvc.Create(r.Context(), rec)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusCreated, toReconciliationResponse(created))
+}
+
+// listReconciliations handles GET /v1/reconciliations?user_id=[&account_id=]
+func (s *Server) listReconciliations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	var accountID *uuid.UUID
+	if raw := q.Get("account_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid account_id"})
+			return
+		}
+		accountID = &id
+	}
+	list, err := s.reconciliationSvc.List(r.Context(), userID, accountID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch reconciliations"})
+		return
+	}
+	out := make([]reconciliationResponse, 0, len(list))
+	for _, rec := range list {
+		out = append(out, toReconciliationResponse(rec))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getReconciliation handles GET /v1/reconciliations/{id}?user_id=
+func (s *Server) getReconciliation(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := reconciliationParams(w, r)
+	if !ok {
+		return
+	}
+	rec, err := s.reconciliationSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toReconciliationResponse(rec))
+}
+
+// addStatementLines handles POST /v1/reconciliations/{id}/lines
+func (s *Server) addStatementLines(w http.ResponseWriter, r *http.Request) {
+	var req addStatementLinesRequest
+	if !decodeReconciliationBody(w, r, &req) {
+		return
+	}
+	id, ok := reconciliationID(w, r)
+	if !ok {
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	rec, err := s.reconciliationSvc.Get(r.Context(), req.UserID, id)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	lines, err := toStatementLines(rec.StatementBalance.Curr().Code(), req.Lines)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	updated, err := s.reconciliationSvc.AddLines(r.Context(), req.UserID, id, lines)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toReconciliationResponse(updated))
+}
+
+// autoMatchReconciliation handles POST /v1/reconciliations/{id}/auto-match
+func (s *Server) autoMatchReconciliation(w http.ResponseWriter, r *http.Request) {
+	var req autoMatchRequest
+	if !decodeReconciliationBody(w, r, &req) {
+		return
+	}
+	id, ok := reconciliationID(w, r)
+	if !ok {
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if req.DateWindowDays < 0 {
+		badRequest(w, "date_window_days must not be negative")
+		return
+	}
+	rec, n, err := s.reconciliationSvc.AutoMatch(r.Context(), req.UserID, id, reconciliation.MatchOptions{DateWindowDays: req.DateWindowDays})
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, autoMatchResponse{Matched: n, Reconciliation: toReconciliationResponse(rec)})
+}
+
+// matchStatementLine handles POST /v1/reconciliations/{id}/match
+func (s *Server) matchStatementLine(w http.ResponseWriter, r *http.Request) {
+	var req matchRequest
+	if !decodeReconciliationBody(w, r, &req) {
+		return
+	}
+	id, ok := reconciliationID(w, r)
+	if !ok {
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if req.StatementLineID == uuid.Nil || req.JournalLineID == uuid.Nil {
+		badRequest(w, "statement_line_id and journal_line_id are required")
+		return
+	}
+	rec, err := s.reconciliationSvc.Match(r.Context(), req.UserID, id, req.StatementLineID, req.JournalLineID)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toReconciliationResponse(rec))
+}
+
+// unmatchStatementLine handles POST /v1/reconciliations/{id}/unmatch
+func (s *Server) unmatchStatementLine(w http.ResponseWriter, r *http.Request) {
+	var req unmatchRequest
+	if !decodeReconciliationBody(w, r, &req) {
+		return
+	}
+	id, ok := reconciliationID(w, r)
+	if !ok {
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if req.StatementLineID == uuid.Nil {
+		badRequest(w, "statement_line_id is required")
+		return
+	}
+	rec, err := s.reconciliationSvc.Unmatch(r.Context(), req.UserID, id, req.StatementLineID)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toReconciliationResponse(rec))
+}
+
+// finishReconciliation handles POST /v1/reconciliations/{id}/finish?user_id=
+func (s *Server) finishReconciliation(w http.ResponseWriter, r *http.Request) {
+	s.transitionReconciliation(w, r, s.reconciliationSvc.Finish)
+}
+
+// reopenReconciliation handles POST /v1/reconciliations/{id}/reopen?user_id=
+func (s *Server) reopenReconciliation(w http.ResponseWriter, r *http.Request) {
+	s.transitionReconciliation(w, r, s.reconciliationSvc.Reopen)
+}
+
+// transitionReconciliation parses the common path/query params and applies a status change.
+func (s *Server) transitionReconciliation(w http.ResponseWriter, r *http.Request, apply func(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error)) {
+	userID, id, ok := reconciliationParams(w, r)
+	if !ok {
+		return
+	}
+	rec, err := apply(r.Context(), userID, id)
+	if err != nil {
+		writeReconciliationErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toReconciliationResponse(rec))
+}
+
+func decodeReconciliationBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if !requireJSON(w, r) {
+		return false
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+func reconciliationID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid reconciliation id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func reconciliationParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, ok = reconciliationID(w, r)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeReconciliationErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	case errors.Is(err, errs.ErrMixedCurrency):
+		unprocessable(w, "statement currency must match the account currency", "currency_mismatch")
+	case errors.Is(err, reconciliation.ErrOpenReconciliation):
+		writeErr(w, http.StatusConflict, err.Error(), "reconciliation_open")
+	case errors.Is(err, reconciliation.ErrStatementDate):
+		writeErr(w, http.StatusConflict, err.Error(), "statement_date_conflict")
+	case errors.Is(err, reconciliation.ErrNotBankAccount):
+		unprocessable(w, err.Error(), "not_bank_account")
+	case errors.Is(err, reconciliation.ErrOpeningBalance):
+		unprocessable(w, err.Error(), "opening_balance_mismatch")
+	case errors.Is(err, reconciliation.ErrFinished):
+		unprocessable(w, err.Error(), "reconciliation_finished")
+	case errors.Is(err, reconciliation.ErrAlreadyMatched):
+		unprocessable(w, err.Error(), "already_matched")
+	case errors.Is(err, reconciliation.ErrNotMatchable):
+		unprocessable(w, err.Error(), "not_matchable")
+	case errors.Is(err, reconciliation.ErrUnmatchedLines):
+		unprocessable(w, err.Error(), "unmatched_lines")
+	case errors.Is(err, reconciliation.ErrOutOfBalance):
+		unprocessable(w, err.Error(), "out_of_balance")
+	case errors.Is(err, reconciliation.ErrNotLatest):
+		unprocessable(w, err.Error(), "not_latest")
+	case errors.Is(err, errs.ErrInvalidAmount):
+		badRequest(w, err.Error())
+	default:
+		writeErr(w, http.StatusInternalServerError, "could not update reconciliation", "")
+	}
+}
+
+func toStatementLines(curr string, in []reconciliationLineBody) ([]ledger.StatementLine, error) {
+	out := make([]ledger.StatementLine, 0, len(in))
+	for i, l := range in {
+		if l.Date.IsZero() {
+			return nil, errors.New("lines[" + itoa(i) + "]: date is required")
+		}
+		if l.AmountMinor == 0 {
+			return nil, errors.New("lines[" + itoa(i) + "]: amount_minor must not be zero")
+		}
+		amt, err := money.NewAmountFromMinorUnits(curr, l.AmountMinor)
+		if err != nil {
+			return nil, errors.New("lines[" + itoa(i) + "]: invalid amount_minor")
+		}
+		out = append(out, ledger.StatementLine{Date: l.Date, Amount: amt, Description: l.Description, Reference: l.Reference})
+	}
+	return out, nil
+}
+
+func toReconciliationResponse(rec ledger.Reconciliation) reconciliationResponse {
+	opening, _ := rec.OpeningBalance.MinorUnits()
+	closing, _ := rec.StatementBalance.MinorUnits()
+	out := reconciliationResponse{
+		ID:                    rec.ID,
+		UserID:                rec.UserID,
+		AccountID:             rec.AccountID,
+		StatementDate:         rec.StatementDate,
+		Currency:              rec.StatementBalance.Curr().Code(),
+		OpeningBalanceMinor:   opening,
+		StatementBalanceMinor: closing,
+		Status:                rec.Status,
+		ClearedBalanceMinor:   opening,
+		FinishedAt:            rec.FinishedAt,
+		Lines:                 make([]reconciliationLineResponse, 0, len(rec.Lines)),
+	}
+	for _, sl := range rec.Lines {
+		minor, _ := sl.Amount.MinorUnits()
+		if sl.JournalLineID != nil {
+			out.ClearedBalanceMinor += minor
+		} else {
+			out.UnmatchedCount++
+		}
+		out.Lines = append(out.Lines, reconciliationLineResponse{
+			ID:            sl.ID,
+			Date:          sl.Date,
+			AmountMinor:   minor,
+			Description:   sl.Description,
+			Reference:     sl.Reference,
+			JournalLineID: sl.JournalLineID,
+			Matched:       sl.JournalLineID != nil,
+		})
+	}
+	out.DifferenceMinor = closing - out.ClearedBalanceMinor
+	if rec.ReconciledBalance != nil {
+		m, _ := rec.ReconciledBalance.MinorUnits()
+		out.ReconciledBalanceMinor = &m
+	}
+	return out
+}
@@ -0,0 +1,211 @@
+// Financial statement handlers built on the report service.
+package v1
+
+import (
+	"net/http"
+	"time"
+
+	"errors"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/service/report"
+)
+
+// getBalanceSheet handles GET /v1/reports/balance-sheet?user_id=&as_of=
+func (s *Server) getBalanceSheet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	var asOf *time.Time
+	if raw := q.Get("as_of"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid as_of"})
+			return
+		}
+		t = t.UTC()
+		asOf = &t
+	}
+	bs, err := s.reportSvc.BalanceSheet(r.Context(), userID, asOf)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not build balance sheet"})
+		return
+	}
+	resp := balanceSheetResponse{UserID: userID, AsOf: bs.AsOf, Sections: make([]balanceSheetSectionResponse, 0, len(bs.Sections))}
+	for _, sec := range bs.Sections {
+		resp.Sections = append(resp.Sections, balanceSheetSectionResponse{
+			Currency:                       sec.Currency,
+			Assets:                         toStatementSection(sec.Currency, sec.Assets),
+			Liabilities:                    toStatementSection(sec.Currency, sec.Liabilities),
+			Equity:                         toStatementSection(sec.Currency, sec.Equity),
+			TotalLiabilitiesAndEquityMinor: sec.TotalLiabilitiesAndEquity,
+			TotalLiabilitiesAndEquity:      formatMinor(sec.Currency, sec.TotalLiabilitiesAndEquity),
+		})
+	}
+	toJSON(w, http.StatusOK, resp)
+}
+
+// getIncomeStatement handles GET /v1/reports/income-statement?user_id=&from=&to=[&columns=monthly|quarterly]
+func (s *Server) getIncomeStatement(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	from, to, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+	is, err := s.reportSvc.IncomeStatement(r.Context(), userID, from, to, report.Interval(q.Get("columns")))
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		badRequest(w, err.Error())
+		return
+	}
+	resp := incomeStatementResponse{
+		UserID:   userID,
+		From:     is.From,
+		To:       is.To,
+		Columns:  toStatementColumns(is.Columns),
+		Sections: make([]incomeStatementSectionResponse, 0, len(is.Sections)),
+	}
+	for _, sec := range is.Sections {
+		resp.Sections = append(resp.Sections, incomeStatementSectionResponse{
+			Currency:              sec.Currency,
+			Revenue:               toStatementSection(sec.Currency, sec.Revenue),
+			Expenses:              toStatementSection(sec.Currency, sec.Expenses),
+			NetIncomeMinor:        sec.NetIncome,
+			NetIncome:             formatMinor(sec.Currency, sec.NetIncome),
+			NetIncomeColumnsMinor: sec.NetIncomeColumns,
+		})
+	}
+	toJSON(w, http.StatusOK, resp)
+}
+
+// getCashFlow handles GET /v1/reports/cash-flow?user_id=&from=&to=
+func (s *Server) getCashFlow(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	from, to, ok := parseRange(w, r)
+	if !ok {
+		return
+	}
+	cf, err := s.reportSvc.CashFlow(r.Context(), userID, from, to)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		badRequest(w, err.Error())
+		return
+	}
+	resp := cashFlowResponse{UserID: userID, From: cf.From, To: cf.To, CashGroups: report.CashGroups, Sections: make([]cashFlowSectionResponse, 0, len(cf.Sections))}
+	for _, sec := range cf.Sections {
+		sr := cashFlowSectionResponse{
+			Currency:       sec.Currency,
+			OpeningMinor:   sec.Opening,
+			NetChangeMinor: sec.NetChange,
+			ClosingMinor:   sec.Closing,
+			Opening:        formatMinor(sec.Currency, sec.Opening),
+			NetChange:      formatMinor(sec.Currency, sec.NetChange),
+			Closing:        formatMinor(sec.Currency, sec.Closing),
+			Activities:     make([]cashFlowActivityResponse, 0, len(sec.Activities)),
+		}
+		for _, a := range sec.Activities {
+			ar := cashFlowActivityResponse{Activity: string(a.Activity), TotalMinor: a.Total, Total: formatMinor(sec.Currency, a.Total), Lines: make([]cashFlowLineResponse, 0, len(a.Lines))}
+			for _, ln := range a.Lines {
+				lr := cashFlowLineResponse{Name: ln.Name, Path: ln.Path, AmountMinor: ln.Amount, Amount: formatMinor(sec.Currency, ln.Amount)}
+				if ln.AccountID != uuid.Nil {
+					id := ln.AccountID
+					lr.AccountID = &id
+				} else {
+					lr.Name = "Cash transfers"
+				}
+				ar.Lines = append(ar.Lines, lr)
+			}
+			sr.Activities = append(sr.Activities, ar)
+		}
+		resp.Sections = append(resp.Sections, sr)
+	}
+	toJSON(w, http.StatusOK, resp)
+}
+
+// parseRange reads the required RFC3339 from/to query parameters, writing 400 on error.
+func parseRange(w http.ResponseWriter, r *http.Request) (from, to time.Time, ok bool) {
+	q := r.URL.Query()
+	from, err := time.Parse(time.RFC3339, q.Get("from"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from"})
+		return time.Time{}, time.Time{}, false
+	}
+	to, err = time.Parse(time.RFC3339, q.Get("to"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid to"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from.UTC(), to.UTC(), true
+}
+
+func toStatementColumns(cols []report.Column) []statementColumnResponse {
+	out := make([]statementColumnResponse, 0, len(cols))
+	for _, c := range cols {
+		out = append(out, statementColumnResponse{Label: c.Label, Start: c.Start, End: c.End})
+	}
+	return out
+}
+
+func toStatementSection(curr string, sec report.StatementSection) statementSectionResponse {
+	out := statementSectionResponse{
+		Type:         sec.Type,
+		Groups:       make([]statementGroupResponse, 0, len(sec.Groups)),
+		TotalMinor:   sec.Total,
+		Total:        formatMinor(curr, sec.Total),
+		ColumnsMinor: sec.Columns,
+	}
+	for _, g := range sec.Groups {
+		gr := statementGroupResponse{
+			Group:         g.Group,
+			Lines:         make([]statementLineResponse, 0, len(g.Lines)),
+			SubtotalMinor: g.Subtotal,
+			Subtotal:      formatMinor(curr, g.Subtotal),
+			ColumnsMinor:  g.Columns,
+		}
+		for _, ln := range g.Lines {
+			lr := statementLineResponse{
+				Name:         ln.Name,
+				Path:         ln.Path,
+				Vendor:       ln.Vendor,
+				AmountMinor:  ln.Amount,
+				Amount:       formatMinor(curr, ln.Amount),
+				ColumnsMinor: ln.Columns,
+				Computed:     ln.Computed,
+			}
+			if ln.AccountID != uuid.Nil {
+				id := ln.AccountID
+				lr.AccountID = &id
+			}
+			gr.Lines = append(gr.Lines, lr)
+		}
+		out.Groups = append(out.Groups, gr)
+	}
+	return out
+}
+
+// formatMinor renders minor units as a decimal string in curr.
+func formatMinor(curr string, units int64) string {
+	return mustAmount(curr, units).Decimal().String()
+}
@@ -3,14 +3,37 @@
 package v1
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	chi "github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/audit"
+	"github.com/tinoosan/ledger/internal/service/budget"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/imports"
 	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/journalimport"
+	"github.com/tinoosan/ledger/internal/service/period"
+	"github.com/tinoosan/ledger/internal/service/reconciliation"
+	"github.com/tinoosan/ledger/internal/service/report"
+	"github.com/tinoosan/ledger/internal/service/revaluation"
+	"github.com/tinoosan/ledger/internal/service/rules"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+	"github.com/tinoosan/ledger/internal/service/webhook"
+	"github.com/tinoosan/ledger/internal/service/yearend"
+	"github.com/tinoosan/ledger/internal/statement/camt053"
+	"github.com/tinoosan/ledger/internal/statement/mt940"
+	"github.com/tinoosan/ledger/internal/statement/ofx"
+	"github.com/tinoosan/ledger/internal/storage/memory"
+	"github.com/tinoosan/ledger/internal/storage/postgres"
+	"github.com/tinoosan/ledger/internal/stream"
 	"log/slog"
 	"sync"
 )
@@ -18,15 +41,39 @@ import (
 // Server wires handlers and middleware using Chi.
 // It composes read (repo) and write (writer) dependencies through services.
 type Server struct {
-	svc         journal.Service
-	accountSvc  account.Service
-	accReader   AccountReader
-	entryReader EntryReader
-	idemStore   IdempotencyStore
-	batchIdemMu sync.RWMutex
-	batchIdem   map[string]storedBatch
-	log         *slog.Logger
-	rt          *chi.Mux
+	svc        journal.Service
+	accountSvc account.Service
+	periodSvc  period.Service
+	yearEndSvc yearend.Service
+	fxSvc      fx.Service
+	// revaluationSvc is set together with fxSvc.
+	revaluationSvc revaluation.Service
+	reportSvc      report.Service
+	budgetSvc      budget.Service
+	scheduleSvc    schedule.Service
+	// ruleSvc, when set, rewrites entries posted through the entry endpoints before validation.
+	ruleSvc   rules.Service
+	importSvc imports.Service
+	// reconciliationSvc also sets journal line clearing status.
+	reconciliationSvc reconciliation.Service
+	assertionSvc      assertion.Service
+	// auditSvc, when set, holds the audit log that svc, accountSvc and journal
+	// imports record their writes to.
+	auditSvc audit.Service
+	// webhookSvc manages endpoints; delivery runs in the dispatcher, not here.
+	webhookSvc webhook.Service
+	// journalImportSvc is set when the store supports batch transactions.
+	journalImportSvc journalimport.Service
+	// hub receives every entry and account change committed through svc and accountSvc.
+	hub             *stream.Hub
+	streamHeartbeat time.Duration
+	accReader       AccountReader
+	entryReader     EntryReader
+	idemStore       IdempotencyStore
+	batchIdemMu     sync.RWMutex
+	batchIdem       map[string]storedBatch
+	log             *slog.Logger
+	rt              *chi.Mux
 }
 
 // New constructs the HTTP server with routes and middleware.
@@ -49,23 +96,122 @@ func New(accReader AccountReader, entryReader EntryReader, idem IdempotencyStore
 		r.Use(mw)
 	}
 
+	// Buffer of recent events for stream resumes (default 1024; override via STREAM_BUFFER_SIZE)
+	bufSize := stream.DefaultBufferSize
+	if v := os.Getenv("STREAM_BUFFER_SIZE"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			bufSize = n
+		}
+	}
+	hub := stream.NewHub(bufSize)
+
 	s := &Server{
-		svc:         journal.New(jrepo, jwriter),
-		accountSvc:  account.New(arepo, awriter),
-		accReader:   accReader,
-		entryReader: entryReader,
-		idemStore:   idem,
-		batchIdem:   make(map[string]storedBatch),
-		rt:          r,
-		log:         logger,
+		hub:             hub,
+		streamHeartbeat: streamHeartbeat,
+		accReader:       accReader,
+		entryReader:     entryReader,
+		idemStore:       idem,
+		batchIdem:       make(map[string]storedBatch),
+		rt:              r,
+		log:             logger,
+	}
+	jw, aw := stream.JournalWriter(jwriter, hub), stream.AccountWriter(awriter, arepo, hub)
+	if as, ok := jrepo.(auditStore); ok {
+		s.auditSvc = audit.New(as, as)
+		a := auditor{svc: s.auditSvc, entries: jrepo, accounts: arepo, log: logger}
+		jw, aw = auditJournalWriter{Writer: jw, a: a}, auditAccountWriter{Writer: aw, a: a}
+	}
+	s.svc = journal.New(jrepo, jw)
+	s.accountSvc = account.New(arepo, aw)
+	s.yearEndSvc = yearend.New(s.svc, s.accountSvc, accReader)
+	// Optional subsystems: enabled when the journal repo also implements their storage.
+	var periods period.Repo
+	if ps, ok := jrepo.(periodStore); ok {
+		s.periodSvc = period.New(ps, ps)
+		periods = ps
 	}
+	if fs, ok := jrepo.(fxStore); ok {
+		s.fxSvc = fx.New(fs, fs)
+		s.revaluationSvc = revaluation.New(s.svc, s.accountSvc, s.fxSvc, accReader, periods)
+	}
+	if bs, ok := jrepo.(budgetStore); ok {
+		s.budgetSvc = budget.New(bs, bs, s.svc, accReader)
+	}
+	if ss, ok := jrepo.(scheduleStore); ok {
+		s.scheduleSvc = schedule.New(ss, ss, s.svc, idem)
+	}
+	if rs, ok := jrepo.(ruleStore); ok {
+		s.ruleSvc = rules.New(rs, rs, s.svc, accReader)
+	}
+	if is, ok := jrepo.(importStore); ok {
+		var rw imports.Rewriter
+		if s.ruleSvc != nil {
+			rw = s.ruleSvc
+		}
+		s.importSvc = imports.New(is, is, s.svc, accReader, s.accountSvc, rw)
+	}
+	if rs, ok := jrepo.(reconciliationStore); ok {
+		s.reconciliationSvc = reconciliation.New(rs, rs, s.svc, accReader)
+	}
+	if as, ok := jrepo.(assertionStore); ok {
+		s.assertionSvc = assertion.New(as, as, s.svc, accReader)
+	}
+	if ws, ok := jrepo.(webhookStore); ok {
+		allowPrivate, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE_HOSTS"))
+		s.webhookSvc = webhook.New(ws, ws, nil, allowPrivate)
+	}
+	if tb := s.txBeginner(jrepo); tb != nil {
+		s.journalImportSvc = journalimport.New(tb)
+	}
+	s.reportSvc = report.New(s.svc, accReader, s.fxSvc)
 	s.routes()
 	return s
 }
 
+// txBeginner adapts the batch transactions of the known stores to
+// journalimport.Tx, recording their writes in the audit log when it is on.
+// Stores return their own transaction types so they do not depend on the
+// services that use them; nil means no batch support.
+func (s *Server) txBeginner(repo any) journalimport.TxBeginner {
+	switch st := repo.(type) {
+	case *memory.Store:
+		return beginTxFunc(st.BeginTx, s.auditTx)
+	case *postgres.Store:
+		return beginTxFunc(func(ctx context.Context, _ uuid.UUID) (*postgres.Tx, error) { return st.BeginTx(ctx) }, s.auditTx)
+	}
+	return nil
+}
+
+// beginTxFunc returns a journalimport.TxBeginner over a store's BeginTx whose
+// transactions are passed through wrap.
+func beginTxFunc[T journalimport.Tx](begin func(context.Context, uuid.UUID) (T, error), wrap func(journalimport.Tx) journalimport.Tx) journalimport.TxBeginner {
+	return txBeginFunc(func(ctx context.Context, userID uuid.UUID) (journalimport.Tx, error) {
+		tx, err := begin(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return wrap(tx), nil
+	})
+}
+
+type txBeginFunc func(context.Context, uuid.UUID) (journalimport.Tx, error)
+
+func (f txBeginFunc) BeginTx(ctx context.Context, userID uuid.UUID) (journalimport.Tx, error) {
+	return f(ctx, userID)
+}
+
 // Handler exposes the configured http.Handler.
 func (s *Server) Handler() http.Handler { return s.rt }
 
+// Schedules returns the recurring entry service, nil when the store has no
+// schedules. Entries it posts go through the server's journal service, so
+// they reach the live stream.
+func (s *Server) Schedules() schedule.Service { return s.scheduleSvc }
+
+// CloseStreams ends every open event stream; clients reconnect elsewhere and
+// resume with Last-Event-ID.
+func (s *Server) CloseStreams() { s.hub.Close() }
+
 // Mux is kept for compatibility with existing main wiring.
 func (s *Server) Mux() http.Handler { return s.rt }
 
@@ -77,9 +223,16 @@ func (s *Server) routes() {
 	s.rt.Post("/v1/entries:batch", s.postEntriesBatch)
 	s.rt.With(s.validateListEntries()).Get("/v1/entries", s.listEntries)
 	s.rt.Get("/v1/entries/{id}", s.getEntry)
+	s.rt.Post("/v1/entries/{id}/relations", s.postEntryRelation)
+	s.rt.Delete("/v1/entries/{id}/relations/{related_id}", s.deleteEntryRelation)
 	s.rt.With(s.validateReverseEntry()).Post("/v1/entries/reverse", s.reverseEntry)
 	s.rt.Post("/v1/entries/reclassify", s.reclassifyEntry)
 	s.rt.With(s.validateTrialBalance()).Get("/v1/trial-balance", s.trialBalance)
+	s.rt.Post("/v1/year-end/close", s.closeYear)
+	s.rt.Get("/v1/reports/balance-sheet", s.getBalanceSheet)
+	s.rt.Get("/v1/reports/income-statement", s.getIncomeStatement)
+	s.rt.Get("/v1/reports/cash-flow", s.getCashFlow)
+	s.rt.Get("/v1/export/journal", s.exportJournal)
 	// Accounts (v1)
 	s.rt.With(s.validatePostAccount()).Post("/v1/accounts", s.postAccount)
 	s.rt.Post("/v1/accounts/batch", s.postAccountsBatch)
@@ -96,6 +249,98 @@ func (s *Server) routes() {
 	s.rt.Delete("/v1/accounts/{id}", s.deactivateAccount)
 	// Reactivate (undo soft delete)
 	s.rt.Post("/v1/accounts/{id}/reactivate", s.reactivateAccount)
+	// Accounting periods; changes need the admin role
+	if s.periodSvc != nil {
+		s.rt.With(requireAdmin).Post("/v1/periods", s.postPeriod)
+		s.rt.Get("/v1/periods", s.listPeriods)
+		s.rt.With(requireAdmin).Post("/v1/periods/{id}/close", s.closePeriod)
+		s.rt.With(requireAdmin).Post("/v1/periods/{id}/reopen", s.reopenPeriod)
+		s.rt.With(requireAdmin).Post("/v1/periods/{id}/lock", s.lockPeriod)
+	}
+	// Exchange rates and FX revaluation
+	if s.fxSvc != nil {
+		s.rt.Post("/v1/fx/rates", s.postFXRates)
+		s.rt.Post("/v1/fx/rates/csv", s.postFXRatesCSV)
+		s.rt.Get("/v1/fx/rates", s.listFXRates)
+		s.rt.Post("/v1/fx/revaluations", s.postRevaluation)
+		s.rt.Post("/v1/fx/revaluations/reverse", s.reverseRevaluation)
+	}
+	// Budgets
+	if s.budgetSvc != nil {
+		s.rt.Post("/v1/budgets", s.postBudget)
+		s.rt.Get("/v1/budgets", s.listBudgets)
+		s.rt.Get("/v1/budgets/{id}", s.getBudget)
+		s.rt.Patch("/v1/budgets/{id}", s.updateBudget)
+		s.rt.Delete("/v1/budgets/{id}", s.deleteBudget)
+		s.rt.Get("/v1/reports/budget-vs-actual", s.getBudgetVsActual)
+	}
+	// Recurring entry schedules
+	if s.scheduleSvc != nil {
+		s.rt.Post("/v1/schedules", s.postSchedule)
+		s.rt.Get("/v1/schedules", s.listSchedules)
+		s.rt.Get("/v1/schedules/{id}", s.getSchedule)
+		s.rt.Patch("/v1/schedules/{id}", s.updateSchedule)
+		s.rt.Delete("/v1/schedules/{id}", s.deleteSchedule)
+		s.rt.Get("/v1/schedules/{id}/preview", s.previewSchedule)
+	}
+	// Categorization rules
+	if s.ruleSvc != nil {
+		s.rt.Post("/v1/rules", s.postRule)
+		s.rt.Get("/v1/rules", s.listRules)
+		s.rt.Post("/v1/rules/dry-run", s.dryRunRules)
+		s.rt.Get("/v1/rules/{id}", s.getRule)
+		s.rt.Patch("/v1/rules/{id}", s.updateRule)
+		s.rt.Delete("/v1/rules/{id}", s.deleteRule)
+	}
+	// Statement imports
+	if s.importSvc != nil {
+		s.rt.Post("/v1/imports/csv", s.postCSVImport)
+		s.rt.Post("/v1/imports/ofx", s.postStatementImport(ofx.Parse, ofxMediaTypes))
+		s.rt.Post("/v1/imports/camt053", s.postStatementImport(camt053.Parse, xmlMediaTypes))
+		s.rt.Post("/v1/imports/mt940", s.postStatementImport(mt940.Parse, mt940MediaTypes))
+		s.rt.Post("/v1/imports/csv/profiles", s.postImportProfile)
+		s.rt.Get("/v1/imports/csv/profiles", s.listImportProfiles)
+		s.rt.Get("/v1/imports/csv/profiles/{id}", s.getImportProfile)
+		s.rt.Delete("/v1/imports/csv/profiles/{id}", s.deleteImportProfile)
+	}
+	// Plain-text journal import
+	if s.journalImportSvc != nil {
+		s.rt.Post("/v1/imports/journal", s.postJournalImport)
+	}
+	// Bank reconciliations
+	if s.reconciliationSvc != nil {
+		s.rt.Post("/v1/reconciliations", s.postReconciliation)
+		s.rt.Get("/v1/reconciliations", s.listReconciliations)
+		s.rt.Get("/v1/reconciliations/{id}", s.getReconciliation)
+		s.rt.Post("/v1/reconciliations/{id}/lines", s.addStatementLines)
+		s.rt.Post("/v1/reconciliations/{id}/auto-match", s.autoMatchReconciliation)
+		s.rt.Post("/v1/reconciliations/{id}/match", s.matchStatementLine)
+		s.rt.Post("/v1/reconciliations/{id}/unmatch", s.unmatchStatementLine)
+		s.rt.Post("/v1/reconciliations/{id}/finish", s.finishReconciliation)
+		s.rt.Post("/v1/reconciliations/{id}/reopen", s.reopenReconciliation)
+	}
+	// Balance assertions
+	if s.assertionSvc != nil {
+		s.rt.Post("/v1/balance-assertions", s.postAssertion)
+		s.rt.Get("/v1/balance-assertions", s.listAssertions)
+		s.rt.Get("/v1/balance-assertions/{id}", s.getAssertion)
+		s.rt.Delete("/v1/balance-assertions/{id}", s.deleteAssertion)
+	}
+	// Audit log
+	if s.auditSvc != nil {
+		s.rt.Get("/v1/audit", s.listAudit)
+	}
+	// Webhooks
+	if s.webhookSvc != nil {
+		s.rt.Post("/v1/webhooks", s.postWebhook)
+		s.rt.Get("/v1/webhooks", s.listWebhooks)
+		s.rt.Get("/v1/webhooks/deliveries", s.listWebhookDeliveries)
+		s.rt.Post("/v1/webhooks/deliveries/{id}/redeliver", s.redeliverWebhook)
+		s.rt.Get("/v1/webhooks/{id}", s.getWebhook)
+		s.rt.Delete("/v1/webhooks/{id}", s.deleteWebhook)
+	}
+	// Live changes
+	s.rt.Get("/v1/stream", s.streamEvents)
 	// Health (unversioned)
 	s.rt.Get("/healthz", s.healthz)
 	s.rt.Get("/readyz", s.readyz)
@@ -0,0 +1,254 @@
+// Categorization rule handlers: CRUD and dry-run against existing entries.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/rules"
+)
+
+// postRule handles POST /v1/rules
+func (s *Server) postRule(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postRuleRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	created, err := s.ruleSvc.Create(r.Context(), toRuleDomain(req))
+	if err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusCreated, toRuleResponse(created))
+}
+
+// listRules handles GET /v1/rules?user_id=
+func (s *Server) listRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	list, err := s.ruleSvc.List(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch rules"})
+		return
+	}
+	out := make([]ruleResponse, 0, len(list))
+	for _, rl := range list {
+		out = append(out, toRuleResponse(rl))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getRule handles GET /v1/rules/{id}?user_id=
+func (s *Server) getRule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := ruleParams(w, r)
+	if !ok {
+		return
+	}
+	rl, err := s.ruleSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toRuleResponse(rl))
+}
+
+// updateRule handles PATCH /v1/rules/{id}?user_id=; match and actions are replaced as a whole.
+func (s *Server) updateRule(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	userID, id, ok := ruleParams(w, r)
+	if !ok {
+		return
+	}
+	var req patchRuleRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	rl, err := s.ruleSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	if req.Name != nil {
+		rl.Name = *req.Name
+	}
+	if req.Priority != nil {
+		rl.Priority = *req.Priority
+	}
+	if req.Active != nil {
+		rl.Active = *req.Active
+	}
+	if req.Stop != nil {
+		rl.Stop = *req.Stop
+	}
+	if req.Match != nil {
+		rl.Match = ledger.RuleMatch(*req.Match)
+	}
+	if req.Actions != nil {
+		rl.Actions = ledger.RuleActions(*req.Actions)
+	}
+	updated, err := s.ruleSvc.Update(r.Context(), rl)
+	if err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toRuleResponse(updated))
+}
+
+// deleteRule handles DELETE /v1/rules/{id}?user_id=
+func (s *Server) deleteRule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := ruleParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.ruleSvc.Delete(r.Context(), userID, id); err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dryRunRules handles POST /v1/rules/dry-run
+func (s *Server) dryRunRules(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req ruleDryRunRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	var draft *ledger.Rule
+	if req.Rule != nil {
+		d := toRuleDomain(*req.Rule)
+		draft = &d
+	}
+	matches, err := s.ruleSvc.DryRun(r.Context(), req.UserID, draft)
+	if err != nil {
+		writeRuleErr(w, err)
+		return
+	}
+	resp := ruleDryRunResponse{UserID: req.UserID, Matches: make([]ruleDryRunMatch, 0, len(matches))}
+	for _, m := range matches {
+		resp.Matches = append(resp.Matches, toDryRunMatch(m))
+	}
+	toJSON(w, http.StatusOK, resp)
+}
+
+// applyRules runs the user's rules over a draft entry when the rules subsystem is enabled.
+func (s *Server) applyRules(r *http.Request, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	if s.ruleSvc == nil {
+		return e, nil
+	}
+	out, _, err := s.ruleSvc.Apply(r.Context(), e)
+	return out, err
+}
+
+func toRuleDomain(req postRuleRequest) ledger.Rule {
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	return ledger.Rule{
+		UserID:   req.UserID,
+		Name:     req.Name,
+		Priority: req.Priority,
+		Active:   active,
+		Stop:     req.Stop,
+		Match:    ledger.RuleMatch(req.Match),
+		Actions:  ledger.RuleActions(req.Actions),
+	}
+}
+
+func toRuleResponse(rl ledger.Rule) ruleResponse {
+	return ruleResponse{
+		ID:       rl.ID,
+		UserID:   rl.UserID,
+		Name:     rl.Name,
+		Priority: rl.Priority,
+		Active:   rl.Active,
+		Stop:     rl.Stop,
+		Match:    ruleMatchBody(rl.Match),
+		Actions:  ruleActionsBody(rl.Actions),
+	}
+}
+
+func toDryRunMatch(m rules.Match) ruleDryRunMatch {
+	out := ruleDryRunMatch{
+		EntryID:        m.Entry.ID,
+		Date:           m.Entry.Date,
+		Memo:           m.Entry.Memo,
+		RuleIDs:        m.RuleIDs,
+		CategoryBefore: m.Entry.Category,
+		CategoryAfter:  m.Result.Category,
+	}
+	for id, ln := range m.Entry.Lines.ByID {
+		if after, ok := m.Result.Lines.ByID[id]; ok && after.AccountID != ln.AccountID {
+			out.LineChanges = append(out.LineChanges, ruleLineChange{LineID: id, FromAccountID: ln.AccountID, ToAccountID: after.AccountID})
+		}
+	}
+	sort.Slice(out.LineChanges, func(i, j int) bool { return out.LineChanges[i].LineID.String() < out.LineChanges[j].LineID.String() })
+	for k, v := range m.Result.Metadata {
+		if prev, ok := m.Entry.Metadata[k]; !ok || prev != v {
+			if out.MetadataAdded == nil {
+				out.MetadataAdded = map[string]string{}
+			}
+			out.MetadataAdded[k] = v
+		}
+	}
+	return out
+}
+
+func ruleParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid rule id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeRuleErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	default:
+		badRequest(w, err.Error())
+	}
+}
@@ -0,0 +1,300 @@
+// Recurring entry schedule handlers: CRUD and occurrence preview.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+)
+
+// maxPreview caps the number of occurrences returned by the preview endpoint.
+const maxPreview = 100
+
+// postSchedule handles POST /v1/schedules
+func (s *Server) postSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postScheduleRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	sc := ledger.Schedule{UserID: req.UserID, Name: req.Name, Rule: req.Rule, Start: req.Start, Until: req.Until}
+	tpl, err := toScheduleTemplate(req.Currency, req.Memo, req.Category, req.Metadata, req.Lines)
+	if err != nil {
+		badRequest(w, err.Error())
+		return
+	}
+	sc.Template = tpl
+	if !checkScheduleFields(w, sc) {
+		return
+	}
+	created, err := s.scheduleSvc.Create(r.Context(), sc)
+	if err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusCreated, toScheduleResponse(created))
+}
+
+// listSchedules handles GET /v1/schedules?user_id=
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	list, err := s.scheduleSvc.List(r.Context(), userID)
+	if err != nil {
+		toJSON(w, http.StatusInternalServerError, errorResponse{Error: "could not fetch schedules"})
+		return
+	}
+	out := make([]scheduleResponse, 0, len(list))
+	for _, sc := range list {
+		out = append(out, toScheduleResponse(sc))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getSchedule handles GET /v1/schedules/{id}?user_id=
+func (s *Server) getSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := scheduleParams(w, r)
+	if !ok {
+		return
+	}
+	sc, err := s.scheduleSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toScheduleResponse(sc))
+}
+
+// updateSchedule handles PATCH /v1/schedules/{id}?user_id=
+func (s *Server) updateSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	userID, id, ok := scheduleParams(w, r)
+	if !ok {
+		return
+	}
+	var req patchScheduleRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	sc, err := s.scheduleSvc.Get(r.Context(), userID, id)
+	if err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	if req.Name != nil {
+		sc.Name = *req.Name
+	}
+	if req.Rule != nil {
+		sc.Rule = *req.Rule
+	}
+	if req.Start != nil {
+		sc.Start = *req.Start
+	}
+	if req.Until != nil {
+		sc.Until = req.Until
+	}
+	if req.Active != nil {
+		sc.Active = *req.Active
+	}
+	if req.Memo != nil {
+		sc.Template.Memo = *req.Memo
+	}
+	if req.Category != nil {
+		sc.Template.Category = *req.Category
+	}
+	if req.Metadata != nil {
+		sc.Template.Metadata = meta.New(*req.Metadata)
+	}
+	if req.Currency != nil || req.Lines != nil {
+		if req.Lines == nil {
+			badRequest(w, "lines are required when changing currency")
+			return
+		}
+		curr := sc.Template.Currency
+		if req.Currency != nil {
+			curr = *req.Currency
+		}
+		tpl, err := toScheduleTemplate(curr, sc.Template.Memo, sc.Template.Category, sc.Template.Metadata, req.Lines)
+		if err != nil {
+			badRequest(w, err.Error())
+			return
+		}
+		sc.Template = tpl
+	}
+	if !checkScheduleFields(w, sc) {
+		return
+	}
+	updated, err := s.scheduleSvc.Update(r.Context(), sc)
+	if err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toScheduleResponse(updated))
+}
+
+// deleteSchedule handles DELETE /v1/schedules/{id}?user_id=
+func (s *Server) deleteSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := scheduleParams(w, r)
+	if !ok {
+		return
+	}
+	if err := s.scheduleSvc.Delete(r.Context(), userID, id); err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// previewSchedule handles GET /v1/schedules/{id}/preview?user_id=[&from=][&count=]
+func (s *Server) previewSchedule(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := scheduleParams(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from := time.Now().UTC()
+	if raw := q.Get("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid from"})
+			return
+		}
+		from = t
+	}
+	count := 10
+	if raw := q.Get("count"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxPreview {
+			toJSON(w, http.StatusBadRequest, errorResponse{Error: "count must be between 1 and " + itoa(maxPreview)})
+			return
+		}
+		count = n
+	}
+	occ, err := s.scheduleSvc.Preview(r.Context(), userID, id, from, count)
+	if err != nil {
+		writeScheduleErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, schedulePreviewResponse{ScheduleID: id, Occurrences: occ})
+}
+
+// checkScheduleFields rejects requests the service would refuse for shape rather
+// than accounting reasons, so those surface as 400 instead of 422.
+func checkScheduleFields(w http.ResponseWriter, sc ledger.Schedule) bool {
+	if strings.TrimSpace(sc.Name) == "" {
+		badRequest(w, "name is required")
+		return false
+	}
+	if sc.Start.IsZero() {
+		badRequest(w, "start is required")
+		return false
+	}
+	if _, err := schedule.ParseRule(sc.Rule); err != nil {
+		writeErr(w, http.StatusBadRequest, "invalid rule: "+err.Error(), "invalid_rule")
+		return false
+	}
+	if sc.Until != nil && sc.Until.Before(sc.Start) {
+		badRequest(w, "until must not be before start")
+		return false
+	}
+	if err := sc.Template.Metadata.Validate(); err != nil {
+		badRequest(w, err.Error())
+		return false
+	}
+	return true
+}
+
+func toScheduleTemplate(curr, memo string, cat ledger.Category, md map[string]string, lines []postEntryLine) (ledger.ScheduleTemplate, error) {
+	curr = strings.ToUpper(strings.TrimSpace(curr))
+	tpl := ledger.ScheduleTemplate{Currency: curr, Memo: memo, Category: cat, Metadata: meta.New(md), Lines: make([]ledger.JournalLine, 0, len(lines))}
+	for i, line := range lines {
+		jl, err := toLineDomain(line, curr)
+		if err != nil {
+			return ledger.ScheduleTemplate{}, errors.New("lines[" + itoa(i) + "]: " + err.Error())
+		}
+		tpl.Lines = append(tpl.Lines, jl)
+	}
+	return tpl, nil
+}
+
+func scheduleParams(w http.ResponseWriter, r *http.Request) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid schedule id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+// writeScheduleErr maps service errors; template validation failures are 422 like entry posts.
+func writeScheduleErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	default:
+		code, msg := mapValidationError(err)
+		unprocessable(w, msg, code)
+	}
+}
+
+func toScheduleResponse(sc ledger.Schedule) scheduleResponse {
+	lines := make([]postEntryLine, 0, len(sc.Template.Lines))
+	for _, ln := range sc.Template.Lines {
+		minor, _ := ln.Amount.MinorUnits()
+		l := postEntryLine{AccountID: ln.AccountID, Side: ln.Side, AmountMinor: minor, Currency: ln.Amount.Curr().Code()}
+		if ln.Rate != nil {
+			l.ExchangeRate = ln.Rate.Decimal().String()
+		}
+		lines = append(lines, l)
+	}
+	return scheduleResponse{
+		ID:        sc.ID,
+		UserID:    sc.UserID,
+		Name:      sc.Name,
+		Rule:      sc.Rule,
+		Start:     sc.Start,
+		Until:     sc.Until,
+		Active:    sc.Active,
+		NextRun:   sc.NextRun,
+		LastError: sc.LastError,
+		Currency:  sc.Template.Currency,
+		Memo:      sc.Template.Memo,
+		Category:  sc.Template.Category,
+		Metadata:  sc.Template.Metadata,
+		Lines:     lines,
+	}
+}
@@ -0,0 +1,104 @@
+// Server-sent events: live entry and account changes per user.
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// streamHeartbeat is how often an idle stream sends a comment to keep
+// proxies and clients from timing the connection out.
+const streamHeartbeat = 15 * time.Second
+
+// GET /v1/stream?user_id=
+// Streams the user's ledger events as they are committed. Each event's id is
+// its position in this process's buffer; reconnecting with Last-Event-ID
+// replays what the buffer still holds, preceded by a reset event when some of
+// it was lost. With auth on, a token may only stream its own subject's events.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		badRequest(w, "invalid user_id")
+		return
+	}
+	if c, ok := r.Context().Value(ctxKeyClaims).(JWTClaims); ok && c.Subject != userID.String() {
+		forbidden(w, "user_id does not match token subject")
+		return
+	}
+	var after int64
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		if after, err = strconv.ParseInt(raw, 10, 64); err != nil || after < 0 {
+			badRequest(w, "invalid Last-Event-ID")
+			return
+		}
+	}
+	// The server's read and write timeouts are meant for ordinary requests;
+	// lift them for this connection so the stream stays open.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	sub, backlog, complete := s.hub.Subscribe(userID, after)
+	defer sub.Close()
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Connection", "keep-alive")
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, "retry: 3000\n\n"); err != nil {
+		return
+	}
+	if !complete {
+		if _, err := fmt.Fprint(w, "event: reset\ndata: {\"reason\":\"events after Last-Event-ID are no longer buffered; reload current state\"}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, ev := range backlog {
+		if err := writeStreamEvent(w, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(s.streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.C():
+			if !ok {
+				// Dropped for falling behind; the client resumes with Last-Event-ID.
+				return
+			}
+			if err := writeStreamEvent(w, ev); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, ev ledger.Event) error {
+	b, err := json.Marshal(streamEventResponse{ID: ev.ID, Type: ev.Type, UserID: ev.UserID, EntityID: ev.EntityID, CreatedAt: ev.CreatedAt, Data: ev.Data})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, b)
+	return err
+}
@@ -0,0 +1,197 @@
+// Webhook handlers: endpoint registration and the delivery log with redelivery.
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	chi "github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/webhook"
+)
+
+// postWebhook handles POST /v1/webhooks
+func (s *Server) postWebhook(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var req postWebhookRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if req.UserID == uuid.Nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id is required"})
+		return
+	}
+	if len(req.EventTypes) == 0 {
+		badRequest(w, "event_types is required")
+		return
+	}
+	for _, t := range req.EventTypes {
+		if !t.IsValid() {
+			badRequest(w, "unknown event type: "+string(t))
+			return
+		}
+	}
+	ep, err := s.webhookSvc.CreateEndpoint(r.Context(), ledger.WebhookEndpoint{UserID: req.UserID, URL: req.URL, EventTypes: req.EventTypes})
+	if err != nil {
+		if errors.Is(err, webhook.ErrPrivateHost) {
+			badRequest(w, "url must not point to a loopback, private or link-local address")
+			return
+		}
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "url must be an absolute http or https URL")
+			return
+		}
+		writeWebhookErr(w, err)
+		return
+	}
+	resp := toWebhookResponse(ep)
+	resp.Secret = ep.Secret
+	toJSON(w, http.StatusCreated, resp)
+}
+
+// listWebhooks handles GET /v1/webhooks?user_id=
+func (s *Server) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	eps, err := s.webhookSvc.ListEndpoints(r.Context(), userID)
+	if err != nil {
+		writeWebhookErr(w, err)
+		return
+	}
+	out := make([]webhookResponse, 0, len(eps))
+	for _, ep := range eps {
+		out = append(out, toWebhookResponse(ep))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// getWebhook handles GET /v1/webhooks/{id}?user_id=
+func (s *Server) getWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := webhookParams(w, r, "invalid webhook id")
+	if !ok {
+		return
+	}
+	ep, err := s.webhookSvc.GetEndpoint(r.Context(), userID, id)
+	if err != nil {
+		writeWebhookErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusOK, toWebhookResponse(ep))
+}
+
+// deleteWebhook handles DELETE /v1/webhooks/{id}?user_id=
+// Pending deliveries to the endpoint go dead on their next attempt.
+func (s *Server) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := webhookParams(w, r, "invalid webhook id")
+	if !ok {
+		return
+	}
+	if err := s.webhookSvc.DeleteEndpoint(r.Context(), userID, id); err != nil {
+		writeWebhookErr(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listWebhookDeliveries handles GET /v1/webhooks/deliveries?user_id=[&status=]
+func (s *Server) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	userID, err := uuid.Parse(q.Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return
+	}
+	status := ledger.DeliveryStatus(q.Get("status"))
+	switch status {
+	case "", ledger.DeliveryPending, ledger.DeliveryDelivered, ledger.DeliveryDead:
+	default:
+		badRequest(w, "status must be pending, delivered or dead")
+		return
+	}
+	ds, err := s.webhookSvc.ListDeliveries(r.Context(), userID, status)
+	if err != nil {
+		writeWebhookErr(w, err)
+		return
+	}
+	out := make([]webhookDeliveryResponse, 0, len(ds))
+	for _, d := range ds {
+		out = append(out, toWebhookDeliveryResponse(d))
+	}
+	toJSON(w, http.StatusOK, out)
+}
+
+// redeliverWebhook handles POST /v1/webhooks/deliveries/{id}/redeliver?user_id=
+// The delivery is queued for the next dispatch with a fresh set of attempts.
+func (s *Server) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	userID, id, ok := webhookParams(w, r, "invalid delivery id")
+	if !ok {
+		return
+	}
+	d, err := s.webhookSvc.Redeliver(r.Context(), userID, id)
+	if err != nil {
+		writeWebhookErr(w, err)
+		return
+	}
+	toJSON(w, http.StatusAccepted, toWebhookDeliveryResponse(d))
+}
+
+func webhookParams(w http.ResponseWriter, r *http.Request, invalidID string) (userID, id uuid.UUID, ok bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: invalidID})
+		return uuid.Nil, uuid.Nil, false
+	}
+	userID, err = uuid.Parse(r.URL.Query().Get("user_id"))
+	if err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid user_id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+func writeWebhookErr(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errs.ErrNotFound):
+		notFound(w)
+	case errors.Is(err, errs.ErrInvalid):
+		badRequest(w, "invalid")
+	default:
+		writeErr(w, http.StatusInternalServerError, "could not save webhook", "")
+	}
+}
+
+func toWebhookResponse(ep ledger.WebhookEndpoint) webhookResponse {
+	return webhookResponse{ID: ep.ID, UserID: ep.UserID, URL: ep.URL, EventTypes: ep.EventTypes, CreatedAt: ep.CreatedAt}
+}
+
+func toWebhookDeliveryResponse(d ledger.WebhookDelivery) webhookDeliveryResponse {
+	resp := webhookDeliveryResponse{
+		ID:             d.ID,
+		UserID:         d.UserID,
+		EventID:        d.EventID,
+		EventType:      d.EventType,
+		EndpointID:     d.EndpointID,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		LastError:      d.LastError,
+		LastStatusCode: d.LastStatusCode,
+		DeliveredAt:    d.DeliveredAt,
+		CreatedAt:      d.CreatedAt,
+	}
+	if d.Status == ledger.DeliveryPending {
+		next := d.NextAttemptAt
+		resp.NextAttemptAt = &next
+	}
+	return resp
+}
@@ -0,0 +1,64 @@
+// Year-end closing handler: zero revenue/expense accounts into retained earnings.
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"errors"
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/service/yearend"
+)
+
+// POST /v1/year-end/close
+// Body: { user_id, year, year_end? }
+// 201 when closing entries were posted; 200 when the year was already closed (idempotent).
+func (s *Server) closeYear(w http.ResponseWriter, r *http.Request) {
+	if !requireJSON(w, r) {
+		return
+	}
+	var body struct {
+		UserID  uuid.UUID  `json:"user_id"`
+		Year    int        `json:"year"`
+		YearEnd *time.Time `json:"year_end,omitempty"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "invalid JSON: " + err.Error()})
+		return
+	}
+	if body.UserID == uuid.Nil || body.Year == 0 {
+		toJSON(w, http.StatusBadRequest, errorResponse{Error: "user_id and year are required"})
+		return
+	}
+	var yearEnd time.Time
+	if body.YearEnd != nil {
+		yearEnd = body.YearEnd.UTC()
+	}
+	res, err := s.yearEndSvc.CloseYear(r.Context(), body.UserID, body.Year, yearEnd)
+	if err != nil {
+		if errors.Is(err, errs.ErrInvalid) {
+			badRequest(w, "invalid")
+			return
+		}
+		if errors.Is(err, yearend.ErrEarlierYearOpen) {
+			unprocessable(w, err.Error(), "earlier_year_open")
+			return
+		}
+		code, msg := mapValidationError(err)
+		unprocessable(w, msg, code)
+		return
+	}
+	resp := yearEndCloseResponse{UserID: body.UserID, Year: res.Year, YearEnd: res.YearEnd, Created: res.Created, Entries: make([]entryResponse, 0, len(res.Entries))}
+	for _, e := range res.Entries {
+		resp.Entries = append(resp.Entries, toEntryResponse(e))
+	}
+	status := http.StatusOK
+	if res.Created {
+		status = http.StatusCreated
+	}
+	toJSON(w, status, resp)
+}
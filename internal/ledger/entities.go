@@ -1,6 +1,7 @@
 package ledger
 
 import (
+	"encoding/json"
 	"strings"
 	"time"
 
@@ -105,9 +106,79 @@ type JournalEntry struct {
 	Category Category
 	// Metadata holds additional key-value attributes for the entry.
 	Metadata meta.Metadata `json:"metadata,omitempty"`
-	// IsReversed marks that this entry has been reversed.
+	// IsReversed marks that every line of this entry has been fully reversed.
 	IsReversed bool
 	Lines      JournalLines
+	// Relations link the entry to reversals, reclassifications and other
+	// entries. Each link is also recorded, in its inverse kind, on the other side.
+	Relations []EntryRelation
+}
+
+// RelationKind names how one journal entry relates to another.
+type RelationKind string
+
+const (
+	// RelationReverses points from a reversal to the entry it reverses.
+	RelationReverses RelationKind = "reverses"
+	// RelationReversedBy points from an entry to each of its reversals.
+	RelationReversedBy RelationKind = "reversed_by"
+	// RelationReclassifiedFrom points from a correcting entry to the entry it reclassifies.
+	RelationReclassifiedFrom RelationKind = "reclassified_from"
+	// RelationReclassifiedTo points from a reclassified entry to its correcting entry.
+	RelationReclassifiedTo RelationKind = "reclassified_to"
+	// RelationRelated is a user-made link between two entries.
+	RelationRelated RelationKind = "related"
+)
+
+// IsValid reports whether k is a known relation kind.
+func (k RelationKind) IsValid() bool {
+	switch k {
+	case RelationReverses, RelationReversedBy, RelationReclassifiedFrom, RelationReclassifiedTo, RelationRelated:
+		return true
+	}
+	return false
+}
+
+// Inverse returns the kind recorded on the other entry of a link.
+func (k RelationKind) Inverse() RelationKind {
+	switch k {
+	case RelationReverses:
+		return RelationReversedBy
+	case RelationReversedBy:
+		return RelationReverses
+	case RelationReclassifiedFrom:
+		return RelationReclassifiedTo
+	case RelationReclassifiedTo:
+		return RelationReclassifiedFrom
+	}
+	return k
+}
+
+// EntryRelation links a journal entry to another entry of the same user.
+type EntryRelation struct {
+	Kind    RelationKind
+	EntryID uuid.UUID
+}
+
+// RelatedTo returns the IDs of entries e links to with kind, in link order.
+func (e JournalEntry) RelatedTo(kind RelationKind) []uuid.UUID {
+	var out []uuid.UUID
+	for _, r := range e.Relations {
+		if r.Kind == kind {
+			out = append(out, r.EntryID)
+		}
+	}
+	return out
+}
+
+// HasRelation reports whether e links to id with kind; an empty kind matches any.
+func (e JournalEntry) HasRelation(kind RelationKind, id uuid.UUID) bool {
+	for _, r := range e.Relations {
+		if (kind == "" || r.Kind == kind) && (id == uuid.Nil || r.EntryID == id) {
+			return true
+		}
+	}
+	return false
 }
 
 // JournalLines groups the set of lines that belong to a journal entry.
@@ -116,11 +187,463 @@ type JournalLines struct {
 }
 
 // JournalLine links a journal entry to an account with an amount on a side.
+// Amount is denominated in the account's currency, which may differ from the
+// entry currency for cross-currency entries.
 type JournalLine struct {
 	ID        uuid.UUID
 	EntryID   uuid.UUID
 	AccountID uuid.UUID
 	Side      Side
 	Amount    money.Amount
-	Metadata  map[string]string
+	// Rate converts Amount into the entry currency (base: line currency,
+	// quote: entry currency). Nil when the line is in the entry currency.
+	Rate     *money.ExchangeRate
+	Metadata map[string]string
+	// Status tracks bank clearing of the line; empty means uncleared.
+	Status LineStatus
+	// Reversed is the part of Amount undone by reversals so far, in the line
+	// currency; nil until the line is first reversed.
+	Reversed *money.Amount
+}
+
+// ReversedMinor returns the reversed part of the line in minor units of the line currency.
+func (l JournalLine) ReversedMinor() int64 {
+	if l.Reversed == nil {
+		return 0
+	}
+	minor, _ := l.Reversed.MinorUnits()
+	return minor
+}
+
+// RemainingMinor returns the part of the line not yet reversed, in minor units of the line currency.
+func (l JournalLine) RemainingMinor() int64 {
+	minor, _ := l.Amount.MinorUnits()
+	return minor - l.ReversedMinor()
+}
+
+// LineStatus is the bank reconciliation state of a journal line.
+type LineStatus string
+
+const (
+	// LineStatusUncleared lines have not been matched to a bank statement.
+	LineStatusUncleared LineStatus = "uncleared"
+	// LineStatusCleared lines are matched to a line of an open reconciliation.
+	LineStatusCleared LineStatus = "cleared"
+	// LineStatusReconciled lines belong to a finished reconciliation; their
+	// entry cannot be reversed or reclassified until it is reopened.
+	LineStatusReconciled LineStatus = "reconciled"
+)
+
+// ClearStatus returns the line status, reading the zero value as uncleared.
+func (l JournalLine) ClearStatus() LineStatus {
+	if l.Status == "" {
+		return LineStatusUncleared
+	}
+	return l.Status
+}
+
+// EntryAmount returns the line amount in the entry currency, rounded to its minor units.
+func (l JournalLine) EntryAmount() (money.Amount, error) {
+	if l.Rate == nil {
+		return l.Amount, nil
+	}
+	v, err := l.Rate.Conv(l.Amount)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	return v.RoundToCurr(), nil
+}
+
+// PeriodStatus enumerates the lifecycle of an accounting period.
+type PeriodStatus string
+
+const (
+	// PeriodStatusOpen accepts postings dated inside the period.
+	PeriodStatusOpen PeriodStatus = "open"
+	// PeriodStatusClosed rejects postings but can be reopened.
+	PeriodStatusClosed PeriodStatus = "closed"
+	// PeriodStatusLocked rejects postings permanently; it cannot be reopened.
+	PeriodStatusLocked PeriodStatus = "locked"
+)
+
+// Period is a per-user accounting period covering [Start, End).
+type Period struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Name is a free-form label, e.g. 2025-09 or FY2025.
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Status PeriodStatus
+	// ClosedAt records when the period was last closed or locked.
+	ClosedAt *time.Time
+}
+
+// Contains reports whether t falls inside the period (start inclusive, end exclusive).
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+// Overlaps reports whether p and o share any instant.
+func (p Period) Overlaps(o Period) bool {
+	return p.Start.Before(o.End) && o.Start.Before(p.End)
+}
+
+// AcceptsPostings reports whether entries may be dated inside the period.
+func (p Period) AcceptsPostings() bool { return p.Status == PeriodStatusOpen }
+
+// FXRate is a per-user exchange rate for a currency pair, effective from Date (a UTC day)
+// until the next rate for the same pair.
+type FXRate struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Date   time.Time
+	// Rate converts the base currency into the quote currency: 1 base = Rate quote.
+	Rate money.ExchangeRate
+}
+
+// Base returns the ISO code of the currency being converted from.
+func (r FXRate) Base() string { return r.Rate.Base().Code() }
+
+// Quote returns the ISO code of the currency being converted into.
+func (r FXRate) Quote() string { return r.Rate.Quote().Code() }
+
+// BudgetPeriod is the length of a budget's period.
+type BudgetPeriod string
+
+const (
+	BudgetPeriodMonthly BudgetPeriod = "monthly"
+	BudgetPeriodYearly  BudgetPeriod = "yearly"
+)
+
+// Budget caps (expense) or targets (revenue) activity for an account path over one period.
+type Budget struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Path is an account path or a prefix of one, e.g. expense:groceries covers every
+	// groceries vendor and expense:groceries:market a single account.
+	Path   string
+	Period BudgetPeriod
+	// Start is the first instant of the period: the 1st of a month or Jan 1 (UTC).
+	Start time.Time
+	// Amount is the budgeted amount; its currency selects the accounts it applies to.
+	Amount money.Amount
+}
+
+// End returns the exclusive end of the budget period.
+func (b Budget) End() time.Time {
+	if b.Period == BudgetPeriodYearly {
+		return b.Start.AddDate(1, 0, 0)
+	}
+	return b.Start.AddDate(0, 1, 0)
+}
+
+// Covers reports whether the account falls under the budget's path and currency.
+func (b Budget) Covers(a Account) bool {
+	if !strings.EqualFold(a.Currency, b.Amount.Curr().Code()) {
+		return false
+	}
+	p, prefix := a.Path(), strings.ToLower(b.Path)
+	return p == prefix || strings.HasPrefix(p, prefix+":")
+}
+
+// Schedule posts a copy of Template on every occurrence of Rule.
+type Schedule struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Name   string
+	// Rule is an RRULE-like recurrence, e.g. FREQ=MONTHLY;BYMONTHDAY=1.
+	Rule string
+	// Start anchors the rule; occurrences keep its time of day.
+	Start time.Time
+	// Until optionally bounds the last occurrence (inclusive).
+	Until    *time.Time
+	Template ScheduleTemplate
+	Active   bool
+	// NextRun is the earliest occurrence not yet posted; nil once the rule is exhausted.
+	NextRun *time.Time
+	// LastError records why the last attempt to post NextRun failed, if it did.
+	LastError string
+}
+
+// ScheduleTemplate is the entry posted for each occurrence; line IDs are assigned per posting.
+type ScheduleTemplate struct {
+	Currency string
+	Memo     string
+	Category Category
+	Metadata meta.Metadata
+	Lines    []JournalLine
+}
+
+// Rule rewrites incoming entries that satisfy every condition set in Match.
+type Rule struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Name   string
+	// Priority orders evaluation ascending; later matches override earlier ones.
+	Priority int
+	Active   bool
+	// Stop ends evaluation once this rule has matched.
+	Stop    bool
+	Match   RuleMatch
+	Actions RuleActions
+}
+
+// RuleMatch holds the conditions of a rule; unset conditions always match.
+type RuleMatch struct {
+	// MemoPattern is a case-insensitive regular expression matched against the memo.
+	MemoPattern string
+	// Metadata requires each key to be present and, when the value is non-empty, equal.
+	Metadata map[string]string
+	// MinAmountMinor and MaxAmountMinor bound the matched amount (inclusive): the
+	// source line amount when SourceAccountID is set, otherwise the entry's debit total.
+	MinAmountMinor *int64
+	MaxAmountMinor *int64
+	// SourceAccountID requires a line posted to this account.
+	SourceAccountID *uuid.UUID
+}
+
+// RuleActions are applied to a matching entry.
+type RuleActions struct {
+	// Category replaces the entry category when set.
+	Category Category
+	// CounterAccountID moves the line not on the source account to this
+	// account; entries with more than one such line are left as they are.
+	CounterAccountID *uuid.UUID
+	// Metadata is merged into the entry metadata.
+	Metadata map[string]string
+}
+
+// AmountSign tells how a single signed amount column maps onto money in and out.
+type AmountSign string
+
+const (
+	// AmountSignInflowPositive treats positive amounts as deposits (most banks).
+	AmountSignInflowPositive AmountSign = "inflow_positive"
+	// AmountSignOutflowPositive treats positive amounts as withdrawals (e.g. card statements).
+	AmountSignOutflowPositive AmountSign = "outflow_positive"
+)
+
+// ImportProfile is a saved column mapping for a bank's CSV export.
+type ImportProfile struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Name   string
+	// CounterAccountID is the default counter-account for imported rows; the
+	// suspense account is used when it is nil and no rule picks one.
+	CounterAccountID *uuid.UUID
+	Mapping          CSVMapping
+}
+
+// CSVMapping describes how to read a CSV. Columns are referenced by header name
+// (case-insensitive) or by 1-based column number.
+type CSVMapping struct {
+	// Delimiter is the field separator; defaults to a comma.
+	Delimiter string
+	// HasHeader marks the first row as column names rather than data.
+	HasHeader bool
+	// SkipRows drops lines before the header (or first data row), e.g. bank preambles.
+	SkipRows   int
+	DateColumn string
+	// DateFormat uses YYYY, YY, MM, MMM, DD tokens, e.g. DD/MM/YYYY.
+	DateFormat string
+	// AmountColumn holds one signed amount interpreted through Sign. Alternatively
+	// InflowColumn and OutflowColumn hold unsigned money in and money out.
+	AmountColumn  string
+	Sign          AmountSign
+	InflowColumn  string
+	OutflowColumn string
+	// DecimalComma parses amounts written as 1.234,56.
+	DecimalComma bool
+	// MemoColumns are joined with a space to form the entry memo.
+	MemoColumns     []string
+	ReferenceColumn string
+}
+
+// ReconciliationStatus enumerates the lifecycle of a bank reconciliation.
+type ReconciliationStatus string
+
+const (
+	// ReconciliationStatusOpen accepts statement lines and matching.
+	ReconciliationStatusOpen ReconciliationStatus = "open"
+	// ReconciliationStatusFinished has recorded its reconciled balance; it can be reopened.
+	ReconciliationStatusFinished ReconciliationStatus = "finished"
+)
+
+// Reconciliation matches one bank statement of an asset or liability account
+// against the account's journal lines. Balances and statement line amounts are
+// signed from the account holder's view (positive is money in), which is the
+// debit-positive sign of account balances.
+type Reconciliation struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	AccountID uuid.UUID
+	// StatementDate is the closing date of the statement.
+	StatementDate time.Time
+	// OpeningBalance is the statement's opening balance: the previous
+	// reconciliation's reconciled balance, or zero for the first one.
+	OpeningBalance money.Amount
+	// StatementBalance is the statement's closing balance.
+	StatementBalance money.Amount
+	Status           ReconciliationStatus
+	// ReconciledBalance is recorded when the reconciliation is finished.
+	ReconciledBalance *money.Amount
+	FinishedAt        *time.Time
+	Lines             []StatementLine
+}
+
+// StatementLine is one bank statement line of a reconciliation.
+type StatementLine struct {
+	ID   uuid.UUID
+	Date time.Time
+	// Amount is signed from the account holder's view: positive is money in.
+	Amount      money.Amount
+	Description string
+	// Reference is the bank's or payer's reference, used to auto-match.
+	Reference string
+	// JournalLineID is the matched journal line on the reconciled account.
+	JournalLineID *uuid.UUID
+}
+
+// BalanceAssertion states that an account's balance (debits minus credits, in
+// the account currency) must equal Amount at the end of Date.
+type BalanceAssertion struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	AccountID uuid.UUID
+	// Date is a UTC calendar day; entries dated any time on it count.
+	Date   time.Time
+	Amount money.Amount
+	Note   string
+}
+
+// Cutoff returns the last instant counted towards the asserted balance.
+func (a BalanceAssertion) Cutoff() time.Time {
+	return a.Date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// AuditOperation names the kind of write an audit record describes.
+type AuditOperation string
+
+const (
+	AuditCreate     AuditOperation = "create"
+	AuditUpdate     AuditOperation = "update"
+	AuditReverse    AuditOperation = "reverse"
+	AuditReclassify AuditOperation = "reclassify"
+	AuditRelate     AuditOperation = "relate"
+	AuditUnrelate   AuditOperation = "unrelate"
+	AuditDeactivate AuditOperation = "deactivate"
+	AuditReactivate AuditOperation = "reactivate"
+)
+
+// AuditEntity names the kind of record an audit record describes.
+type AuditEntity string
+
+const (
+	AuditEntityEntry   AuditEntity = "entry"
+	AuditEntityAccount AuditEntity = "account"
+)
+
+// AuditRecord is an append-only record of one write to an entry or account.
+type AuditRecord struct {
+	// Seq orders records of the whole log; stores assign it on append.
+	Seq    int64
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Actor is the JWT subject of the caller; empty when auth is disabled or
+	// no request made the write, as for scheduled postings.
+	Actor string
+	// RequestID is empty for writes made outside a request.
+	RequestID string
+	Operation AuditOperation
+	Entity    AuditEntity
+	EntityID  uuid.UUID
+	// Before and After are JSON snapshots of the entity as the API returns
+	// it. Before is nil for creations.
+	Before    json.RawMessage
+	After     json.RawMessage
+	CreatedAt time.Time
+}
+
+// EventType names a ledger event published to webhooks.
+type EventType string
+
+const (
+	EventEntryCreated       EventType = "entry.created"
+	EventEntryReversed      EventType = "entry.reversed"
+	EventAccountUpdated     EventType = "account.updated"
+	EventAccountDeactivated EventType = "account.deactivated"
+)
+
+// IsValid reports whether t is a known event type.
+func (t EventType) IsValid() bool {
+	switch t {
+	case EventEntryCreated, EventEntryReversed, EventAccountUpdated, EventAccountDeactivated:
+		return true
+	}
+	return false
+}
+
+// Event is a change to the ledger recorded in the outbox by the same write
+// that made it, for delivery to webhooks.
+type Event struct {
+	// Seq orders events; stores assign it on insert.
+	Seq      int64
+	ID       uuid.UUID
+	UserID   uuid.UUID
+	Type     EventType
+	EntityID uuid.UUID
+	// Data is the JSON payload delivered as the event's data.
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+// WebhookEndpoint receives a user's events of the chosen types.
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	URL        string
+	EventTypes []EventType
+	// Secret keys the HMAC-SHA256 signature sent with every delivery.
+	Secret    string
+	CreatedAt time.Time
+}
+
+// Wants reports whether the endpoint subscribes to events of type t.
+func (w WebhookEndpoint) Wants(t EventType) bool {
+	for _, et := range w.EventTypes {
+		if et == t {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliveryStatus is the state of an event's delivery to one endpoint.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are retried until they succeed or run out of attempts.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliveryDelivered deliveries got a 2xx response.
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead deliveries ran out of attempts; only a manual redeliver retries them.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery tracks sending one event to one endpoint.
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	UserID        uuid.UUID
+	EventID       uuid.UUID
+	EventType     EventType
+	EndpointID    uuid.UUID
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastError and LastStatusCode describe the latest failed attempt.
+	LastError      string
+	LastStatusCode int
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
 }
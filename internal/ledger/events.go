@@ -0,0 +1,95 @@
+package ledger
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/meta"
+)
+
+// Event payloads. Stores build events with these helpers inside the write
+// they describe so the outbox never disagrees with the ledger.
+
+type eventLine struct {
+	ID          uuid.UUID `json:"id"`
+	AccountID   uuid.UUID `json:"account_id"`
+	Side        Side      `json:"side"`
+	AmountMinor int64     `json:"amount_minor"`
+	Currency    string    `json:"currency"`
+}
+
+type eventEntry struct {
+	ID       uuid.UUID     `json:"id"`
+	UserID   uuid.UUID     `json:"user_id"`
+	Date     time.Time     `json:"date"`
+	Currency string        `json:"currency"`
+	Memo     string        `json:"memo"`
+	Category Category      `json:"category"`
+	Metadata meta.Metadata `json:"metadata,omitempty"`
+	Lines    []eventLine   `json:"lines"`
+}
+
+type eventAccount struct {
+	ID       uuid.UUID     `json:"id"`
+	UserID   uuid.UUID     `json:"user_id"`
+	Name     string        `json:"name"`
+	Currency string        `json:"currency"`
+	Type     AccountType   `json:"type"`
+	Group    string        `json:"group"`
+	Vendor   string        `json:"vendor"`
+	Path     string        `json:"path"`
+	Metadata meta.Metadata `json:"metadata,omitempty"`
+	System   bool          `json:"system"`
+	Active   bool          `json:"active"`
+}
+
+// EntryEvents returns the events for posting e: entry.created, plus
+// entry.reversed on the original when e is a reversal.
+func EntryEvents(e JournalEntry) []Event {
+	lines := make([]eventLine, 0, len(e.Lines.ByID))
+	for _, ln := range e.Lines.ByID {
+		minor, _ := ln.Amount.MinorUnits()
+		lines = append(lines, eventLine{ID: ln.ID, AccountID: ln.AccountID, Side: ln.Side, AmountMinor: minor, Currency: ln.Amount.Curr().Code()})
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ID.String() < lines[j].ID.String() })
+	out := []Event{newEvent(e.UserID, EventEntryCreated, e.ID, map[string]any{"entry": eventEntry{
+		ID: e.ID, UserID: e.UserID, Date: e.Date, Currency: e.Currency, Memo: e.Memo, Category: e.Category, Metadata: e.Metadata, Lines: lines,
+	}})}
+	for _, orig := range e.RelatedTo(RelationReverses) {
+		out = append(out, newEvent(e.UserID, EventEntryReversed, orig, map[string]any{"entry_id": orig, "reversal_id": e.ID}))
+	}
+	return out
+}
+
+// AccountEvents returns the event for changing before into after:
+// account.deactivated when it stopped being active, account.updated otherwise,
+// and none when nothing changed.
+func AccountEvents(before, after Account) []Event {
+	was, now := toEventAccount(before), toEventAccount(after)
+	if string(mustJSON(was)) == string(mustJSON(now)) {
+		return nil
+	}
+	t := EventAccountUpdated
+	if before.Active && !after.Active {
+		t = EventAccountDeactivated
+	}
+	return []Event{newEvent(after.UserID, t, after.ID, map[string]any{"account": now})}
+}
+
+func toEventAccount(a Account) eventAccount {
+	return eventAccount{
+		ID: a.ID, UserID: a.UserID, Name: a.Name, Currency: a.Currency, Type: a.Type, Group: a.Group,
+		Vendor: a.Vendor, Path: a.Path(), Metadata: a.Metadata, System: a.System, Active: a.Active,
+	}
+}
+
+func mustJSON(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+func newEvent(userID uuid.UUID, t EventType, entityID uuid.UUID, data any) Event {
+	return Event{ID: uuid.New(), UserID: userID, Type: t, EntityID: entityID, Data: mustJSON(data), CreatedAt: time.Now().UTC()}
+}
@@ -0,0 +1,292 @@
+package plaintext
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// metaCategory carries the entry category alongside its metadata.
+const metaCategory = "category"
+
+// Export writes accounts and entries as a journal in format f: account
+// directives first, then one transaction per entry in date order.
+//
+// Postings are debit-positive in the account currency, so per-account,
+// per-commodity balances computed by the tools equal the trial balance.
+// Lines in another currency than the entry carry their converted value as a
+// total cost (@@); any rounding residue the journal tolerates is absorbed into
+// the last such cost so every transaction balances exactly.
+func Export(w io.Writer, f Format, accounts []ledger.Account, entries []ledger.JournalEntry) error {
+	names := AccountNames(f, accounts)
+	sorted := append([]ledger.JournalEntry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].Date.Equal(sorted[j].Date) {
+			return sorted[i].Date.Before(sorted[j].Date)
+		}
+		return sorted[i].ID.String() < sorted[j].ID.String()
+	})
+	bw := bufio.NewWriter(w)
+	openDate := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	if len(sorted) > 0 {
+		openDate = sorted[0].Date.UTC()
+	}
+	writeAccounts(bw, f, accounts, names, openDate)
+	for _, e := range sorted {
+		if err := writeEntry(bw, f, e, names); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+type accountDirective struct {
+	name       string
+	typ        ledger.AccountType
+	currencies []string
+}
+
+func writeAccounts(w *bufio.Writer, f Format, accounts []ledger.Account, names map[uuid.UUID]string, openDate time.Time) {
+	byName := map[string]*accountDirective{}
+	currencies := map[string]bool{}
+	for _, a := range accounts {
+		name := names[a.ID]
+		d := byName[name]
+		if d == nil {
+			d = &accountDirective{name: name, typ: a.Type}
+			byName[name] = d
+		}
+		if !contains(d.currencies, a.Currency) {
+			d.currencies = append(d.currencies, a.Currency)
+		}
+		currencies[a.Currency] = true
+	}
+	dirs := make([]*accountDirective, 0, len(byName))
+	for _, d := range byName {
+		sort.Strings(d.currencies)
+		dirs = append(dirs, d)
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].name < dirs[j].name })
+
+	if f == FormatHledger && len(currencies) > 0 {
+		codes := make([]string, 0, len(currencies))
+		for c := range currencies {
+			codes = append(codes, c)
+		}
+		sort.Strings(codes)
+		for _, c := range codes {
+			fmt.Fprintf(w, "commodity %s\n", commodityStyle(c))
+		}
+		w.WriteString("\n")
+	}
+	for _, d := range dirs {
+		switch f {
+		case FormatBeancount:
+			fmt.Fprintf(w, "%s open %s %s\n", openDate.Format("2006-01-02"), d.name, strings.Join(d.currencies, ","))
+		case FormatHledger:
+			fmt.Fprintf(w, "account %s  ; type: %s, currencies: %s\n", d.name, hledgerTypes[d.typ], strings.Join(d.currencies, " "))
+		default:
+			checks := make([]string, 0, len(d.currencies))
+			for _, c := range d.currencies {
+				checks = append(checks, fmt.Sprintf("commodity == %q", c))
+			}
+			fmt.Fprintf(w, "account %s\n    check %s\n", d.name, strings.Join(checks, " or "))
+		}
+	}
+	if len(dirs) > 0 {
+		w.WriteString("\n")
+	}
+}
+
+type posting struct {
+	name   string
+	line   *ledger.JournalLine
+	amount money.Amount
+	// cost is the total value in the entry currency for converted lines.
+	cost *money.Amount
+}
+
+func writeEntry(w *bufio.Writer, f Format, e ledger.JournalEntry, names map[uuid.UUID]string) error {
+	posts := make([]posting, 0, len(e.Lines.ByID))
+	var residue int64
+	for _, l := range e.Lines.ByID {
+		name, ok := names[l.AccountID]
+		if !ok {
+			return fmt.Errorf("entry %s: unknown account %s", e.ID, l.AccountID)
+		}
+		p := posting{name: name, line: l, amount: l.Amount}
+		if l.Side == ledger.SideCredit {
+			p.amount = l.Amount.Neg()
+		}
+		conv, err := l.EntryAmount()
+		if err != nil {
+			return fmt.Errorf("entry %s: %w", e.ID, err)
+		}
+		minor, _ := conv.MinorUnits()
+		if l.Side == ledger.SideCredit {
+			minor = -minor
+		}
+		residue += minor
+		if l.Rate != nil {
+			c := conv
+			p.cost = &c
+		}
+		posts = append(posts, p)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		if (posts[i].line.Side == ledger.SideDebit) != (posts[j].line.Side == ledger.SideDebit) {
+			return posts[i].line.Side == ledger.SideDebit
+		}
+		if posts[i].name != posts[j].name {
+			return posts[i].name < posts[j].name
+		}
+		return posts[i].line.ID.String() < posts[j].line.ID.String()
+	})
+	if residue != 0 {
+		for i := len(posts) - 1; i >= 0; i-- {
+			if posts[i].cost == nil {
+				continue
+			}
+			// The cost's weight carries the posting's sign, so shrink it by the residue.
+			minor, _ := posts[i].cost.MinorUnits()
+			if posts[i].line.Side == ledger.SideCredit {
+				minor += residue
+			} else {
+				minor -= residue
+			}
+			if minor < 0 {
+				minor = -minor
+			}
+			adj, err := money.NewAmountFromMinorUnits(e.Currency, minor)
+			if err != nil {
+				return fmt.Errorf("entry %s: %w", e.ID, err)
+			}
+			posts[i].cost = &adj
+			break
+		}
+	}
+
+	tags := entryTags(e)
+	date := e.Date.UTC().Format("2006-01-02")
+	if f == FormatBeancount {
+		fmt.Fprintf(w, "%s * %s ^%s\n", date, quote(e.Memo), e.ID)
+		writeBeancountMeta(w, "  ", tags)
+	} else {
+		fmt.Fprintf(w, "%s (%s) %s\n", date, e.ID, oneLine(e.Memo))
+		writeComments(w, "    ", tags)
+	}
+	width := 0
+	for _, p := range posts {
+		if n := len(statusMark(p.line.ClearStatus())) + len(p.name); n > width {
+			width = n
+		}
+	}
+	indent := "    "
+	if f == FormatBeancount {
+		indent = "  "
+	}
+	for _, p := range posts {
+		account := statusMark(p.line.ClearStatus()) + p.name
+		fmt.Fprintf(w, "%s%-*s  %s %s", indent, width, account, p.amount.Decimal(), p.amount.Curr().Code())
+		if p.cost != nil {
+			fmt.Fprintf(w, " @@ %s %s", p.cost.Decimal(), p.cost.Curr().Code())
+		}
+		w.WriteString("\n")
+		if f == FormatBeancount {
+			writeBeancountMeta(w, indent+"  ", p.line.Metadata)
+		} else {
+			writeComments(w, indent+"  ", p.line.Metadata)
+		}
+	}
+	w.WriteString("\n")
+	return nil
+}
+
+// entryTags returns the entry metadata plus its category.
+func entryTags(e ledger.JournalEntry) map[string]string {
+	tags := make(map[string]string, len(e.Metadata)+1)
+	for k, v := range e.Metadata {
+		tags[k] = v
+	}
+	if _, ok := tags[metaCategory]; !ok && e.Category != "" {
+		tags[metaCategory] = string(e.Category)
+	}
+	return tags
+}
+
+// statusMark renders a line's clearing state as a posting flag: reconciled
+// lines are cleared (*) and lines matched in an open reconciliation pending (!).
+func statusMark(s ledger.LineStatus) string {
+	switch s {
+	case ledger.LineStatusReconciled:
+		return "* "
+	case ledger.LineStatusCleared:
+		return "! "
+	default:
+		return ""
+	}
+}
+
+func writeComments(w *bufio.Writer, indent string, m map[string]string) {
+	for _, k := range sortedKeys(m) {
+		fmt.Fprintf(w, "%s; %s: %s\n", indent, oneLine(k), oneLine(m[k]))
+	}
+}
+
+func writeBeancountMeta(w *bufio.Writer, indent string, m map[string]string) {
+	for _, k := range sortedKeys(m) {
+		fmt.Fprintf(w, "%s%s: %s\n", indent, beancountKey(k), quote(m[k]))
+	}
+}
+
+// commodityStyle is a sample amount fixing a commodity's display precision for hledger.
+func commodityStyle(code string) string {
+	scale := 2
+	if c, err := money.ParseCurr(code); err == nil {
+		scale = c.Scale()
+	}
+	coef := int64(1000)
+	for i := 0; i < scale; i++ {
+		coef *= 10
+	}
+	if a, err := money.NewAmount(code, coef, scale); err == nil {
+		return a.Decimal().String() + " " + code
+	}
+	return "1000.00 " + code
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(oneLine(s), `"`, `\"`) + `"`
+}
+
+// oneLine folds line breaks, which would end a journal line early.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package plaintext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/decimal"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+)
+
+func fixture(t *testing.T) ([]ledger.Account, []ledger.JournalEntry) {
+	t.Helper()
+	user := uuid.New()
+	cash := ledger.Account{ID: uuid.New(), UserID: user, Currency: "USD", Type: ledger.AccountTypeAsset, Group: "cash", Vendor: "Wallet", Active: true}
+	card := ledger.Account{ID: uuid.New(), UserID: user, Currency: "USD", Type: ledger.AccountTypeLiability, Group: "credit_card", Vendor: "Amex", Active: true}
+	euro := ledger.Account{ID: uuid.New(), UserID: user, Currency: "EUR", Type: ledger.AccountTypeAsset, Group: "bank", Vendor: "N26", Active: true}
+	obUSD := ledger.Account{ID: uuid.New(), UserID: user, Currency: "USD", Type: ledger.AccountTypeEquity, Group: "opening_balances", Vendor: "System", System: true, Active: true}
+	obEUR := ledger.Account{ID: uuid.New(), UserID: user, Currency: "EUR", Type: ledger.AccountTypeEquity, Group: "opening_balances", Vendor: "System", System: true, Active: true}
+	amt := func(curr string, minor int64) money.Amount {
+		a, err := money.NewAmountFromMinorUnits(curr, minor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	line := func(acc ledger.Account, side ledger.Side, minor int64) *ledger.JournalLine {
+		return &ledger.JournalLine{ID: uuid.New(), AccountID: acc.ID, Side: side, Amount: amt(acc.Currency, minor)}
+	}
+	entry := func(day int, curr, memo string, lines ...*ledger.JournalLine) ledger.JournalEntry {
+		e := ledger.JournalEntry{ID: uuid.New(), UserID: user, Date: time.Date(2025, 1, day, 9, 30, 0, 0, time.UTC), Currency: curr, Memo: memo, Category: ledger.CategoryGeneral, Lines: ledger.JournalLines{ByID: map[uuid.UUID]*ledger.JournalLine{}}}
+		for _, l := range lines {
+			l.EntryID = e.ID
+			e.Lines.ByID[l.ID] = l
+		}
+		return e
+	}
+	rate, err := money.NewExchRate("EUR", "USD", 15, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fx1, fx2 := line(euro, ledger.SideDebit, 1), line(euro, ledger.SideDebit, 1)
+	fx1.Rate, fx2.Rate = &rate, &rate
+	opening := entry(1, "USD", "Opening \"balance\"", line(cash, ledger.SideDebit, 10000), line(obUSD, ledger.SideCredit, 10000))
+	opening.Lines.ByID[firstLine(opening, cash.ID)].Status = ledger.LineStatusReconciled
+	openingEUR := entry(1, "EUR", "Opening EUR", line(euro, ledger.SideDebit, 5000), line(obEUR, ledger.SideCredit, 5000))
+	lunch := entry(3, "USD", "Lunch\nwith team", line(cash, ledger.SideCredit, 1250), line(card, ledger.SideDebit, 1250))
+	lunch.Metadata = meta.New(map[string]string{"tracker.bank_ref": "A1", "note": "team"})
+	// Each EUR line converts to 0.015 USD and rounds up, leaving a one cent residue.
+	transfer := entry(5, "USD", "Move to EUR", fx1, fx2, line(cash, ledger.SideCredit, 3))
+	return []ledger.Account{cash, card, euro, obUSD, obEUR}, []ledger.JournalEntry{lunch, transfer, openingEUR, opening}
+}
+
+func firstLine(e ledger.JournalEntry, accountID uuid.UUID) uuid.UUID {
+	for id, l := range e.Lines.ByID {
+		if l.AccountID == accountID {
+			return id
+		}
+	}
+	return uuid.Nil
+}
+
+// parsed sums postings per account and commodity and checks each transaction balances.
+func parsed(t *testing.T, out string, beancount bool) map[string]decimal.Decimal {
+	t.Helper()
+	sums := map[string]decimal.Decimal{}
+	var weights map[string]decimal.Decimal
+	flush := func() {
+		for curr, w := range weights {
+			if !w.IsZero() {
+				t.Fatalf("transaction does not balance in %s: %s\n%s", curr, w, out)
+			}
+		}
+		weights = nil
+	}
+	for _, raw := range strings.Split(out, "\n") {
+		if raw == "" || raw[0] != ' ' {
+			flush()
+			if raw != "" && raw[0] >= '0' && raw[0] <= '9' && !strings.Contains(raw, " open ") {
+				weights = map[string]decimal.Decimal{}
+			}
+			continue
+		}
+		if weights == nil {
+			continue
+		}
+		f := strings.Fields(raw)
+		if f[0] == "*" || f[0] == "!" {
+			f = f[1:]
+		}
+		if len(f) < 3 || strings.HasSuffix(f[0], ":") && beancount || f[0] == ";" {
+			continue
+		}
+		n, err := decimal.Parse(f[1])
+		if err != nil {
+			t.Fatalf("bad amount in %q: %v", raw, err)
+		}
+		key := f[0] + " " + f[2]
+		sums[key], _ = sums[key].Add(n)
+		wc, wn := f[2], n
+		if len(f) == 6 && f[3] == "@@" {
+			c, err := decimal.Parse(f[4])
+			if err != nil {
+				t.Fatal(err)
+			}
+			wc, wn = f[5], c.CopySign(n)
+		}
+		weights[wc], _ = weights[wc].Add(wn)
+	}
+	flush()
+	return sums
+}
+
+func TestExport_LedgerBalancesAndTags(t *testing.T) {
+	accounts, entries := fixture(t)
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatLedger, accounts, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"account asset:cash:wallet\n    check commodity == \"USD\"\n",
+		"account equity:opening_balances\n    check commodity == \"EUR\" or commodity == \"USD\"\n",
+		") Lunch with team\n    ; category: general\n    ; note: team\n    ; tracker.bank_ref: A1\n",
+		"    * asset:cash:wallet",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "Opening EUR") > strings.Index(out, "Lunch") {
+		t.Fatalf("entries not in date order:\n%s", out)
+	}
+	sums := parsed(t, out, false)
+	want := map[string]string{
+		"asset:cash:wallet USD":          "87.47",
+		"liability:credit_card:amex USD": "12.50",
+		"asset:bank:n26 EUR":             "50.02",
+		"equity:opening_balances USD":    "-100.00",
+		"equity:opening_balances EUR":    "-50.00",
+	}
+	for k, v := range want {
+		if sums[k].String() != v {
+			t.Fatalf("%s = %s, want %s\n%s", k, sums[k], v, out)
+		}
+	}
+}
+
+func TestExport_BeancountNamesAndMetadata(t *testing.T) {
+	accounts, entries := fixture(t)
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatBeancount, accounts, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"2025-01-01 open Liabilities:CreditCard:Amex USD\n",
+		"2025-01-01 open Equity:OpeningBalances EUR,USD\n",
+		" * \"Opening \\\"balance\\\"\" ^",
+		"  tracker-bank_ref: \"A1\"\n",
+		"  Assets:Bank:N26",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	sums := parsed(t, out, true)
+	if sums["Assets:Cash:Wallet USD"].String() != "87.47" || sums["Assets:Bank:N26 EUR"].String() != "50.02" {
+		t.Fatalf("unexpected balances %v\n%s", sums, out)
+	}
+}
+
+func TestExport_HledgerDirectives(t *testing.T) {
+	accounts, entries := fixture(t)
+	var buf bytes.Buffer
+	if err := Export(&buf, FormatHledger, accounts, entries); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"commodity 1000.00 EUR\n", "account liability:credit_card:amex  ; type: L, currencies: USD\n"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	parsed(t, out, false)
+}
+
+func TestAccountNames_DisambiguatesSameCurrency(t *testing.T) {
+	a := ledger.Account{ID: uuid.New(), Currency: "USD", Type: ledger.AccountTypeExpense, Group: "rent", Vendor: "Landlord Ltd"}
+	b := ledger.Account{ID: uuid.New(), Currency: "USD", Type: ledger.AccountTypeExpense, Group: "rent", Vendor: "landlord-ltd"}
+	names := AccountNames(FormatBeancount, []ledger.Account{a, b})
+	if names[a.ID] == names[b.ID] || !strings.HasPrefix(names[a.ID], "Expenses:Rent:LandlordLtd") {
+		t.Fatalf("unexpected names %v", names)
+	}
+	if n := AccountNames(FormatLedger, []ledger.Account{a})[a.ID]; n != "expense:rent:landlord ltd" {
+		t.Fatalf("unexpected ledger name %q", n)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(""); err != nil || f != FormatLedger {
+		t.Fatalf("default: %v %v", f, err)
+	}
+	if _, err := ParseFormat("gnucash"); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,824 @@
+package plaintext
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/govalues/decimal"
+	"github.com/govalues/money"
+)
+
+// Journal is the content of a parsed journal file, in file order.
+type Journal struct {
+	Accounts     []Open
+	Transactions []Transaction
+	Balances     []Balance
+}
+
+// Open declares an account: a beancount open directive or a ledger/hledger
+// account directive.
+type Open struct {
+	Line int
+	// Date is zero for ledger and hledger, whose account directives are undated.
+	Date    time.Time
+	Account string
+	// Currencies restricts the commodities the account may hold; empty means any.
+	Currencies []string
+}
+
+// Transaction is one dated transaction.
+type Transaction struct {
+	Line  int
+	Date  time.Time
+	Payee string
+	Memo  string
+	// Tags holds beancount #tags and ledger :tags:, without markers.
+	Tags     []string
+	Metadata map[string]string
+	Postings []Posting
+}
+
+// Posting moves an amount into or out of an account; positive is a debit.
+type Posting struct {
+	Line    int
+	Account string
+	// Amount is nil when elided; at most one posting per transaction may omit it.
+	Amount *money.Amount
+	// Cost is the posting's total value in another currency, from a total
+	// (@@, {{}}) or per-unit (@, {}) price.
+	Cost     *money.Amount
+	Metadata map[string]string
+}
+
+// Balance is a beancount balance directive: the account holds Amount at the
+// start of Date, before any of that day's transactions.
+type Balance struct {
+	Line    int
+	Date    time.Time
+	Account string
+	Amount  money.Amount
+}
+
+// LineError is a problem with one line of a journal.
+type LineError struct {
+	Line int
+	// Code classifies the problem, e.g. syntax or unsupported.
+	Code string
+	Err  error
+}
+
+func (e LineError) Error() string { return fmt.Sprintf("line %d: %v", e.Line, e.Err) }
+
+// commoditySymbols maps the currency symbols ledger files commonly use to ISO codes.
+var commoditySymbols = map[string]string{"$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY"}
+
+// Parse reads a journal in format f. It reports every malformed line rather
+// than stopping at the first; the returned error is reserved for read failures.
+func Parse(r io.Reader, f Format) (Journal, []LineError, error) {
+	p := &parser{format: f}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		p.line++
+		p.parseLine(strings.TrimRight(sc.Text(), "\r"))
+	}
+	if err := sc.Err(); err != nil {
+		return Journal{}, nil, err
+	}
+	p.endTxn()
+	sort.SliceStable(p.errs, func(i, j int) bool { return p.errs[i].Line < p.errs[j].Line })
+	return p.journal, p.errs, nil
+}
+
+type parser struct {
+	format  Format
+	line    int
+	journal Journal
+	errs    []LineError
+	// txn is the transaction whose indented lines are being read.
+	txn *Transaction
+	// txnFailed marks txn as having a bad line, so it is dropped at its end.
+	txnFailed bool
+	// postingIndent is the indentation of the last posting of txn.
+	postingIndent int
+	// open is the ledger account directive whose subdirectives are being read.
+	open *Open
+	// skipIndented ignores the indented body of an ignored directive.
+	skipIndented bool
+	inComment    bool
+	// tags holds beancount pushtag tags applied to following transactions.
+	tags []string
+}
+
+func (p *parser) fail(code, format string, args ...any) {
+	p.errs = append(p.errs, LineError{Line: p.line, Code: code, Err: fmt.Errorf(format, args...)})
+}
+
+func (p *parser) parseLine(raw string) {
+	if p.inComment {
+		if strings.HasPrefix(strings.TrimSpace(raw), "end comment") {
+			p.inComment = false
+		}
+		return
+	}
+	if strings.TrimSpace(raw) == "" {
+		p.endTxn()
+		return
+	}
+	if raw[0] == ' ' || raw[0] == '\t' {
+		p.parseIndented(raw)
+		return
+	}
+	p.endTxn()
+	p.open = nil
+	p.skipIndented = false
+	switch raw[0] {
+	case ';', '#', '%', '|', '*':
+		// Comments, and org-mode headings in beancount files.
+		return
+	}
+	n := len(p.errs)
+	if p.format == FormatBeancount {
+		p.parseBeancountDirective(raw)
+	} else {
+		p.parseLedgerDirective(raw)
+	}
+	// The body of a rejected directive would only add noise to the report.
+	if len(p.errs) > n && p.txn == nil && p.open == nil {
+		p.skipIndented = true
+	}
+}
+
+func (p *parser) endTxn() {
+	if p.txn == nil {
+		return
+	}
+	if p.txnFailed {
+		// Its postings were already reported; checking the rest would repeat them.
+		p.txn, p.txnFailed = nil, false
+		return
+	}
+	elided := 0
+	for _, ps := range p.txn.Postings {
+		if ps.Amount == nil {
+			elided++
+		}
+	}
+	switch {
+	case len(p.txn.Postings) < 2:
+		p.errs = append(p.errs, LineError{Line: p.txn.Line, Code: "syntax", Err: errors.New("transaction needs at least two postings")})
+	case elided > 1:
+		p.errs = append(p.errs, LineError{Line: p.txn.Line, Code: "syntax", Err: errors.New("only one posting per transaction may omit its amount")})
+	default:
+		p.journal.Transactions = append(p.journal.Transactions, *p.txn)
+	}
+	p.txn = nil
+}
+
+func (p *parser) parseIndented(raw string) {
+	if p.skipIndented {
+		return
+	}
+	indent := len(raw) - len(strings.TrimLeft(raw, " \t"))
+	text := strings.TrimSpace(raw)
+	if p.open != nil {
+		p.parseAccountSubdirective(text)
+		return
+	}
+	if p.txn == nil {
+		if text[0] != ';' {
+			p.fail("syntax", "unexpected indented line")
+		}
+		return
+	}
+	n := len(p.errs)
+	if p.format == FormatBeancount {
+		p.parseBeancountIndented(indent, text)
+	} else {
+		p.parseLedgerIndented(text)
+	}
+	if len(p.errs) > n {
+		p.txnFailed = true
+	}
+}
+
+// --- beancount ---
+
+func (p *parser) parseBeancountDirective(raw string) {
+	toks, err := tokenize(stripBeancountComment(raw))
+	if err != nil {
+		p.fail("syntax", "%v", err)
+		return
+	}
+	switch toks[0] {
+	case "option", "plugin":
+		return
+	case "include":
+		p.fail("unsupported", "include is not supported; import each file separately")
+		return
+	case "pushtag":
+		if len(toks) == 2 && strings.HasPrefix(toks[1], "#") {
+			p.tags = append(p.tags, toks[1][1:])
+		} else {
+			p.fail("syntax", "pushtag expects one #tag")
+		}
+		return
+	case "poptag":
+		if len(toks) == 2 && strings.HasPrefix(toks[1], "#") {
+			for i := len(p.tags) - 1; i >= 0; i-- {
+				if p.tags[i] == toks[1][1:] {
+					p.tags = append(p.tags[:i], p.tags[i+1:]...)
+					return
+				}
+			}
+		}
+		p.fail("syntax", "poptag without matching pushtag")
+		return
+	}
+	date, err := parseDate(toks[0])
+	if err != nil {
+		p.fail("syntax", "expected a date or directive, got %q", toks[0])
+		return
+	}
+	if len(toks) < 2 {
+		p.fail("syntax", "missing directive after date")
+		return
+	}
+	switch kw := toks[1]; kw {
+	case "open":
+		if len(toks) < 3 {
+			p.fail("syntax", "open needs an account")
+			return
+		}
+		o := Open{Line: p.line, Date: date, Account: toks[2]}
+		for _, t := range toks[3:] {
+			if isQuoted(t) {
+				continue // booking method
+			}
+			for _, c := range strings.Split(t, ",") {
+				if c = strings.TrimSpace(c); c != "" {
+					o.Currencies = append(o.Currencies, c)
+				}
+			}
+		}
+		p.journal.Accounts = append(p.journal.Accounts, o)
+	case "balance":
+		// balance Account NUMBER [~ TOLERANCE] CURRENCY
+		if len(toks) == 7 && toks[4] == "~" {
+			toks = append(toks[:4], toks[6])
+		}
+		if len(toks) != 5 {
+			p.fail("syntax", "balance expects an account, a number and a currency")
+			return
+		}
+		amt, err := parseAmount(toks[3], toks[4], false)
+		if err != nil {
+			p.fail("invalid_amount", "%v", err)
+			return
+		}
+		p.journal.Balances = append(p.journal.Balances, Balance{Line: p.line, Date: date, Account: toks[2], Amount: amt})
+	case "txn", "*", "!":
+		p.startBeancountTxn(date, toks[2:])
+	case "pad":
+		p.fail("unsupported", "pad directives are not supported; post the padding entry explicitly")
+	case "close", "commodity", "price", "note", "document", "event", "query", "custom":
+		p.skipIndented = true
+	default:
+		if len(kw) == 1 && kw[0] >= 'A' && kw[0] <= 'Z' {
+			// Custom transaction flags.
+			p.startBeancountTxn(date, toks[2:])
+			return
+		}
+		p.fail("syntax", "unknown directive %q", kw)
+	}
+}
+
+func (p *parser) startBeancountTxn(date time.Time, toks []string) {
+	t := Transaction{Line: p.line, Date: date, Metadata: map[string]string{}, Tags: append([]string(nil), p.tags...)}
+	var strs []string
+	for _, tok := range toks {
+		switch {
+		case isQuoted(tok):
+			strs = append(strs, unquote(tok))
+		case strings.HasPrefix(tok, "#"):
+			t.Tags = append(t.Tags, tok[1:])
+		case strings.HasPrefix(tok, "^"):
+			// Links group related transactions; they carry no value here.
+		default:
+			p.fail("syntax", "unexpected %q in transaction header", tok)
+			return
+		}
+	}
+	switch len(strs) {
+	case 0:
+	case 1:
+		t.Memo = strs[0]
+	case 2:
+		t.Payee, t.Memo = strs[0], strs[1]
+	default:
+		p.fail("syntax", "transaction header has more than a payee and a narration")
+		return
+	}
+	p.txn = &t
+	p.postingIndent = -1
+}
+
+func (p *parser) parseBeancountIndented(indent int, text string) {
+	if text[0] == ';' {
+		return
+	}
+	if key, val, ok := beancountMeta(text); ok {
+		if p.postingIndent >= 0 && indent > p.postingIndent {
+			ps := &p.txn.Postings[len(p.txn.Postings)-1]
+			ps.Metadata[key] = val
+		} else {
+			p.txn.Metadata[key] = val
+		}
+		return
+	}
+	toks, err := tokenize(stripBeancountComment(text))
+	if err != nil {
+		p.fail("syntax", "%v", err)
+		return
+	}
+	if len(toks) > 0 && (toks[0] == "*" || toks[0] == "!") {
+		toks = toks[1:]
+	}
+	if len(toks) == 0 {
+		p.fail("syntax", "posting needs an account")
+		return
+	}
+	ps := Posting{Line: p.line, Account: toks[0], Metadata: map[string]string{}}
+	if err := parsePostingAmount(&ps, toks[1:], false); err != nil {
+		p.fail("invalid_amount", "%v", err)
+		return
+	}
+	p.txn.Postings = append(p.txn.Postings, ps)
+	p.postingIndent = indent
+}
+
+// beancountMeta reads a "key: value" metadata line. Keys are decoded back
+// from the export encoding: dashes become dots.
+func beancountMeta(text string) (key, val string, ok bool) {
+	i := strings.Index(text, ":")
+	if i <= 0 || i == len(text)-1 || text[i+1] != ' ' {
+		return "", "", false
+	}
+	key = text[:i]
+	if key[0] < 'a' || key[0] > 'z' {
+		return "", "", false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "", "", false
+		}
+	}
+	val = strings.TrimSpace(stripBeancountComment(text[i+1:]))
+	if isQuoted(val) {
+		val = unquote(val)
+	}
+	return strings.ReplaceAll(key, "-", "."), val, true
+}
+
+// --- ledger and hledger ---
+
+func (p *parser) parseLedgerDirective(raw string) {
+	if raw[0] >= '0' && raw[0] <= '9' {
+		p.startLedgerTxn(raw)
+		return
+	}
+	word, rest, _ := strings.Cut(raw, " ")
+	rest = strings.TrimSpace(rest)
+	switch word {
+	case "account":
+		name, comment := splitComment(rest)
+		o := Open{Line: p.line, Account: strings.TrimSpace(name)}
+		if o.Account == "" {
+			p.fail("syntax", "account needs a name")
+			return
+		}
+		// hledger: account a:b  ; type: A, currencies: USD EUR
+		for k, v := range commentTags(comment, true) {
+			if k == "currencies" {
+				o.Currencies = append(o.Currencies, strings.Fields(v)...)
+			}
+		}
+		p.journal.Accounts = append(p.journal.Accounts, o)
+		p.open = &p.journal.Accounts[len(p.journal.Accounts)-1]
+	case "comment":
+		p.inComment = true
+	case "commodity", "P", "D", "Y", "year", "payee", "tag", "decimal-mark", "N":
+		p.skipIndented = true
+	case "include", "alias", "apply", "end", "bucket", "A", "~", "=":
+		p.fail("unsupported", "%s directives are not supported", word)
+		p.skipIndented = true
+	default:
+		p.fail("syntax", "unknown directive %q", word)
+		p.skipIndented = true
+	}
+}
+
+// parseAccountSubdirective reads the commodity check the exporter writes:
+// check commodity == "USD" or commodity == "EUR".
+func (p *parser) parseAccountSubdirective(text string) {
+	word, rest, _ := strings.Cut(text, " ")
+	switch word {
+	case "check", "assert":
+		for _, clause := range strings.Split(rest, " or ") {
+			_, v, ok := strings.Cut(clause, "==")
+			if !ok || !strings.Contains(clause, "commodity") {
+				p.fail("unsupported", "only commodity checks are supported on accounts")
+				return
+			}
+			p.open.Currencies = append(p.open.Currencies, unquote(strings.TrimSpace(v)))
+		}
+	case "alias":
+		p.fail("unsupported", "account aliases are not supported")
+	}
+}
+
+func (p *parser) startLedgerTxn(raw string) {
+	head, comment := splitComment(raw)
+	dateStr, rest, _ := strings.Cut(strings.TrimSpace(head), " ")
+	// A secondary date (2025-01-02=2025-01-05) is ignored.
+	dateStr, _, _ = strings.Cut(dateStr, "=")
+	date, err := parseDate(dateStr)
+	if err != nil {
+		p.fail("syntax", "invalid date %q", dateStr)
+		return
+	}
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "* ") || strings.HasPrefix(rest, "! ") || rest == "*" || rest == "!" {
+		rest = strings.TrimSpace(rest[1:])
+	}
+	t := Transaction{Line: p.line, Date: date, Metadata: map[string]string{}}
+	if strings.HasPrefix(rest, "(") {
+		if end := strings.Index(rest, ")"); end > 0 {
+			if code := strings.TrimSpace(rest[1:end]); code != "" {
+				t.Metadata["code"] = code
+			}
+			rest = strings.TrimSpace(rest[end+1:])
+		}
+	}
+	// hledger: payee | note
+	if payee, note, ok := strings.Cut(rest, "|"); ok {
+		t.Payee, t.Memo = strings.TrimSpace(payee), strings.TrimSpace(note)
+	} else {
+		t.Memo = rest
+	}
+	p.addLedgerComment(&t, nil, comment)
+	p.txn = &t
+}
+
+func (p *parser) parseLedgerIndented(text string) {
+	if text[0] == ';' || text[0] == '#' {
+		var md map[string]string
+		if n := len(p.txn.Postings); n > 0 {
+			md = p.txn.Postings[n-1].Metadata
+		}
+		p.addLedgerComment(p.txn, md, text[1:])
+		return
+	}
+	flagless := text
+	if strings.HasPrefix(text, "* ") || strings.HasPrefix(text, "! ") {
+		flagless = strings.TrimSpace(text[1:])
+	}
+	body, comment := splitComment(flagless)
+	// Two spaces or a tab end the account name.
+	name, amount := body, ""
+	end := strings.Index(body, "  ")
+	if i := strings.Index(body, "\t"); i >= 0 && (end < 0 || i < end) {
+		end = i
+	}
+	if end >= 0 {
+		name, amount = body[:end], body[end:]
+	}
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(name, "(") || strings.HasPrefix(name, "[") {
+		p.fail("unsupported", "virtual postings are not supported")
+		return
+	}
+	ps := Posting{Line: p.line, Account: name, Metadata: map[string]string{}}
+	amount = strings.TrimSpace(amount)
+	if strings.Contains(amount, "=") {
+		p.fail("unsupported", "posting balance assertions are not supported")
+		return
+	}
+	if err := parsePostingAmount(&ps, ledgerAmountTokens(amount), true); err != nil {
+		p.fail("invalid_amount", "%v", err)
+		return
+	}
+	p.txn.Postings = append(p.txn.Postings, ps)
+	n := len(p.txn.Postings)
+	p.addLedgerComment(p.txn, p.txn.Postings[n-1].Metadata, comment)
+}
+
+// addLedgerComment reads tags from a comment into md, or into the
+// transaction when md is nil.
+func (p *parser) addLedgerComment(t *Transaction, md map[string]string, comment string) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return
+	}
+	// :tag1:tag2:
+	if strings.HasPrefix(comment, ":") && strings.HasSuffix(comment, ":") && !strings.Contains(comment, " ") {
+		for _, tag := range strings.Split(strings.Trim(comment, ":"), ":") {
+			if tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		return
+	}
+	if md == nil {
+		md = t.Metadata
+	}
+	for k, v := range commentTags(comment, p.format == FormatHledger) {
+		md[k] = v
+	}
+}
+
+// commentTags reads "key: value" pairs from a comment. Ledger values run to the
+// end of the line; hledger separates tags with commas.
+func commentTags(comment string, commas bool) map[string]string {
+	out := map[string]string{}
+	parts := []string{comment}
+	if commas {
+		parts = strings.Split(comment, ",")
+	}
+	for _, part := range parts {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), ":")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" || strings.ContainsAny(k, " \t") {
+			continue
+		}
+		out[k] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+// ledgerAmountTokens splits "10.00 USD @@ 12.00 EUR", "$10.00" or "USD -5"
+// into number/commodity tokens with the price operator kept separate.
+func ledgerAmountTokens(s string) []string {
+	var out []string
+	for _, f := range strings.Fields(s) {
+		if f == "@" || f == "@@" {
+			out = append(out, f)
+			continue
+		}
+		sign := ""
+		if strings.HasPrefix(f, "-") {
+			sign, f = "-", f[1:]
+		}
+		if sym, num, ok := cutSymbol(f); ok {
+			out = append(out, sign+num, sym)
+			continue
+		}
+		out = append(out, sign+f)
+	}
+	return out
+}
+
+// cutSymbol splits a currency symbol from the front or back of a number.
+func cutSymbol(f string) (sym, num string, ok bool) {
+	for sym := range commoditySymbols {
+		if strings.HasPrefix(f, sym) && len(f) > len(sym) {
+			return sym, f[len(sym):], true
+		}
+		if strings.HasSuffix(f, sym) && len(f) > len(sym) {
+			return sym, f[:len(f)-len(sym)], true
+		}
+	}
+	return "", "", false
+}
+
+// --- shared ---
+
+// parsePostingAmount reads "NUMBER CURRENCY [@|@@ NUMBER CURRENCY]" and, for
+// beancount, "{COST}" / "{{TOTAL}}" lot costs. Ledger commodities may precede
+// the number.
+func parsePostingAmount(ps *Posting, toks []string, ledgerStyle bool) error {
+	if len(toks) == 0 {
+		return nil
+	}
+	amt, rest, err := takeAmount(toks, ledgerStyle)
+	if err != nil {
+		return err
+	}
+	ps.Amount = &amt
+	if len(rest) == 0 {
+		return nil
+	}
+	op := rest[0]
+	total := false
+	switch {
+	case op == "@@":
+		total = true
+	case op == "@":
+	case strings.HasPrefix(op, "{{"):
+		total = true
+		rest = braced(rest, "{{", "}}")
+		op = ""
+	case strings.HasPrefix(op, "{"):
+		rest = braced(rest, "{", "}")
+		op = ""
+	default:
+		return fmt.Errorf("unexpected %q after amount", op)
+	}
+	if op != "" {
+		rest = rest[1:]
+	}
+	price, rest, err := takeAmount(rest, ledgerStyle)
+	if err != nil {
+		return fmt.Errorf("price: %w", err)
+	}
+	if len(rest) > 0 && rest[0] != "@" && rest[0] != "@@" {
+		return fmt.Errorf("unexpected %q after price", rest[0])
+	}
+	price = price.Abs()
+	if !total {
+		d, err := price.Decimal().Mul(amt.Decimal().Abs())
+		if err != nil {
+			return err
+		}
+		if price, err = money.NewAmountFromDecimal(price.Curr(), d); err != nil {
+			return err
+		}
+	}
+	price = price.RoundToCurr()
+	ps.Cost = &price
+	return nil
+}
+
+// braced strips the braces of a beancount cost spec, which may be split over tokens.
+func braced(toks []string, open, close string) []string {
+	joined := strings.Join(toks, " ")
+	end := strings.Index(joined, close)
+	if end < 0 {
+		return []string{joined}
+	}
+	// Only the per-unit or total cost is used; a lot date or label is ignored.
+	out := strings.Fields(strings.ReplaceAll(joined[len(open):end], ",", " "))
+	if len(out) > 2 {
+		out = out[:2]
+	}
+	return append(out, strings.Fields(joined[end+len(close):])...)
+}
+
+func takeAmount(toks []string, ledgerStyle bool) (money.Amount, []string, error) {
+	if len(toks) < 2 {
+		return money.Amount{}, nil, errors.New("amount needs a number and a currency")
+	}
+	num, curr := toks[0], toks[1]
+	if ledgerStyle && !looksNumeric(num) {
+		num, curr = curr, num
+	}
+	amt, err := parseAmount(num, curr, ledgerStyle)
+	return amt, toks[2:], err
+}
+
+func parseAmount(num, curr string, ledgerStyle bool) (money.Amount, error) {
+	if ledgerStyle {
+		if code, ok := commoditySymbols[curr]; ok {
+			curr = code
+		}
+		curr = unquote(curr)
+	}
+	c, err := money.ParseCurr(curr)
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("unknown currency %q", curr)
+	}
+	d, err := decimal.Parse(ungroup(strings.TrimPrefix(num, "+")))
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("invalid number %q", num)
+	}
+	amt, err := money.NewAmountFromDecimal(c, d)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	if amt.Trim(c.Scale()).Scale() > c.Scale() {
+		return money.Amount{}, fmt.Errorf("%s has more decimals than %s allows", num, c.Code())
+	}
+	return amt.RoundToCurr(), nil
+}
+
+// ungroup drops thousands separators from num. Commas anywhere else, such as
+// a decimal comma, are left in place for decimal.Parse to reject.
+func ungroup(num string) string {
+	if !strings.Contains(num, ",") {
+		return num
+	}
+	intPart, frac, hasFrac := strings.Cut(num, ".")
+	sign := ""
+	if strings.HasPrefix(intPart, "-") {
+		sign, intPart = "-", intPart[1:]
+	}
+	groups := strings.Split(intPart, ",")
+	if len(groups[0]) == 0 || len(groups[0]) > 3 {
+		return num
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return num
+		}
+	}
+	out := sign + strings.Join(groups, "")
+	if hasFrac {
+		out += "." + frac
+	}
+	return out
+}
+
+func looksNumeric(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	return s != "" && (s[0] >= '0' && s[0] <= '9' || s[0] == '.')
+}
+
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006/01/02", "2006.01.02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", s)
+}
+
+// tokenize splits on whitespace, keeping quoted strings (with escapes) whole.
+func tokenize(s string) ([]string, error) {
+	var out []string
+	var b strings.Builder
+	inQuote, escaped := false, false
+	flush := func() {
+		if b.Len() > 0 {
+			out = append(out, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range s {
+		switch {
+		case inQuote:
+			b.WriteRune(r)
+			if escaped {
+				escaped = false
+			} else if r == '\\' {
+				escaped = true
+			} else if r == '"' {
+				inQuote = false
+				flush()
+			}
+		case r == '"':
+			flush()
+			inQuote = true
+			b.WriteRune(r)
+		case r == ' ' || r == '\t':
+			flush()
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated string")
+	}
+	flush()
+	return out, nil
+}
+
+func isQuoted(s string) bool { return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' }
+
+func unquote(s string) string {
+	if !isQuoted(s) {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(s)
+}
+
+// stripBeancountComment drops a ; comment outside quoted strings.
+func stripBeancountComment(s string) string {
+	inQuote, escaped := false, false
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\' && inQuote:
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote:
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// splitComment separates a ledger line from its ; comment.
+func splitComment(s string) (body, comment string) {
+	if i := strings.Index(s, ";"); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
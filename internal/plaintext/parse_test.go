@@ -0,0 +1,158 @@
+package plaintext
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParse_Beancount(t *testing.T) {
+	src := `option "operating_currency" "USD"
+
+2024-01-01 open Assets:Bank:Chase USD
+2024-01-01 open Expenses:Food:Groceries
+2024-01-01 open Equity:OpeningBalances USD,EUR
+
+pushtag #household
+2024-01-02 * "Whole Foods" "Weekly shop" #food ^receipt-1
+  ref-id: "A1"
+  Expenses:Food:Groceries   42.10 USD
+    aisle: "7"
+  Assets:Bank:Chase
+poptag #household
+
+2024-01-05 ! "Opening"
+  Assets:Bank:Chase    1,000.00 USD ; comment
+  Equity:OpeningBalances
+
+2024-02-01 balance Assets:Bank:Chase  957.90 USD
+`
+	j, lerrs, err := Parse(strings.NewReader(src), FormatBeancount)
+	if err != nil || len(lerrs) > 0 {
+		t.Fatalf("parse: %v %v", err, lerrs)
+	}
+	if len(j.Accounts) != 3 || strings.Join(j.Accounts[2].Currencies, ",") != "USD,EUR" || j.Accounts[1].Currencies != nil {
+		t.Fatalf("unexpected opens %+v", j.Accounts)
+	}
+	if len(j.Transactions) != 2 {
+		t.Fatalf("want 2 transactions, got %d", len(j.Transactions))
+	}
+	shop := j.Transactions[0]
+	if shop.Line != 8 || shop.Payee != "Whole Foods" || shop.Memo != "Weekly shop" {
+		t.Fatalf("unexpected header %+v", shop)
+	}
+	if strings.Join(shop.Tags, ",") != "household,food" || shop.Metadata["ref.id"] != "A1" {
+		t.Fatalf("unexpected tags or metadata %v %v", shop.Tags, shop.Metadata)
+	}
+	if p := shop.Postings[0]; p.Amount == nil || p.Amount.Decimal().String() != "42.10" || p.Metadata["aisle"] != "7" {
+		t.Fatalf("unexpected posting %+v", p)
+	}
+	if shop.Postings[1].Amount != nil || shop.Postings[1].Line != 12 {
+		t.Fatalf("second posting should be elided %+v", shop.Postings[1])
+	}
+	if j.Transactions[1].Postings[0].Amount.Decimal().String() != "1000.00" || len(j.Transactions[1].Tags) != 0 {
+		t.Fatalf("unexpected opening %+v", j.Transactions[1])
+	}
+	if len(j.Balances) != 1 || j.Balances[0].Amount.Decimal().String() != "957.90" || j.Balances[0].Line != 19 {
+		t.Fatalf("unexpected balances %+v", j.Balances)
+	}
+}
+
+func TestParse_LedgerSymbolsCostsAndComments(t *testing.T) {
+	src := `; a journal
+account assets:checking
+    check commodity == "USD"
+
+2024/03/01=2024/03/02 * (1042) Grocer
+    ; :food:weekly:
+    ; receipt: yes
+    expenses:food        $12.50
+    assets:checking
+
+2024-03-04 Trip | train tickets
+    expenses:travel      €20,00 @@ $22.00
+    assets:checking     -22.00 USD
+`
+	j, lerrs, err := Parse(strings.NewReader(src), FormatHledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// hledger would read 20,00 as a decimal comma; we only take ISO decimals.
+	if len(lerrs) != 1 || lerrs[0].Line != 12 || lerrs[0].Code != "invalid_amount" {
+		t.Fatalf("unexpected errors %v", lerrs)
+	}
+	src = strings.Replace(src, "€20,00", "20.00 EUR", 1)
+	j, lerrs, err = Parse(strings.NewReader(src), FormatHledger)
+	if err != nil || len(lerrs) > 0 {
+		t.Fatalf("parse: %v %v", err, lerrs)
+	}
+	if len(j.Accounts) != 1 || strings.Join(j.Accounts[0].Currencies, ",") != "USD" {
+		t.Fatalf("unexpected accounts %+v", j.Accounts)
+	}
+	g := j.Transactions[0]
+	if g.Date.Format("2006-01-02") != "2024-03-01" || g.Memo != "Grocer" || g.Metadata["code"] != "1042" || g.Metadata["receipt"] != "yes" {
+		t.Fatalf("unexpected header %+v", g)
+	}
+	if strings.Join(g.Tags, ",") != "food,weekly" || g.Postings[0].Amount.Curr().Code() != "USD" {
+		t.Fatalf("unexpected tags or amount %+v", g)
+	}
+	trip := j.Transactions[1]
+	if trip.Payee != "Trip" || trip.Memo != "train tickets" {
+		t.Fatalf("unexpected payee split %+v", trip)
+	}
+	if c := trip.Postings[0].Cost; c == nil || c.Decimal().String() != "22.00" || c.Curr().Code() != "USD" {
+		t.Fatalf("unexpected cost %+v", trip.Postings[0])
+	}
+}
+
+func TestParse_ReportsEveryBadLine(t *testing.T) {
+	src := `2024-01-01 open Assets:Cash USD
+2024-01-01 pad Assets:Cash Equity:OpeningBalances
+2024-01-02 * "Too few"
+  Assets:Cash  1.00 USD
+
+2024-01-03 * "Two elided"
+  Assets:Cash
+  Expenses:Misc
+
+2024-13-01 * "Bad date"
+  Assets:Cash  1.001 USD
+  Expenses:Misc
+include "other.beancount"
+`
+	_, lerrs, err := Parse(strings.NewReader(src), FormatBeancount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range lerrs {
+		got = append(got, strconv.Itoa(e.Line)+":"+e.Code)
+	}
+	want := "2:unsupported,3:syntax,6:syntax,10:syntax,13:unsupported"
+	if strings.Join(got, ",") != want {
+		t.Fatalf("errors = %s, want %s\n%v", strings.Join(got, ","), want, lerrs)
+	}
+}
+
+func TestParse_ReadsExport(t *testing.T) {
+	accounts, entries := fixture(t)
+	for _, f := range []Format{FormatLedger, FormatHledger, FormatBeancount} {
+		var buf bytes.Buffer
+		if err := Export(&buf, f, accounts, entries); err != nil {
+			t.Fatal(err)
+		}
+		j, lerrs, err := Parse(&buf, f)
+		if err != nil || len(lerrs) > 0 {
+			t.Fatalf("%s: %v %v\n%s", f, err, lerrs, buf.String())
+		}
+		if len(j.Accounts) != 4 || len(j.Transactions) != len(entries) {
+			t.Fatalf("%s: %d accounts, %d transactions", f, len(j.Accounts), len(j.Transactions))
+		}
+		for _, tx := range j.Transactions {
+			if tx.Metadata[metaCategory] != "general" {
+				t.Fatalf("%s: category not read back %+v", f, tx)
+			}
+		}
+	}
+}
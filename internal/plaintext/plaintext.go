@@ -0,0 +1,158 @@
+// Package plaintext reads and writes a user's ledger in the journal formats
+// of the plain-text accounting tools ledger-cli, hledger and beancount.
+package plaintext
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// Format names a plain-text journal dialect.
+type Format string
+
+const (
+	FormatLedger    Format = "ledger"
+	FormatHledger   Format = "hledger"
+	FormatBeancount Format = "beancount"
+)
+
+// ParseFormat validates a format name; empty means ledger.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case "":
+		return FormatLedger, nil
+	case FormatLedger, FormatHledger, FormatBeancount:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q; expected ledger, hledger or beancount", s)
+	}
+}
+
+// Extension returns the conventional file extension for f.
+func (f Format) Extension() string {
+	switch f {
+	case FormatHledger:
+		return "journal"
+	case FormatBeancount:
+		return "beancount"
+	default:
+		return "ledger"
+	}
+}
+
+// beancountRoots maps account types to beancount's fixed root accounts.
+var beancountRoots = map[ledger.AccountType]string{
+	ledger.AccountTypeAsset:     "Assets",
+	ledger.AccountTypeLiability: "Liabilities",
+	ledger.AccountTypeEquity:    "Equity",
+	ledger.AccountTypeRevenue:   "Income",
+	ledger.AccountTypeExpense:   "Expenses",
+}
+
+// hledgerTypes maps account types to hledger's account type codes.
+var hledgerTypes = map[ledger.AccountType]string{
+	ledger.AccountTypeAsset:     "A",
+	ledger.AccountTypeLiability: "L",
+	ledger.AccountTypeEquity:    "E",
+	ledger.AccountTypeRevenue:   "R",
+	ledger.AccountTypeExpense:   "X",
+}
+
+// AccountNames returns the journal account name of each account.
+// Ledger and hledger use Account.Path(); beancount needs capitalised
+// components under its fixed roots. Accounts of different currencies may
+// share a name, which the tools read as one multi-commodity account; two
+// accounts of the same currency that would share a name are told apart by a
+// short ID suffix.
+func AccountNames(f Format, accounts []ledger.Account) map[uuid.UUID]string {
+	sorted := append([]ledger.Account(nil), accounts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID.String() < sorted[j].ID.String() })
+	out := make(map[uuid.UUID]string, len(sorted))
+	taken := make(map[string]bool, len(sorted))
+	for _, a := range sorted {
+		name, sep := ledgerName(a), ":"
+		if f == FormatBeancount {
+			name, sep = beancountName(a), "-"
+		}
+		if key := name + " " + a.Currency; taken[key] {
+			name += sep + a.ID.String()[:8]
+		}
+		taken[name+" "+a.Currency] = true
+		out[a.ID] = name
+	}
+	return out
+}
+
+// ledgerName is the account path with whitespace runs collapsed, since two
+// spaces end an account name in ledger and hledger postings.
+func ledgerName(a ledger.Account) string {
+	return strings.Join(strings.Fields(a.Path()), " ")
+}
+
+func beancountName(a ledger.Account) string {
+	root, ok := beancountRoots[a.Type]
+	if !ok {
+		root = "Equity"
+	}
+	parts := strings.Split(a.Path(), ":")[1:]
+	out := []string{root}
+	for _, p := range parts {
+		out = append(out, beancountComponent(p))
+	}
+	return strings.Join(out, ":")
+}
+
+// beancountComponent turns a path component such as credit_card into
+// CreditCard: ASCII letters and digits only, each word capitalised.
+func beancountComponent(s string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "Other"
+	}
+	return b.String()
+}
+
+// beancountKey encodes a metadata key for beancount, whose keys must match
+// [a-z][a-zA-Z0-9_-]*: dots become dashes and other characters underscores.
+func beancountKey(k string) string {
+	var b strings.Builder
+	for i, r := range k {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9' && i > 0, r == '_' && i > 0:
+			b.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			if i == 0 {
+				r = unicode.ToLower(r)
+			}
+			b.WriteRune(r)
+		case r == '.' && i > 0:
+			b.WriteByte('-')
+		case i == 0:
+			b.WriteString("x_")
+			if r >= '0' && r <= '9' || r == '_' {
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
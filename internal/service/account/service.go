@@ -31,6 +31,8 @@ type Service interface {
 	Deactivate(ctx context.Context, userID, accountID uuid.UUID) error
 	Reactivate(ctx context.Context, userID, accountID uuid.UUID) (ledger.Account, error)
 	EnsureOpeningBalanceAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error)
+	EnsureRetainedEarningsAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error)
+	EnsureSuspenseAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error)
 	EnsureAccountsBatch(ctx context.Context, userID uuid.UUID, specs []ledger.Account) ([]ledger.Account, []ItemError, error)
 }
 
@@ -51,6 +53,24 @@ type ItemError struct {
 // EnsureOpeningBalanceAccount returns the OpeningBalances system account for the currency,
 // creating it if missing (idempotent per (user, currency)).
 func (s *service) EnsureOpeningBalanceAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error) {
+	return s.ensureSystemAccount(ctx, userID, currency, GroupOpeningBalances, "Opening Balances")
+}
+
+// EnsureRetainedEarningsAccount returns the RetainedEarnings system account for the currency,
+// creating it if missing (idempotent per (user, currency)). Year-end closing posts into it.
+func (s *service) EnsureRetainedEarningsAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error) {
+	return s.ensureSystemAccount(ctx, userID, currency, GroupRetainedEarnings, "Retained Earnings")
+}
+
+// EnsureSuspenseAccount returns the Suspense system account for the currency,
+// creating it if missing (idempotent per (user, currency)). Imported bank lines
+// without a known counter-account are parked in it until reclassified.
+func (s *service) EnsureSuspenseAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error) {
+	return s.ensureSystemAccount(ctx, userID, currency, GroupSuspense, "Suspense")
+}
+
+// ensureSystemAccount looks up a reserved equity account by group and currency, creating it if missing.
+func (s *service) ensureSystemAccount(ctx context.Context, userID uuid.UUID, currency, group, name string) (ledger.Account, error) {
 	if userID == uuid.Nil || currency == "" {
 		return ledger.Account{}, errs.ErrInvalid
 	}
@@ -59,8 +79,9 @@ func (s *service) EnsureOpeningBalanceAccount(ctx context.Context, userID uuid.U
 	if err != nil {
 		return ledger.Account{}, err
 	}
+	probe := ledger.Account{Type: ledger.AccountTypeEquity, Group: group, Vendor: "System"}
 	for _, a := range existing {
-		if strings.EqualFold(a.Currency, currency) && strings.EqualFold(normalizedPathString(a), "equity:opening_balances") {
+		if strings.EqualFold(a.Currency, currency) && strings.EqualFold(normalizedPathString(a), normalizedPathString(probe)) {
 			return a, nil
 		}
 	}
@@ -68,10 +89,10 @@ func (s *service) EnsureOpeningBalanceAccount(ctx context.Context, userID uuid.U
 	a := ledger.Account{
 		ID:       uuid.New(),
 		UserID:   userID,
-		Name:     "Opening Balances",
+		Name:     name,
 		Currency: currency,
 		Type:     ledger.AccountTypeEquity,
-		Group:    "opening_balances",
+		Group:    group,
 		Vendor:   "System",
 		System:   true,
 		Active:   true,
@@ -115,13 +136,13 @@ func (s *service) ValidateCreate(account ledger.Account) error {
 	default:
 		return errors.New("invalid account type")
 	}
-	// OpeningBalances/system rules
-	if account.System || strings.EqualFold(account.Group, "opening_balances") {
+	// OpeningBalances/RetainedEarnings/system rules
+	if account.System || isSystemGroup(account.Group) {
 		if account.Type != ledger.AccountTypeEquity {
-			return errors.New("opening balances must be equity type")
+			return errors.New("system accounts must be equity type")
 		}
-		if !strings.EqualFold(account.Group, "opening_balances") {
-			return errors.New("invalid system account group; expected opening_balances")
+		if !isSystemGroup(account.Group) {
+			return errors.New("invalid system account group; expected opening_balances, retained_earnings or suspense")
 		}
 	}
 	return nil
@@ -220,7 +241,7 @@ func (s *service) EnsureAccountsBatch(ctx context.Context, userID uuid.UUID, spe
 				Active:   true,
 				Metadata: a.Metadata,
 			}
-			if acc.Type == ledger.AccountTypeEquity && strings.EqualFold(acc.Group, "opening_balances") {
+			if acc.Type == ledger.AccountTypeEquity && isSystemGroup(acc.Group) {
 				acc.Vendor = "System"
 				acc.System = true
 			}
@@ -274,7 +295,7 @@ func (s *service) Create(ctx context.Context, account ledger.Account) (ledger.Ac
 		}
 	}
 	accNew := ledger.Account{ID: uuid.New(), UserID: account.UserID, Name: account.Name, Currency: account.Currency, Type: account.Type, Group: account.Group, Vendor: account.Vendor, System: account.System, Active: true, Metadata: account.Metadata}
-	if accNew.Type == ledger.AccountTypeEquity && strings.EqualFold(accNew.Group, "opening_balances") {
+	if accNew.Type == ledger.AccountTypeEquity && isSystemGroup(accNew.Group) {
 		accNew.Vendor = "System"
 		accNew.System = true
 	}
@@ -298,6 +319,17 @@ func normalizedPathString(a ledger.Account) string {
 	return strings.ToLower(string(a.Type)) + ":" + strings.ToLower(a.Group) + ":" + vendorSlug
 }
 
+// Reserved equity groups backing per-currency system accounts.
+const (
+	GroupOpeningBalances  = "opening_balances"
+	GroupRetainedEarnings = "retained_earnings"
+	GroupSuspense         = "suspense"
+)
+
+func isSystemGroup(group string) bool {
+	return strings.EqualFold(group, GroupOpeningBalances) || strings.EqualFold(group, GroupRetainedEarnings) || strings.EqualFold(group, GroupSuspense)
+}
+
 // ErrPathExists indicates an account with the same normalized path already exists for the user.
 var ErrPathExists = errors.New("account path already exists for user")
 
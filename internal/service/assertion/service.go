@@ -0,0 +1,281 @@
+// Package assertion stores balance assertions, in the style of plain-text
+// accounting tools: an account must hold an exact balance at the end of a day.
+// The journal service calls Check before every posting and reversal so writes
+// that would break a passing assertion, or move a failing one further off, are
+// rejected.
+package assertion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+type Repo interface {
+	ListAssertions(ctx context.Context, userID uuid.UUID) ([]ledger.BalanceAssertion, error)
+	GetAssertion(ctx context.Context, userID, assertionID uuid.UUID) (ledger.BalanceAssertion, error)
+}
+
+type Writer interface {
+	CreateAssertion(ctx context.Context, a ledger.BalanceAssertion) (ledger.BalanceAssertion, error)
+	DeleteAssertion(ctx context.Context, userID, assertionID uuid.UUID) error
+}
+
+// BalanceReader computes an account's net balance (debits minus credits) up to asOf.
+// journal.Service satisfies it.
+type BalanceReader interface {
+	AccountBalance(ctx context.Context, userID, accountID uuid.UUID, asOf *time.Time) (money.Amount, error)
+}
+
+// AccountReader resolves the asserted account.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+}
+
+// Result is an assertion evaluated against the ledger as it stands.
+type Result struct {
+	Assertion ledger.BalanceAssertion
+	// Actual is the account balance at the assertion's cutoff.
+	Actual money.Amount
+	Pass   bool
+}
+
+type Service interface {
+	Create(ctx context.Context, a ledger.BalanceAssertion) (Result, error)
+	// List evaluates the user's assertions, optionally for one account, ordered by date.
+	List(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) ([]Result, error)
+	Get(ctx context.Context, userID, assertionID uuid.UUID) (Result, error)
+	Delete(ctx context.Context, userID, assertionID uuid.UUID) error
+}
+
+type service struct {
+	repo     Repo
+	writer   Writer
+	balances BalanceReader
+	accounts AccountReader
+}
+
+func New(repo Repo, writer Writer, balances BalanceReader, accounts AccountReader) Service {
+	return &service{repo: repo, writer: writer, balances: balances, accounts: accounts}
+}
+
+// Create stores an assertion for a day; one assertion per account and day.
+// Assertions that fail when created are kept so they can be listed as failing.
+func (s *service) Create(ctx context.Context, a ledger.BalanceAssertion) (Result, error) {
+	if a.UserID == uuid.Nil || a.AccountID == uuid.Nil {
+		return Result{}, errs.ErrInvalid
+	}
+	if a.Date.IsZero() {
+		return Result{}, errors.New("date is required")
+	}
+	accs, err := s.accounts.FetchAccounts(ctx, a.UserID, []uuid.UUID{a.AccountID})
+	if err != nil {
+		return Result{}, err
+	}
+	acc, ok := accs[a.AccountID]
+	if !ok {
+		return Result{}, errs.ErrNotFound
+	}
+	if a.Amount.Curr().Code() != acc.Currency {
+		return Result{}, errs.ErrMixedCurrency
+	}
+	y, m, d := a.Date.UTC().Date()
+	a.Date = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	a.Note = strings.TrimSpace(a.Note)
+	existing, err := s.repo.ListAssertions(ctx, a.UserID)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, o := range existing {
+		if o.AccountID == a.AccountID && o.Date.Equal(a.Date) {
+			return Result{}, ErrDuplicate
+		}
+	}
+	a.ID = uuid.New()
+	created, err := s.writer.CreateAssertion(ctx, a)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.evaluate(ctx, created)
+}
+
+func (s *service) List(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) ([]Result, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	all, err := s.repo.ListAssertions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sortAssertions(all)
+	out := make([]Result, 0, len(all))
+	for _, a := range all {
+		if accountID != nil && a.AccountID != *accountID {
+			continue
+		}
+		r, err := s.evaluate(ctx, a)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, assertionID uuid.UUID) (Result, error) {
+	if userID == uuid.Nil || assertionID == uuid.Nil {
+		return Result{}, errs.ErrInvalid
+	}
+	a, err := s.repo.GetAssertion(ctx, userID, assertionID)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.evaluate(ctx, a)
+}
+
+func (s *service) Delete(ctx context.Context, userID, assertionID uuid.UUID) error {
+	if userID == uuid.Nil || assertionID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteAssertion(ctx, userID, assertionID)
+}
+
+func (s *service) evaluate(ctx context.Context, a ledger.BalanceAssertion) (Result, error) {
+	actual, err := balanceAt(ctx, s.balances, a)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Assertion: a, Actual: actual, Pass: sameMinor(actual, a.Amount)}, nil
+}
+
+// Change is a net debit-positive change per account, in account currency,
+// posted on Date.
+type Change struct {
+	Date  time.Time
+	Delta map[uuid.UUID]money.Amount
+}
+
+// Violation is the error Check returns for an assertion a change would break;
+// it wraps errs.ErrAssertionFailed.
+type Violation struct {
+	Assertion ledger.BalanceAssertion
+	// Before and After are the balances at the assertion's cutoff.
+	Before, After money.Amount
+}
+
+func (v *Violation) Error() string {
+	a := v.Assertion
+	if sameMinor(v.Before, a.Amount) {
+		return fmt.Sprintf("%s: account %s must be %s at the end of %s, the change would make it %s",
+			errs.ErrAssertionFailed, a.AccountID, a.Amount.Decimal(), a.Date.Format("2006-01-02"), v.After.Decimal())
+	}
+	return fmt.Sprintf("%s: account %s must be %s at the end of %s and is %s, the change would move it further to %s",
+		errs.ErrAssertionFailed, a.AccountID, a.Amount.Decimal(), a.Date.Format("2006-01-02"), v.Before.Decimal(), v.After.Decimal())
+}
+
+func (v *Violation) Unwrap() error { return errs.ErrAssertionFailed }
+
+// Check returns a *Violation when applying delta (net debit-positive change per
+// account, in account currency) on date would break an assertion that
+// currently passes, or move one that already fails further from its amount.
+// Assertions dated before date are unaffected; changes that bring a failing
+// assertion closer to its amount are allowed, so it can be corrected in steps.
+func Check(ctx context.Context, repo Repo, balances BalanceReader, userID uuid.UUID, date time.Time, delta map[uuid.UUID]money.Amount) error {
+	return CheckChanges(ctx, repo, balances, userID, []Change{{Date: date, Delta: delta}})
+}
+
+// CheckChanges is Check for changes applied together, such as a batch of
+// entries: each assertion is checked once against the sum of the changes
+// dated on or before its cutoff.
+func CheckChanges(ctx context.Context, repo Repo, balances BalanceReader, userID uuid.UUID, changes []Change) error {
+	touched := false
+	for _, c := range changes {
+		if len(c.Delta) > 0 {
+			touched = true
+			break
+		}
+	}
+	if !touched {
+		return nil
+	}
+	all, err := repo.ListAssertions(ctx, userID)
+	if err != nil {
+		return err
+	}
+	sortAssertions(all)
+	for _, a := range all {
+		var change int64
+		for _, c := range changes {
+			d, ok := c.Delta[a.AccountID]
+			if !ok || c.Date.After(a.Cutoff()) {
+				continue
+			}
+			minor, _ := d.MinorUnits()
+			change += minor
+		}
+		if change == 0 {
+			continue
+		}
+		before, err := balanceAt(ctx, balances, a)
+		if err != nil {
+			return err
+		}
+		beforeMinor, _ := before.MinorUnits()
+		want, _ := a.Amount.MinorUnits()
+		if off := absMinor(beforeMinor - want); off != 0 && absMinor(beforeMinor+change-want) < off {
+			continue
+		}
+		after, err := money.NewAmountFromMinorUnits(a.Amount.Curr().Code(), beforeMinor+change)
+		if err != nil {
+			return err
+		}
+		return &Violation{Assertion: a, Before: before, After: after}
+	}
+	return nil
+}
+
+func absMinor(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// balanceAt returns the account balance at a's cutoff in the assertion currency.
+func balanceAt(ctx context.Context, balances BalanceReader, a ledger.BalanceAssertion) (money.Amount, error) {
+	cutoff := a.Cutoff()
+	bal, err := balances.AccountBalance(ctx, a.UserID, a.AccountID, &cutoff)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	// Accounts without postings report a zero balance in a default currency.
+	minor, _ := bal.MinorUnits()
+	return money.NewAmountFromMinorUnits(a.Amount.Curr().Code(), minor)
+}
+
+func sameMinor(a, b money.Amount) bool {
+	am, _ := a.MinorUnits()
+	bm, _ := b.MinorUnits()
+	return am == bm
+}
+
+func sortAssertions(as []ledger.BalanceAssertion) {
+	sort.Slice(as, func(i, j int) bool {
+		if !as[i].Date.Equal(as[j].Date) {
+			return as[i].Date.Before(as[j].Date)
+		}
+		return as[i].ID.String() < as[j].ID.String()
+	})
+}
+
+// ErrDuplicate rejects a second assertion for the same account and day.
+var ErrDuplicate = errors.New("an assertion already exists for this account and date")
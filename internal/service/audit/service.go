@@ -0,0 +1,83 @@
+// Package audit keeps an append-only trail of writes to entries and accounts:
+// who made each one, in which request, and the entity before and after.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+type Repo interface {
+	ListAuditRecords(ctx context.Context, f Filter) ([]ledger.AuditRecord, error)
+}
+
+// Writer appends records; there is deliberately no way to change or remove one.
+type Writer interface {
+	AppendAuditRecord(ctx context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error)
+}
+
+// Filter selects a user's audit records in Seq order. Zero fields match everything.
+type Filter struct {
+	UserID   uuid.UUID
+	Entity   ledger.AuditEntity
+	EntityID uuid.UUID
+	Actor    string
+	// From and To bound CreatedAt, inclusive.
+	From *time.Time
+	To   *time.Time
+	// AfterSeq skips records up to and including this sequence number.
+	AfterSeq int64
+	// Limit caps the number of records returned; 0 returns all.
+	Limit int
+}
+
+type operationKey struct{}
+
+// WithOperation labels the writes made with ctx as op, such as a reclassify
+// that reverses and reposts an entry; unlabelled writes are recorded as the
+// plain create or update they are.
+func WithOperation(ctx context.Context, op ledger.AuditOperation) context.Context {
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// OperationFrom returns the operation ctx was labelled with, or def.
+func OperationFrom(ctx context.Context, def ledger.AuditOperation) ledger.AuditOperation {
+	if op, ok := ctx.Value(operationKey{}).(ledger.AuditOperation); ok {
+		return op
+	}
+	return def
+}
+
+type Service interface {
+	// Record appends rec, stamping its ID and time.
+	Record(ctx context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error)
+	List(ctx context.Context, f Filter) ([]ledger.AuditRecord, error)
+}
+
+type service struct {
+	repo   Repo
+	writer Writer
+}
+
+func New(repo Repo, writer Writer) Service { return &service{repo: repo, writer: writer} }
+
+func (s *service) Record(ctx context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error) {
+	if rec.UserID == uuid.Nil || rec.EntityID == uuid.Nil || rec.Operation == "" || rec.Entity == "" {
+		return ledger.AuditRecord{}, errs.ErrInvalid
+	}
+	rec.ID = uuid.New()
+	rec.CreatedAt = time.Now().UTC()
+	return s.writer.AppendAuditRecord(ctx, rec)
+}
+
+func (s *service) List(ctx context.Context, f Filter) ([]ledger.AuditRecord, error) {
+	if f.UserID == uuid.Nil || f.Limit < 0 {
+		return nil, errs.ErrInvalid
+	}
+	return s.repo.ListAuditRecords(ctx, f)
+}
@@ -0,0 +1,257 @@
+// Package budget stores per-user budgets for revenue and expense account paths and
+// compares them with posted activity.
+package budget
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/yearend"
+)
+
+type Repo interface {
+	ListBudgets(ctx context.Context, userID uuid.UUID) ([]ledger.Budget, error)
+	GetBudget(ctx context.Context, userID, budgetID uuid.UUID) (ledger.Budget, error)
+}
+
+type Writer interface {
+	CreateBudget(ctx context.Context, b ledger.Budget) (ledger.Budget, error)
+	UpdateBudget(ctx context.Context, b ledger.Budget) (ledger.Budget, error)
+	DeleteBudget(ctx context.Context, userID, budgetID uuid.UUID) error
+}
+
+// AccountReader lists a user's accounts for path matching.
+type AccountReader interface {
+	ListAccounts(ctx context.Context, userID uuid.UUID) ([]ledger.Account, error)
+}
+
+// Line compares one budget with actual activity. Actual is signed by the normal side
+// of the budget's type (debits for expense, credits for revenue). OverBudget is set
+// when actual exceeds the budget, i.e. overspending or beating a revenue target.
+type Line struct {
+	Budget      ledger.Budget
+	Actual      int64
+	Remaining   int64
+	PercentUsed float64
+	OverBudget  bool
+}
+
+type Service interface {
+	Create(ctx context.Context, b ledger.Budget) (ledger.Budget, error)
+	List(ctx context.Context, userID uuid.UUID) ([]ledger.Budget, error)
+	Get(ctx context.Context, userID, budgetID uuid.UUID) (ledger.Budget, error)
+	// UpdateAmount changes the budgeted amount; the currency cannot change.
+	UpdateAmount(ctx context.Context, userID, budgetID uuid.UUID, minor int64) (ledger.Budget, error)
+	Delete(ctx context.Context, userID, budgetID uuid.UUID) error
+	// VsActual compares every budget whose period contains asOf with activity from the
+	// start of its period through asOf.
+	VsActual(ctx context.Context, userID uuid.UUID, asOf time.Time) ([]Line, error)
+}
+
+type service struct {
+	repo     Repo
+	writer   Writer
+	journal  journal.Service
+	accounts AccountReader
+}
+
+func New(repo Repo, writer Writer, j journal.Service, accounts AccountReader) Service {
+	return &service{repo: repo, writer: writer, journal: j, accounts: accounts}
+}
+
+// Create validates and stores a budget; Start is normalized to the period start.
+func (s *service) Create(ctx context.Context, b ledger.Budget) (ledger.Budget, error) {
+	if b.UserID == uuid.Nil {
+		return ledger.Budget{}, errs.ErrInvalid
+	}
+	b.Path = strings.ToLower(strings.TrimSpace(b.Path))
+	if err := validatePath(b.Path); err != nil {
+		return ledger.Budget{}, err
+	}
+	switch b.Period {
+	case ledger.BudgetPeriodMonthly, ledger.BudgetPeriodYearly:
+	default:
+		return ledger.Budget{}, errors.New("invalid period; expected monthly or yearly")
+	}
+	if b.Start.IsZero() {
+		return ledger.Budget{}, errors.New("start is required")
+	}
+	b.Start = PeriodStart(b.Period, b.Start)
+	if b.Amount.IsNeg() {
+		return ledger.Budget{}, errors.New("amount must be >= 0")
+	}
+	existing, err := s.repo.ListBudgets(ctx, b.UserID)
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	for _, o := range existing {
+		if o.Path == b.Path && o.Period == b.Period && o.Start.Equal(b.Start) && o.Amount.Curr() == b.Amount.Curr() {
+			return ledger.Budget{}, ErrDuplicate
+		}
+	}
+	b.ID = uuid.New()
+	return s.writer.CreateBudget(ctx, b)
+}
+
+// List returns budgets ordered by start, period and path.
+func (s *service) List(ctx context.Context, userID uuid.UUID) ([]ledger.Budget, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	out, err := s.repo.ListBudgets(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sortBudgets(out)
+	return out, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, budgetID uuid.UUID) (ledger.Budget, error) {
+	if userID == uuid.Nil || budgetID == uuid.Nil {
+		return ledger.Budget{}, errs.ErrInvalid
+	}
+	return s.repo.GetBudget(ctx, userID, budgetID)
+}
+
+func (s *service) UpdateAmount(ctx context.Context, userID, budgetID uuid.UUID, minor int64) (ledger.Budget, error) {
+	if minor < 0 {
+		return ledger.Budget{}, errors.New("amount must be >= 0")
+	}
+	b, err := s.Get(ctx, userID, budgetID)
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	amt, err := money.NewAmountFromMinorUnits(b.Amount.Curr().Code(), minor)
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	b.Amount = amt
+	return s.writer.UpdateBudget(ctx, b)
+}
+
+func (s *service) Delete(ctx context.Context, userID, budgetID uuid.UUID) error {
+	if userID == uuid.Nil || budgetID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteBudget(ctx, userID, budgetID)
+}
+
+func (s *service) VsActual(ctx context.Context, userID uuid.UUID, asOf time.Time) ([]Line, error) {
+	budgets, err := s.List(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	asOf = asOf.UTC()
+	active := budgets[:0]
+	for _, b := range budgets {
+		if !asOf.Before(b.Start) && asOf.Before(b.End()) {
+			active = append(active, b)
+		}
+	}
+	out := make([]Line, 0, len(active))
+	if len(active) == 0 {
+		return out, nil
+	}
+	accs, err := s.accounts.ListAccounts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	// One query covers every active budget, from the earliest start.
+	from := active[0].Start
+	for _, b := range active[1:] {
+		if b.Start.Before(from) {
+			from = b.Start
+		}
+	}
+	entries, err := s.journal.QueryEntries(ctx, journal.EntryFilter{UserID: userID, From: &from, To: &asOf})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range active {
+		covered := map[uuid.UUID]bool{}
+		for _, a := range accs {
+			if b.Covers(a) {
+				covered[a.ID] = true
+			}
+		}
+		sign := int64(1)
+		if strings.HasPrefix(b.Path, string(ledger.AccountTypeRevenue)) {
+			sign = -1
+		}
+		var actual int64
+		for _, e := range entries {
+			// Closing entries move earnings to retained earnings, and the reversals
+			// that reopen a year move them back; neither is activity.
+			if e.Date.Before(b.Start) || e.Metadata[yearend.MetaKind] == yearend.KindYearEnd {
+				continue
+			}
+			for _, ln := range e.Lines.ByID {
+				if !covered[ln.AccountID] {
+					continue
+				}
+				units, _ := ln.Amount.MinorUnits()
+				if ln.Side == ledger.SideCredit {
+					units = -units
+				}
+				actual += sign * units
+			}
+		}
+		budgeted, _ := b.Amount.MinorUnits()
+		l := Line{Budget: b, Actual: actual, Remaining: budgeted - actual, OverBudget: actual > budgeted}
+		if budgeted != 0 {
+			l.PercentUsed = float64(int64(float64(actual)*10000/float64(budgeted))) / 100
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+// PeriodStart truncates t to the start of its month or year (UTC).
+func PeriodStart(p ledger.BudgetPeriod, t time.Time) time.Time {
+	t = t.UTC()
+	if p == ledger.BudgetPeriodYearly {
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// validatePath accepts expense or revenue paths: type, type:group or type:group:vendor.
+func validatePath(p string) error {
+	parts := strings.Split(p, ":")
+	if len(parts) == 0 || len(parts) > 3 {
+		return errors.New("invalid path; expected type[:group[:vendor]]")
+	}
+	if parts[0] != string(ledger.AccountTypeExpense) && parts[0] != string(ledger.AccountTypeRevenue) {
+		return errors.New("budgets apply to expense or revenue paths")
+	}
+	for _, seg := range parts[1:] {
+		if seg == "" {
+			return errors.New("invalid path; empty segment")
+		}
+	}
+	return nil
+}
+
+func sortBudgets(b []ledger.Budget) {
+	sort.Slice(b, func(i, j int) bool {
+		if !b[i].Start.Equal(b[j].Start) {
+			return b[i].Start.Before(b[j].Start)
+		}
+		if b[i].Period != b[j].Period {
+			return b[i].Period < b[j].Period
+		}
+		return b[i].Path < b[j].Path
+	})
+}
+
+// ErrDuplicate indicates a budget already exists for the (path, period, start, currency).
+var ErrDuplicate = errors.New("budget already exists for path, period and currency")
@@ -0,0 +1,61 @@
+package fx
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ParseCSV reads rates from CSV with a header row naming the columns date, base,
+// quote and rate (any order, case-insensitive). Example:
+//
+//	date,base,quote,rate
+//	2025-01-31,GBP,USD,1.2391
+func ParseCSV(r io.Reader) ([]ledger.FXRate, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("csv is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	for _, name := range []string{"date", "base", "quote", "rate"} {
+		if _, ok := col[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	var out []ledger.FXRate
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		date, err := ParseDate(rec[col["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rate, err := money.ParseExchRate(strings.ToUpper(strings.TrimSpace(rec[col["base"]])), strings.ToUpper(strings.TrimSpace(rec[col["quote"]])), strings.TrimSpace(rec[col["rate"]]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate", line)
+		}
+		out = append(out, ledger.FXRate{Date: date, Rate: rate})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("csv has no rates")
+	}
+	return out, nil
+}
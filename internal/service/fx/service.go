@@ -0,0 +1,178 @@
+// Package fx stores per-user exchange rates by currency pair and date and resolves
+// the rate effective on a given day. Rates are loaded through the API or a CSV upload.
+package fx
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+type Repo interface {
+	// ListRates returns a user's rates; empty base/quote match any currency.
+	ListRates(ctx context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error)
+}
+
+type Writer interface {
+	// UpsertRates stores rates, replacing any existing rate for the same (user, pair, date).
+	UpsertRates(ctx context.Context, rates []ledger.FXRate) ([]ledger.FXRate, error)
+}
+
+type Service interface {
+	Upsert(ctx context.Context, userID uuid.UUID, rates []ledger.FXRate) ([]ledger.FXRate, error)
+	List(ctx context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error)
+	// RateAt returns the latest rate for base->quote dated on or before at. Identity
+	// pairs resolve to 1 and a stored quote->base rate is inverted when needed.
+	RateAt(ctx context.Context, userID uuid.UUID, base, quote string, at time.Time) (money.ExchangeRate, error)
+	// Table loads every stored rate between base and quote once, for callers
+	// that look up the pair at many dates.
+	Table(ctx context.Context, userID uuid.UUID, base, quote string) (Table, error)
+}
+
+type service struct {
+	repo   Repo
+	writer Writer
+}
+
+func New(repo Repo, writer Writer) Service { return &service{repo: repo, writer: writer} }
+
+// ErrNoRate indicates no rate is available for a pair on or before the requested date.
+var ErrNoRate = errors.New("fx_rate_unavailable")
+
+// Upsert validates and stores rates. Dates are truncated to the UTC day.
+func (s *service) Upsert(ctx context.Context, userID uuid.UUID, rates []ledger.FXRate) ([]ledger.FXRate, error) {
+	if userID == uuid.Nil || len(rates) == 0 {
+		return nil, errs.ErrInvalid
+	}
+	out := make([]ledger.FXRate, 0, len(rates))
+	for _, r := range rates {
+		if r.Date.IsZero() {
+			return nil, errors.New("date is required")
+		}
+		if r.Rate.Base() == r.Rate.Quote() {
+			return nil, errors.New("base and quote must differ")
+		}
+		if !r.Rate.IsPos() {
+			return nil, errors.New("rate must be positive")
+		}
+		r.ID = uuid.New()
+		r.UserID = userID
+		r.Date = Day(r.Date)
+		out = append(out, r)
+	}
+	return s.writer.UpsertRates(ctx, out)
+}
+
+// List returns rates ordered by pair then date.
+func (s *service) List(ctx context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	out, err := s.repo.ListRates(ctx, userID, strings.ToUpper(base), strings.ToUpper(quote))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Base() != out[j].Base() {
+			return out[i].Base() < out[j].Base()
+		}
+		if out[i].Quote() != out[j].Quote() {
+			return out[i].Quote() < out[j].Quote()
+		}
+		return out[i].Date.Before(out[j].Date)
+	})
+	return out, nil
+}
+
+func (s *service) RateAt(ctx context.Context, userID uuid.UUID, base, quote string, at time.Time) (money.ExchangeRate, error) {
+	t, err := s.Table(ctx, userID, base, quote)
+	if err != nil {
+		return money.ExchangeRate{}, err
+	}
+	return t.At(at)
+}
+
+func (s *service) Table(ctx context.Context, userID uuid.UUID, base, quote string) (Table, error) {
+	t := Table{base: strings.ToUpper(base), quote: strings.ToUpper(quote)}
+	if t.base == t.quote {
+		return t, nil
+	}
+	var err error
+	if t.direct, err = s.sortedRates(ctx, userID, t.base, t.quote); err != nil {
+		return Table{}, err
+	}
+	if t.inverse, err = s.sortedRates(ctx, userID, t.quote, t.base); err != nil {
+		return Table{}, err
+	}
+	return t, nil
+}
+
+// sortedRates returns the stored base->quote rates in date order.
+func (s *service) sortedRates(ctx context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error) {
+	rates, err := s.repo.ListRates(ctx, userID, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	return rates, nil
+}
+
+// Table holds the stored rates of one currency pair in both directions.
+type Table struct {
+	base, quote string
+	// direct holds base->quote rates and inverse quote->base ones, by date.
+	direct, inverse []ledger.FXRate
+}
+
+// At returns the latest rate dated on or before at, like RateAt: identity
+// pairs resolve to 1 and a quote->base rate is inverted when there is no
+// base->quote one.
+func (t Table) At(at time.Time) (money.ExchangeRate, error) {
+	if t.base == t.quote {
+		return money.ParseExchRate(t.base, t.quote, "1")
+	}
+	day := Day(at)
+	if r, ok := latest(t.direct, day); ok {
+		return r, nil
+	}
+	inv, ok := latest(t.inverse, day)
+	if !ok {
+		return money.ExchangeRate{}, ErrNoRate
+	}
+	return inv.Inv()
+}
+
+// latest returns the most recent of the date-ordered rates dated on or before day.
+func latest(rates []ledger.FXRate, day time.Time) (money.ExchangeRate, bool) {
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(day) })
+	if i == 0 {
+		return money.ExchangeRate{}, false
+	}
+	return rates[i-1].Rate, true
+}
+
+// Day truncates t to midnight UTC; rates are effective per calendar day.
+func Day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// ParseDate accepts YYYY-MM-DD or an RFC3339 timestamp.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, errors.New("invalid date; expected YYYY-MM-DD")
+	}
+	return Day(t), nil
+}
@@ -0,0 +1,320 @@
+package imports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// dateTokens converts DateFormat tokens into a Go time layout. Longer tokens
+// come first so YYYY is not read as two YY.
+var dateTokens = strings.NewReplacer("YYYY", "2006", "YY", "06", "MMM", "Jan", "MM", "01", "DD", "02")
+
+// DateLayout returns the Go time layout for a DateFormat such as DD/MM/YYYY.
+func DateLayout(format string) (string, error) {
+	if format == "" {
+		format = "YYYY-MM-DD"
+	}
+	layout := dateTokens.Replace(format)
+	if !strings.Contains(layout, "06") || !strings.Contains(layout, "02") || (!strings.Contains(layout, "01") && !strings.Contains(layout, "Jan")) {
+		return "", fmt.Errorf("invalid date_format %q: needs year, month and day tokens (YYYY, MM, DD)", format)
+	}
+	return layout, nil
+}
+
+// ValidateMapping normalizes m and checks that it can be applied to a file.
+func ValidateMapping(m *ledger.CSVMapping) error {
+	if m.Delimiter == "" {
+		m.Delimiter = ","
+	}
+	if utf8.RuneCountInString(m.Delimiter) != 1 {
+		return errors.New("delimiter must be a single character")
+	}
+	if m.SkipRows < 0 {
+		return errors.New("skip_rows must not be negative")
+	}
+	if _, err := DateLayout(m.DateFormat); err != nil {
+		return err
+	}
+	if m.DateColumn == "" {
+		return errors.New("date_column is required")
+	}
+	split := m.InflowColumn != "" || m.OutflowColumn != ""
+	switch {
+	case m.AmountColumn != "" && split:
+		return errors.New("use either amount_column or inflow_column/outflow_column")
+	case m.AmountColumn != "":
+		switch m.Sign {
+		case "":
+			m.Sign = ledger.AmountSignInflowPositive
+		case ledger.AmountSignInflowPositive, ledger.AmountSignOutflowPositive:
+		default:
+			return errors.New("sign must be inflow_positive or outflow_positive")
+		}
+	case m.InflowColumn == "" || m.OutflowColumn == "":
+		return errors.New("amount_column or both inflow_column and outflow_column are required")
+	default:
+		m.Sign = ""
+	}
+	cols := append([]string{m.DateColumn, m.AmountColumn, m.InflowColumn, m.OutflowColumn, m.ReferenceColumn}, m.MemoColumns...)
+	for _, c := range cols {
+		if c == "" {
+			continue
+		}
+		if n, err := strconv.Atoi(c); err == nil {
+			if n < 1 {
+				return fmt.Errorf("invalid column %q: numbers start at 1", c)
+			}
+		} else if !m.HasHeader {
+			return fmt.Errorf("column %q is a name but has_header is false", c)
+		}
+	}
+	return nil
+}
+
+// ParseCSV reads bank transactions from r using the mapping; amounts are parsed
+// in currency. Rows that cannot be read are reported per row; the error return
+// is reserved for problems with the file as a whole. Blank rows are ignored.
+func ParseCSV(r io.Reader, m ledger.CSVMapping, currency string) ([]Txn, []RowError, error) {
+	if err := ValidateMapping(&m); err != nil {
+		return nil, nil, err
+	}
+	layout, _ := DateLayout(m.DateFormat)
+	cr := csv.NewReader(r)
+	cr.Comma, _ = utf8.DecodeRuneInString(m.Delimiter)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	cr.TrimLeadingSpace = true
+
+	line := 0
+	read := func() ([]string, error) {
+		rec, err := cr.Read()
+		if err == nil {
+			line, _ = cr.FieldPos(0)
+		}
+		return rec, err
+	}
+	for i := 0; i < m.SkipRows; i++ {
+		if _, err := read(); err == io.EOF {
+			return nil, nil, errors.New("csv is empty")
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+	var header map[string]int
+	if m.HasHeader {
+		rec, err := read()
+		if err == io.EOF {
+			return nil, nil, errors.New("csv is empty")
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		header = make(map[string]int, len(rec))
+		for i, h := range rec {
+			header[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+		}
+	}
+	col := func(ref string) (int, error) {
+		if ref == "" {
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(ref); err == nil {
+			return n - 1, nil
+		}
+		i, ok := header[strings.ToLower(strings.TrimSpace(ref))]
+		if !ok {
+			return 0, fmt.Errorf("missing column %q", ref)
+		}
+		return i, nil
+	}
+	var idx struct{ date, amount, inflow, outflow, ref int }
+	var memo []int
+	for _, c := range []struct {
+		ref string
+		dst *int
+	}{{m.DateColumn, &idx.date}, {m.AmountColumn, &idx.amount}, {m.InflowColumn, &idx.inflow}, {m.OutflowColumn, &idx.outflow}, {m.ReferenceColumn, &idx.ref}} {
+		i, err := col(c.ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		*c.dst = i
+	}
+	for _, ref := range m.MemoColumns {
+		i, err := col(ref)
+		if err != nil {
+			return nil, nil, err
+		}
+		memo = append(memo, i)
+	}
+
+	var out []Txn
+	var rowErrs []RowError
+	for {
+		rec, err := read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if blank(rec) {
+			continue
+		}
+		field := func(i int) string {
+			if i < 0 || i >= len(rec) {
+				return ""
+			}
+			return strings.TrimSpace(rec[i])
+		}
+		fail := func(code, msg string) {
+			rowErrs = append(rowErrs, RowError{Row: line, Code: code, Err: errors.New(msg)})
+		}
+		date, err := time.ParseInLocation(layout, field(idx.date), time.UTC)
+		if err != nil {
+			fail("invalid_date", fmt.Sprintf("invalid date %q", field(idx.date)))
+			continue
+		}
+		var amt money.Amount
+		if idx.amount >= 0 {
+			amt, err = parseAmount(currency, field(idx.amount), m.DecimalComma)
+			if err == nil && m.Sign == ledger.AmountSignOutflowPositive {
+				amt = amt.Neg()
+			}
+		} else {
+			amt, err = splitAmount(currency, field(idx.inflow), field(idx.outflow), m.DecimalComma)
+		}
+		if err != nil {
+			fail("invalid_amount", err.Error())
+			continue
+		}
+		if amt.IsZero() {
+			fail("invalid_amount", "amount is zero")
+			continue
+		}
+		parts := make([]string, 0, len(memo))
+		for _, i := range memo {
+			if v := field(i); v != "" {
+				parts = append(parts, v)
+			}
+		}
+		out = append(out, Txn{Row: line, Date: date, Amount: amt, Memo: strings.Join(parts, " "), SourceID: field(idx.ref)})
+	}
+	if len(out) == 0 && len(rowErrs) == 0 {
+		return nil, nil, errors.New("csv has no transactions")
+	}
+	return out, rowErrs, nil
+}
+
+// splitAmount combines unsigned money-in and money-out columns into a signed amount.
+func splitAmount(currency, in, out string, decimalComma bool) (money.Amount, error) {
+	total, err := money.NewAmountFromMinorUnits(currency, 0)
+	if err != nil {
+		return money.Amount{}, err
+	}
+	if in != "" {
+		a, err := parseAmount(currency, in, decimalComma)
+		if err != nil {
+			return money.Amount{}, err
+		}
+		if total, err = total.Add(a.Abs()); err != nil {
+			return money.Amount{}, err
+		}
+	}
+	if out != "" {
+		a, err := parseAmount(currency, out, decimalComma)
+		if err != nil {
+			return money.Amount{}, err
+		}
+		if total, err = total.Sub(a.Abs()); err != nil {
+			return money.Amount{}, err
+		}
+	}
+	return total, nil
+}
+
+// parseAmount reads bank-formatted numbers: currency symbols, three-letter
+// currency codes and thousands separators are ignored, and a leading or
+// trailing minus, surrounding parentheses or a DR marker make the amount
+// negative (CR marks a positive one). Any other letters are rejected, so a
+// marker the parser does not know never flips an amount silently.
+func parseAmount(currency, s string, decimalComma bool) (money.Amount, error) {
+	raw := s
+	thousands, point := ',', '.'
+	if decimalComma {
+		thousands, point = '.', ','
+	}
+	neg := false
+	var b, word strings.Builder
+	endWord := func() bool {
+		w := strings.ToUpper(word.String())
+		word.Reset()
+		switch {
+		case w == "", w == "CR", len(w) == 3:
+		case w == "DR":
+			neg = true
+		default:
+			return false
+		}
+		return true
+	}
+	for _, r := range s {
+		if r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' {
+			word.WriteRune(r)
+			continue
+		}
+		if !endWord() {
+			return money.Amount{}, fmt.Errorf("invalid amount %q", raw)
+		}
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == point:
+			b.WriteByte('.')
+		case r == '-' || r == '(' || r == ')':
+			neg = true
+		case r == thousands, r == '+', r == ' ', r == '\'', r == '\u00a0':
+		case r == '$', r == '£', r == '€', r == '¥':
+			// currency symbols
+		default:
+			return money.Amount{}, fmt.Errorf("invalid amount %q", raw)
+		}
+	}
+	if !endWord() {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", raw)
+	}
+	if b.Len() == 0 {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", raw)
+	}
+	a, err := money.ParseAmount(currency, b.String())
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", raw)
+	}
+	if a.Scale() > a.Curr().Scale() {
+		if ok, _ := a.Equal(a.RoundToCurr()); !ok {
+			return money.Amount{}, fmt.Errorf("amount %q has too many decimal places", raw)
+		}
+		a = a.RoundToCurr()
+	}
+	if neg {
+		a = a.Neg()
+	}
+	return a, nil
+}
+
+func blank(rec []string) bool {
+	for _, f := range rec {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
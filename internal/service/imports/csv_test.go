@@ -0,0 +1,62 @@
+package imports
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+func TestParseCSVSplitColumnsDecimalCommaAndPreamble(t *testing.T) {
+	data := "Kontoauszug\n" +
+		"Buchungstag;Text;Soll;Haben\n" +
+		"01.03.2025;Miete;1.200,00;\n" +
+		"02.03.2025;Gehalt;;2.500,50\n" +
+		";;;\n"
+	m := ledger.CSVMapping{
+		Delimiter: ";", HasHeader: true, SkipRows: 1, DateColumn: "buchungstag", DateFormat: "DD.MM.YYYY",
+		InflowColumn: "Haben", OutflowColumn: "Soll", DecimalComma: true, MemoColumns: []string{"Text"},
+	}
+	txns, rowErrs, err := ParseCSV(strings.NewReader(data), m, "EUR")
+	if err != nil || len(rowErrs) != 0 {
+		t.Fatalf("unexpected errors: %v %v", err, rowErrs)
+	}
+	if len(txns) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txns))
+	}
+	if got := txns[0].Amount.Decimal().String(); got != "-1200.00" || txns[0].Memo != "Miete" || txns[0].Row != 3 {
+		t.Fatalf("unexpected first txn: %s %+v", got, txns[0])
+	}
+	if got := txns[1].Amount.Decimal().String(); got != "2500.50" || txns[1].Date.Day() != 2 {
+		t.Fatalf("unexpected second txn: %s %+v", got, txns[1])
+	}
+}
+
+func TestParseAmountFormats(t *testing.T) {
+	cases := map[string]string{
+		"$1,234.56": "1234.56",
+		"(12.00)":   "-12.00",
+		"12.00-":    "-12.00",
+		"-0.5":      "-0.50",
+		"GBP 7":     "7.00",
+		"100.00 DR": "-100.00",
+		"100.00 cr": "100.00",
+	}
+	for in, want := range cases {
+		a, err := parseAmount("USD", in, false)
+		if err != nil {
+			t.Fatalf("%q: %v", in, err)
+		}
+		if got := a.Decimal().String(); got != want {
+			t.Fatalf("%q: got %s want %s", in, got, want)
+		}
+	}
+	if _, err := parseAmount("USD", "1.234", false); err == nil {
+		t.Fatalf("expected too many decimal places to fail")
+	}
+	for _, in := range []string{"100.00 DB", "12 EURO", "N/A"} {
+		if _, err := parseAmount("USD", in, false); err == nil {
+			t.Fatalf("%q: expected unexpected letters to fail", in)
+		}
+	}
+}
@@ -0,0 +1,483 @@
+// Package imports turns bank statement files into balanced journal entries
+// posted against a single bank account. Every posted transaction carries its
+// source ID and a content hash so importing the same file again posts nothing
+// twice.
+package imports
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+// Metadata keys stamped on imported entries.
+const (
+	// MetaSourceTxnID is the bank's reference for the transaction, or the input
+	// hash when the file has none.
+	MetaSourceTxnID = "tracker.source_txn_id"
+	// MetaInputHash identifies the transaction's content within the target account.
+	MetaInputHash = "tracker.input_hash"
+	// MetaBankAccount on an account maps it to a bank's account identifier
+	// (account number or IBAN) so statements can be imported without naming it.
+	MetaBankAccount = "tracker.bank_account"
+)
+
+type Repo interface {
+	ListImportProfiles(ctx context.Context, userID uuid.UUID) ([]ledger.ImportProfile, error)
+	GetImportProfile(ctx context.Context, userID, profileID uuid.UUID) (ledger.ImportProfile, error)
+	// EntriesByInputHash maps those of hashes that the user's entries carry as
+	// MetaInputHash to the entry IDs. Stores keep the hash unique per user, so
+	// concurrent imports of one file cannot both post a row.
+	EntriesByInputHash(ctx context.Context, userID uuid.UUID, hashes []string) (map[string]uuid.UUID, error)
+}
+
+type Writer interface {
+	CreateImportProfile(ctx context.Context, p ledger.ImportProfile) (ledger.ImportProfile, error)
+	DeleteImportProfile(ctx context.Context, userID, profileID uuid.UUID) error
+}
+
+// AccountReader resolves the target and counter accounts of an import.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+	ListAccounts(ctx context.Context, userID uuid.UUID) ([]ledger.Account, error)
+}
+
+// SuspenseAccounts provides the fallback counter-account (see account.Service).
+type SuspenseAccounts interface {
+	EnsureSuspenseAccount(ctx context.Context, userID uuid.UUID, currency string) (ledger.Account, error)
+}
+
+// Rewriter rewrites drafts before they are posted, e.g. categorization rules
+// picking the counter-account (see rules.Service).
+type Rewriter interface {
+	Apply(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, []uuid.UUID, error)
+}
+
+// Txn is one normalized bank transaction.
+type Txn struct {
+	// Row locates the transaction in the source file for error reports.
+	Row  int
+	Date time.Time
+	// Amount is signed from the account holder's view: positive is money in.
+	Amount money.Amount
+	Memo   string
+	// SourceID is the bank's identifier for the transaction, if the file has one.
+	SourceID string
+	// Unique marks SourceID as unique within the account (e.g. OFX FITID), so
+	// duplicates are detected by SourceID alone even if the bank rewrites the memo.
+	Unique bool
+	// Metadata is merged into the posted entry.
+	Metadata map[string]string
+}
+
+// Target selects where transactions are posted.
+type Target struct {
+	UserID uuid.UUID
+	// AccountID may be left nil for statement imports; the account whose
+	// tracker.bank_account metadata matches the statement's account is used.
+	AccountID uuid.UUID
+	// CounterAccountID overrides the default suspense counter-account.
+	CounterAccountID *uuid.UUID
+}
+
+// RowError reports a transaction that could not be read or posted.
+type RowError struct {
+	Row  int
+	Code string
+	Err  error
+}
+
+// Duplicate is a transaction skipped because an earlier import already posted it.
+type Duplicate struct {
+	Row       int
+	SourceID  string
+	InputHash string
+	EntryID   uuid.UUID
+}
+
+// BalanceCheck compares a balance reported on a statement with the ledger
+// balance of the account at the same instant, after the import.
+type BalanceCheck struct {
+	// Kind is opening or closing.
+	Kind           string
+	AsOf           time.Time
+	Currency       string
+	StatementMinor int64
+	LedgerMinor    int64
+}
+
+// DifferenceMinor is the statement balance minus the ledger balance.
+func (c BalanceCheck) DifferenceMinor() int64 { return c.StatementMinor - c.LedgerMinor }
+
+// Result summarizes an import. Every row is validated before any is posted,
+// so when Errors is non-empty nothing was posted.
+type Result struct {
+	Entries    []ledger.JournalEntry
+	Duplicates []Duplicate
+	Errors     []RowError
+	// Balances holds the statement balance checks, if the file reports balances.
+	Balances []BalanceCheck
+}
+
+type Service interface {
+	CreateProfile(ctx context.Context, p ledger.ImportProfile) (ledger.ImportProfile, error)
+	ListProfiles(ctx context.Context, userID uuid.UUID) ([]ledger.ImportProfile, error)
+	GetProfile(ctx context.Context, userID, profileID uuid.UUID) (ledger.ImportProfile, error)
+	DeleteProfile(ctx context.Context, userID, profileID uuid.UUID) error
+	// ImportCSV parses data with a saved profile and posts the rows to t.
+	// A counter-account on t takes precedence over the profile's.
+	ImportCSV(ctx context.Context, t Target, profileID uuid.UUID, data io.Reader) (Result, error)
+	// ImportStatement posts a parsed statement's booked transactions to t and
+	// then checks the balances it reports against the ledger. Without an
+	// AccountID on t the statement's account is looked up by MetaBankAccount.
+	ImportStatement(ctx context.Context, t Target, st statement.Statement) (Result, error)
+	// Post posts already parsed transactions to t, skipping those a previous
+	// import posted. No row is posted unless every row validates; entries are
+	// then posted one at a time, so a store failure part way leaves the earlier
+	// ones posted and retrying the import skips them as duplicates.
+	Post(ctx context.Context, t Target, txns []Txn) (Result, error)
+}
+
+type service struct {
+	repo     Repo
+	writer   Writer
+	journal  journal.Service
+	accounts AccountReader
+	suspense SuspenseAccounts
+	rewriter Rewriter
+}
+
+// New constructs the import service; rw may be nil when no rules are configured.
+func New(repo Repo, writer Writer, j journal.Service, accounts AccountReader, suspense SuspenseAccounts, rw Rewriter) Service {
+	return &service{repo: repo, writer: writer, journal: j, accounts: accounts, suspense: suspense, rewriter: rw}
+}
+
+func (s *service) CreateProfile(ctx context.Context, p ledger.ImportProfile) (ledger.ImportProfile, error) {
+	if p.UserID == uuid.Nil {
+		return ledger.ImportProfile{}, errs.ErrInvalid
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return ledger.ImportProfile{}, errors.New("name is required")
+	}
+	if err := ValidateMapping(&p.Mapping); err != nil {
+		return ledger.ImportProfile{}, err
+	}
+	if p.CounterAccountID != nil {
+		accs, err := s.accounts.FetchAccounts(ctx, p.UserID, []uuid.UUID{*p.CounterAccountID})
+		if err != nil {
+			return ledger.ImportProfile{}, err
+		}
+		if len(accs) != 1 {
+			return ledger.ImportProfile{}, errors.New("unknown or unauthorized counter_account_id")
+		}
+	}
+	p.ID = uuid.New()
+	return s.writer.CreateImportProfile(ctx, p)
+}
+
+func (s *service) ListProfiles(ctx context.Context, userID uuid.UUID) ([]ledger.ImportProfile, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	return s.repo.ListImportProfiles(ctx, userID)
+}
+
+func (s *service) GetProfile(ctx context.Context, userID, profileID uuid.UUID) (ledger.ImportProfile, error) {
+	if userID == uuid.Nil || profileID == uuid.Nil {
+		return ledger.ImportProfile{}, errs.ErrInvalid
+	}
+	return s.repo.GetImportProfile(ctx, userID, profileID)
+}
+
+func (s *service) DeleteProfile(ctx context.Context, userID, profileID uuid.UUID) error {
+	if userID == uuid.Nil || profileID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteImportProfile(ctx, userID, profileID)
+}
+
+func (s *service) ImportCSV(ctx context.Context, t Target, profileID uuid.UUID, data io.Reader) (Result, error) {
+	p, err := s.GetProfile(ctx, t.UserID, profileID)
+	if err != nil {
+		return Result{}, err
+	}
+	if t.CounterAccountID == nil {
+		t.CounterAccountID = p.CounterAccountID
+	}
+	acc, counter, err := s.target(ctx, t)
+	if err != nil {
+		return Result{}, err
+	}
+	txns, rowErrs, err := ParseCSV(data, p.Mapping, acc.Currency)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnreadable, err.Error())
+	}
+	if len(rowErrs) > 0 {
+		return Result{Errors: rowErrs}, nil
+	}
+	return s.post(ctx, acc, counter, txns)
+}
+
+func (s *service) ImportStatement(ctx context.Context, t Target, st statement.Statement) (Result, error) {
+	if t.AccountID == uuid.Nil && t.UserID != uuid.Nil {
+		id, err := s.mappedAccount(ctx, t.UserID, st.Account)
+		if err != nil {
+			return Result{}, err
+		}
+		t.AccountID = id
+	}
+	acc, counter, err := s.target(ctx, t)
+	if err != nil {
+		return Result{}, err
+	}
+	if !strings.EqualFold(st.Currency, acc.Currency) {
+		return Result{}, ErrStatementCurrency
+	}
+	txns := make([]Txn, 0, len(st.Transactions))
+	for i, tx := range st.Transactions {
+		if tx.Pending {
+			continue
+		}
+		txns = append(txns, Txn{Row: i + 1, Date: tx.Date, Amount: tx.Amount, Memo: tx.Description, SourceID: tx.ID, Unique: tx.ID != "", Metadata: tx.Metadata})
+	}
+	res, err := s.post(ctx, acc, counter, txns)
+	if err != nil || len(res.Errors) > 0 {
+		return res, err
+	}
+	for _, b := range []struct {
+		kind string
+		bal  *statement.Balance
+	}{{"opening", st.Opening}, {"closing", st.Closing}} {
+		if b.bal == nil {
+			continue
+		}
+		chk, err := s.checkBalance(ctx, acc, b.kind, *b.bal)
+		if err != nil {
+			return Result{}, err
+		}
+		res.Balances = append(res.Balances, chk)
+	}
+	return res, nil
+}
+
+// mappedAccount finds the account mapped to a bank account identifier.
+// Spaces and case are ignored, as IBANs are often printed in groups.
+func (s *service) mappedAccount(ctx context.Context, userID uuid.UUID, bankAccount string) (uuid.UUID, error) {
+	want := normalizeBankAccount(bankAccount)
+	if want == "" {
+		return uuid.Nil, ErrUnmappedAccount
+	}
+	accs, err := s.accounts.ListAccounts(ctx, userID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	found := uuid.Nil
+	for _, a := range accs {
+		if normalizeBankAccount(a.Metadata[MetaBankAccount]) != want {
+			continue
+		}
+		if found != uuid.Nil {
+			return uuid.Nil, fmt.Errorf("%w: several accounts are mapped to %s", ErrUnmappedAccount, bankAccount)
+		}
+		found = a.ID
+	}
+	if found == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("%w: no account has %s=%s", ErrUnmappedAccount, MetaBankAccount, bankAccount)
+	}
+	return found, nil
+}
+
+func normalizeBankAccount(s string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(s), ""))
+}
+
+// checkBalance reads the ledger balance of acc at the statement balance's instant.
+func (s *service) checkBalance(ctx context.Context, acc ledger.Account, kind string, b statement.Balance) (BalanceCheck, error) {
+	asOf := b.AsOf
+	got, err := s.journal.AccountBalance(ctx, acc.UserID, acc.ID, &asOf)
+	if err != nil {
+		return BalanceCheck{}, err
+	}
+	ledgerMinor, _ := got.MinorUnits()
+	stmtMinor, _ := b.Amount.MinorUnits()
+	return BalanceCheck{Kind: kind, AsOf: asOf, Currency: acc.Currency, StatementMinor: stmtMinor, LedgerMinor: ledgerMinor}, nil
+}
+
+func (s *service) Post(ctx context.Context, t Target, txns []Txn) (Result, error) {
+	acc, counter, err := s.target(ctx, t)
+	if err != nil {
+		return Result{}, err
+	}
+	return s.post(ctx, acc, counter, txns)
+}
+
+// target resolves the bank account and the counter-account, falling back to
+// the suspense account for the bank account's currency.
+func (s *service) target(ctx context.Context, t Target) (ledger.Account, ledger.Account, error) {
+	if t.UserID == uuid.Nil || t.AccountID == uuid.Nil {
+		return ledger.Account{}, ledger.Account{}, errs.ErrInvalid
+	}
+	ids := []uuid.UUID{t.AccountID}
+	if t.CounterAccountID != nil {
+		if *t.CounterAccountID == t.AccountID {
+			return ledger.Account{}, ledger.Account{}, errors.New("counter_account_id must differ from account_id")
+		}
+		ids = append(ids, *t.CounterAccountID)
+	}
+	accs, err := s.accounts.FetchAccounts(ctx, t.UserID, ids)
+	if err != nil {
+		return ledger.Account{}, ledger.Account{}, err
+	}
+	if len(accs) != len(ids) {
+		return ledger.Account{}, ledger.Account{}, errs.ErrNotFound
+	}
+	acc := accs[t.AccountID]
+	if acc.Type != ledger.AccountTypeAsset && acc.Type != ledger.AccountTypeLiability {
+		return ledger.Account{}, ledger.Account{}, ErrNotBankAccount
+	}
+	var counter ledger.Account
+	if t.CounterAccountID != nil {
+		counter = accs[*t.CounterAccountID]
+		if !strings.EqualFold(counter.Currency, acc.Currency) {
+			return ledger.Account{}, ledger.Account{}, errs.ErrMixedCurrency
+		}
+	} else if counter, err = s.suspense.EnsureSuspenseAccount(ctx, t.UserID, acc.Currency); err != nil {
+		return ledger.Account{}, ledger.Account{}, err
+	}
+	return acc, counter, nil
+}
+
+func (s *service) post(ctx context.Context, acc, counter ledger.Account, txns []Txn) (Result, error) {
+	hashes := make([]string, len(txns))
+	seen := make(map[string]int)
+	for i, tx := range txns {
+		base := InputHash(acc.ID, tx, 0)
+		hashes[i] = InputHash(acc.ID, tx, seen[base])
+		seen[base]++
+	}
+	posted, err := s.repo.EntriesByInputHash(ctx, acc.UserID, hashes)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Entries: make([]ledger.JournalEntry, 0), Duplicates: make([]Duplicate, 0)}
+	drafts := make([]ledger.JournalEntry, 0, len(txns))
+	rows := make([]int, 0, len(txns))
+	for i, tx := range txns {
+		if !strings.EqualFold(tx.Amount.Curr().Code(), acc.Currency) {
+			res.Errors = append(res.Errors, RowError{Row: tx.Row, Code: "mixed_currency", Err: errs.ErrMixedCurrency})
+			continue
+		}
+		if tx.Amount.IsZero() {
+			res.Errors = append(res.Errors, RowError{Row: tx.Row, Code: "invalid_amount", Err: errs.ErrInvalidAmount})
+			continue
+		}
+		hash := hashes[i]
+		sourceID := tx.SourceID
+		if sourceID == "" {
+			sourceID = hash
+		}
+		if id, ok := posted[hash]; ok {
+			res.Duplicates = append(res.Duplicates, Duplicate{Row: tx.Row, SourceID: sourceID, InputHash: hash, EntryID: id})
+			continue
+		}
+		d := draftFor(acc, counter, tx, sourceID, hash)
+		if s.rewriter != nil {
+			if d, _, err = s.rewriter.Apply(ctx, d); err != nil {
+				return Result{}, err
+			}
+		}
+		drafts = append(drafts, d)
+		rows = append(rows, tx.Row)
+	}
+	if len(res.Errors) > 0 || len(drafts) == 0 {
+		return res, nil
+	}
+	created, itemErrs, err := s.journal.CreateEntriesBatch(ctx, drafts)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(itemErrs) > 0 {
+		for _, ie := range itemErrs {
+			res.Errors = append(res.Errors, RowError{Row: rows[ie.Index], Code: ie.Code, Err: ie.Err})
+		}
+		return res, nil
+	}
+	res.Entries = created
+	return res, nil
+}
+
+// InputHash fingerprints a transaction posted to accountID. n counts earlier
+// transactions in the same file with the same key, so two genuine same-day
+// coffees at the same price stay distinct while a re-import of the file
+// reproduces the same hashes. Transactions with a unique SourceID are keyed
+// by it alone.
+func InputHash(accountID uuid.UUID, tx Txn, n int) string {
+	h := sha256.New()
+	if tx.Unique {
+		fmt.Fprintf(h, "%s\x1fid\x1f%s\x1f%d", accountID, tx.SourceID, n)
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	fmt.Fprintf(h, "%s\x1f%s\x1f%s\x1f%s\x1f%s\x1f%d", accountID, tx.Date.UTC().Format("2006-01-02"), tx.Amount.Decimal().String(), strings.TrimSpace(tx.Memo), tx.SourceID, n)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// draftFor builds the two-line entry for tx: money in debits the bank account,
+// money out credits it, and the counter-account takes the other side.
+func draftFor(acc, counter ledger.Account, tx Txn, sourceID, hash string) ledger.JournalEntry {
+	amt := tx.Amount.Abs()
+	bankSide, counterSide := ledger.SideDebit, ledger.SideCredit
+	if tx.Amount.IsNeg() {
+		bankSide, counterSide = ledger.SideCredit, ledger.SideDebit
+	}
+	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, 2)}
+	for _, ln := range []ledger.JournalLine{
+		{ID: uuid.New(), AccountID: acc.ID, Side: bankSide, Amount: amt},
+		{ID: uuid.New(), AccountID: counter.ID, Side: counterSide, Amount: amt},
+	} {
+		l := ln
+		lines.ByID[l.ID] = &l
+	}
+	md := meta.New(tx.Metadata)
+	md.Set(MetaSourceTxnID, sourceID)
+	md.Set(MetaInputHash, hash)
+	memo := tx.Memo
+	if memo == "" {
+		memo = "Imported transaction"
+	}
+	return ledger.JournalEntry{
+		UserID:   acc.UserID,
+		Date:     tx.Date,
+		Currency: acc.Currency,
+		Memo:     memo,
+		Category: ledger.CategoryUncategorized,
+		Metadata: md,
+		Lines:    lines,
+	}
+}
+
+// ErrNotBankAccount rejects imports into accounts that cannot hold bank balances.
+var ErrNotBankAccount = errors.New("account must be an asset or liability account")
+
+// ErrStatementCurrency rejects statements in another currency than the target account.
+var ErrStatementCurrency = errors.New("statement currency does not match the account currency")
+
+// ErrUnmappedAccount reports a statement whose account no ledger account is mapped to.
+var ErrUnmappedAccount = errors.New("statement account is not mapped to a ledger account")
+
+// ErrUnreadable wraps problems with an import file as a whole.
+var ErrUnreadable = errors.New("unreadable file")
@@ -0,0 +1,85 @@
+package journal
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// EntryKey is an entry's position in the (Date, ID) order entries are listed in.
+type EntryKey struct {
+	Date time.Time
+	ID   uuid.UUID
+}
+
+// Before reports whether k sorts before o.
+func (k EntryKey) Before(o EntryKey) bool {
+	if !k.Date.Equal(o.Date) {
+		return k.Date.Before(o.Date)
+	}
+	return k.ID.String() < o.ID.String()
+}
+
+// EntryFilter selects a page of a user's entries in (Date, ID) order. Zero
+// fields match everything.
+type EntryFilter struct {
+	UserID uuid.UUID
+	// From and To bound Date, inclusive.
+	From *time.Time
+	To   *time.Time
+	// Currency, Category and Memo match whole values, ignoring case.
+	Currency string
+	Category string
+	Memo     string
+	// AccountID keeps entries with a line on this account.
+	AccountID  uuid.UUID
+	IsReversed *bool
+	// Relation keeps entries with a relation of this kind; RelatedTo keeps
+	// entries linked to that entry. Both set means one relation must match both.
+	Relation  ledger.RelationKind
+	RelatedTo uuid.UUID
+	// After is a keyset cursor: only entries sorting after it are returned.
+	After *EntryKey
+	// Limit caps the number of entries returned; 0 returns all.
+	Limit int
+}
+
+// Matches reports whether e passes every filter except After and Limit.
+func (f EntryFilter) Matches(e ledger.JournalEntry) bool {
+	if e.UserID != f.UserID {
+		return false
+	}
+	if f.From != nil && e.Date.Before(*f.From) {
+		return false
+	}
+	if f.To != nil && e.Date.After(*f.To) {
+		return false
+	}
+	if f.Currency != "" && !strings.EqualFold(e.Currency, f.Currency) {
+		return false
+	}
+	if f.Category != "" && !strings.EqualFold(string(e.Category), f.Category) {
+		return false
+	}
+	if f.Memo != "" && !strings.EqualFold(e.Memo, f.Memo) {
+		return false
+	}
+	if f.IsReversed != nil && e.IsReversed != *f.IsReversed {
+		return false
+	}
+	if (f.Relation != "" || f.RelatedTo != uuid.Nil) && !e.HasRelation(f.Relation, f.RelatedTo) {
+		return false
+	}
+	if f.AccountID != uuid.Nil {
+		for _, ln := range e.Lines.ByID {
+			if ln.AccountID == f.AccountID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -3,6 +3,8 @@ package journal
 import (
 	"context"
 	"errors"
+	"math/big"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +12,9 @@ import (
 
 	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/period"
 )
 
 // Repo defines read operations needed by the service.
@@ -23,6 +28,41 @@ type Repo interface {
 type Writer interface {
 	CreateJournalEntry(ctx context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error)
 	UpdateJournalEntry(ctx context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error)
+	// ReverseJournalEntry creates rev, and corr when it is not nil, records
+	// upd's reversed state on the original entry and links the original to rev
+	// (reversed_by) and corr (reclassified_to), all in one transaction. It
+	// fails with errs.ErrConflict, writing nothing, when the stored entry's
+	// reversed state no longer matches orig.
+	ReverseJournalEntry(ctx context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error)
+	// AddEntryRelations records links in one transaction, skipping those
+	// already recorded. It fails with errs.ErrNotFound when an entry is not
+	// the user's.
+	AddEntryRelations(ctx context.Context, userID uuid.UUID, links []EntryLink) error
+	// RemoveEntryRelations deletes links in one transaction.
+	RemoveEntryRelations(ctx context.Context, userID uuid.UUID, links []EntryLink) error
+}
+
+// EntryLink is one stored relation: entry From relates to entry To by Kind.
+type EntryLink struct {
+	From uuid.UUID
+	Kind ledger.RelationKind
+	To   uuid.UUID
+}
+
+// BalanceRepo is implemented by repos that maintain per-account balances as
+// entries are written, so balances are read without scanning every entry.
+type BalanceRepo interface {
+	// AccountBalances returns the net (debits - credits) in the account currency
+	// of each account with lines dated up to asOf (inclusive; nil means all),
+	// limited to accountIDs when it is not empty.
+	AccountBalances(ctx context.Context, userID uuid.UUID, accountIDs []uuid.UUID, asOf *time.Time) (map[uuid.UUID]money.Amount, error)
+}
+
+// EntryQuerier is implemented by repos that filter entries in the store, so
+// a query reads only the entries it returns.
+type EntryQuerier interface {
+	// QueryEntries returns the entries matching f in (Date, ID) order.
+	QueryEntries(ctx context.Context, f EntryFilter) ([]ledger.JournalEntry, error)
 }
 
 // Service exposes validation and creation of journal entries and reporting helpers.
@@ -30,19 +70,62 @@ type Service interface {
 	ValidateEntry(ctx context.Context, e ledger.JournalEntry) error
 	CreateEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error)
 	ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.JournalEntry, error)
+	// QueryEntries returns the entries matching f in (Date, ID) order; reports
+	// use it to read only their date range.
+	QueryEntries(ctx context.Context, f EntryFilter) ([]ledger.JournalEntry, error)
 	ReverseEntry(ctx context.Context, userID, entryID uuid.UUID, date time.Time) (ledger.JournalEntry, error)
+	ReversePartial(ctx context.Context, userID, entryID uuid.UUID, date time.Time, p Partial) (ledger.JournalEntry, error)
 	Reclassify(ctx context.Context, userID, entryID uuid.UUID, date time.Time, memo string, category ledger.Category, newLines []ledger.JournalLine, metadata map[string]string) (ledger.JournalEntry, error)
 	TrialBalance(ctx context.Context, userID uuid.UUID, asOf *time.Time) (map[uuid.UUID]money.Amount, error)
 	AccountBalance(ctx context.Context, userID, accountID uuid.UUID, asOf *time.Time) (money.Amount, error)
 	CreateEntriesBatch(ctx context.Context, drafts []ledger.JournalEntry) ([]ledger.JournalEntry, []ItemError, error)
+	Relate(ctx context.Context, userID, entryID, relatedID uuid.UUID) error
+	Unrelate(ctx context.Context, userID, entryID, relatedID uuid.UUID) error
 }
 
 type service struct {
 	repo   Repo
 	writer Writer
+	// periods is set when the repo also stores accounting periods; postings
+	// into closed or locked periods are then rejected.
+	periods period.Repo
+	// assertions is set when the repo also stores balance assertions; writes
+	// that would break a passing assertion, or move a failing one further
+	// off, are then rejected.
+	assertions assertion.Repo
+	// balances is set when the repo also maintains account balances; balance
+	// and trial balance reads then use it instead of scanning entries.
+	balances BalanceRepo
+	// query is set when the repo filters entries itself; QueryEntries then
+	// uses it instead of filtering every entry.
+	query EntryQuerier
+}
+
+func New(repo Repo, writer Writer) Service {
+	s := &service{repo: repo, writer: writer}
+	if pr, ok := repo.(period.Repo); ok {
+		s.periods = pr
+	}
+	if ar, ok := repo.(assertion.Repo); ok {
+		s.assertions = ar
+	}
+	if br, ok := repo.(BalanceRepo); ok {
+		s.balances = br
+	}
+	if q, ok := repo.(EntryQuerier); ok {
+		s.query = q
+	}
+	return s
 }
 
-func New(repo Repo, writer Writer) Service { return &service{repo: repo, writer: writer} }
+// MetaKind tags entries that services generate, e.g. year-end closes. A
+// reversal carries its original's kind, so reports that skip an entry by kind
+// skip the reversal that undoes it too.
+const MetaKind = "ledger.kind"
+
+// FXToleranceMinorPerLine is the entry-currency imbalance (in minor units)
+// tolerated per converted line of a cross-currency entry.
+const FXToleranceMinorPerLine = 1
 
 // ItemError mirrors account batch; defined here to keep package-level independence.
 type ItemError struct {
@@ -52,6 +135,14 @@ type ItemError struct {
 }
 
 func (s *service) ValidateEntry(ctx context.Context, entry ledger.JournalEntry) error {
+	if err := s.validate(ctx, entry); err != nil {
+		return err
+	}
+	return s.checkAssertions(ctx, entry.UserID, entry.Date, lineDeltas(nil, entry.Lines, false))
+}
+
+// validate checks an entry on its own, without balance assertions.
+func (s *service) validate(ctx context.Context, entry ledger.JournalEntry) error {
 	if entry.UserID == uuid.Nil {
 		return errs.ErrInvalid
 	}
@@ -61,18 +152,38 @@ func (s *service) ValidateEntry(ctx context.Context, entry ledger.JournalEntry)
 	if len(entry.Lines.ByID) < 2 {
 		return errs.ErrTooFewLines
 	}
+	if err := s.checkPeriod(ctx, entry.UserID, entry.Date); err != nil {
+		return err
+	}
 
 	ids := make([]uuid.UUID, 0, len(entry.Lines.ByID))
 	var sumDebits, sumCredits int64
+	converted := 0
 	i := 0
 	for _, line := range entry.Lines.ByID {
 		if line.AccountID == uuid.Nil {
 			return lineFieldError(i, "account_id required")
 		}
-		units, _ := line.Amount.MinorUnits()
-		if units <= 0 {
+		if units, _ := line.Amount.MinorUnits(); units <= 0 {
 			return errs.ErrInvalidAmount
 		}
+		// Lines in another currency must carry a rate into the entry currency.
+		lineCurr := line.Amount.Curr().Code()
+		if line.Rate != nil {
+			if line.Rate.Base().Code() != lineCurr || line.Rate.Quote().Code() != entry.Currency || !line.Rate.IsPos() {
+				return errs.ErrMixedCurrency
+			}
+			if lineCurr != entry.Currency {
+				converted++
+			}
+		} else if lineCurr != entry.Currency {
+			return errs.ErrMixedCurrency
+		}
+		amt, err := line.EntryAmount()
+		if err != nil {
+			return errs.ErrMixedCurrency
+		}
+		units, _ := amt.MinorUnits()
 		switch line.Side {
 		case ledger.SideDebit:
 			sumDebits += units
@@ -84,7 +195,13 @@ func (s *service) ValidateEntry(ctx context.Context, entry ledger.JournalEntry)
 		ids = append(ids, line.AccountID)
 		i++
 	}
-	if sumDebits != sumCredits {
+	// Converted lines are rounded to the entry currency's minor units, so allow
+	// a residue of up to FXToleranceMinorPerLine per converted line.
+	diff := sumDebits - sumCredits
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > int64(converted)*FXToleranceMinorPerLine {
 		return errs.ErrUnbalancedEntry
 	}
 
@@ -104,7 +221,7 @@ func (s *service) ValidateEntry(ctx context.Context, entry ledger.JournalEntry)
 		if acc.UserID != entry.UserID {
 			return lineFieldError(i, "account does not belong to user")
 		}
-		if acc.Currency != entry.Currency {
+		if acc.Currency != line.Amount.Curr().Code() {
 			return errs.ErrMixedCurrency
 		}
 		i++
@@ -117,14 +234,37 @@ func (s *service) ValidateEntry(ctx context.Context, entry ledger.JournalEntry)
 func (s *service) CreateEntriesBatch(ctx context.Context, drafts []ledger.JournalEntry) ([]ledger.JournalEntry, []ItemError, error) {
 	errsList := make([]ItemError, 0)
 	// validate all
+	changes := make([]assertion.Change, len(drafts))
 	for i, d := range drafts {
-		if err := s.ValidateEntry(ctx, d); err != nil {
+		if err := s.validate(ctx, d); err != nil {
 			errsList = append(errsList, ItemError{Index: i, Code: codeForErr(err), Err: err})
 		}
+		changes[i] = assertion.Change{Date: d.Date, Delta: lineDeltas(nil, d.Lines, false)}
 	}
 	if len(errsList) > 0 {
 		return nil, errsList, nil
 	}
+	// Assertions see each user's drafts as one change, not each draft on its own.
+	checked := make(map[uuid.UUID]bool)
+	for _, d := range drafts {
+		if checked[d.UserID] {
+			continue
+		}
+		checked[d.UserID] = true
+		mine := make([]assertion.Change, len(changes))
+		for i, c := range changes {
+			if drafts[i].UserID == d.UserID {
+				mine[i] = c
+			}
+		}
+		if err := s.checkChanges(ctx, d.UserID, mine); err != nil {
+			var v *assertion.Violation
+			if !errors.As(err, &v) {
+				return nil, nil, err
+			}
+			return nil, []ItemError{{Index: firstTouching(mine, v.Assertion), Code: codeForErr(err), Err: err}}, nil
+		}
+	}
 	// create all under transaction if available
 	type txBeginner interface {
 		BeginTx(context.Context) (interface {
@@ -185,6 +325,12 @@ func codeForErr(err error) string {
 		return "unbalanced_entry"
 	case errors.Is(err, errs.ErrAlreadyReversed):
 		return "already_reversed"
+	case errors.Is(err, errs.ErrPeriodClosed):
+		return "period_closed"
+	case errors.Is(err, errs.ErrReconciled):
+		return "reconciled"
+	case errors.Is(err, errs.ErrAssertionFailed):
+		return "assertion_failed"
 	default:
 		return "validation_error"
 	}
@@ -192,6 +338,11 @@ func codeForErr(err error) string {
 
 func (s *service) CreateEntry(ctx context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error) {
 	// Assume ValidateEntry has been called; create and persist atomically.
+	return s.writer.CreateJournalEntry(ctx, newEntry(entry))
+}
+
+// newEntry copies a draft with fresh entry and line IDs.
+func newEntry(entry ledger.JournalEntry) ledger.JournalEntry {
 	entryID := uuid.New()
 	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(entry.Lines.ByID))}
 	for _, ln := range entry.Lines.ByID {
@@ -202,7 +353,7 @@ func (s *service) CreateEntry(ctx context.Context, entry ledger.JournalEntry) (l
 		lines.ByID[id] = &nl
 	}
 
-	entry = ledger.JournalEntry{
+	return ledger.JournalEntry{
 		ID:       entryID,
 		UserID:   entry.UserID,
 		Date:     entry.Date,
@@ -211,8 +362,9 @@ func (s *service) CreateEntry(ctx context.Context, entry ledger.JournalEntry) (l
 		Category: entry.Category,
 		Metadata: entry.Metadata,
 		Lines:    lines,
+		// Relations are only set by reversals and reclassifications.
+		Relations: entry.Relations,
 	}
-	return s.writer.CreateJournalEntry(ctx, entry)
 }
 
 func (s *service) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.JournalEntry, error) {
@@ -222,8 +374,73 @@ func (s *service) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.J
 	return s.repo.ListEntries(ctx, userID)
 }
 
-// ReverseEntry flips all lines of a prior entry and posts a new balancing entry.
+func (s *service) QueryEntries(ctx context.Context, f EntryFilter) ([]ledger.JournalEntry, error) {
+	if f.UserID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	if s.query != nil {
+		return s.query.QueryEntries(ctx, f)
+	}
+	entries, err := s.repo.ListEntries(ctx, f.UserID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ledger.JournalEntry, 0)
+	for _, e := range entries {
+		if f.Matches(e) && (f.After == nil || f.After.Before(EntryKey{Date: e.Date, ID: e.ID})) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return EntryKey{Date: out[i].Date, ID: out[i].ID}.Before(EntryKey{Date: out[j].Date, ID: out[j].ID})
+	})
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// Partial selects what ReversePartial undoes. Set Lines or AmountMinor, not both.
+type Partial struct {
+	// Lines maps original line IDs to the minor units of each to reverse, in
+	// the line currency.
+	Lines map[uuid.UUID]int64
+	// AmountMinor, in the entry currency, reverses every line in proportion
+	// to its original amount: 40 of a 100 purchase reverses 40% of each line.
+	AmountMinor int64
+}
+
+// ReverseEntry flips the unreversed part of every line of a prior entry and
+// posts it as a new balancing entry.
 func (s *service) ReverseEntry(ctx context.Context, userID, entryID uuid.UUID, date time.Time) (ledger.JournalEntry, error) {
+	return s.reverse(ctx, userID, entryID, date, nil, true)
+}
+
+// ReversePartial posts a balancing entry that flips part of a prior entry,
+// e.g. a partial refund. The entry is marked reversed once nothing remains.
+func (s *service) ReversePartial(ctx context.Context, userID, entryID uuid.UUID, date time.Time, p Partial) (ledger.JournalEntry, error) {
+	return s.reverse(ctx, userID, entryID, date, &p, true)
+}
+
+// maxReverseAttempts bounds how often reverse starts over after a concurrent
+// reversal of the same entry.
+const maxReverseAttempts = 3
+
+// reverse implements ReverseEntry and ReversePartial; Reclassify checks
+// assertions against the reversal and the correcting entry together and
+// skips the check here.
+func (s *service) reverse(ctx context.Context, userID, entryID uuid.UUID, date time.Time, p *Partial, checkAssertions bool) (ledger.JournalEntry, error) {
+	for attempt := 1; ; attempt++ {
+		rev, err := s.reverseOnce(ctx, userID, entryID, date, p, checkAssertions)
+		// Another reversal landed since the entry was read; recheck against it.
+		if errors.Is(err, errs.ErrConflict) && attempt < maxReverseAttempts {
+			continue
+		}
+		return rev, err
+	}
+}
+
+func (s *service) reverseOnce(ctx context.Context, userID, entryID uuid.UUID, date time.Time, p *Partial, checkAssertions bool) (ledger.JournalEntry, error) {
 	if userID == uuid.Nil || entryID == uuid.Nil {
 		return ledger.JournalEntry{}, errs.ErrInvalid
 	}
@@ -237,12 +454,56 @@ func (s *service) ReverseEntry(ctx context.Context, userID, entryID uuid.UUID, d
 	if orig.IsReversed {
 		return ledger.JournalEntry{}, errs.ErrAlreadyReversed
 	}
+	if reconciled(orig) {
+		return ledger.JournalEntry{}, errs.ErrReconciled
+	}
+	if err := s.checkPeriod(ctx, userID, date); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	upd, rev, err := s.reversal(ctx, orig, date, p, checkAssertions)
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	// The store posts the reversal only if no other reversal changed the
+	// entry since it was read, so two cannot both pass the remaining check.
+	return s.writer.ReverseJournalEntry(ctx, orig, upd, rev, nil)
+}
+
+// reversal builds the entry reversing p of orig (all of what remains when p
+// is nil) and the original as it stands once that is posted.
+func (s *service) reversal(ctx context.Context, orig ledger.JournalEntry, date time.Time, p *Partial, checkAssertions bool) (upd, rev ledger.JournalEntry, err error) {
+	userID := orig.UserID
+	// amounts holds the minor units to reverse per original line.
+	amounts := make(map[uuid.UUID]int64, len(orig.Lines.ByID))
+	if p == nil {
+		for id, ln := range orig.Lines.ByID {
+			if n := ln.RemainingMinor(); n > 0 {
+				amounts[id] = n
+			}
+		}
+		if len(amounts) == 0 {
+			return ledger.JournalEntry{}, ledger.JournalEntry{}, errs.ErrAlreadyReversed
+		}
+	} else if amounts, err = partialAmounts(orig, *p); err != nil {
+		return ledger.JournalEntry{}, ledger.JournalEntry{}, err
+	}
 	rid := uuid.New()
-	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(orig.Lines.ByID))}
-	for _, ln := range orig.Lines.ByID {
+	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(amounts))}
+	for id, minor := range amounts {
+		ln := orig.Lines.ByID[id]
+		if minor > ln.RemainingMinor() {
+			return ledger.JournalEntry{}, ledger.JournalEntry{}, errs.ErrOverReversal
+		}
+		amt, err := money.NewAmountFromMinorUnits(ln.Amount.Curr().Code(), minor)
+		if err != nil {
+			return ledger.JournalEntry{}, ledger.JournalEntry{}, err
+		}
 		nl := *ln
 		nl.ID = uuid.New()
 		nl.EntryID = rid
+		nl.Status = ""
+		nl.Reversed = nil
+		nl.Amount = amt
 		if ln.Side == ledger.SideDebit {
 			nl.Side = ledger.SideCredit
 		} else {
@@ -251,32 +512,185 @@ func (s *service) ReverseEntry(ctx context.Context, userID, entryID uuid.UUID, d
 		lines.ByID[nl.ID] = &nl
 	}
 	e := ledger.JournalEntry{
-		ID:       rid,
-		UserID:   userID,
-		Date:     date,
-		Currency: orig.Currency,
-		Memo:     "reversal of " + orig.ID.String() + ": " + orig.Memo,
-		Category: orig.Category,
-		Lines:    lines,
+		ID:        rid,
+		UserID:    userID,
+		Date:      date,
+		Currency:  orig.Currency,
+		Memo:      "reversal of " + orig.ID.String() + ": " + orig.Memo,
+		Category:  orig.Category,
+		Lines:     lines,
+		Relations: []ledger.EntryRelation{{Kind: ledger.RelationReverses, EntryID: orig.ID}},
+	}
+	if kind := orig.Metadata[MetaKind]; kind != "" {
+		e.Metadata = meta.Metadata{MetaKind: kind}
+	}
+	if p != nil {
+		e.Memo = "partial " + e.Memo
+		// A chosen subset of lines must still balance.
+		if err := s.validate(ctx, e); err != nil {
+			return ledger.JournalEntry{}, ledger.JournalEntry{}, err
+		}
 	}
-	rev, err := s.writer.CreateJournalEntry(ctx, e)
-	if err != nil {
-		return ledger.JournalEntry{}, err
+	if checkAssertions {
+		if err := s.checkAssertions(ctx, userID, date, lineDeltas(nil, lines, false)); err != nil {
+			return ledger.JournalEntry{}, ledger.JournalEntry{}, err
+		}
 	}
-	// Mark original as reversed
-	orig.IsReversed = true
-	if _, err := s.writer.UpdateJournalEntry(ctx, orig); err != nil {
-		return ledger.JournalEntry{}, err
+	// Record the reversed amounts on copies of the original lines: stores may
+	// share line maps with earlier reads.
+	upd = orig
+	upd.IsReversed = true
+	upd.Relations = append(append([]ledger.EntryRelation(nil), orig.Relations...), ledger.EntryRelation{Kind: ledger.RelationReversedBy, EntryID: e.ID})
+	upd.Lines = ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(orig.Lines.ByID))}
+	for id, ln := range orig.Lines.ByID {
+		nl := *ln
+		if minor, ok := amounts[id]; ok {
+			r, err := money.NewAmountFromMinorUnits(ln.Amount.Curr().Code(), ln.ReversedMinor()+minor)
+			if err != nil {
+				return ledger.JournalEntry{}, ledger.JournalEntry{}, err
+			}
+			nl.Reversed = &r
+		}
+		if nl.RemainingMinor() > 0 {
+			upd.IsReversed = false
+		}
+		upd.Lines.ByID[id] = &nl
+	}
+	return upd, e, nil
+}
+
+// partialAmounts resolves p into the minor units to reverse per line of orig.
+func partialAmounts(orig ledger.JournalEntry, p Partial) (map[uuid.UUID]int64, error) {
+	if (len(p.Lines) > 0) == (p.AmountMinor != 0) {
+		return nil, errs.ErrInvalid
+	}
+	out := make(map[uuid.UUID]int64, len(orig.Lines.ByID))
+	if len(p.Lines) > 0 {
+		for id, minor := range p.Lines {
+			if _, ok := orig.Lines.ByID[id]; !ok {
+				return nil, errors.New("line " + id.String() + " is not part of the entry")
+			}
+			if minor <= 0 {
+				return nil, errs.ErrInvalidAmount
+			}
+			out[id] = minor
+		}
+		return out, nil
+	}
+	num := p.AmountMinor
+	if num < 0 {
+		return nil, errs.ErrInvalidAmount
+	}
+	// The entry total is the sum of its debits in the entry currency.
+	var den int64
+	for _, ln := range orig.Lines.ByID {
+		if ln.Side != ledger.SideDebit {
+			continue
+		}
+		amt, err := ln.EntryAmount()
+		if err != nil {
+			return nil, err
+		}
+		minor, _ := amt.MinorUnits()
+		den += minor
+	}
+	if num > den {
+		return nil, errs.ErrOverReversal
+	}
+	// Each side must total num in the entry currency. Converted lines round
+	// on their own; entry-currency lines share the rest by largest remainder.
+	type share struct {
+		id  uuid.UUID
+		rem *big.Int
+	}
+	var sides [2][]share
+	var targets [2]int64
+	targets[0], targets[1] = num, num
+	for id, ln := range orig.Lines.ByID {
+		side := 0
+		if ln.Side == ledger.SideCredit {
+			side = 1
+		}
+		minor, _ := ln.Amount.MinorUnits()
+		q, rem := proportion(minor, num, den)
+		if ln.Rate == nil {
+			out[id] = q
+			targets[side] -= q
+			sides[side] = append(sides[side], share{id: id, rem: rem})
+			continue
+		}
+		if 2*rem.Int64() >= den {
+			q++
+		}
+		amt, err := money.NewAmountFromMinorUnits(ln.Amount.Curr().Code(), q)
+		if err != nil {
+			return nil, err
+		}
+		conv, err := ln.Rate.Conv(amt)
+		if err != nil {
+			return nil, err
+		}
+		c, _ := conv.RoundToCurr().MinorUnits()
+		out[id] = q
+		targets[side] -= c
+	}
+	// Hand out the rest a unit at a time, largest remainder first, until each
+	// side meets its target; rounded converted lines can leave it more than
+	// one unit per share away, or past it.
+	for side, shares := range sides {
+		if len(shares) == 0 {
+			continue
+		}
+		sort.Slice(shares, func(i, j int) bool { return shares[i].rem.Cmp(shares[j].rem) > 0 })
+		for i := 0; targets[side] > 0; i = (i + 1) % len(shares) {
+			out[shares[i].id]++
+			targets[side]--
+		}
+		for targets[side] < 0 {
+			taken := false
+			for i := len(shares) - 1; i >= 0 && targets[side] < 0; i-- {
+				if out[shares[i].id] > 0 {
+					out[shares[i].id]--
+					targets[side]++
+					taken = true
+				}
+			}
+			if !taken {
+				break
+			}
+		}
 	}
-	return rev, nil
+	for id, minor := range out {
+		if minor == 0 {
+			delete(out, id)
+		}
+	}
+	return out, nil
 }
 
-// Reclassify posts a reversing entry for the original, then a correcting entry with provided lines.
-// Returns the correcting entry.
+// proportion returns amount*num/den as a quotient and remainder, without overflowing.
+func proportion(amount, num, den int64) (int64, *big.Int) {
+	q, r := new(big.Int).QuoRem(new(big.Int).Mul(big.NewInt(amount), big.NewInt(num)), big.NewInt(den), new(big.Int))
+	return q.Int64(), r
+}
+
+// Reclassify posts a reversing entry for the original and a correcting entry with provided lines,
+// together. Returns the correcting entry.
 func (s *service) Reclassify(ctx context.Context, userID, entryID uuid.UUID, date time.Time, memo string, category ledger.Category, newLines []ledger.JournalLine, metadata map[string]string) (ledger.JournalEntry, error) {
 	if userID == uuid.Nil || entryID == uuid.Nil {
 		return ledger.JournalEntry{}, errs.ErrInvalid
 	}
+	for attempt := 1; ; attempt++ {
+		corr, err := s.reclassifyOnce(ctx, userID, entryID, date, memo, category, newLines, metadata)
+		// A reversal landed since the entry was read; recheck against it.
+		if errors.Is(err, errs.ErrConflict) && attempt < maxReverseAttempts {
+			continue
+		}
+		return corr, err
+	}
+}
+
+func (s *service) reclassifyOnce(ctx context.Context, userID, entryID uuid.UUID, date time.Time, memo string, category ledger.Category, newLines []ledger.JournalLine, metadata map[string]string) (ledger.JournalEntry, error) {
 	orig, err := s.repo.GetEntry(ctx, userID, entryID)
 	if err != nil {
 		return ledger.JournalEntry{}, err
@@ -284,16 +698,16 @@ func (s *service) Reclassify(ctx context.Context, userID, entryID uuid.UUID, dat
 	if orig.UserID != userID {
 		return ledger.JournalEntry{}, errs.ErrForbidden
 	}
-	if orig.IsReversed {
+	if orig.IsReversed || partiallyReversed(orig) {
 		return ledger.JournalEntry{}, errs.ErrAlreadyReversed
 	}
-
-	// 1) reversing entry
-	if _, err := s.ReverseEntry(ctx, userID, entryID, date); err != nil {
+	if reconciled(orig) {
+		return ledger.JournalEntry{}, errs.ErrReconciled
+	}
+	if err := s.checkPeriod(ctx, userID, date); err != nil {
 		return ledger.JournalEntry{}, err
 	}
 
-	// 2) correcting entry
 	if memo == "" {
 		memo = "reclassify of " + orig.ID.String()
 	}
@@ -303,21 +717,95 @@ func (s *service) Reclassify(ctx context.Context, userID, entryID uuid.UUID, dat
 		id := uuid.New()
 		ln.ID = id
 		ln.EntryID = uuid.Nil
+		// Lines without a rate are in the original entry's currency.
+		if ln.Rate == nil {
+			units, _ := ln.Amount.MinorUnits()
+			amt, err := money.NewAmountFromMinorUnits(orig.Currency, units)
+			if err != nil {
+				return ledger.JournalEntry{}, err
+			}
+			ln.Amount = amt
+		}
 		lines.ByID[id] = &ln
 	}
 	e := ledger.JournalEntry{UserID: userID, Date: date, Currency: orig.Currency, Memo: memo, Category: category, Metadata: metadata, Lines: lines}
-	if err := s.ValidateEntry(ctx, e); err != nil {
+	if err := s.validate(ctx, e); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	// Assertions see the net effect: a reclassification between expense
+	// accounts leaves the bank balance untouched.
+	delta := lineDeltas(nil, orig.Lines, true)
+	delta = lineDeltas(delta, lines, false)
+	if err := s.checkAssertions(ctx, userID, date, delta); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+
+	upd, rev, err := s.reversal(ctx, orig, date, nil, false)
+	if err != nil {
 		return ledger.JournalEntry{}, err
 	}
-	return s.CreateEntry(ctx, e)
+	// The correcting entry is linked both ways to the original and posted
+	// with the reversal, so neither lands without the other.
+	e.Relations = []ledger.EntryRelation{{Kind: ledger.RelationReclassifiedFrom, EntryID: orig.ID}}
+	corr := newEntry(e)
+	upd.Relations = append(upd.Relations, ledger.EntryRelation{Kind: ledger.RelationReclassifiedTo, EntryID: corr.ID})
+	if _, err := s.writer.ReverseJournalEntry(ctx, orig, upd, rev, &corr); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	return corr, nil
+}
+
+// Relate links two entries of a user as related, on both sides. Linking
+// entries that are already related is a no-op.
+func (s *service) Relate(ctx context.Context, userID, entryID, relatedID uuid.UUID) error {
+	if userID == uuid.Nil || entryID == uuid.Nil || relatedID == uuid.Nil || entryID == relatedID {
+		return errs.ErrInvalid
+	}
+	for _, id := range []uuid.UUID{entryID, relatedID} {
+		e, err := s.repo.GetEntry(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if e.UserID != userID {
+			return errs.ErrForbidden
+		}
+	}
+	return s.writer.AddEntryRelations(ctx, userID, []EntryLink{
+		{From: entryID, Kind: ledger.RelationRelated, To: relatedID},
+		{From: relatedID, Kind: ledger.RelationRelated, To: entryID},
+	})
+}
+
+// Unrelate removes a related link from both entries. Links recorded by
+// reversals and reclassifications cannot be removed.
+func (s *service) Unrelate(ctx context.Context, userID, entryID, relatedID uuid.UUID) error {
+	if userID == uuid.Nil || entryID == uuid.Nil || relatedID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	e, err := s.repo.GetEntry(ctx, userID, entryID)
+	if err != nil {
+		return err
+	}
+	if e.UserID != userID {
+		return errs.ErrForbidden
+	}
+	if !e.HasRelation(ledger.RelationRelated, relatedID) {
+		return errs.ErrNotFound
+	}
+	return s.writer.RemoveEntryRelations(ctx, userID, []EntryLink{
+		{From: entryID, Kind: ledger.RelationRelated, To: relatedID},
+		{From: relatedID, Kind: ledger.RelationRelated, To: entryID},
+	})
 }
 
 // TrialBalance returns net amounts per account (debits - credits) up to asOf (inclusive).
-// TrialBalance returns net amounts (debits - credits) per account up to asOf.
 func (s *service) TrialBalance(ctx context.Context, userID uuid.UUID, asOf *time.Time) (map[uuid.UUID]money.Amount, error) {
 	if userID == uuid.Nil {
 		return nil, errors.New("user_id is required")
 	}
+	if s.balances != nil {
+		return s.balances.AccountBalances(ctx, userID, nil, asOf)
+	}
 	entries, err := s.repo.ListEntries(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -353,6 +841,17 @@ func (s *service) AccountBalance(ctx context.Context, userID, accountID uuid.UUI
 	if userID == uuid.Nil || accountID == uuid.Nil {
 		return money.MustNewAmount("USD", 0, 0), errors.New("user_id and account_id are required")
 	}
+	if s.balances != nil {
+		nets, err := s.balances.AccountBalances(ctx, userID, []uuid.UUID{accountID}, asOf)
+		if err != nil {
+			return money.MustNewAmount("USD", 0, 0), err
+		}
+		if net, ok := nets[accountID]; ok {
+			return net, nil
+		}
+		// No lines yet: same USD default as the scan below.
+		return money.MustNewAmount("USD", 0, 0), nil
+	}
 	entries, err := s.repo.ListEntries(ctx, userID)
 	if err != nil {
 		return money.MustNewAmount("USD", 0, 0), err
@@ -400,6 +899,83 @@ func (s *service) AccountBalance(ctx context.Context, userID, accountID uuid.UUI
 	return net, nil
 }
 
+// checkPeriod rejects dates inside closed or locked periods when periods are available.
+func (s *service) checkPeriod(ctx context.Context, userID uuid.UUID, date time.Time) error {
+	if s.periods == nil {
+		return nil
+	}
+	return period.CheckDate(ctx, s.periods, userID, date)
+}
+
+// checkAssertions rejects changes that would break a passing balance assertion,
+// or move a failing one further off, when assertions are available.
+func (s *service) checkAssertions(ctx context.Context, userID uuid.UUID, date time.Time, delta map[uuid.UUID]money.Amount) error {
+	if s.assertions == nil {
+		return nil
+	}
+	return assertion.Check(ctx, s.assertions, s, userID, date, delta)
+}
+
+// checkChanges is checkAssertions for changes applied together.
+func (s *service) checkChanges(ctx context.Context, userID uuid.UUID, changes []assertion.Change) error {
+	if s.assertions == nil {
+		return nil
+	}
+	return assertion.CheckChanges(ctx, s.assertions, s, userID, changes)
+}
+
+// firstTouching returns the index of the first change that counts towards a,
+// which is where a batch that breaks it is reported.
+func firstTouching(changes []assertion.Change, a ledger.BalanceAssertion) int {
+	for i, c := range changes {
+		if _, ok := c.Delta[a.AccountID]; ok && !c.Date.After(a.Cutoff()) {
+			return i
+		}
+	}
+	return 0
+}
+
+// lineDeltas adds the net debit-positive change of lines per account to into,
+// negated when negate is set, and returns it.
+func lineDeltas(into map[uuid.UUID]money.Amount, lines ledger.JournalLines, negate bool) map[uuid.UUID]money.Amount {
+	if into == nil {
+		into = make(map[uuid.UUID]money.Amount, len(lines.ByID))
+	}
+	for _, ln := range lines.ByID {
+		amt := ln.Amount
+		if (ln.Side == ledger.SideCredit) != negate {
+			amt = amt.Neg()
+		}
+		if cur, ok := into[ln.AccountID]; ok {
+			if sum, err := cur.Add(amt); err == nil {
+				amt = sum
+			}
+		}
+		into[ln.AccountID] = amt
+	}
+	return into
+}
+
+// reconciled reports whether any line of e belongs to a finished reconciliation.
+func reconciled(e ledger.JournalEntry) bool {
+	for _, ln := range e.Lines.ByID {
+		if ln.Status == ledger.LineStatusReconciled {
+			return true
+		}
+	}
+	return false
+}
+
+// partiallyReversed reports whether any line of e has been partly reversed.
+func partiallyReversed(e ledger.JournalEntry) bool {
+	for _, ln := range e.Lines.ByID {
+		if ln.ReversedMinor() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func lineFieldError(i int, msg string) error {
 	return errors.New("line[" + intToString(i) + "]: " + msg)
 }
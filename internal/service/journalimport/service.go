@@ -0,0 +1,569 @@
+// Package journalimport loads plain-text beancount, ledger and hledger
+// journals: declared accounts are created, transactions posted as balanced
+// entries and balance directives kept as balance assertions. Every write goes
+// through one store transaction, so an import either lands whole or not at all.
+package journalimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/plaintext"
+	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/slug"
+)
+
+// Metadata keys filled from transaction headers.
+const (
+	// MetaPayee holds the payee when a transaction has both a payee and a narration.
+	MetaPayee = "payee"
+	// MetaTags holds the transaction's tags, space-separated.
+	MetaTags = "tags"
+	// MetaCategory selects the entry category, as written by the exporter.
+	MetaCategory = "category"
+)
+
+// Tx is a store transaction that reads its own uncommitted writes, so the
+// account, journal and assertion services can run inside it unchanged.
+type Tx interface {
+	account.Repo
+	account.Writer
+	journal.Repo
+	journal.Writer
+	assertion.Repo
+	assertion.Writer
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+}
+
+// TxBeginner begins batch transactions. An import only touches the data of
+// userID, so a store may limit the transaction to it.
+type TxBeginner interface {
+	BeginTx(ctx context.Context, userID uuid.UUID) (Tx, error)
+}
+
+// Result summarizes an import. When Errors is non-empty nothing was written.
+type Result struct {
+	// Accounts lists the accounts the import created, including system accounts.
+	Accounts   []ledger.Account
+	Entries    []ledger.JournalEntry
+	Assertions []assertion.Result
+	Errors     []plaintext.LineError
+}
+
+type Service interface {
+	// Import parses a journal in format f and posts it for userID.
+	Import(ctx context.Context, userID uuid.UUID, f plaintext.Format, r io.Reader) (Result, error)
+}
+
+type service struct {
+	store TxBeginner
+}
+
+func New(store TxBeginner) Service { return &service{store: store} }
+
+// accountKey identifies a ledger account by journal name and currency; a
+// multi-currency journal account maps to one ledger account per currency.
+type accountKey struct {
+	name     string
+	currency string
+}
+
+func (s *service) Import(ctx context.Context, userID uuid.UUID, f plaintext.Format, r io.Reader) (Result, error) {
+	if userID == uuid.Nil {
+		return Result{}, errs.ErrInvalid
+	}
+	j, lineErrs, err := plaintext.Parse(r, f)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrUnreadable, err.Error())
+	}
+	if len(lineErrs) > 0 {
+		return Result{Errors: lineErrs}, nil
+	}
+	tx, err := s.store.BeginTx(ctx, userID)
+	if err != nil {
+		return Result{}, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_ = tx.Rollback(ctx)
+		}
+	}()
+	im := &importer{
+		userID:     userID,
+		format:     f,
+		journal:    j,
+		accounts:   account.New(tx, tx),
+		entries:    journal.New(tx, tx),
+		repo:       tx,
+		resolved:   map[accountKey]ledger.Account{},
+		currencies: make([]string, len(j.Transactions)),
+	}
+	im.assertions = assertion.New(tx, tx, im.entries, tx)
+	res, err := im.run(ctx)
+	if err != nil || len(res.Errors) > 0 {
+		sort.SliceStable(res.Errors, func(i, k int) bool { return res.Errors[i].Line < res.Errors[k].Line })
+		return res, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return Result{}, err
+	}
+	committed = true
+	return res, nil
+}
+
+type importer struct {
+	userID     uuid.UUID
+	format     plaintext.Format
+	journal    plaintext.Journal
+	accounts   account.Service
+	entries    journal.Service
+	assertions assertion.Service
+	repo       Tx
+	resolved   map[accountKey]ledger.Account
+	// currencies holds the entry currency of each transaction.
+	currencies []string
+	errs       []plaintext.LineError
+}
+
+func (im *importer) fail(line int, code string, err error) {
+	im.errs = append(im.errs, plaintext.LineError{Line: line, Code: code, Err: err})
+}
+
+func (im *importer) run(ctx context.Context) (Result, error) {
+	var res Result
+	created, err := im.resolveAccounts(ctx)
+	if err != nil || len(im.errs) > 0 {
+		return Result{Errors: im.errs}, err
+	}
+	res.Accounts = created
+
+	drafts, lines := im.drafts()
+	if len(im.errs) > 0 {
+		return Result{Errors: im.errs}, nil
+	}
+	if len(drafts) > 0 {
+		entries, itemErrs, err := im.entries.CreateEntriesBatch(ctx, drafts)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, ie := range itemErrs {
+			im.fail(lines[ie.Index], ie.Code, ie.Err)
+		}
+		if len(im.errs) > 0 {
+			return Result{Errors: im.errs}, nil
+		}
+		res.Entries = entries
+	}
+
+	for _, b := range im.journal.Balances {
+		acc := im.resolved[accountKey{b.Account, b.Amount.Curr().Code()}]
+		// A balance holds at the start of its day, which is the end of the day before.
+		out, err := im.assertions.Create(ctx, ledger.BalanceAssertion{UserID: im.userID, AccountID: acc.ID, Date: b.Date.AddDate(0, 0, -1), Amount: b.Amount, Note: "imported balance"})
+		switch {
+		case errors.Is(err, assertion.ErrDuplicate):
+			im.fail(b.Line, "assertion_exists", err)
+		case err != nil:
+			return Result{}, err
+		case !out.Pass:
+			im.fail(b.Line, "balance_failed", fmt.Errorf("%s should be %s %s at the start of %s but is %s",
+				b.Account, b.Amount.Decimal(), b.Amount.Curr().Code(), b.Date.Format("2006-01-02"), out.Actual.Decimal()))
+		default:
+			res.Assertions = append(res.Assertions, out)
+		}
+	}
+	if len(im.errs) > 0 {
+		return Result{Errors: im.errs}, nil
+	}
+	return res, nil
+}
+
+// resolveAccounts maps every journal account and currency in use to a ledger
+// account, reusing existing accounts with the same path and currency and
+// creating the rest through EnsureAccountsBatch.
+func (im *importer) resolveAccounts(ctx context.Context) ([]ledger.Account, error) {
+	opens := map[string]plaintext.Open{}
+	for _, o := range im.journal.Accounts {
+		if prev, ok := opens[o.Account]; ok {
+			im.fail(o.Line, "duplicate_account", fmt.Errorf("account %s is already declared on line %d", o.Account, prev.Line))
+			continue
+		}
+		opens[o.Account] = o
+	}
+	// needed lists each account key with the first line that uses it.
+	needed := map[accountKey]int{}
+	var order []accountKey
+	use := func(line int, name, currency string) {
+		k := accountKey{name, currency}
+		if _, ok := needed[k]; ok {
+			return
+		}
+		o, declared := opens[name]
+		if !declared && im.format == plaintext.FormatBeancount {
+			im.fail(line, "unknown_account", fmt.Errorf("account %s is not opened", name))
+			return
+		}
+		if declared && len(o.Currencies) > 0 && !containsFold(o.Currencies, currency) {
+			im.fail(line, "currency_mismatch", fmt.Errorf("account %s does not allow %s", name, currency))
+			return
+		}
+		needed[k] = line
+		order = append(order, k)
+	}
+	for _, o := range im.journal.Accounts {
+		for _, c := range o.Currencies {
+			use(o.Line, o.Account, strings.ToUpper(c))
+		}
+	}
+	for i, t := range im.journal.Transactions {
+		curr, err := entryCurrency(t)
+		if err != nil {
+			im.fail(t.Line, "currency_mismatch", err)
+			continue
+		}
+		im.currencies[i] = curr
+		for _, p := range t.Postings {
+			if o, ok := opens[p.Account]; ok && !o.Date.IsZero() && t.Date.Before(o.Date) {
+				im.fail(p.Line, "account_not_open", fmt.Errorf("account %s is opened on %s, after this transaction", p.Account, o.Date.Format("2006-01-02")))
+			}
+			c := curr
+			if p.Amount != nil {
+				c = p.Amount.Curr().Code()
+			}
+			use(p.Line, p.Account, c)
+		}
+	}
+	for _, b := range im.journal.Balances {
+		use(b.Line, b.Account, b.Amount.Curr().Code())
+	}
+	if len(im.errs) > 0 {
+		return nil, nil
+	}
+
+	existing, err := im.repo.ListAccounts(ctx, im.userID)
+	if err != nil {
+		return nil, err
+	}
+	var created []ledger.Account
+	var specs []ledger.Account
+	var specLines []int
+	var specKeys []accountKey
+	for _, k := range order {
+		line := needed[k]
+		spec, err := accountSpec(k.name, k.currency)
+		if err != nil {
+			im.fail(line, "invalid_account", err)
+			continue
+		}
+		if acc, ok := findAccount(existing, spec); ok {
+			im.resolved[k] = acc
+			continue
+		}
+		if spec.Type == ledger.AccountTypeEquity && isSystemGroup(spec.Group) {
+			acc, err := im.ensureSystem(ctx, spec)
+			if err != nil {
+				return nil, err
+			}
+			im.resolved[k] = acc
+			existing = append(existing, acc)
+			created = append(created, acc)
+			continue
+		}
+		specs = append(specs, spec)
+		specLines = append(specLines, line)
+		specKeys = append(specKeys, k)
+	}
+	if len(im.errs) > 0 || len(specs) == 0 {
+		return created, nil
+	}
+	// Opening balance accounts ensured by the batch are reported as created too.
+	before := make(map[uuid.UUID]bool, len(existing))
+	for _, a := range existing {
+		before[a.ID] = true
+	}
+	accs, itemErrs, err := im.accounts.EnsureAccountsBatch(ctx, im.userID, specs)
+	if err != nil {
+		return nil, err
+	}
+	for _, ie := range itemErrs {
+		im.fail(specLines[ie.Index], ie.Code, fmt.Errorf("account %s: %w", specKeys[ie.Index].name, ie.Err))
+	}
+	if len(im.errs) > 0 {
+		return nil, nil
+	}
+	for i, acc := range accs {
+		im.resolved[specKeys[i]] = acc
+	}
+	all, err := im.repo.ListAccounts(ctx, im.userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range all {
+		if !before[a.ID] {
+			created = append(created, a)
+		}
+	}
+	return created, nil
+}
+
+func (im *importer) ensureSystem(ctx context.Context, spec ledger.Account) (ledger.Account, error) {
+	switch strings.ToLower(spec.Group) {
+	case account.GroupOpeningBalances:
+		return im.accounts.EnsureOpeningBalanceAccount(ctx, im.userID, spec.Currency)
+	case account.GroupRetainedEarnings:
+		return im.accounts.EnsureRetainedEarningsAccount(ctx, im.userID, spec.Currency)
+	default:
+		return im.accounts.EnsureSuspenseAccount(ctx, im.userID, spec.Currency)
+	}
+}
+
+// drafts builds an entry per transaction, filling in an elided amount from
+// the others. lines maps each draft to its transaction's line.
+func (im *importer) drafts() ([]ledger.JournalEntry, []int) {
+	drafts := make([]ledger.JournalEntry, 0, len(im.journal.Transactions))
+	lines := make([]int, 0, len(im.journal.Transactions))
+	for i, t := range im.journal.Transactions {
+		d, err := im.draft(t, im.currencies[i])
+		if err != nil {
+			var le plaintext.LineError
+			if errors.As(err, &le) {
+				im.errs = append(im.errs, le)
+			} else {
+				im.fail(t.Line, "validation_error", err)
+			}
+			continue
+		}
+		drafts = append(drafts, d)
+		lines = append(lines, t.Line)
+	}
+	return drafts, lines
+}
+
+func (im *importer) draft(t plaintext.Transaction, curr string) (ledger.JournalEntry, error) {
+	md := meta.New(t.Metadata)
+	category := ledger.CategoryUncategorized
+	if c, ok := md.Get(MetaCategory); ok {
+		category = ledger.Category(c)
+		md.Del(MetaCategory)
+	}
+	memo := t.Memo
+	if memo == "" {
+		memo = t.Payee
+	} else if t.Payee != "" {
+		md.Set(MetaPayee, t.Payee)
+	}
+	if len(t.Tags) > 0 {
+		md.Set(MetaTags, strings.Join(t.Tags, " "))
+	}
+	if err := md.Validate(); err != nil {
+		return ledger.JournalEntry{}, plaintext.LineError{Line: t.Line, Code: "invalid_metadata", Err: err}
+	}
+
+	// Weights are in the entry currency: the cost of priced postings, else the amount.
+	var sum int64
+	elided := -1
+	for i, p := range t.Postings {
+		if p.Amount == nil {
+			elided = i
+			continue
+		}
+		w := *p.Amount
+		if p.Cost != nil && p.Amount.Curr().Code() != curr {
+			w = p.Cost.CopySign(*p.Amount)
+		}
+		minor, _ := w.MinorUnits()
+		sum += minor
+	}
+
+	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(t.Postings))}
+	for i, p := range t.Postings {
+		amt := p.Amount
+		if i == elided {
+			if sum == 0 {
+				return ledger.JournalEntry{}, plaintext.LineError{Line: p.Line, Code: "invalid_amount", Err: errors.New("cannot infer the elided amount: the other postings already balance")}
+			}
+			v, err := money.NewAmountFromMinorUnits(curr, -sum)
+			if err != nil {
+				return ledger.JournalEntry{}, err
+			}
+			amt = &v
+		}
+		if amt.IsZero() {
+			return ledger.JournalEntry{}, plaintext.LineError{Line: p.Line, Code: "invalid_amount", Err: errors.New("posting amount must not be zero")}
+		}
+		acc := im.resolved[accountKey{p.Account, amt.Curr().Code()}]
+		line := &ledger.JournalLine{ID: uuid.New(), AccountID: acc.ID, Side: ledger.SideDebit, Amount: amt.Abs(), Metadata: meta.New(p.Metadata)}
+		if amt.IsNeg() {
+			line.Side = ledger.SideCredit
+		}
+		if c := amt.Curr().Code(); c != curr {
+			rate, err := costRate(*amt, *p.Cost)
+			if err != nil {
+				return ledger.JournalEntry{}, plaintext.LineError{Line: p.Line, Code: "invalid_amount", Err: err}
+			}
+			line.Rate = &rate
+		}
+		lines.ByID[line.ID] = line
+	}
+	return ledger.JournalEntry{
+		UserID:   im.userID,
+		Date:     t.Date,
+		Currency: curr,
+		Memo:     memo,
+		Category: category,
+		Metadata: md,
+		Lines:    lines,
+	}, nil
+}
+
+// entryCurrency picks the currency a transaction balances in: the currency
+// of its prices, or the one currency of its amounts.
+func entryCurrency(t plaintext.Transaction) (string, error) {
+	var priced, plain []string
+	for _, p := range t.Postings {
+		if p.Amount == nil {
+			continue
+		}
+		if p.Cost != nil && p.Cost.Curr().Code() != p.Amount.Curr().Code() {
+			priced = appendUnique(priced, p.Cost.Curr().Code())
+		} else {
+			plain = appendUnique(plain, p.Amount.Curr().Code())
+		}
+	}
+	switch {
+	case len(priced) > 1:
+		return "", errors.New("prices must all be in one currency")
+	case len(priced) == 1:
+		for _, c := range plain {
+			if c != priced[0] {
+				return "", fmt.Errorf("postings in %s need a price in %s", c, priced[0])
+			}
+		}
+		return priced[0], nil
+	case len(plain) == 1:
+		return plain[0], nil
+	default:
+		return "", errors.New("postings in several currencies need a price (@ or @@)")
+	}
+}
+
+// costRate is the exchange rate that converts amt into its cost.
+func costRate(amt, cost money.Amount) (money.ExchangeRate, error) {
+	r, err := cost.Decimal().Abs().Quo(amt.Decimal().Abs())
+	if err != nil {
+		return money.ExchangeRate{}, err
+	}
+	return money.NewExchRateFromDecimal(amt.Curr(), cost.Curr(), r)
+}
+
+// accountRoots maps root account names of either dialect to account types.
+var accountRoots = map[string]ledger.AccountType{
+	"asset":       ledger.AccountTypeAsset,
+	"assets":      ledger.AccountTypeAsset,
+	"liability":   ledger.AccountTypeLiability,
+	"liabilities": ledger.AccountTypeLiability,
+	"equity":      ledger.AccountTypeEquity,
+	"income":      ledger.AccountTypeRevenue,
+	"revenue":     ledger.AccountTypeRevenue,
+	"revenues":    ledger.AccountTypeRevenue,
+	"expense":     ledger.AccountTypeExpense,
+	"expenses":    ledger.AccountTypeExpense,
+}
+
+// accountSpec derives type, group and vendor from a journal account name:
+// Root:Group:Vendor, where CamelCase groups become snake_case slugs. Names
+// with one component below the root use it as both group and vendor; deeper
+// components are folded into the vendor.
+func accountSpec(name, currency string) (ledger.Account, error) {
+	parts := strings.Split(name, ":")
+	typ, ok := accountRoots[strings.ToLower(parts[0])]
+	if !ok {
+		return ledger.Account{}, fmt.Errorf("account %s: root must be one of Assets, Liabilities, Equity, Income or Expenses", name)
+	}
+	if len(parts) < 2 {
+		return ledger.Account{}, fmt.Errorf("account %s needs at least one component below the root", name)
+	}
+	group := slug.Slugify(splitWords(parts[1], "_"))
+	vendor := parts[1]
+	if len(parts) > 2 {
+		words := make([]string, 0, len(parts)-2)
+		for _, p := range parts[2:] {
+			words = append(words, splitWords(p, " "))
+		}
+		vendor = strings.Join(words, " ")
+	}
+	if len(group) < 2 {
+		group += "_account"
+	}
+	return ledger.Account{Name: name, Currency: currency, Type: typ, Group: group, Vendor: strings.TrimSpace(vendor)}, nil
+}
+
+// splitWords separates CamelCase words: CreditCard becomes Credit<sep>Card.
+func splitWords(s, sep string) string {
+	var b strings.Builder
+	prev := rune(0)
+	for _, r := range s {
+		if unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)) {
+			b.WriteString(sep)
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// findAccount matches an existing account by type, group, slugged vendor and currency.
+func findAccount(existing []ledger.Account, spec ledger.Account) (ledger.Account, bool) {
+	for _, a := range existing {
+		if a.Type != spec.Type || !strings.EqualFold(a.Currency, spec.Currency) || !strings.EqualFold(a.Group, spec.Group) {
+			continue
+		}
+		if isSystemGroup(spec.Group) || slug.Slugify(a.Vendor) == slug.Slugify(spec.Vendor) {
+			return a, true
+		}
+	}
+	return ledger.Account{}, false
+}
+
+func isSystemGroup(g string) bool {
+	switch strings.ToLower(g) {
+	case account.GroupOpeningBalances, account.GroupRetainedEarnings, account.GroupSuspense:
+		return true
+	}
+	return false
+}
+
+func appendUnique(xs []string, s string) []string {
+	for _, x := range xs {
+		if x == s {
+			return xs
+		}
+	}
+	return append(xs, s)
+}
+
+func containsFold(xs []string, s string) bool {
+	for _, x := range xs {
+		if strings.EqualFold(x, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrUnreadable reports a journal that could not be read at all.
+var ErrUnreadable = errors.New("journal could not be read")
@@ -0,0 +1,170 @@
+// Package period implements per-user accounting periods: definition, close,
+// reopen and lock. The journal service consults periods to reject postings
+// dated inside a closed or locked period.
+package period
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+type Repo interface {
+	ListPeriods(ctx context.Context, userID uuid.UUID) ([]ledger.Period, error)
+	GetPeriod(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error)
+}
+
+type Writer interface {
+	CreatePeriod(ctx context.Context, p ledger.Period) (ledger.Period, error)
+	UpdatePeriod(ctx context.Context, p ledger.Period) (ledger.Period, error)
+}
+
+type Service interface {
+	Create(ctx context.Context, p ledger.Period) (ledger.Period, error)
+	List(ctx context.Context, userID uuid.UUID) ([]ledger.Period, error)
+	Close(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error)
+	Reopen(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error)
+	Lock(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error)
+	// CheckDate returns errs.ErrPeriodClosed when date falls inside a closed or locked period.
+	CheckDate(ctx context.Context, userID uuid.UUID, date time.Time) error
+}
+
+type service struct {
+	repo   Repo
+	writer Writer
+}
+
+func New(repo Repo, writer Writer) Service { return &service{repo: repo, writer: writer} }
+
+// Create validates and persists a new open period. Periods of a user must not overlap.
+func (s *service) Create(ctx context.Context, p ledger.Period) (ledger.Period, error) {
+	if p.UserID == uuid.Nil {
+		return ledger.Period{}, errs.ErrInvalid
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return ledger.Period{}, errors.New("name is required")
+	}
+	if p.Start.IsZero() || p.End.IsZero() || !p.Start.Before(p.End) {
+		return ledger.Period{}, errors.New("start must be before end")
+	}
+	existing, err := s.repo.ListPeriods(ctx, p.UserID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	for _, other := range existing {
+		if other.Overlaps(p) {
+			return ledger.Period{}, ErrOverlap
+		}
+	}
+	p.ID = uuid.New()
+	p.Start = p.Start.UTC()
+	p.End = p.End.UTC()
+	p.Status = ledger.PeriodStatusOpen
+	p.ClosedAt = nil
+	return s.writer.CreatePeriod(ctx, p)
+}
+
+// List returns the user's periods ordered by start.
+func (s *service) List(ctx context.Context, userID uuid.UUID) ([]ledger.Period, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	out, err := s.repo.ListPeriods(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+// Close moves an open period to closed. Closing a closed period is a no-op.
+func (s *service) Close(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	p, err := s.load(ctx, userID, periodID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	switch p.Status {
+	case ledger.PeriodStatusClosed:
+		return p, nil
+	case ledger.PeriodStatusLocked:
+		return ledger.Period{}, errs.ErrImmutable
+	}
+	now := time.Now().UTC()
+	p.Status = ledger.PeriodStatusClosed
+	p.ClosedAt = &now
+	return s.writer.UpdatePeriod(ctx, p)
+}
+
+// Reopen moves a closed period back to open. Locked periods cannot be reopened.
+func (s *service) Reopen(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	p, err := s.load(ctx, userID, periodID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	switch p.Status {
+	case ledger.PeriodStatusOpen:
+		return p, nil
+	case ledger.PeriodStatusLocked:
+		return ledger.Period{}, errs.ErrImmutable
+	}
+	p.Status = ledger.PeriodStatusOpen
+	p.ClosedAt = nil
+	return s.writer.UpdatePeriod(ctx, p)
+}
+
+// Lock permanently closes a period (open or closed). Locking is irreversible.
+func (s *service) Lock(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	p, err := s.load(ctx, userID, periodID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	if p.Status == ledger.PeriodStatusLocked {
+		return p, nil
+	}
+	now := time.Now().UTC()
+	p.Status = ledger.PeriodStatusLocked
+	p.ClosedAt = &now
+	return s.writer.UpdatePeriod(ctx, p)
+}
+
+func (s *service) CheckDate(ctx context.Context, userID uuid.UUID, date time.Time) error {
+	return CheckDate(ctx, s.repo, userID, date)
+}
+
+func (s *service) load(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	if userID == uuid.Nil || periodID == uuid.Nil {
+		return ledger.Period{}, errs.ErrInvalid
+	}
+	p, err := s.repo.GetPeriod(ctx, userID, periodID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	if p.UserID != userID {
+		return ledger.Period{}, errs.ErrForbidden
+	}
+	return p, nil
+}
+
+// CheckDate is shared with the journal service so both enforce the same rule.
+func CheckDate(ctx context.Context, repo Repo, userID uuid.UUID, date time.Time) error {
+	periods, err := repo.ListPeriods(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, p := range periods {
+		if p.Contains(date) && !p.AcceptsPostings() {
+			return errs.ErrPeriodClosed
+		}
+	}
+	return nil
+}
+
+// ErrOverlap indicates a new period intersects an existing one for the user.
+var ErrOverlap = errors.New("period overlaps an existing period")
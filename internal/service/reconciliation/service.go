@@ -0,0 +1,516 @@
+// Package reconciliation implements bank reconciliation: statement lines for an
+// asset or liability account are matched to the account's journal lines, which
+// become cleared. Finishing a reconciliation records the reconciled balance and
+// marks its lines reconciled, which blocks reversing their entries until the
+// reconciliation is reopened.
+package reconciliation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// DefaultDateWindowDays is how far apart statement and journal dates may be for auto-matching.
+const DefaultDateWindowDays = 3
+
+// referenceKeys are the entry metadata keys compared with a statement line's reference.
+var referenceKeys = []string{"tracker.source_txn_id", "tracker.bank_ref", "tracker.end_to_end_id"}
+
+type Repo interface {
+	ListReconciliations(ctx context.Context, userID uuid.UUID) ([]ledger.Reconciliation, error)
+	GetReconciliation(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error)
+}
+
+type Writer interface {
+	CreateReconciliation(ctx context.Context, r ledger.Reconciliation) (ledger.Reconciliation, error)
+	// UpdateReconciliation saves r with its statement lines and, in the same
+	// write, sets the status of the given journal lines.
+	UpdateReconciliation(ctx context.Context, r ledger.Reconciliation, lineStatus map[uuid.UUID]ledger.LineStatus) (ledger.Reconciliation, error)
+}
+
+// AccountReader resolves the reconciled account.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+}
+
+// MatchOptions tunes auto-matching.
+type MatchOptions struct {
+	// DateWindowDays bounds the day difference between matched lines; 0 uses DefaultDateWindowDays.
+	DateWindowDays int
+}
+
+type Service interface {
+	// Create starts a reconciliation with its first statement lines. A zero
+	// OpeningBalance defaults to the account's last reconciled balance.
+	Create(ctx context.Context, r ledger.Reconciliation) (ledger.Reconciliation, error)
+	// List returns the user's reconciliations, optionally for one account, newest statement first.
+	List(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) ([]ledger.Reconciliation, error)
+	Get(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error)
+	// AddLines appends statement lines to an open reconciliation.
+	AddLines(ctx context.Context, userID, reconciliationID uuid.UUID, lines []ledger.StatementLine) (ledger.Reconciliation, error)
+	// AutoMatch matches unmatched statement lines to uncleared journal lines of
+	// the same amount within the date window, preferring lines whose entry
+	// carries the statement reference, then the closest date. It returns the
+	// number of new matches.
+	AutoMatch(ctx context.Context, userID, reconciliationID uuid.UUID, opts MatchOptions) (ledger.Reconciliation, int, error)
+	// Match clears journalLineID against a statement line.
+	Match(ctx context.Context, userID, reconciliationID, statementLineID, journalLineID uuid.UUID) (ledger.Reconciliation, error)
+	// Unmatch removes a statement line's match; its journal line becomes uncleared.
+	Unmatch(ctx context.Context, userID, reconciliationID, statementLineID uuid.UUID) (ledger.Reconciliation, error)
+	// Finish requires every statement line to be matched and the opening
+	// balance plus the statement lines to equal the statement balance.
+	Finish(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error)
+	// Reopen moves the account's latest finished reconciliation back to open.
+	Reopen(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error)
+}
+
+type service struct {
+	repo     Repo
+	writer   Writer
+	journal  journal.Service
+	accounts AccountReader
+}
+
+func New(repo Repo, writer Writer, j journal.Service, accounts AccountReader) Service {
+	return &service{repo: repo, writer: writer, journal: j, accounts: accounts}
+}
+
+func (s *service) Create(ctx context.Context, r ledger.Reconciliation) (ledger.Reconciliation, error) {
+	if r.UserID == uuid.Nil || r.AccountID == uuid.Nil {
+		return ledger.Reconciliation{}, errs.ErrInvalid
+	}
+	if r.StatementDate.IsZero() {
+		return ledger.Reconciliation{}, errors.New("statement_date is required")
+	}
+	accs, err := s.accounts.FetchAccounts(ctx, r.UserID, []uuid.UUID{r.AccountID})
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	acc, ok := accs[r.AccountID]
+	if !ok {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if acc.Type != ledger.AccountTypeAsset && acc.Type != ledger.AccountTypeLiability {
+		return ledger.Reconciliation{}, ErrNotBankAccount
+	}
+	if r.StatementBalance.Curr().Code() != acc.Currency {
+		return ledger.Reconciliation{}, errs.ErrMixedCurrency
+	}
+	existing, err := s.forAccount(ctx, r.UserID, r.AccountID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	previous, err := money.NewAmountFromMinorUnits(acc.Currency, 0)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	for _, o := range existing {
+		if o.Status == ledger.ReconciliationStatusOpen {
+			return ledger.Reconciliation{}, ErrOpenReconciliation
+		}
+		if !r.StatementDate.After(o.StatementDate) {
+			return ledger.Reconciliation{}, fmt.Errorf("%w: %s", ErrStatementDate, o.StatementDate.Format("2006-01-02"))
+		}
+	}
+	if len(existing) > 0 && existing[0].ReconciledBalance != nil {
+		previous = *existing[0].ReconciledBalance
+	}
+	if r.OpeningBalance.Curr().Code() != acc.Currency || r.OpeningBalance.IsZero() {
+		r.OpeningBalance = previous
+	} else if ok, _ := r.OpeningBalance.Equal(previous); !ok && len(existing) > 0 {
+		return ledger.Reconciliation{}, fmt.Errorf("%w: last reconciled balance is %s", ErrOpeningBalance, previous.Decimal())
+	}
+	lines, err := newLines(acc.Currency, r.Lines)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	r.ID = uuid.New()
+	r.StatementDate = r.StatementDate.UTC()
+	r.Status = ledger.ReconciliationStatusOpen
+	r.ReconciledBalance = nil
+	r.FinishedAt = nil
+	r.Lines = lines
+	return s.writer.CreateReconciliation(ctx, r)
+}
+
+func (s *service) List(ctx context.Context, userID uuid.UUID, accountID *uuid.UUID) ([]ledger.Reconciliation, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	if accountID != nil {
+		return s.forAccount(ctx, userID, *accountID)
+	}
+	out, err := s.repo.ListReconciliations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sortNewestFirst(out)
+	return out, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	if userID == uuid.Nil || reconciliationID == uuid.Nil {
+		return ledger.Reconciliation{}, errs.ErrInvalid
+	}
+	return s.repo.GetReconciliation(ctx, userID, reconciliationID)
+}
+
+func (s *service) AddLines(ctx context.Context, userID, reconciliationID uuid.UUID, lines []ledger.StatementLine) (ledger.Reconciliation, error) {
+	r, err := s.loadOpen(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if len(lines) == 0 {
+		return ledger.Reconciliation{}, errors.New("lines are required")
+	}
+	added, err := newLines(r.StatementBalance.Curr().Code(), lines)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	r.Lines = append(r.Lines, added...)
+	return s.writer.UpdateReconciliation(ctx, r, nil)
+}
+
+// candidate is an uncleared journal line on the reconciled account.
+type candidate struct {
+	line  ledger.JournalLine
+	entry *ledger.JournalEntry
+	// minor is the line amount signed debit-positive.
+	minor int64
+}
+
+func (s *service) AutoMatch(ctx context.Context, userID, reconciliationID uuid.UUID, opts MatchOptions) (ledger.Reconciliation, int, error) {
+	r, err := s.loadOpen(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, 0, err
+	}
+	window := opts.DateWindowDays
+	if window < 0 {
+		return ledger.Reconciliation{}, 0, errors.New("date_window_days must not be negative")
+	}
+	if window == 0 {
+		window = DefaultDateWindowDays
+	}
+	cands, err := s.candidates(ctx, r)
+	if err != nil {
+		return ledger.Reconciliation{}, 0, err
+	}
+	taken := make(map[uuid.UUID]bool)
+	// Match in statement order so earlier statement lines take earlier postings.
+	order := make([]int, 0, len(r.Lines))
+	for i := range r.Lines {
+		if r.Lines[i].JournalLineID == nil {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return r.Lines[order[a]].Date.Before(r.Lines[order[b]].Date) })
+	status := make(map[uuid.UUID]ledger.LineStatus)
+	for _, i := range order {
+		sl := &r.Lines[i]
+		want, _ := sl.Amount.MinorUnits()
+		var best *candidate
+		bestRef, bestDays := false, 0
+		for k := range cands {
+			c := &cands[k]
+			if taken[c.line.ID] || c.minor != want {
+				continue
+			}
+			days := dayDiff(sl.Date, c.entry.Date)
+			if days > window {
+				continue
+			}
+			ref := referenceMatches(sl.Reference, c.entry)
+			if best == nil || (ref && !bestRef) || (ref == bestRef && days < bestDays) {
+				best, bestRef, bestDays = c, ref, days
+			}
+		}
+		if best == nil {
+			continue
+		}
+		id := best.line.ID
+		taken[id] = true
+		sl.JournalLineID = &id
+		status[id] = ledger.LineStatusCleared
+	}
+	if len(status) == 0 {
+		return r, 0, nil
+	}
+	saved, err := s.writer.UpdateReconciliation(ctx, r, status)
+	if err != nil {
+		return ledger.Reconciliation{}, 0, err
+	}
+	return saved, len(status), nil
+}
+
+func (s *service) Match(ctx context.Context, userID, reconciliationID, statementLineID, journalLineID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := s.loadOpen(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	sl := findLine(r, statementLineID)
+	if sl == nil {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if sl.JournalLineID != nil {
+		return ledger.Reconciliation{}, ErrAlreadyMatched
+	}
+	cands, err := s.candidates(ctx, r)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	var found *candidate
+	for k := range cands {
+		if cands[k].line.ID == journalLineID {
+			found = &cands[k]
+			break
+		}
+	}
+	if found == nil {
+		return ledger.Reconciliation{}, fmt.Errorf("%w: journal line is not an uncleared line of the account", ErrNotMatchable)
+	}
+	if want, _ := sl.Amount.MinorUnits(); want != found.minor {
+		return ledger.Reconciliation{}, fmt.Errorf("%w: amounts differ", ErrNotMatchable)
+	}
+	sl.JournalLineID = &journalLineID
+	return s.writer.UpdateReconciliation(ctx, r, map[uuid.UUID]ledger.LineStatus{journalLineID: ledger.LineStatusCleared})
+}
+
+func (s *service) Unmatch(ctx context.Context, userID, reconciliationID, statementLineID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := s.loadOpen(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	sl := findLine(r, statementLineID)
+	if sl == nil {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if sl.JournalLineID == nil {
+		return r, nil
+	}
+	id := *sl.JournalLineID
+	sl.JournalLineID = nil
+	return s.writer.UpdateReconciliation(ctx, r, map[uuid.UUID]ledger.LineStatus{id: ledger.LineStatusUncleared})
+}
+
+func (s *service) Finish(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := s.loadOpen(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	unmatched := 0
+	balance := r.OpeningBalance
+	status := make(map[uuid.UUID]ledger.LineStatus, len(r.Lines))
+	for _, sl := range r.Lines {
+		if sl.JournalLineID == nil {
+			unmatched++
+			continue
+		}
+		if balance, err = balance.Add(sl.Amount); err != nil {
+			return ledger.Reconciliation{}, err
+		}
+		status[*sl.JournalLineID] = ledger.LineStatusReconciled
+	}
+	if unmatched > 0 {
+		return ledger.Reconciliation{}, fmt.Errorf("%w: %d statement lines are unmatched", ErrUnmatchedLines, unmatched)
+	}
+	if ok, _ := balance.Equal(r.StatementBalance); !ok {
+		return ledger.Reconciliation{}, fmt.Errorf("%w: statement balance %s, reconciled %s", ErrOutOfBalance, r.StatementBalance.Decimal(), balance.Decimal())
+	}
+	now := time.Now().UTC()
+	r.Status = ledger.ReconciliationStatusFinished
+	r.ReconciledBalance = &balance
+	r.FinishedAt = &now
+	return s.writer.UpdateReconciliation(ctx, r, status)
+}
+
+func (s *service) Reopen(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := s.Get(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if r.Status == ledger.ReconciliationStatusOpen {
+		return r, nil
+	}
+	existing, err := s.forAccount(ctx, userID, r.AccountID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if len(existing) > 0 && existing[0].ID != r.ID {
+		return ledger.Reconciliation{}, ErrNotLatest
+	}
+	status := make(map[uuid.UUID]ledger.LineStatus, len(r.Lines))
+	for _, sl := range r.Lines {
+		if sl.JournalLineID != nil {
+			status[*sl.JournalLineID] = ledger.LineStatusCleared
+		}
+	}
+	r.Status = ledger.ReconciliationStatusOpen
+	r.ReconciledBalance = nil
+	r.FinishedAt = nil
+	return s.writer.UpdateReconciliation(ctx, r, status)
+}
+
+// forAccount returns the account's reconciliations, newest statement first.
+func (s *service) forAccount(ctx context.Context, userID, accountID uuid.UUID) ([]ledger.Reconciliation, error) {
+	all, err := s.repo.ListReconciliations(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ledger.Reconciliation, 0)
+	for _, r := range all {
+		if r.AccountID == accountID {
+			out = append(out, r)
+		}
+	}
+	sortNewestFirst(out)
+	return out, nil
+}
+
+func (s *service) loadOpen(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := s.Get(ctx, userID, reconciliationID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if r.Status != ledger.ReconciliationStatusOpen {
+		return ledger.Reconciliation{}, ErrFinished
+	}
+	return r, nil
+}
+
+// candidates lists the uncleared lines on r's account of entries that have
+// not been reversed.
+func (s *service) candidates(ctx context.Context, r ledger.Reconciliation) ([]candidate, error) {
+	entries, err := s.journal.ListEntries(ctx, r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]candidate, 0)
+	for i := range entries {
+		e := &entries[i]
+		if e.IsReversed {
+			continue
+		}
+		for _, ln := range e.Lines.ByID {
+			if ln.AccountID != r.AccountID || ln.ClearStatus() != ledger.LineStatusUncleared {
+				continue
+			}
+			minor, _ := ln.Amount.MinorUnits()
+			if ln.Side == ledger.SideCredit {
+				minor = -minor
+			}
+			out = append(out, candidate{line: *ln, entry: e, minor: minor})
+		}
+	}
+	// Deterministic order for ties: earliest posting first.
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].entry.Date.Equal(out[j].entry.Date) {
+			return out[i].entry.Date.Before(out[j].entry.Date)
+		}
+		return out[i].line.ID.String() < out[j].line.ID.String()
+	})
+	return out, nil
+}
+
+func newLines(currency string, in []ledger.StatementLine) ([]ledger.StatementLine, error) {
+	out := make([]ledger.StatementLine, 0, len(in))
+	for i, sl := range in {
+		if sl.Date.IsZero() {
+			return nil, fmt.Errorf("lines[%d]: date is required", i)
+		}
+		if sl.Amount.Curr().Code() != currency {
+			return nil, errs.ErrMixedCurrency
+		}
+		if sl.Amount.IsZero() {
+			return nil, fmt.Errorf("lines[%d]: %w", i, errs.ErrInvalidAmount)
+		}
+		sl.ID = uuid.New()
+		sl.Date = sl.Date.UTC()
+		sl.Description = strings.TrimSpace(sl.Description)
+		sl.Reference = strings.TrimSpace(sl.Reference)
+		sl.JournalLineID = nil
+		out = append(out, sl)
+	}
+	return out, nil
+}
+
+func findLine(r ledger.Reconciliation, id uuid.UUID) *ledger.StatementLine {
+	for i := range r.Lines {
+		if r.Lines[i].ID == id {
+			return &r.Lines[i]
+		}
+	}
+	return nil
+}
+
+func referenceMatches(ref string, e *ledger.JournalEntry) bool {
+	if ref == "" {
+		return false
+	}
+	for _, k := range referenceKeys {
+		if v := e.Metadata[k]; v != "" && strings.EqualFold(v, ref) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(e.Memo), strings.ToLower(ref))
+}
+
+// dayDiff returns the absolute number of UTC calendar days between a and b.
+func dayDiff(a, b time.Time) int {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	d := int(time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC).Sub(time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+func sortNewestFirst(rs []ledger.Reconciliation) {
+	sort.Slice(rs, func(i, j int) bool {
+		if !rs[i].StatementDate.Equal(rs[j].StatementDate) {
+			return rs[i].StatementDate.After(rs[j].StatementDate)
+		}
+		return rs[i].ID.String() < rs[j].ID.String()
+	})
+}
+
+// ErrNotBankAccount rejects reconciliations of accounts that cannot hold bank balances.
+var ErrNotBankAccount = errors.New("account must be an asset or liability account")
+
+// ErrOpenReconciliation rejects a second open reconciliation for an account.
+var ErrOpenReconciliation = errors.New("account already has an open reconciliation")
+
+// ErrStatementDate rejects statements not after the account's latest reconciliation.
+var ErrStatementDate = errors.New("statement_date must be after the latest reconciliation")
+
+// ErrOpeningBalance rejects an opening balance that differs from the last reconciled balance.
+var ErrOpeningBalance = errors.New("opening balance does not match the last reconciled balance")
+
+// ErrFinished rejects changes to a finished reconciliation; reopen it first.
+var ErrFinished = errors.New("reconciliation is finished")
+
+// ErrAlreadyMatched rejects matching a statement line that is already matched.
+var ErrAlreadyMatched = errors.New("statement line is already matched")
+
+// ErrNotMatchable rejects a manual match with an unsuitable journal line.
+var ErrNotMatchable = errors.New("journal line cannot be matched")
+
+// ErrUnmatchedLines blocks finishing while statement lines are unmatched.
+var ErrUnmatchedLines = errors.New("unmatched statement lines")
+
+// ErrOutOfBalance blocks finishing when the lines do not add up to the statement balance.
+var ErrOutOfBalance = errors.New("reconciliation does not balance")
+
+// ErrNotLatest rejects reopening a reconciliation older than the account's latest.
+var ErrNotLatest = errors.New("only the latest reconciliation of an account can be reopened")
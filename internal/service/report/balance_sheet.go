@@ -0,0 +1,100 @@
+package report
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// Computed equity groups on the balance sheet.
+const (
+	// GroupCurrentEarnings holds revenue minus expense not yet closed into retained earnings.
+	GroupCurrentEarnings = "current_earnings"
+	// GroupCrossCurrency holds the net moved into or out of the currency by
+	// cross-currency entries, which would otherwise leave the section unbalanced.
+	GroupCrossCurrency = "cross_currency"
+)
+
+// BalanceSheetSection is the statement of financial position for one currency.
+// Assets.Total equals Liabilities.Total + Equity.Total.
+type BalanceSheetSection struct {
+	Currency    string
+	Assets      StatementSection
+	Liabilities StatementSection
+	// Equity includes the computed current earnings (and cross-currency) lines.
+	Equity                    StatementSection
+	TotalLiabilitiesAndEquity int64
+}
+
+// BalanceSheet is one section per currency, ordered by currency code.
+type BalanceSheet struct {
+	AsOf     time.Time
+	Sections []BalanceSheetSection
+}
+
+// BalanceSheet builds the statement from the trial balance as of asOf (nil = now).
+func (s *service) BalanceSheet(ctx context.Context, userID uuid.UUID, asOf *time.Time) (BalanceSheet, error) {
+	if userID == uuid.Nil {
+		return BalanceSheet{}, errs.ErrInvalid
+	}
+	at := time.Now().UTC()
+	if asOf != nil {
+		at = asOf.UTC()
+	}
+	nets, err := s.journal.TrialBalance(ctx, userID, asOf)
+	if err != nil {
+		return BalanceSheet{}, err
+	}
+	accs, err := s.fetchAccounts(ctx, userID, nets)
+	if err != nil {
+		return BalanceSheet{}, err
+	}
+	byCurrency := map[string]map[uuid.UUID][]int64{}
+	for id, amt := range nets {
+		curr := amt.Curr().Code()
+		if byCurrency[curr] == nil {
+			byCurrency[curr] = map[uuid.UUID][]int64{}
+		}
+		units, _ := amt.MinorUnits()
+		byCurrency[curr][id] = []int64{units}
+	}
+	currencies := make([]string, 0, len(byCurrency))
+	for c := range byCurrency {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	out := BalanceSheet{AsOf: at, Sections: make([]BalanceSheetSection, 0, len(currencies))}
+	for _, curr := range currencies {
+		nets := byCurrency[curr]
+		sec := BalanceSheetSection{
+			Currency:    curr,
+			Assets:      buildSection(ledger.AccountTypeAsset, accs, nets, 1),
+			Liabilities: buildSection(ledger.AccountTypeLiability, accs, nets, 1),
+			Equity:      buildSection(ledger.AccountTypeEquity, accs, nets, 1),
+		}
+		// Earnings since the last year-end close: revenue less expense, credit-positive.
+		var earnings, all int64
+		for id, cols := range nets {
+			all += cols[0]
+			if t := accs[id].Type; t == ledger.AccountTypeRevenue || t == ledger.AccountTypeExpense {
+				earnings -= cols[0]
+			}
+		}
+		if earnings != 0 {
+			sec.Equity.addGroup(StatementGroup{Group: GroupCurrentEarnings, Lines: []StatementLine{{Name: "Current earnings", Amount: earnings, Computed: true}}}, 1)
+		}
+		// A single-currency book nets to zero; anything else came from cross-currency entries.
+		if all != 0 {
+			sec.Equity.addGroup(StatementGroup{Group: GroupCrossCurrency, Lines: []StatementLine{{Name: "Cross-currency balance", Amount: all, Computed: true}}}, 1)
+		}
+		sec.TotalLiabilitiesAndEquity = sec.Liabilities.Total + sec.Equity.Total
+		out.Sections = append(out.Sections, sec)
+	}
+	return out, nil
+}
@@ -0,0 +1,265 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// CashGroups are the asset groups treated as cash and cash equivalents.
+var CashGroups = []string{"bank", "cash", "wallet", "savings"}
+
+// Activity classifies a cash movement by the accounts on the other side of the entry.
+type Activity string
+
+const (
+	// ActivityOperating: revenue, expense, receivables, payables and credit cards.
+	ActivityOperating Activity = "operating"
+	// ActivityInvesting: other non-cash assets, e.g. investments.
+	ActivityInvesting Activity = "investing"
+	// ActivityFinancing: equity, loans and other liabilities.
+	ActivityFinancing Activity = "financing"
+	// ActivityTransfers: movements between cash accounts, e.g. a currency exchange.
+	ActivityTransfers Activity = "transfers"
+)
+
+// Activities lists the activities in statement order.
+var Activities = []Activity{ActivityOperating, ActivityInvesting, ActivityFinancing, ActivityTransfers}
+
+// CashFlowLine is the cash moved against one counter account (positive = inflow).
+type CashFlowLine struct {
+	AccountID uuid.UUID
+	Name      string
+	Path      string
+	Amount    int64
+}
+
+// CashFlowActivity totals the movements of one activity.
+type CashFlowActivity struct {
+	Activity Activity
+	Lines    []CashFlowLine
+	Total    int64
+}
+
+// CashFlowSection is the cash flow for one currency; Opening + NetChange = Closing.
+type CashFlowSection struct {
+	Currency   string
+	Opening    int64
+	Activities []CashFlowActivity
+	NetChange  int64
+	Closing    int64
+}
+
+// CashFlow covers entries dated in [From, To].
+type CashFlow struct {
+	From     time.Time
+	To       time.Time
+	Sections []CashFlowSection
+}
+
+// IsCashAccount reports whether a is a cash-like asset account.
+func IsCashAccount(a ledger.Account) bool {
+	if a.Type != ledger.AccountTypeAsset {
+		return false
+	}
+	for _, g := range CashGroups {
+		if strings.EqualFold(a.Group, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// activityFor classifies a non-cash counter account.
+func activityFor(a ledger.Account) Activity {
+	switch a.Type {
+	case ledger.AccountTypeRevenue, ledger.AccountTypeExpense:
+		return ActivityOperating
+	case ledger.AccountTypeAsset:
+		if strings.EqualFold(a.Group, "receivable") {
+			return ActivityOperating
+		}
+		return ActivityInvesting
+	case ledger.AccountTypeLiability:
+		if strings.EqualFold(a.Group, "credit_card") || strings.EqualFold(a.Group, "payable") {
+			return ActivityOperating
+		}
+		return ActivityFinancing
+	case ledger.AccountTypeEquity:
+		return ActivityFinancing
+	}
+	return ActivityOperating
+}
+
+// cashFlowKey aggregates movements per currency, activity and counter account.
+type cashFlowKey struct {
+	Currency string
+	Activity Activity
+	Account  uuid.UUID
+}
+
+// CashFlow classifies movements in cash accounts between from and to (inclusive).
+//
+// In a single-currency entry every non-cash line is attributed the cash it moved
+// (minus its own debit-positive amount), so an entry that pays an expense and a loan
+// splits across operating and financing exactly. When an entry spans currencies the
+// cash moved in each currency is attributed to its largest non-cash line, or to
+// transfers when only cash accounts are involved.
+func (s *service) CashFlow(ctx context.Context, userID uuid.UUID, from, to time.Time) (CashFlow, error) {
+	if userID == uuid.Nil || from.IsZero() || to.IsZero() {
+		return CashFlow{}, errs.ErrInvalid
+	}
+	from, to = from.UTC(), to.UTC()
+	if to.Before(from) {
+		return CashFlow{}, errors.New("from must not be after to")
+	}
+	// Opening balances come from the trial balance just before from; only the
+	// range's entries are read.
+	before := from.Add(-time.Nanosecond)
+	nets, err := s.journal.TrialBalance(ctx, userID, &before)
+	if err != nil {
+		return CashFlow{}, err
+	}
+	entries, err := s.journal.QueryEntries(ctx, journal.EntryFilter{UserID: userID, From: &from, To: &to})
+	if err != nil {
+		return CashFlow{}, err
+	}
+	idSet := map[uuid.UUID]struct{}{}
+	for id := range nets {
+		idSet[id] = struct{}{}
+	}
+	for _, e := range entries {
+		for _, ln := range e.Lines.ByID {
+			idSet[ln.AccountID] = struct{}{}
+		}
+	}
+	ids := make([]uuid.UUID, 0, len(idSet))
+	for id := range idSet {
+		ids = append(ids, id)
+	}
+	accs, err := s.reader.FetchAccounts(ctx, userID, ids)
+	if err != nil {
+		return CashFlow{}, err
+	}
+
+	opening := map[string]int64{}
+	closing := map[string]int64{}
+	flows := map[cashFlowKey]int64{}
+	for id, amt := range nets {
+		if !IsCashAccount(accs[id]) {
+			continue
+		}
+		curr := amt.Curr().Code()
+		units, _ := amt.MinorUnits()
+		opening[curr] += units
+		closing[curr] += units
+	}
+	for _, e := range entries {
+		cash := map[string]int64{} // cash moved per currency by this entry
+		for _, ln := range e.Lines.ByID {
+			if !IsCashAccount(accs[ln.AccountID]) {
+				continue
+			}
+			curr := ln.Amount.Curr().Code()
+			units := signedUnits(*ln)
+			closing[curr] += units
+			cash[curr] += units
+		}
+		if len(cash) == 0 {
+			continue
+		}
+		classifyEntry(e, accs, cash, flows)
+	}
+
+	currencies := make([]string, 0, len(closing))
+	for c := range closing {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	out := CashFlow{From: from, To: to}
+	for _, curr := range currencies {
+		sec := CashFlowSection{Currency: curr, Opening: opening[curr], Closing: closing[curr]}
+		for _, act := range Activities {
+			a := CashFlowActivity{Activity: act, Lines: []CashFlowLine{}}
+			for k, amt := range flows {
+				if k.Currency != curr || k.Activity != act || amt == 0 {
+					continue
+				}
+				acc := accs[k.Account]
+				a.Lines = append(a.Lines, CashFlowLine{AccountID: k.Account, Name: acc.Name, Path: acc.Path(), Amount: amt})
+				a.Total += amt
+			}
+			sort.Slice(a.Lines, func(i, j int) bool {
+				if a.Lines[i].Path != a.Lines[j].Path {
+					return a.Lines[i].Path < a.Lines[j].Path
+				}
+				return a.Lines[i].AccountID.String() < a.Lines[j].AccountID.String()
+			})
+			sec.NetChange += a.Total
+			sec.Activities = append(sec.Activities, a)
+		}
+		out.Sections = append(out.Sections, sec)
+	}
+	return out, nil
+}
+
+// classifyEntry attributes the cash an entry moved to activities and counter accounts.
+func classifyEntry(e ledger.JournalEntry, accs map[uuid.UUID]ledger.Account, cash map[string]int64, flows map[cashFlowKey]int64) {
+	var others []ledger.JournalLine
+	singleCurrency := true
+	for _, ln := range e.Lines.ByID {
+		if ln.Amount.Curr().Code() != e.Currency {
+			singleCurrency = false
+		}
+		if !IsCashAccount(accs[ln.AccountID]) {
+			others = append(others, *ln)
+		}
+	}
+	if singleCurrency && len(others) > 0 {
+		for _, ln := range others {
+			k := cashFlowKey{Currency: e.Currency, Activity: activityFor(accs[ln.AccountID]), Account: ln.AccountID}
+			flows[k] -= signedUnits(ln)
+		}
+		return
+	}
+	// Cross-currency (or cash-only) entry: one counter account per currency.
+	var largest *ledger.JournalLine
+	var largestUnits int64
+	for i := range others {
+		amt, err := others[i].EntryAmount()
+		if err != nil {
+			continue
+		}
+		units, _ := amt.MinorUnits()
+		if units > largestUnits {
+			largest, largestUnits = &others[i], units
+		}
+	}
+	for curr, units := range cash {
+		if units == 0 {
+			continue
+		}
+		k := cashFlowKey{Currency: curr, Activity: ActivityTransfers}
+		if largest != nil {
+			k.Activity, k.Account = activityFor(accs[largest.AccountID]), largest.AccountID
+		}
+		flows[k] += units
+	}
+}
+
+// signedUnits returns a line's minor units, positive for debits.
+func signedUnits(ln ledger.JournalLine) int64 {
+	units, _ := ln.Amount.MinorUnits()
+	if ln.Side == ledger.SideCredit {
+		return -units
+	}
+	return units
+}
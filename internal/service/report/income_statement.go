@@ -0,0 +1,182 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/yearend"
+)
+
+// Interval selects the column layout of a statement over a date range.
+type Interval string
+
+const (
+	// IntervalNone reports a single total for the range.
+	IntervalNone      Interval = ""
+	IntervalMonthly   Interval = "monthly"
+	IntervalQuarterly Interval = "quarterly"
+)
+
+// Column is one bucket of a statement covering [Start, End).
+type Column struct {
+	Label string
+	Start time.Time
+	End   time.Time
+}
+
+// IncomeStatementSection is the profit and loss for one currency.
+type IncomeStatementSection struct {
+	Currency string
+	Revenue  StatementSection
+	Expenses StatementSection
+	// NetIncome is revenue less expenses; NetIncomeColumns splits it per column.
+	NetIncome        int64
+	NetIncomeColumns []int64
+}
+
+// IncomeStatement nets revenue and expense activity between From and To (inclusive).
+type IncomeStatement struct {
+	From     time.Time
+	To       time.Time
+	Interval Interval
+	Columns  []Column
+	Sections []IncomeStatementSection
+}
+
+// IncomeStatement builds the P&L from entries dated in [from, to]. Reversals and
+// reclassifications are ordinary entries, so a correction dated in the range nets
+// against the original. Year-end closing entries are excluded: they move earnings
+// into retained earnings and would otherwise zero the statement.
+func (s *service) IncomeStatement(ctx context.Context, userID uuid.UUID, from, to time.Time, interval Interval) (IncomeStatement, error) {
+	if userID == uuid.Nil || from.IsZero() || to.IsZero() {
+		return IncomeStatement{}, errs.ErrInvalid
+	}
+	from, to = from.UTC(), to.UTC()
+	if to.Before(from) {
+		return IncomeStatement{}, errors.New("from must not be after to")
+	}
+	cols, err := columns(from, to, interval)
+	if err != nil {
+		return IncomeStatement{}, err
+	}
+	entries, err := s.journal.QueryEntries(ctx, journal.EntryFilter{UserID: userID, From: &from, To: &to})
+	if err != nil {
+		return IncomeStatement{}, err
+	}
+	// Debit-positive activity per currency, account and column.
+	byCurrency := map[string]map[uuid.UUID][]int64{}
+	ids := map[uuid.UUID]struct{}{}
+	for _, e := range entries {
+		// Closing entries, and the reversals that reopen a year, are not activity.
+		if e.Metadata[yearend.MetaKind] == yearend.KindYearEnd {
+			continue
+		}
+		col := columnIndex(cols, e.Date)
+		for _, ln := range e.Lines.ByID {
+			curr := ln.Amount.Curr().Code()
+			if byCurrency[curr] == nil {
+				byCurrency[curr] = map[uuid.UUID][]int64{}
+			}
+			if byCurrency[curr][ln.AccountID] == nil {
+				byCurrency[curr][ln.AccountID] = make([]int64, len(cols))
+			}
+			byCurrency[curr][ln.AccountID][col] += signedUnits(*ln)
+			ids[ln.AccountID] = struct{}{}
+		}
+	}
+	idList := make([]uuid.UUID, 0, len(ids))
+	for id := range ids {
+		idList = append(idList, id)
+	}
+	accs, err := s.reader.FetchAccounts(ctx, userID, idList)
+	if err != nil {
+		return IncomeStatement{}, err
+	}
+
+	out := IncomeStatement{From: from, To: to, Interval: interval, Columns: cols}
+	currencies := make([]string, 0, len(byCurrency))
+	for c := range byCurrency {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+	for _, curr := range currencies {
+		sec := IncomeStatementSection{
+			Currency: curr,
+			Revenue:  buildSection(ledger.AccountTypeRevenue, accs, byCurrency[curr], len(cols)),
+			Expenses: buildSection(ledger.AccountTypeExpense, accs, byCurrency[curr], len(cols)),
+		}
+		if len(sec.Revenue.Groups) == 0 && len(sec.Expenses.Groups) == 0 {
+			continue
+		}
+		sec.NetIncome = sec.Revenue.Total - sec.Expenses.Total
+		if len(cols) > 1 {
+			sec.NetIncomeColumns = make([]int64, len(cols))
+			for i := range cols {
+				sec.NetIncomeColumns[i] = columnAt(sec.Revenue.Columns, i) - columnAt(sec.Expenses.Columns, i)
+			}
+		}
+		out.Sections = append(out.Sections, sec)
+	}
+	return out, nil
+}
+
+// columns splits [from, to] into calendar months or quarters clipped to the range.
+func columns(from, to time.Time, interval Interval) ([]Column, error) {
+	end := to.Add(time.Nanosecond) // columns are half-open; to is inclusive
+	var months int
+	switch interval {
+	case IntervalNone:
+		return []Column{{Label: from.Format("2006-01-02") + ".." + to.Format("2006-01-02"), Start: from, End: end}}, nil
+	case IntervalMonthly:
+		months = 1
+	case IntervalQuarterly:
+		months = 3
+	default:
+		return nil, errors.New("invalid interval; expected monthly or quarterly")
+	}
+	// Align the first bucket to the calendar month/quarter containing from.
+	m := int(from.Month()-1) / months * months
+	start := time.Date(from.Year(), time.Month(m+1), 1, 0, 0, 0, 0, time.UTC)
+	var out []Column
+	for start.Before(end) {
+		next := start.AddDate(0, months, 0)
+		label := start.Format("2006-01")
+		if months == 3 {
+			label = strconv.Itoa(start.Year()) + "-Q" + strconv.Itoa(int(start.Month()-1)/3+1)
+		}
+		c := Column{Label: label, Start: start, End: next}
+		if c.Start.Before(from) {
+			c.Start = from
+		}
+		if c.End.After(end) {
+			c.End = end
+		}
+		out = append(out, c)
+		start = next
+	}
+	return out, nil
+}
+
+func columnIndex(cols []Column, t time.Time) int {
+	for i, c := range cols {
+		if t.Before(c.End) {
+			return i
+		}
+	}
+	return len(cols) - 1
+}
+
+func columnAt(v []int64, i int) int64 {
+	if i < len(v) {
+		return v[i]
+	}
+	return 0
+}
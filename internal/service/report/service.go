@@ -0,0 +1,164 @@
+// Package report builds financial reports on top of the journal: trial balances
+// translated into a reporting currency and the statements derived from them.
+package report
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// AccountReader loads account details for the accounts found in the journal.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+}
+
+// TranslatedAccount is an account's net (debit-positive) in its own currency and
+// translated into the reporting currency.
+type TranslatedAccount struct {
+	Account ledger.Account
+	Rate    money.ExchangeRate
+	// Net is in the account currency; ReportingNet in the reporting currency (minor units).
+	Net          int64
+	ReportingNet int64
+}
+
+// TranslationDifference balances one currency's translated accounts. Net is the
+// currency's own-currency total; Difference (reporting currency) is minus the sum of
+// its translated nets. Books without cross-currency entries net to zero per currency,
+// so the difference is only rounding; cross-currency entries move balances between
+// currencies at their booked rate and the revaluation since then shows up here.
+type TranslationDifference struct {
+	Currency   string
+	Rate       money.ExchangeRate
+	Net        int64
+	Difference int64
+}
+
+// ConsolidatedTrialBalance is the trial balance in a single reporting currency.
+// Summing ReportingNet over Accounts and Difference over Differences gives zero.
+type ConsolidatedTrialBalance struct {
+	ReportingCurrency string
+	AsOf              time.Time
+	Accounts          []TranslatedAccount
+	Differences       []TranslationDifference
+}
+
+type Service interface {
+	// ConsolidatedTrialBalance translates every account's net as of asOf (nil = now)
+	// with the rates effective on that day.
+	ConsolidatedTrialBalance(ctx context.Context, userID uuid.UUID, asOf *time.Time, reportingCurrency string) (ConsolidatedTrialBalance, error)
+	// BalanceSheet groups asset, liability and equity balances as of asOf (nil = now) per currency.
+	BalanceSheet(ctx context.Context, userID uuid.UUID, asOf *time.Time) (BalanceSheet, error)
+	// IncomeStatement nets revenue and expense activity between from and to (inclusive).
+	IncomeStatement(ctx context.Context, userID uuid.UUID, from, to time.Time, interval Interval) (IncomeStatement, error)
+	// CashFlow classifies cash account movements between from and to (inclusive) per currency.
+	CashFlow(ctx context.Context, userID uuid.UUID, from, to time.Time) (CashFlow, error)
+}
+
+type service struct {
+	journal journal.Service
+	reader  AccountReader
+	rates   fx.Service
+}
+
+// New constructs the report service; rates may be nil when the store keeps no
+// exchange rates, in which case only reporting-currency accounts can be translated.
+func New(j journal.Service, reader AccountReader, rates fx.Service) Service {
+	return &service{journal: j, reader: reader, rates: rates}
+}
+
+func (s *service) ConsolidatedTrialBalance(ctx context.Context, userID uuid.UUID, asOf *time.Time, reportingCurrency string) (ConsolidatedTrialBalance, error) {
+	rc := strings.ToUpper(strings.TrimSpace(reportingCurrency))
+	if userID == uuid.Nil || len(rc) != 3 {
+		return ConsolidatedTrialBalance{}, errs.ErrInvalid
+	}
+	at := time.Now().UTC()
+	if asOf != nil {
+		at = asOf.UTC()
+	}
+	out := ConsolidatedTrialBalance{ReportingCurrency: rc, AsOf: at}
+
+	nets, err := s.journal.TrialBalance(ctx, userID, asOf)
+	if err != nil {
+		return ConsolidatedTrialBalance{}, err
+	}
+	accs, err := s.fetchAccounts(ctx, userID, nets)
+	if err != nil {
+		return ConsolidatedTrialBalance{}, err
+	}
+	rates := map[string]money.ExchangeRate{}
+	diffs := map[string]*TranslationDifference{}
+	for id, amt := range nets {
+		acc, ok := accs[id]
+		if !ok {
+			continue
+		}
+		curr := amt.Curr().Code()
+		rate, ok := rates[curr]
+		if !ok {
+			if rate, err = s.rateAt(ctx, userID, curr, rc, at); err != nil {
+				return ConsolidatedTrialBalance{}, err
+			}
+			rates[curr] = rate
+		}
+		conv, err := rate.Conv(amt)
+		if err != nil {
+			return ConsolidatedTrialBalance{}, err
+		}
+		units, _ := amt.MinorUnits()
+		reporting, _ := conv.RoundToCurr().MinorUnits()
+		out.Accounts = append(out.Accounts, TranslatedAccount{Account: acc, Rate: rate, Net: units, ReportingNet: reporting})
+		d := diffs[curr]
+		if d == nil {
+			d = &TranslationDifference{Currency: curr, Rate: rate}
+			diffs[curr] = d
+		}
+		d.Net += units
+		d.Difference -= reporting
+	}
+	sort.Slice(out.Accounts, func(i, j int) bool {
+		a, b := out.Accounts[i].Account, out.Accounts[j].Account
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Path() != b.Path() {
+			return a.Path() < b.Path()
+		}
+		return a.ID.String() < b.ID.String()
+	})
+	for _, d := range diffs {
+		out.Differences = append(out.Differences, *d)
+	}
+	sort.Slice(out.Differences, func(i, j int) bool { return out.Differences[i].Currency < out.Differences[j].Currency })
+	return out, nil
+}
+
+// rateAt resolves curr->rc; identity needs no stored rates.
+func (s *service) rateAt(ctx context.Context, userID uuid.UUID, curr, rc string, at time.Time) (money.ExchangeRate, error) {
+	if curr == rc {
+		return money.ParseExchRate(curr, rc, "1")
+	}
+	if s.rates == nil {
+		return money.ExchangeRate{}, fx.ErrNoRate
+	}
+	return s.rates.RateAt(ctx, userID, curr, rc, at)
+}
+
+// fetchAccounts loads the accounts keyed in m.
+func (s *service) fetchAccounts(ctx context.Context, userID uuid.UUID, m map[uuid.UUID]money.Amount) (map[uuid.UUID]ledger.Account, error) {
+	ids := make([]uuid.UUID, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	return s.reader.FetchAccounts(ctx, userID, ids)
+}
@@ -0,0 +1,127 @@
+package report
+
+import (
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// StatementLine is one account (one vendor within a group) in a statement. Amounts
+// are minor units signed by the account type's normal side, so a positive asset is a
+// debit balance and a positive liability, equity or revenue is a credit balance.
+type StatementLine struct {
+	AccountID uuid.UUID
+	Name      string
+	Path      string
+	Vendor    string
+	Amount    int64
+	// Columns holds per-column amounts when the statement has columns.
+	Columns []int64
+	// Computed marks lines derived by the report rather than posted to an account.
+	Computed bool
+}
+
+// StatementGroup collects the lines of one Account.Group with a subtotal.
+type StatementGroup struct {
+	Group    string
+	Lines    []StatementLine
+	Subtotal int64
+	Columns  []int64
+}
+
+// StatementSection holds the groups of one account type with a total.
+type StatementSection struct {
+	Type    ledger.AccountType
+	Groups  []StatementGroup
+	Total   int64
+	Columns []int64
+}
+
+// normalSign is +1 for debit-normal types and -1 for credit-normal types.
+func normalSign(t ledger.AccountType) int64 {
+	switch t {
+	case ledger.AccountTypeAsset, ledger.AccountTypeExpense:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// buildSection groups accounts of type t by group and vendor. nets holds debit-positive
+// net minor units per account, one value per column; accounts whose columns are all
+// zero are omitted. Columns are only reported when there is more than one.
+func buildSection(t ledger.AccountType, accs map[uuid.UUID]ledger.Account, nets map[uuid.UUID][]int64, ncols int) StatementSection {
+	sec := StatementSection{Type: t, Groups: []StatementGroup{}}
+	byGroup := map[string]*StatementGroup{}
+	sign := normalSign(t)
+	for id, cols := range nets {
+		acc, ok := accs[id]
+		if !ok || acc.Type != t || allZero(cols) {
+			continue
+		}
+		ln := StatementLine{AccountID: id, Name: acc.Name, Path: acc.Path(), Vendor: acc.Vendor}
+		if ncols > 1 {
+			ln.Columns = make([]int64, ncols)
+		}
+		for i, v := range cols {
+			ln.Amount += sign * v
+			if ln.Columns != nil {
+				ln.Columns[i] = sign * v
+			}
+		}
+		g := byGroup[acc.Group]
+		if g == nil {
+			g = &StatementGroup{Group: acc.Group}
+			byGroup[acc.Group] = g
+		}
+		g.Lines = append(g.Lines, ln)
+	}
+	names := make([]string, 0, len(byGroup))
+	for name := range byGroup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g := byGroup[name]
+		sort.Slice(g.Lines, func(i, j int) bool {
+			if g.Lines[i].Vendor != g.Lines[j].Vendor {
+				return g.Lines[i].Vendor < g.Lines[j].Vendor
+			}
+			return g.Lines[i].AccountID.String() < g.Lines[j].AccountID.String()
+		})
+		sec.addGroup(*g, ncols)
+	}
+	return sec
+}
+
+// addGroup computes the group's subtotal and folds it into the section total.
+func (sec *StatementSection) addGroup(g StatementGroup, ncols int) {
+	g.Subtotal = 0
+	if ncols > 1 {
+		g.Columns = make([]int64, ncols)
+		if sec.Columns == nil {
+			sec.Columns = make([]int64, ncols)
+		}
+	}
+	for _, ln := range g.Lines {
+		g.Subtotal += ln.Amount
+		for i, v := range ln.Columns {
+			g.Columns[i] += v
+		}
+	}
+	sec.Total += g.Subtotal
+	for i, v := range g.Columns {
+		sec.Columns[i] += v
+	}
+	sec.Groups = append(sec.Groups, g)
+}
+
+func allZero(v []int64) bool {
+	for _, x := range v {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
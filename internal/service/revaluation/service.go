@@ -0,0 +1,428 @@
+// Package revaluation posts unrealized FX gain/loss for foreign-currency balances.
+//
+// Accounts hold a single currency, so the reporting-currency value of a foreign
+// account is tracked on a companion "FX revaluation" account in the reporting
+// currency (created on first use and linked through metadata). A run compares,
+// per foreign asset or liability account:
+//
+//	closing value   = balance × rate(as_of)
+//	booked value    = Σ line amounts at their booked rate (the line's own rate when
+//	                  the entry is in the reporting currency, else the rate on the entry date)
+//	adjustment      = closing value − booked value − companion balance
+//
+// and posts one reporting-currency entry moving the adjustment between the
+// companion accounts and the gain/loss account. Because the companion balance is
+// subtracted, re-running for the same date posts nothing unless rates changed.
+// Rates are loaded once per currency. An account lacking a rate it needs is
+// left out and reported in Result.MissingRates rather than failing the run.
+// Reverse undoes a run at the start of the next period.
+package revaluation
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/period"
+	"github.com/tinoosan/ledger/internal/service/yearend"
+)
+
+// Metadata keys stamped on revaluation entries and companion accounts.
+const (
+	MetaKind              = yearend.MetaKind
+	KindRevaluation       = "fx_revaluation"
+	MetaRevaluationDate   = "ledger.revaluation_date"
+	MetaReportingCurrency = "ledger.reporting_currency"
+	// MetaRevalues links a companion account to the foreign account it revalues.
+	MetaRevalues = "ledger.revalues"
+	// GroupFXRevaluation is the group of companion accounts.
+	GroupFXRevaluation = "fx_revaluation"
+)
+
+// ErrGainLossAccount indicates the gain/loss account is missing, inactive, not
+// equity/revenue, or not in the reporting currency.
+var ErrGainLossAccount = errors.New("invalid gain/loss account; expected an active equity or revenue account in the reporting currency")
+
+// AccountReader lists a user's accounts.
+type AccountReader interface {
+	ListAccounts(ctx context.Context, userID uuid.UUID) ([]ledger.Account, error)
+}
+
+// Request configures a revaluation run.
+type Request struct {
+	UserID            uuid.UUID
+	AsOf              time.Time
+	ReportingCurrency string
+	GainLossAccountID uuid.UUID
+}
+
+// Adjustment reports the revaluation of one foreign account. Amounts are minor units;
+// Balance is in the account currency, the others in the reporting currency. Carried is
+// the companion balance before the run and Delta the amount posted by it.
+type Adjustment struct {
+	AccountID   uuid.UUID
+	CompanionID uuid.UUID
+	Currency    string
+	Rate        money.ExchangeRate
+	Balance     int64
+	Value       int64
+	Booked      int64
+	Carried     int64
+	Delta       int64
+}
+
+// MissingRate names a rate a run needed and could not find: no Currency to
+// reporting currency rate (or its inverse) is dated on or before Date. The
+// account it was needed for is left out of the run.
+type MissingRate struct {
+	AccountID uuid.UUID
+	Currency  string
+	Date      time.Time
+}
+
+// Result describes a run. Entry is nil when nothing needed posting.
+type Result struct {
+	AsOf              time.Time
+	ReportingCurrency string
+	Adjustments       []Adjustment
+	MissingRates      []MissingRate
+	Entry             *ledger.JournalEntry
+}
+
+type Service interface {
+	Run(ctx context.Context, req Request) (Result, error)
+	// Reverse reverses every unreversed run entry for (asOf day, reporting currency).
+	// A zero date defaults to the start of the next period: the end of the accounting
+	// period containing asOf, or the first day of the following month.
+	Reverse(ctx context.Context, userID uuid.UUID, asOf time.Time, reportingCurrency string, date time.Time) ([]ledger.JournalEntry, error)
+}
+
+type service struct {
+	journal  journal.Service
+	accounts account.Service
+	rates    fx.Service
+	reader   AccountReader
+	periods  period.Repo
+}
+
+// New constructs the service; periods may be nil when the store has no accounting periods.
+func New(j journal.Service, a account.Service, rates fx.Service, reader AccountReader, periods period.Repo) Service {
+	return &service{journal: j, accounts: a, rates: rates, reader: reader, periods: periods}
+}
+
+func (s *service) Run(ctx context.Context, req Request) (Result, error) {
+	rc := strings.ToUpper(strings.TrimSpace(req.ReportingCurrency))
+	if req.UserID == uuid.Nil || req.AsOf.IsZero() || len(rc) != 3 || req.GainLossAccountID == uuid.Nil {
+		return Result{}, errs.ErrInvalid
+	}
+	asOf := req.AsOf.UTC()
+	res := Result{AsOf: asOf, ReportingCurrency: rc}
+
+	accs, err := s.reader.ListAccounts(ctx, req.UserID)
+	if err != nil {
+		return Result{}, err
+	}
+	var gainLoss *ledger.Account
+	companions := map[string]ledger.Account{} // foreign account id -> companion
+	for i := range accs {
+		a := accs[i]
+		if a.ID == req.GainLossAccountID {
+			gainLoss = &accs[i]
+		}
+		if target := a.Metadata[MetaRevalues]; target != "" {
+			companions[target] = a
+		}
+	}
+	if gainLoss == nil || !gainLoss.Active || !strings.EqualFold(gainLoss.Currency, rc) ||
+		(gainLoss.Type != ledger.AccountTypeEquity && gainLoss.Type != ledger.AccountTypeRevenue) {
+		return Result{}, ErrGainLossAccount
+	}
+
+	entries, err := s.journal.ListEntries(ctx, req.UserID)
+	if err != nil {
+		return Result{}, err
+	}
+	foreign := make([]ledger.Account, 0)
+	for _, a := range accs {
+		if strings.EqualFold(a.Currency, rc) || a.Metadata[MetaRevalues] != "" {
+			continue
+		}
+		if a.Type != ledger.AccountTypeAsset && a.Type != ledger.AccountTypeLiability {
+			// Income, expense and equity stay at historical rates.
+			continue
+		}
+		foreign = append(foreign, a)
+	}
+	sort.Slice(foreign, func(i, j int) bool { return foreign[i].ID.String() < foreign[j].ID.String() })
+
+	// Rates are loaded once per currency and shared by its accounts.
+	tables := map[string]fx.Table{}
+	for _, a := range foreign {
+		curr := strings.ToUpper(a.Currency)
+		table, ok := tables[curr]
+		if !ok {
+			if table, err = s.rates.Table(ctx, req.UserID, curr, rc); err != nil {
+				return Result{}, err
+			}
+			tables[curr] = table
+		}
+		adj, missing, err := revalue(a, table, rc, asOf, entries, companions[a.ID.String()].ID)
+		if err != nil {
+			return Result{}, err
+		}
+		if missing != nil {
+			res.MissingRates = append(res.MissingRates, *missing)
+			continue
+		}
+		if adj.Balance == 0 && adj.Booked == 0 && adj.Carried == 0 {
+			continue
+		}
+		res.Adjustments = append(res.Adjustments, adj)
+	}
+
+	lines := ledger.JournalLines{ByID: map[uuid.UUID]*ledger.JournalLine{}}
+	var total int64 // net debit posted to companion accounts
+	for i := range res.Adjustments {
+		adj := &res.Adjustments[i]
+		if adj.Delta == 0 {
+			continue
+		}
+		if adj.CompanionID == uuid.Nil {
+			c, err := s.ensureCompanion(ctx, req.UserID, rc, foreignByID(foreign, adj.AccountID))
+			if err != nil {
+				return Result{}, err
+			}
+			adj.CompanionID = c.ID
+		}
+		if err := addLine(lines, adj.CompanionID, rc, adj.Delta); err != nil {
+			return Result{}, err
+		}
+		total += adj.Delta
+	}
+	if len(lines.ByID) == 0 {
+		return res, nil
+	}
+	if total != 0 {
+		if err := addLine(lines, gainLoss.ID, rc, -total); err != nil {
+			return Result{}, err
+		}
+	}
+	day := fx.Day(asOf).Format("2006-01-02")
+	entry := ledger.JournalEntry{
+		UserID:   req.UserID,
+		Date:     asOf,
+		Currency: rc,
+		Memo:     "FX revaluation " + day,
+		Category: ledger.CategoryGeneral,
+		Metadata: meta.Metadata{MetaKind: KindRevaluation, MetaRevaluationDate: day, MetaReportingCurrency: rc},
+		Lines:    lines,
+	}
+	if err := s.journal.ValidateEntry(ctx, entry); err != nil {
+		return Result{}, err
+	}
+	created, err := s.journal.CreateEntry(ctx, entry)
+	if err != nil {
+		return Result{}, err
+	}
+	res.Entry = &created
+	return res, nil
+}
+
+// revalue computes the adjustment for one foreign account as of asOf, with
+// rates from table. When a rate is missing it reports the earliest date one
+// is needed for instead.
+func revalue(a ledger.Account, table fx.Table, rc string, asOf time.Time, entries []ledger.JournalEntry, companionID uuid.UUID) (Adjustment, *MissingRate, error) {
+	adj := Adjustment{AccountID: a.ID, CompanionID: companionID, Currency: strings.ToUpper(a.Currency)}
+	var missing *MissingRate
+	noRate := func(at time.Time) {
+		if missing == nil || at.Before(missing.Date) {
+			missing = &MissingRate{AccountID: a.ID, Currency: adj.Currency, Date: fx.Day(at)}
+		}
+	}
+	for _, e := range entries {
+		if e.Date.After(asOf) {
+			continue
+		}
+		for _, ln := range e.Lines.ByID {
+			switch ln.AccountID {
+			case a.ID:
+				units, _ := ln.Amount.MinorUnits()
+				booked, err := bookedValue(table, e, *ln, rc)
+				if errors.Is(err, fx.ErrNoRate) {
+					noRate(e.Date)
+					continue
+				}
+				if err != nil {
+					return Adjustment{}, nil, err
+				}
+				if ln.Side == ledger.SideCredit {
+					units, booked = -units, -booked
+				}
+				adj.Balance += units
+				adj.Booked += booked
+			case companionID:
+				units, _ := ln.Amount.MinorUnits()
+				if ln.Side == ledger.SideCredit {
+					units = -units
+				}
+				adj.Carried += units
+			}
+		}
+	}
+	if adj.Balance == 0 && adj.Booked == 0 && adj.Carried == 0 && missing == nil {
+		return adj, nil, nil
+	}
+	rate, err := table.At(asOf)
+	if errors.Is(err, fx.ErrNoRate) {
+		noRate(asOf)
+		return Adjustment{}, missing, nil
+	}
+	if err != nil {
+		return Adjustment{}, nil, err
+	}
+	if missing != nil {
+		return Adjustment{}, missing, nil
+	}
+	adj.Rate = rate
+	value, err := convert(rate, adj.Currency, adj.Balance)
+	if err != nil {
+		return Adjustment{}, nil, err
+	}
+	adj.Value = value
+	adj.Delta = adj.Value - adj.Booked - adj.Carried
+	return adj, nil, nil
+}
+
+// bookedValue returns the reporting-currency value of a line at the rate it
+// was booked: its own rate when the entry is in the reporting currency, else
+// the table's rate on the entry date.
+func bookedValue(table fx.Table, e ledger.JournalEntry, ln ledger.JournalLine, rc string) (int64, error) {
+	if strings.EqualFold(e.Currency, rc) && ln.Rate != nil {
+		amt, err := ln.EntryAmount()
+		if err != nil {
+			return 0, err
+		}
+		units, _ := amt.MinorUnits()
+		return units, nil
+	}
+	rate, err := table.At(e.Date)
+	if err != nil {
+		return 0, err
+	}
+	units, _ := ln.Amount.MinorUnits()
+	return convert(rate, ln.Amount.Curr().Code(), units)
+}
+
+// ensureCompanion creates the reporting-currency account that carries a foreign account's revaluation.
+func (s *service) ensureCompanion(ctx context.Context, userID uuid.UUID, rc string, foreign ledger.Account) (ledger.Account, error) {
+	return s.accounts.Create(ctx, ledger.Account{
+		UserID:   userID,
+		Name:     foreign.Name + " FX revaluation",
+		Currency: rc,
+		Type:     foreign.Type,
+		Group:    GroupFXRevaluation,
+		Vendor:   foreign.Vendor + " " + strings.ToUpper(foreign.Currency) + " " + foreign.Group,
+		Metadata: meta.Metadata{MetaRevalues: foreign.ID.String()},
+	})
+}
+
+func (s *service) Reverse(ctx context.Context, userID uuid.UUID, asOf time.Time, reportingCurrency string, date time.Time) ([]ledger.JournalEntry, error) {
+	rc := strings.ToUpper(strings.TrimSpace(reportingCurrency))
+	if userID == uuid.Nil || asOf.IsZero() || len(rc) != 3 {
+		return nil, errs.ErrInvalid
+	}
+	asOf = asOf.UTC()
+	if date.IsZero() {
+		next, err := s.nextPeriodStart(ctx, userID, asOf)
+		if err != nil {
+			return nil, err
+		}
+		date = next
+	}
+	entries, err := s.journal.ListEntries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	day := fx.Day(asOf).Format("2006-01-02")
+	var out []ledger.JournalEntry
+	for _, e := range entries {
+		if e.IsReversed || e.Metadata[MetaKind] != KindRevaluation || e.Metadata[MetaRevaluationDate] != day || e.Metadata[MetaReportingCurrency] != rc {
+			continue
+		}
+		rev, err := s.journal.ReverseEntry(ctx, userID, e.ID, date.UTC())
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rev)
+	}
+	if len(out) == 0 {
+		return nil, errs.ErrNotFound
+	}
+	return out, nil
+}
+
+// nextPeriodStart returns the end of the accounting period containing t, or the first
+// day of the following month when no period covers t.
+func (s *service) nextPeriodStart(ctx context.Context, userID uuid.UUID, t time.Time) (time.Time, error) {
+	if s.periods != nil {
+		periods, err := s.periods.ListPeriods(ctx, userID)
+		if err != nil {
+			return time.Time{}, err
+		}
+		for _, p := range periods {
+			if p.Contains(t) {
+				return p.End.UTC(), nil
+			}
+		}
+	}
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// convert applies rate to minor units of curr and rounds to the quote currency.
+func convert(rate money.ExchangeRate, curr string, units int64) (int64, error) {
+	amt, err := money.NewAmountFromMinorUnits(curr, units)
+	if err != nil {
+		return 0, err
+	}
+	v, err := rate.Conv(amt)
+	if err != nil {
+		return 0, err
+	}
+	out, _ := v.RoundToCurr().MinorUnits()
+	return out, nil
+}
+
+// addLine appends a line for signed units: positive debits, negative credits.
+func addLine(lines ledger.JournalLines, accountID uuid.UUID, curr string, units int64) error {
+	side := ledger.SideDebit
+	if units < 0 {
+		side, units = ledger.SideCredit, -units
+	}
+	amt, err := money.NewAmountFromMinorUnits(curr, units)
+	if err != nil {
+		return err
+	}
+	id := uuid.New()
+	lines.ByID[id] = &ledger.JournalLine{ID: id, AccountID: accountID, Side: side, Amount: amt}
+	return nil
+}
+
+func foreignByID(accs []ledger.Account, id uuid.UUID) ledger.Account {
+	for _, a := range accs {
+		if a.ID == id {
+			return a
+		}
+	}
+	return ledger.Account{}
+}
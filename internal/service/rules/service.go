@@ -0,0 +1,364 @@
+// Package rules implements per-user auto-categorization rules. Rules are
+// evaluated in priority order against draft entries before validation and can
+// rewrite the category, move the counter lines to another account, and add
+// metadata.
+package rules
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// MetaRuleIDs lists the rules applied to an entry (comma separated, evaluation order).
+const MetaRuleIDs = "ledger.rule_ids"
+
+type Repo interface {
+	ListRules(ctx context.Context, userID uuid.UUID) ([]ledger.Rule, error)
+	GetRule(ctx context.Context, userID, ruleID uuid.UUID) (ledger.Rule, error)
+}
+
+type Writer interface {
+	CreateRule(ctx context.Context, r ledger.Rule) (ledger.Rule, error)
+	UpdateRule(ctx context.Context, r ledger.Rule) (ledger.Rule, error)
+	DeleteRule(ctx context.Context, userID, ruleID uuid.UUID) error
+}
+
+// AccountReader resolves the accounts referenced by a rule.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+}
+
+// Match is the dry-run outcome for one existing entry.
+type Match struct {
+	Entry   ledger.JournalEntry
+	Result  ledger.JournalEntry
+	RuleIDs []uuid.UUID
+}
+
+type Service interface {
+	Create(ctx context.Context, r ledger.Rule) (ledger.Rule, error)
+	// List returns rules in evaluation order.
+	List(ctx context.Context, userID uuid.UUID) ([]ledger.Rule, error)
+	Get(ctx context.Context, userID, ruleID uuid.UUID) (ledger.Rule, error)
+	Update(ctx context.Context, r ledger.Rule) (ledger.Rule, error)
+	Delete(ctx context.Context, userID, ruleID uuid.UUID) error
+	// Apply runs the user's active rules over a draft entry and returns the
+	// rewritten draft with the IDs of the rules that matched.
+	Apply(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, []uuid.UUID, error)
+	// DryRun evaluates rules against the user's existing entries without changing
+	// them. A non-nil draft is evaluated on its own instead of the stored rules.
+	DryRun(ctx context.Context, userID uuid.UUID, draft *ledger.Rule) ([]Match, error)
+}
+
+type service struct {
+	repo     Repo
+	writer   Writer
+	journal  journal.Service
+	accounts AccountReader
+}
+
+func New(repo Repo, writer Writer, j journal.Service, accounts AccountReader) Service {
+	return &service{repo: repo, writer: writer, journal: j, accounts: accounts}
+}
+
+func (s *service) Create(ctx context.Context, r ledger.Rule) (ledger.Rule, error) {
+	if err := s.validate(ctx, &r); err != nil {
+		return ledger.Rule{}, err
+	}
+	r.ID = uuid.New()
+	return s.writer.CreateRule(ctx, r)
+}
+
+func (s *service) List(ctx context.Context, userID uuid.UUID) ([]ledger.Rule, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	out, err := s.repo.ListRules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sortRules(out)
+	return out, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, ruleID uuid.UUID) (ledger.Rule, error) {
+	if userID == uuid.Nil || ruleID == uuid.Nil {
+		return ledger.Rule{}, errs.ErrInvalid
+	}
+	return s.repo.GetRule(ctx, userID, ruleID)
+}
+
+func (s *service) Update(ctx context.Context, r ledger.Rule) (ledger.Rule, error) {
+	if _, err := s.Get(ctx, r.UserID, r.ID); err != nil {
+		return ledger.Rule{}, err
+	}
+	if err := s.validate(ctx, &r); err != nil {
+		return ledger.Rule{}, err
+	}
+	return s.writer.UpdateRule(ctx, r)
+}
+
+func (s *service) Delete(ctx context.Context, userID, ruleID uuid.UUID) error {
+	if userID == uuid.Nil || ruleID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteRule(ctx, userID, ruleID)
+}
+
+func (s *service) Apply(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, []uuid.UUID, error) {
+	if e.UserID == uuid.Nil {
+		return e, nil, nil
+	}
+	list, err := s.List(ctx, e.UserID)
+	if err != nil {
+		return e, nil, err
+	}
+	compiled, err := compileAll(list)
+	if err != nil {
+		return e, nil, err
+	}
+	out, ids := apply(e, compiled)
+	return out, ids, nil
+}
+
+func (s *service) DryRun(ctx context.Context, userID uuid.UUID, draft *ledger.Rule) ([]Match, error) {
+	var list []ledger.Rule
+	if draft != nil {
+		d := *draft
+		d.UserID = userID
+		d.Active = true
+		if err := s.validate(ctx, &d); err != nil {
+			return nil, err
+		}
+		list = []ledger.Rule{d}
+	} else {
+		var err error
+		if list, err = s.List(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	compiled, err := compileAll(list)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.journal.ListEntries(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Match, 0)
+	for _, e := range entries {
+		res, ids := apply(e, compiled)
+		if len(ids) > 0 {
+			out = append(out, Match{Entry: e, Result: res, RuleIDs: ids})
+		}
+	}
+	return out, nil
+}
+
+// validate normalizes a rule and checks that it is well formed and that the
+// accounts it references belong to the user.
+func (s *service) validate(ctx context.Context, r *ledger.Rule) error {
+	if r.UserID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	m, a := r.Match, r.Actions
+	if m.MemoPattern != "" {
+		if _, err := regexp.Compile("(?i)" + m.MemoPattern); err != nil {
+			return errors.New("invalid memo_pattern: " + err.Error())
+		}
+	}
+	if m.MinAmountMinor != nil && m.MaxAmountMinor != nil && *m.MinAmountMinor > *m.MaxAmountMinor {
+		return errors.New("min_amount_minor must not exceed max_amount_minor")
+	}
+	if a.Category == "" && a.CounterAccountID == nil && len(a.Metadata) == 0 {
+		return errors.New("at least one action is required")
+	}
+	if err := meta.New(a.Metadata).Validate(); err != nil {
+		return err
+	}
+	ids := make([]uuid.UUID, 0, 2)
+	if m.SourceAccountID != nil {
+		ids = append(ids, *m.SourceAccountID)
+	}
+	if a.CounterAccountID != nil {
+		if m.SourceAccountID == nil {
+			return errors.New("counter_account_id requires source_account_id")
+		}
+		if *a.CounterAccountID == *m.SourceAccountID {
+			return errors.New("counter_account_id must differ from source_account_id")
+		}
+		ids = append(ids, *a.CounterAccountID)
+	}
+	if len(ids) > 0 {
+		accs, err := s.accounts.FetchAccounts(ctx, r.UserID, ids)
+		if err != nil {
+			return err
+		}
+		if len(accs) != len(ids) {
+			return errors.New("unknown or unauthorized accounts")
+		}
+	}
+	return nil
+}
+
+type compiledRule struct {
+	rule ledger.Rule
+	memo *regexp.Regexp
+}
+
+func compileAll(list []ledger.Rule) ([]compiledRule, error) {
+	out := make([]compiledRule, 0, len(list))
+	for _, r := range list {
+		if !r.Active {
+			continue
+		}
+		c := compiledRule{rule: r}
+		if r.Match.MemoPattern != "" {
+			re, err := regexp.Compile("(?i)" + r.Match.MemoPattern)
+			if err != nil {
+				return nil, err
+			}
+			c.memo = re
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// apply evaluates compiled rules in order against a copy of e.
+func apply(e ledger.JournalEntry, rules []compiledRule) (ledger.JournalEntry, []uuid.UUID) {
+	out := e
+	out.Metadata = e.Metadata.Clone()
+	out.Lines = ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(e.Lines.ByID))}
+	for id, ln := range e.Lines.ByID {
+		cp := *ln
+		out.Lines.ByID[id] = &cp
+	}
+	var ids []uuid.UUID
+	for _, c := range rules {
+		if !c.matches(out) {
+			continue
+		}
+		ids = append(ids, c.rule.ID)
+		a := c.rule.Actions
+		if a.Category != "" {
+			out.Category = a.Category
+		}
+		if a.CounterAccountID != nil {
+			if ln := counterLine(out, *c.rule.Match.SourceAccountID); ln != nil {
+				ln.AccountID = *a.CounterAccountID
+			}
+		}
+		out.Metadata.Merge(meta.New(a.Metadata))
+		if c.rule.Stop {
+			break
+		}
+	}
+	if len(ids) > 0 {
+		out.Metadata.Set(MetaRuleIDs, joinIDs(ids))
+	}
+	return out, ids
+}
+
+// counterLine returns the one line of e not on the source account; nil when
+// there are several, since the rule cannot tell which of them to move.
+func counterLine(e ledger.JournalEntry, source uuid.UUID) *ledger.JournalLine {
+	var found *ledger.JournalLine
+	for _, ln := range e.Lines.ByID {
+		if ln.AccountID == source {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = ln
+	}
+	return found
+}
+
+func (c compiledRule) matches(e ledger.JournalEntry) bool {
+	m := c.rule.Match
+	if c.memo != nil && !c.memo.MatchString(e.Memo) {
+		return false
+	}
+	for k, v := range m.Metadata {
+		got, ok := e.Metadata[k]
+		if !ok || (v != "" && got != v) {
+			return false
+		}
+	}
+	var amount int64
+	if m.SourceAccountID != nil {
+		found := false
+		for _, ln := range e.Lines.ByID {
+			if ln.AccountID == *m.SourceAccountID {
+				units, _ := ln.Amount.MinorUnits()
+				amount += units
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	} else {
+		for _, ln := range e.Lines.ByID {
+			if ln.Side == ledger.SideDebit {
+				amt, err := ln.EntryAmount()
+				if err != nil {
+					return false
+				}
+				units, _ := amt.MinorUnits()
+				amount += units
+			}
+		}
+	}
+	if m.MinAmountMinor != nil && amount < *m.MinAmountMinor {
+		return false
+	}
+	if m.MaxAmountMinor != nil && amount > *m.MaxAmountMinor {
+		return false
+	}
+	return true
+}
+
+// joinIDs joins rule IDs, dropping trailing ones that would exceed the metadata value limit.
+func joinIDs(ids []uuid.UUID) string {
+	var b strings.Builder
+	for _, id := range ids {
+		s := id.String()
+		if b.Len() > 0 {
+			if b.Len()+1+len(s) > meta.MaxValLen {
+				break
+			}
+			b.WriteByte(',')
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func sortRules(list []ledger.Rule) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority < list[j].Priority
+		}
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID.String() < list[j].ID.String()
+	})
+}
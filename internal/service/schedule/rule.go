@@ -0,0 +1,240 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base unit a Rule repeats on.
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+// Rule is a subset of RFC 5545 RRULE:
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY|YEARLY  (required)
+//	INTERVAL=n                        every n-th period (default 1)
+//	BYDAY=MO,WE,FR                    weekly only; defaults to the start weekday
+//	BYMONTHDAY=1,15,-1                monthly only; -1 is the last day; defaults to the start day
+//	COUNT=n                           stop after n occurrences
+//
+// Unlike RRULE, month days past the end of a month clamp to its last day, so
+// BYMONTHDAY=31 fires on Feb 28/29 instead of skipping February.
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxPeriods bounds iteration so a malformed rule cannot spin forever.
+const maxPeriods = 100000
+
+// ParseRule parses an RRULE-like string. The bare words daily, weekly, monthly
+// and yearly are accepted as shorthand for FREQ=<word>.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return Rule{}, errors.New("rule is required")
+	}
+	if !strings.Contains(s, "=") {
+		s = "FREQ=" + s
+	}
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return Rule{}, fmt.Errorf("invalid rule part %q", part)
+		}
+		k, v = strings.ToUpper(strings.TrimSpace(k)), strings.ToUpper(strings.TrimSpace(v))
+		switch k {
+		case "FREQ":
+			switch f := Frequency(v); f {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = f
+			default:
+				return Rule{}, fmt.Errorf("unsupported FREQ %q", v)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return Rule{}, errors.New("INTERVAL must be a positive integer")
+			}
+			r.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return Rule{}, errors.New("COUNT must be a positive integer")
+			}
+			r.Count = n
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				wd, ok := weekdays[d]
+				if !ok {
+					return Rule{}, fmt.Errorf("invalid BYDAY %q", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return Rule{}, fmt.Errorf("invalid BYMONTHDAY %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		default:
+			return Rule{}, fmt.Errorf("unsupported rule part %q", k)
+		}
+	}
+	if r.Freq == "" {
+		return Rule{}, errors.New("FREQ is required")
+	}
+	if len(r.ByDay) > 0 && r.Freq != FreqWeekly {
+		return Rule{}, errors.New("BYDAY is only supported with FREQ=WEEKLY")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != FreqMonthly {
+		return Rule{}, errors.New("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	return r, nil
+}
+
+// String renders the rule in canonical form.
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, strings.ToUpper(wd.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, d := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(d))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns up to limit occurrences of the rule anchored at start that fall
+// in [from, until]. A nil until means no upper bound; limit <= 0 means no limit
+// (only sensible with an until or COUNT).
+func (r Rule) Between(start, from time.Time, until *time.Time, limit int) []time.Time {
+	start = start.UTC()
+	out := make([]time.Time, 0)
+	seen := 0
+	for k := 0; k < maxPeriods; k++ {
+		for _, t := range r.period(start, k) {
+			if t.Before(start) {
+				continue
+			}
+			if until != nil && t.After(*until) {
+				return out
+			}
+			seen++
+			if r.Count > 0 && seen > r.Count {
+				return out
+			}
+			if t.Before(from) {
+				continue
+			}
+			out = append(out, t)
+			if limit > 0 && len(out) >= limit {
+				return out
+			}
+		}
+	}
+	return out
+}
+
+// period returns the sorted candidate occurrences of the k-th period after start.
+func (r Rule) period(start time.Time, k int) []time.Time {
+	n := k * r.Interval
+	h, m, s := start.Clock()
+	at := func(y int, mo time.Month, d int) time.Time {
+		return time.Date(y, mo, d, h, m, s, 0, time.UTC)
+	}
+	switch r.Freq {
+	case FreqDaily:
+		return []time.Time{start.AddDate(0, 0, n)}
+	case FreqWeekly:
+		// Weeks start on Monday.
+		offset := (int(start.Weekday()) + 6) % 7
+		monday := start.AddDate(0, 0, -offset+7*n)
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		out := make([]time.Time, 0, len(days))
+		for _, wd := range days {
+			out = append(out, monday.AddDate(0, 0, (int(wd)+6)%7))
+		}
+		sortTimes(out)
+		return out
+	case FreqMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		out := make([]time.Time, 0, len(days))
+		seen := map[int]bool{}
+		for _, d := range days {
+			if d < 0 {
+				d = last + d + 1
+				if d < 1 {
+					d = 1
+				}
+			}
+			if d > last {
+				d = last
+			}
+			if !seen[d] {
+				seen[d] = true
+				out = append(out, at(first.Year(), first.Month(), d))
+			}
+		}
+		sortTimes(out)
+		return out
+	default: // FreqYearly
+		y := start.Year() + n
+		last := time.Date(y, start.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		d := start.Day()
+		if d > last {
+			d = last
+		}
+		return []time.Time{at(y, start.Month(), d)}
+	}
+}
+
+func sortTimes(ts []time.Time) {
+	sort.Slice(ts, func(i, j int) bool { return ts[i].Before(ts[j]) })
+}
@@ -0,0 +1,66 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func day(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 9, 0, 0, 0, time.UTC) }
+
+func TestRuleMonthlyClampsToMonthEnd(t *testing.T) {
+	r, err := ParseRule("FREQ=MONTHLY;BYMONTHDAY=31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Between(day(2024, time.January, 1), day(2024, time.January, 1), nil, 3)
+	want := []time.Time{day(2024, time.January, 31), day(2024, time.February, 29), day(2024, time.March, 31)}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("occurrence %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRuleWeeklyByDayIntervalAndCount(t *testing.T) {
+	r, err := ParseRule("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2025-01-01 is a Wednesday: Friday of week 0, then Monday/Friday two weeks later.
+	got := r.Between(day(2025, time.January, 1), time.Time{}, nil, 0)
+	want := []time.Time{day(2025, time.January, 3), day(2025, time.January, 13), day(2025, time.January, 17)}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i := range want {
+		if !got[i].Equal(want[i]) {
+			t.Fatalf("occurrence %d: got %v want %v", i, got[i], want[i])
+		}
+	}
+	if r.String() != "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3" {
+		t.Fatalf("unexpected canonical form %q", r.String())
+	}
+}
+
+func TestRuleFromAndUntil(t *testing.T) {
+	r, err := ParseRule("monthly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	until := day(2025, time.June, 15)
+	got := r.Between(day(2025, time.January, 15), day(2025, time.March, 1), &until, 0)
+	if len(got) != 4 || !got[0].Equal(day(2025, time.March, 15)) || !got[3].Equal(until) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestParseRuleRejectsInvalid(t *testing.T) {
+	for _, s := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;BYDAY=MO", "FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=WEEKLY;INTERVAL=0", "INTERVAL=2"} {
+		if _, err := ParseRule(s); err == nil {
+			t.Fatalf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,416 @@
+// Package schedule implements recurring journal entries: a schedule stores an
+// entry template and a recurrence rule, and RunDue posts every occurrence that
+// has come due through the journal service.
+//
+// Each occurrence is posted at most once: a run holds the schedule's lock while
+// it posts, each occurrence is guarded by an idempotency key
+// ("schedule:<id>:<occurrence>") and the posted entry is tagged with the
+// schedule id and occurrence, so overlapping runs or a restart at any point of
+// a run never double post.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// Metadata keys stamped on posted occurrences.
+const (
+	MetaScheduleID = "ledger.schedule_id"
+	MetaOccurrence = "ledger.occurrence"
+)
+
+// MaxCatchUp bounds how many occurrences of one schedule a single run posts, so a
+// schedule with a start far in the past catches up over several runs.
+const MaxCatchUp = 100
+
+type Repo interface {
+	ListSchedules(ctx context.Context, userID uuid.UUID) ([]ledger.Schedule, error)
+	GetSchedule(ctx context.Context, userID, scheduleID uuid.UUID) (ledger.Schedule, error)
+	// ListDueSchedules returns active schedules of all users whose NextRun is at or before now.
+	ListDueSchedules(ctx context.Context, now time.Time) ([]ledger.Schedule, error)
+}
+
+type Writer interface {
+	CreateSchedule(ctx context.Context, s ledger.Schedule) (ledger.Schedule, error)
+	UpdateSchedule(ctx context.Context, s ledger.Schedule) (ledger.Schedule, error)
+	// AdvanceSchedule sets a schedule's NextRun and LastError if its NextRun is
+	// still from; an edit made meanwhile keeps its own cursor.
+	AdvanceSchedule(ctx context.Context, userID, scheduleID uuid.UUID, from, next *time.Time, lastError string) error
+	DeleteSchedule(ctx context.Context, userID, scheduleID uuid.UUID) error
+}
+
+// Locker is optionally implemented by repos that lock schedules across
+// processes; without it runs are only serialized within this service.
+type Locker interface {
+	// TryLockSchedule takes the schedule's lock without waiting; ok is false
+	// when another run holds it. unlock releases the lock.
+	TryLockSchedule(ctx context.Context, scheduleID uuid.UUID) (unlock func(), ok bool, err error)
+}
+
+// IdempotencyStore maps occurrence keys to posted entries.
+type IdempotencyStore interface {
+	GetEntryByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) (ledger.JournalEntry, bool, error)
+	SaveIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, entryID uuid.UUID) error
+}
+
+// RunError reports a schedule whose due occurrence could not be posted.
+type RunError struct {
+	ScheduleID uuid.UUID
+	Occurrence time.Time
+	Err        error
+}
+
+// RunResult summarizes one RunDue pass.
+type RunResult struct {
+	Posted []ledger.JournalEntry
+	Errors []RunError
+}
+
+type Service interface {
+	Create(ctx context.Context, s ledger.Schedule) (ledger.Schedule, error)
+	List(ctx context.Context, userID uuid.UUID) ([]ledger.Schedule, error)
+	Get(ctx context.Context, userID, scheduleID uuid.UUID) (ledger.Schedule, error)
+	// Update replaces name, rule, start, until, template and active. Occurrences
+	// already posted are not posted again.
+	Update(ctx context.Context, s ledger.Schedule) (ledger.Schedule, error)
+	Delete(ctx context.Context, userID, scheduleID uuid.UUID) error
+	// Preview lists the next n occurrences at or after from without posting them.
+	Preview(ctx context.Context, userID, scheduleID uuid.UUID, from time.Time, n int) ([]time.Time, error)
+	// RunDue posts every occurrence at or before now for all users' active schedules.
+	RunDue(ctx context.Context, now time.Time) (RunResult, error)
+}
+
+type service struct {
+	repo    Repo
+	writer  Writer
+	journal journal.Service
+	idem    IdempotencyStore
+	// locker is set when the repo locks schedules across processes.
+	locker Locker
+	// running holds schedules being posted by this service, for repos without a Locker.
+	mu      sync.Mutex
+	running map[uuid.UUID]struct{}
+}
+
+func New(repo Repo, writer Writer, j journal.Service, idem IdempotencyStore) Service {
+	s := &service{repo: repo, writer: writer, journal: j, idem: idem, running: make(map[uuid.UUID]struct{})}
+	if l, ok := repo.(Locker); ok {
+		s.locker = l
+	}
+	return s
+}
+
+func (s *service) Create(ctx context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	if sc.UserID == uuid.Nil {
+		return ledger.Schedule{}, errs.ErrInvalid
+	}
+	sc.ID = uuid.New()
+	sc.Active = true
+	if err := s.prepare(ctx, &sc, sc.Start); err != nil {
+		return ledger.Schedule{}, err
+	}
+	return s.writer.CreateSchedule(ctx, sc)
+}
+
+// List returns the user's schedules ordered by name.
+func (s *service) List(ctx context.Context, userID uuid.UUID) ([]ledger.Schedule, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	out, err := s.repo.ListSchedules(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+func (s *service) Get(ctx context.Context, userID, scheduleID uuid.UUID) (ledger.Schedule, error) {
+	if userID == uuid.Nil || scheduleID == uuid.Nil {
+		return ledger.Schedule{}, errs.ErrInvalid
+	}
+	return s.repo.GetSchedule(ctx, userID, scheduleID)
+}
+
+func (s *service) Update(ctx context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	cur, err := s.Get(ctx, sc.UserID, sc.ID)
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	// Resume from the old cursor; earlier occurrences were already handled.
+	from := sc.Start
+	if cur.NextRun != nil && cur.NextRun.After(from) {
+		from = *cur.NextRun
+	} else if cur.NextRun == nil {
+		if now := time.Now().UTC(); now.After(from) {
+			from = now
+		}
+	}
+	if err := s.prepare(ctx, &sc, from); err != nil {
+		return ledger.Schedule{}, err
+	}
+	return s.writer.UpdateSchedule(ctx, sc)
+}
+
+func (s *service) Delete(ctx context.Context, userID, scheduleID uuid.UUID) error {
+	if userID == uuid.Nil || scheduleID == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteSchedule(ctx, userID, scheduleID)
+}
+
+func (s *service) Preview(ctx context.Context, userID, scheduleID uuid.UUID, from time.Time, n int) ([]time.Time, error) {
+	sc, err := s.Get(ctx, userID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := ParseRule(sc.Rule)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		n = 10
+	}
+	return rule.Between(sc.Start, from.UTC(), sc.Until, n), nil
+}
+
+func (s *service) RunDue(ctx context.Context, now time.Time) (RunResult, error) {
+	now = now.UTC()
+	due, err := s.repo.ListDueSchedules(ctx, now)
+	if err != nil {
+		return RunResult{}, err
+	}
+	var res RunResult
+	for _, sc := range due {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		posted, runErr := s.runSchedule(ctx, sc, now)
+		res.Posted = append(res.Posted, posted...)
+		if runErr != nil {
+			res.Errors = append(res.Errors, *runErr)
+		}
+	}
+	return res, nil
+}
+
+// lock takes the schedule's lock, returning ok false when another run holds it.
+func (s *service) lock(ctx context.Context, scheduleID uuid.UUID) (func(), bool, error) {
+	if s.locker != nil {
+		return s.locker.TryLockSchedule(ctx, scheduleID)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.running[scheduleID]; busy {
+		return nil, false, nil
+	}
+	s.running[scheduleID] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		delete(s.running, scheduleID)
+		s.mu.Unlock()
+	}, true, nil
+}
+
+// runSchedule posts the due occurrences of one schedule and advances its cursor.
+// Posting stops at the first failure, which is retried on the next run. A
+// schedule locked by another run is skipped; that run posts it.
+func (s *service) runSchedule(ctx context.Context, due ledger.Schedule, now time.Time) ([]ledger.JournalEntry, *RunError) {
+	unlock, ok, err := s.lock(ctx, due.ID)
+	if err != nil {
+		return nil, &RunError{ScheduleID: due.ID, Err: err}
+	}
+	if !ok {
+		return nil, nil
+	}
+	defer unlock()
+	// Re-read under the lock: a run that just finished may have moved the cursor.
+	sc, err := s.repo.GetSchedule(ctx, due.UserID, due.ID)
+	if errors.Is(err, errs.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &RunError{ScheduleID: due.ID, Err: err}
+	}
+	if !sc.Active || sc.NextRun == nil || sc.NextRun.After(now) {
+		return nil, nil
+	}
+	rule, err := ParseRule(sc.Rule)
+	if err != nil {
+		runErr := &RunError{ScheduleID: sc.ID, Occurrence: *sc.NextRun, Err: err}
+		_ = s.writer.AdvanceSchedule(ctx, sc.UserID, sc.ID, sc.NextRun, sc.NextRun, err.Error())
+		return nil, runErr
+	}
+	upTo := now
+	if sc.Until != nil && sc.Until.Before(upTo) {
+		upTo = *sc.Until
+	}
+	occurrences := rule.Between(sc.Start, *sc.NextRun, &upTo, MaxCatchUp)
+	var posted []ledger.JournalEntry
+	var runErr *RunError
+	next := sc.NextRun
+	var existing map[string]ledger.JournalEntry
+	if len(occurrences) > 0 {
+		existing, err = s.postedOccurrences(ctx, sc, occurrences[0], occurrences[len(occurrences)-1])
+		if err != nil {
+			return nil, &RunError{ScheduleID: sc.ID, Occurrence: occurrences[0], Err: err}
+		}
+	}
+	for _, occ := range occurrences {
+		e, created, err := s.post(ctx, sc, occ, existing)
+		if err != nil {
+			runErr = &RunError{ScheduleID: sc.ID, Occurrence: occ, Err: err}
+			t := occ
+			next = &t
+			break
+		}
+		if created {
+			posted = append(posted, e)
+		}
+		next = nextAfter(rule, sc, occ)
+	}
+	lastError := ""
+	if runErr != nil {
+		lastError = runErr.Err.Error()
+	}
+	if err := s.writer.AdvanceSchedule(ctx, sc.UserID, sc.ID, sc.NextRun, next, lastError); err != nil && runErr == nil {
+		runErr = &RunError{ScheduleID: sc.ID, Err: err}
+	}
+	return posted, runErr
+}
+
+// postedOccurrences indexes the schedule's entries dated from first to last,
+// the occurrences about to be posted, by occurrence tag.
+func (s *service) postedOccurrences(ctx context.Context, sc ledger.Schedule, first, last time.Time) (map[string]ledger.JournalEntry, error) {
+	entries, err := s.journal.QueryEntries(ctx, journal.EntryFilter{UserID: sc.UserID, From: &first, To: &last})
+	if err != nil {
+		return nil, err
+	}
+	id := sc.ID.String()
+	out := map[string]ledger.JournalEntry{}
+	for _, e := range entries {
+		if e.Metadata[MetaScheduleID] == id {
+			out[e.Metadata[MetaOccurrence]] = e
+		}
+	}
+	return out, nil
+}
+
+// post creates the entry for one occurrence unless it was already posted. An
+// entry tagged with the occurrence but missing its key (the process stopped
+// between the two writes) gets its key restored instead of being posted again.
+func (s *service) post(ctx context.Context, sc ledger.Schedule, occ time.Time, tagged map[string]ledger.JournalEntry) (ledger.JournalEntry, bool, error) {
+	key := OccurrenceKey(sc.ID, occ)
+	if existing, ok, err := s.idem.GetEntryByIdempotencyKey(ctx, sc.UserID, key); err != nil {
+		return ledger.JournalEntry{}, false, err
+	} else if ok {
+		return existing, false, nil
+	}
+	if e, ok := tagged[occ.UTC().Format(time.RFC3339)]; ok {
+		return e, false, s.idem.SaveIdempotencyKey(ctx, sc.UserID, key, e.ID)
+	}
+	draft := draftFor(sc, occ)
+	if err := s.journal.ValidateEntry(ctx, draft); err != nil {
+		return ledger.JournalEntry{}, false, err
+	}
+	e, err := s.journal.CreateEntry(ctx, draft)
+	if err != nil {
+		return ledger.JournalEntry{}, false, err
+	}
+	if err := s.idem.SaveIdempotencyKey(ctx, sc.UserID, key, e.ID); err != nil {
+		return ledger.JournalEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+// prepare normalizes and validates a schedule and sets NextRun to the first
+// occurrence at or after from.
+func (s *service) prepare(ctx context.Context, sc *ledger.Schedule, from time.Time) error {
+	sc.Name = strings.TrimSpace(sc.Name)
+	if sc.Name == "" {
+		return errors.New("name is required")
+	}
+	if sc.Start.IsZero() {
+		return errors.New("start is required")
+	}
+	// Occurrences are whole seconds; a fractional start would skip the first one.
+	sc.Start = sc.Start.UTC().Truncate(time.Second)
+	rule, err := ParseRule(sc.Rule)
+	if err != nil {
+		return err
+	}
+	sc.Rule = rule.String()
+	if sc.Until != nil {
+		u := sc.Until.UTC()
+		if u.Before(sc.Start) {
+			return errors.New("until must not be before start")
+		}
+		sc.Until = &u
+	}
+	sc.Template.Currency = strings.ToUpper(strings.TrimSpace(sc.Template.Currency))
+	if err := sc.Template.Metadata.Validate(); err != nil {
+		return err
+	}
+	// Validate the template as an entry dated at start; closed periods and
+	// balance assertions are checked per posting.
+	draft := draftFor(*sc, sc.Start)
+	if err := s.journal.ValidateEntry(ctx, draft); err != nil && !errors.Is(err, errs.ErrPeriodClosed) && !errors.Is(err, errs.ErrAssertionFailed) {
+		return err
+	}
+	sc.NextRun = nil
+	if next := rule.Between(sc.Start, from.UTC().Truncate(time.Second), sc.Until, 1); len(next) == 1 {
+		sc.NextRun = &next[0]
+	}
+	sc.LastError = ""
+	return nil
+}
+
+// OccurrenceKey is the idempotency key of a schedule occurrence.
+func OccurrenceKey(scheduleID uuid.UUID, occ time.Time) string {
+	return "schedule:" + scheduleID.String() + ":" + occ.UTC().Format(time.RFC3339)
+}
+
+func nextAfter(rule Rule, sc ledger.Schedule, occ time.Time) *time.Time {
+	next := rule.Between(sc.Start, occ.Add(time.Second), sc.Until, 1)
+	if len(next) == 0 {
+		return nil
+	}
+	return &next[0]
+}
+
+func draftFor(sc ledger.Schedule, occ time.Time) ledger.JournalEntry {
+	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(sc.Template.Lines))}
+	for _, ln := range sc.Template.Lines {
+		nl := ln
+		nl.ID = uuid.New()
+		lines.ByID[nl.ID] = &nl
+	}
+	md := sc.Template.Metadata.Clone()
+	md[MetaScheduleID] = sc.ID.String()
+	md[MetaOccurrence] = occ.UTC().Format(time.RFC3339)
+	return ledger.JournalEntry{
+		UserID:   sc.UserID,
+		Date:     occ,
+		Currency: sc.Template.Currency,
+		Memo:     sc.Template.Memo,
+		Category: sc.Template.Category,
+		Metadata: md,
+		Lines:    lines,
+	}
+}
@@ -0,0 +1,427 @@
+// Package webhook delivers outbox events to the endpoints users register for
+// them. Stores write events in the same transaction as the change they
+// describe; Dispatch fans each one out to a delivery per subscribed endpoint
+// and POSTs due deliveries, signed with the endpoint's secret, retrying with
+// exponential backoff until they succeed or are marked dead.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it goes dead.
+	MaxAttempts = 8
+	// BaseBackoff is the wait after the first failed attempt; it doubles with each failure.
+	BaseBackoff = 30 * time.Second
+	// MaxBackoff caps the wait between attempts.
+	MaxBackoff = 6 * time.Hour
+	// lease is how long a claimed delivery is hidden from other dispatchers while it is sent.
+	lease = time.Minute
+	// sendTimeout bounds one attempt, so a round of sends ends well inside its lease.
+	sendTimeout = 10 * time.Second
+	// batchSize bounds the events and deliveries handled per Dispatch.
+	batchSize = 100
+	// parallel is how many deliveries are claimed and sent at once.
+	parallel = 10
+)
+
+// ErrPrivateHost rejects endpoint URLs whose host is a loopback, private,
+// link-local or unspecified address: deliveries must not reach into the
+// network the ledger runs in.
+var ErrPrivateHost = fmt.Errorf("%w: webhook url points to a loopback, private or link-local address", errs.ErrInvalid)
+
+// Headers sent with every delivery.
+const (
+	HeaderSignature = "Ledger-Signature"
+	HeaderEvent     = "Ledger-Event"
+	HeaderDelivery  = "Ledger-Delivery"
+)
+
+type Repo interface {
+	ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]ledger.WebhookEndpoint, error)
+	GetWebhookEndpoint(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookEndpoint, error)
+	// ListUndispatchedEvents returns outbox events not yet fanned out, in Seq order.
+	ListUndispatchedEvents(ctx context.Context, limit int) ([]ledger.Event, error)
+	GetEvent(ctx context.Context, id uuid.UUID) (ledger.Event, error)
+	// ListWebhookDeliveries returns a user's deliveries, newest first; an empty status matches all.
+	ListWebhookDeliveries(ctx context.Context, userID uuid.UUID, status ledger.DeliveryStatus) ([]ledger.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookDelivery, error)
+}
+
+type Writer interface {
+	CreateWebhookEndpoint(ctx context.Context, ep ledger.WebhookEndpoint) (ledger.WebhookEndpoint, error)
+	DeleteWebhookEndpoint(ctx context.Context, userID, id uuid.UUID) error
+	// MarkEventDispatched records the event's deliveries and marks it
+	// dispatched in one step; it does nothing if the event already was.
+	MarkEventDispatched(ctx context.Context, eventID uuid.UUID, deliveries []ledger.WebhookDelivery) error
+	// ClaimDueDeliveries returns pending deliveries due by now and pushes their
+	// NextAttemptAt to until, so concurrent dispatchers do not send them twice.
+	ClaimDueDeliveries(ctx context.Context, now, until time.Time, limit int) ([]ledger.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, d ledger.WebhookDelivery) (ledger.WebhookDelivery, error)
+}
+
+type Service interface {
+	// CreateEndpoint registers ep, generating its signing secret.
+	CreateEndpoint(ctx context.Context, ep ledger.WebhookEndpoint) (ledger.WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, userID uuid.UUID) ([]ledger.WebhookEndpoint, error)
+	GetEndpoint(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookEndpoint, error)
+	DeleteEndpoint(ctx context.Context, userID, id uuid.UUID) error
+	ListDeliveries(ctx context.Context, userID uuid.UUID, status ledger.DeliveryStatus) ([]ledger.WebhookDelivery, error)
+	// Redeliver queues a delivery to be sent again straight away with a fresh
+	// set of attempts, whatever its current status.
+	Redeliver(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookDelivery, error)
+	// Dispatch fans out new outbox events and sends the deliveries due at now.
+	// It returns the number of delivery attempts made.
+	Dispatch(ctx context.Context, now time.Time) (int, error)
+}
+
+type service struct {
+	repo   Repo
+	writer Writer
+	client *http.Client
+	// allowPrivate lets endpoints point at private hosts, for development.
+	allowPrivate bool
+}
+
+// New constructs the service. A nil client uses one with a 10s timeout that
+// refuses to connect to private addresses, whatever a host name resolves to
+// at send time, unless allowPrivate is set. allowPrivate also lets
+// CreateEndpoint accept private hosts.
+func New(repo Repo, writer Writer, client *http.Client, allowPrivate bool) Service {
+	if client == nil {
+		dialer := &net.Dialer{Timeout: sendTimeout}
+		if !allowPrivate {
+			dialer.Control = refusePrivate
+		}
+		tr := http.DefaultTransport.(*http.Transport).Clone()
+		tr.DialContext = dialer.DialContext
+		client = &http.Client{Timeout: sendTimeout, Transport: tr}
+	}
+	return &service{repo: repo, writer: writer, client: client, allowPrivate: allowPrivate}
+}
+
+// privateIP reports whether ip is an address deliveries must not reach.
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// refusePrivate is a net.Dialer Control that fails connections to private
+// addresses; it sees the address a host name resolved to, so a name that
+// resolves differently after the endpoint was created is still caught.
+func refusePrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return ErrPrivateHost
+	}
+	return nil
+}
+
+// checkHost rejects a host that is, or resolves to, a private address. Names
+// that do not resolve yet are let through; the delivery client checks the
+// address again when it connects.
+func checkHost(ctx context.Context, host string) error {
+	if ip := net.ParseIP(host); ip != nil {
+		if privateIP(ip) {
+			return ErrPrivateHost
+		}
+		return nil
+	}
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return ErrPrivateHost
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	for _, a := range addrs {
+		if privateIP(a.IP) {
+			return ErrPrivateHost
+		}
+	}
+	return nil
+}
+
+func (s *service) CreateEndpoint(ctx context.Context, ep ledger.WebhookEndpoint) (ledger.WebhookEndpoint, error) {
+	if ep.UserID == uuid.Nil || len(ep.EventTypes) == 0 {
+		return ledger.WebhookEndpoint{}, errs.ErrInvalid
+	}
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ledger.WebhookEndpoint{}, errs.ErrInvalid
+	}
+	if !s.allowPrivate {
+		if err := checkHost(ctx, u.Hostname()); err != nil {
+			return ledger.WebhookEndpoint{}, err
+		}
+	}
+	seen := make(map[ledger.EventType]struct{}, len(ep.EventTypes))
+	types := make([]ledger.EventType, 0, len(ep.EventTypes))
+	for _, t := range ep.EventTypes {
+		if !t.IsValid() {
+			return ledger.WebhookEndpoint{}, errs.ErrInvalid
+		}
+		if _, dup := seen[t]; !dup {
+			seen[t] = struct{}{}
+			types = append(types, t)
+		}
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return ledger.WebhookEndpoint{}, err
+	}
+	ep.ID = uuid.New()
+	ep.EventTypes = types
+	ep.Secret = "whsec_" + hex.EncodeToString(secret)
+	ep.CreatedAt = time.Now().UTC()
+	return s.writer.CreateWebhookEndpoint(ctx, ep)
+}
+
+func (s *service) ListEndpoints(ctx context.Context, userID uuid.UUID) ([]ledger.WebhookEndpoint, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	return s.repo.ListWebhookEndpoints(ctx, userID)
+}
+
+func (s *service) GetEndpoint(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookEndpoint, error) {
+	if userID == uuid.Nil || id == uuid.Nil {
+		return ledger.WebhookEndpoint{}, errs.ErrInvalid
+	}
+	return s.repo.GetWebhookEndpoint(ctx, userID, id)
+}
+
+func (s *service) DeleteEndpoint(ctx context.Context, userID, id uuid.UUID) error {
+	if userID == uuid.Nil || id == uuid.Nil {
+		return errs.ErrInvalid
+	}
+	return s.writer.DeleteWebhookEndpoint(ctx, userID, id)
+}
+
+func (s *service) ListDeliveries(ctx context.Context, userID uuid.UUID, status ledger.DeliveryStatus) ([]ledger.WebhookDelivery, error) {
+	if userID == uuid.Nil {
+		return nil, errs.ErrInvalid
+	}
+	switch status {
+	case "", ledger.DeliveryPending, ledger.DeliveryDelivered, ledger.DeliveryDead:
+	default:
+		return nil, errs.ErrInvalid
+	}
+	return s.repo.ListWebhookDeliveries(ctx, userID, status)
+}
+
+func (s *service) Redeliver(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookDelivery, error) {
+	if userID == uuid.Nil || id == uuid.Nil {
+		return ledger.WebhookDelivery{}, errs.ErrInvalid
+	}
+	d, err := s.repo.GetWebhookDelivery(ctx, userID, id)
+	if err != nil {
+		return ledger.WebhookDelivery{}, err
+	}
+	d.Status = ledger.DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now().UTC()
+	d.DeliveredAt = nil
+	return s.writer.UpdateWebhookDelivery(ctx, d)
+}
+
+func (s *service) Dispatch(ctx context.Context, now time.Time) (int, error) {
+	now = now.UTC()
+	// clock is now moved on by the time spent dispatching, so leases,
+	// signatures and retries are timed from when each attempt is made.
+	start := time.Now()
+	clock := func() time.Time { return now.Add(time.Since(start)) }
+	if err := s.fanOut(ctx, now); err != nil {
+		return 0, err
+	}
+	// Deliveries are claimed a round at a time and the round is sent at once,
+	// so each lease only has to cover one attempt, not the whole batch.
+	attempts := 0
+	for attempts < batchSize {
+		at := clock()
+		due, err := s.writer.ClaimDueDeliveries(ctx, at, at.Add(lease), min(parallel, batchSize-attempts))
+		if err != nil || len(due) == 0 {
+			return attempts, err
+		}
+		failed := make([]error, len(due))
+		var wg sync.WaitGroup
+		for i, d := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				failed[i] = s.deliver(ctx, d, clock)
+			}()
+		}
+		wg.Wait()
+		for _, err := range failed {
+			if err != nil {
+				return attempts, err
+			}
+			attempts++
+		}
+	}
+	return attempts, nil
+}
+
+// fanOut creates a pending delivery for every endpoint subscribed to each new event.
+func (s *service) fanOut(ctx context.Context, now time.Time) error {
+	events, err := s.repo.ListUndispatchedEvents(ctx, batchSize)
+	if err != nil {
+		return err
+	}
+	endpoints := make(map[uuid.UUID][]ledger.WebhookEndpoint)
+	for _, ev := range events {
+		eps, ok := endpoints[ev.UserID]
+		if !ok {
+			if eps, err = s.repo.ListWebhookEndpoints(ctx, ev.UserID); err != nil {
+				return err
+			}
+			endpoints[ev.UserID] = eps
+		}
+		var ds []ledger.WebhookDelivery
+		for _, ep := range eps {
+			if !ep.Wants(ev.Type) {
+				continue
+			}
+			ds = append(ds, ledger.WebhookDelivery{
+				ID:            uuid.New(),
+				UserID:        ev.UserID,
+				EventID:       ev.ID,
+				EventType:     ev.Type,
+				EndpointID:    ep.ID,
+				Status:        ledger.DeliveryPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+			})
+		}
+		if err := s.writer.MarkEventDispatched(ctx, ev.ID, ds); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliver makes one attempt at d and records the outcome, timed by clock.
+func (s *service) deliver(ctx context.Context, d ledger.WebhookDelivery, clock func() time.Time) error {
+	d.Attempts++
+	ep, err := s.repo.GetWebhookEndpoint(ctx, d.UserID, d.EndpointID)
+	if errors.Is(err, errs.ErrNotFound) {
+		d.Status = ledger.DeliveryDead
+		d.LastError = "endpoint deleted"
+		_, err = s.writer.UpdateWebhookDelivery(ctx, d)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	ev, err := s.repo.GetEvent(ctx, d.EventID)
+	if err != nil {
+		return err
+	}
+	code, sendErr := s.send(ctx, ep, d, ev, clock())
+	now := clock()
+	d.LastStatusCode = code
+	switch {
+	case sendErr == nil:
+		d.Status = ledger.DeliveryDelivered
+		d.LastError = ""
+		at := now
+		d.DeliveredAt = &at
+	case d.Attempts >= MaxAttempts:
+		d.Status = ledger.DeliveryDead
+		d.LastError = sendErr.Error()
+	default:
+		d.LastError = sendErr.Error()
+		d.NextAttemptAt = now.Add(Backoff(d.Attempts))
+	}
+	_, err = s.writer.UpdateWebhookDelivery(ctx, d)
+	return err
+}
+
+// payload is the JSON body POSTed to endpoints.
+type payload struct {
+	ID        uuid.UUID        `json:"id"`
+	Type      ledger.EventType `json:"type"`
+	UserID    uuid.UUID        `json:"user_id"`
+	EntityID  uuid.UUID        `json:"entity_id"`
+	CreatedAt time.Time        `json:"created_at"`
+	Data      json.RawMessage  `json:"data"`
+}
+
+// send POSTs the event and reports the response status; any non-2xx status is an error.
+func (s *service) send(ctx context.Context, ep ledger.WebhookEndpoint, d ledger.WebhookDelivery, ev ledger.Event, now time.Time) (int, error) {
+	body, err := json.Marshal(payload{ID: ev.ID, Type: ev.Type, UserID: ev.UserID, EntityID: ev.EntityID, CreatedAt: ev.CreatedAt, Data: ev.Data})
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	ts := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(ev.Type))
+	req.Header.Set(HeaderDelivery, d.ID.String())
+	req.Header.Set(HeaderSignature, "t="+strconv.FormatInt(ts, 10)+",v1="+Sign(ep.Secret, ts, body))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by secret,
+// the v1 value of the signature header. Receivers recompute it to verify a
+// delivery and compare the timestamp to reject replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is the wait before retrying after the given number of failed attempts.
+func Backoff(attempts int) time.Duration {
+	d := BaseBackoff
+	for i := 1; i < attempts && d < MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > MaxBackoff {
+		d = MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,51 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+func TestBackoffDoublesUpToCap(t *testing.T) {
+	cases := map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 8: 64 * time.Minute, 20: MaxBackoff}
+	for attempts, want := range cases {
+		if got := Backoff(attempts); got != want {
+			t.Fatalf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestSignCoversTimestampAndBody(t *testing.T) {
+	body := []byte(`{"id":"x"}`)
+	sig := Sign("whsec_test", 1700000000, body)
+	if len(sig) != 64 || sig != Sign("whsec_test", 1700000000, body) {
+		t.Fatalf("unexpected signature %q", sig)
+	}
+	if sig == Sign("whsec_test", 1700000001, body) || sig == Sign("whsec_other", 1700000000, body) || sig == Sign("whsec_test", 1700000000, []byte(`{"id":"y"}`)) {
+		t.Fatal("signature must change with timestamp, secret and body")
+	}
+}
+
+func TestDefaultClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	strict := New(nil, nil, nil, false).(*service)
+	if _, err := strict.client.Get(srv.URL); !errors.Is(err, ErrPrivateHost) {
+		t.Fatalf("dialing loopback expected ErrPrivateHost, got %v", err)
+	}
+	if _, err := strict.CreateEndpoint(context.Background(), ledger.WebhookEndpoint{UserID: uuid.New(), URL: srv.URL, EventTypes: []ledger.EventType{ledger.EventEntryCreated}}); !errors.Is(err, ErrPrivateHost) {
+		t.Fatalf("registering loopback expected ErrPrivateHost, got %v", err)
+	}
+	resp, err := New(nil, nil, nil, true).(*service).client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("allowed client: %v", err)
+	}
+	resp.Body.Close()
+}
@@ -0,0 +1,221 @@
+// Package yearend generates fiscal year-end closing entries: every revenue and
+// expense account is zeroed into the per-currency RetainedEarnings account.
+//
+// Closing is idempotent per (user, year): generated entries are tagged with
+// metadata and a second run returns them instead of posting again. To reopen a
+// year, reverse the closing entries through the journal service (dated at the
+// year end so the year's balances are restored) and run the close again; the
+// reversals keep the closing kind, so reports skip them like the closes. A year
+// is only closed once every earlier year is: its revenue and expense balances
+// must be zero at the start of the year.
+package yearend
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// Metadata keys stamped on generated closing entries.
+const (
+	MetaKind       = journal.MetaKind
+	MetaFiscalYear = "ledger.fiscal_year"
+	KindYearEnd    = "year_end_close"
+)
+
+// ErrEarlierYearOpen reports revenue or expense left unclosed before the year
+// starts; closing now would sweep it into this year's closing entry.
+var ErrEarlierYearOpen = errors.New("an earlier year has revenue or expense balances: close it first")
+
+// AccountReader loads account details for the accounts found in the trial balance.
+type AccountReader interface {
+	FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]ledger.Account, error)
+}
+
+// Result describes the closing entries for a year.
+type Result struct {
+	Year    int
+	YearEnd time.Time
+	Entries []ledger.JournalEntry
+	// Created is false when the year was already closed and existing entries were returned.
+	Created bool
+}
+
+type Service interface {
+	// CloseYear posts closing entries dated yearEnd. A zero yearEnd defaults to
+	// the last second of the calendar year.
+	CloseYear(ctx context.Context, userID uuid.UUID, year int, yearEnd time.Time) (Result, error)
+}
+
+type service struct {
+	journal  journal.Service
+	accounts account.Service
+	reader   AccountReader
+}
+
+func New(j journal.Service, a account.Service, reader AccountReader) Service {
+	return &service{journal: j, accounts: a, reader: reader}
+}
+
+func (s *service) CloseYear(ctx context.Context, userID uuid.UUID, year int, yearEnd time.Time) (Result, error) {
+	if userID == uuid.Nil || year < 1900 || year > 9999 {
+		return Result{}, errs.ErrInvalid
+	}
+	if yearEnd.IsZero() {
+		yearEnd = time.Date(year, time.December, 31, 23, 59, 59, 0, time.UTC)
+	}
+	yearEnd = yearEnd.UTC()
+	res := Result{Year: year, YearEnd: yearEnd}
+
+	// Idempotency: an unreversed closing entry for this year means the year is
+	// closed. Closing entries are dated within the year.
+	yearStart := yearEnd.AddDate(-1, 0, 0)
+	entries, err := s.journal.QueryEntries(ctx, journal.EntryFilter{UserID: userID, From: &yearStart, To: &yearEnd})
+	if err != nil {
+		return Result{}, err
+	}
+	yearStr := strconv.Itoa(year)
+	for _, e := range entries {
+		if e.Metadata[MetaKind] == KindYearEnd && e.Metadata[MetaFiscalYear] == yearStr && !e.IsReversed {
+			res.Entries = append(res.Entries, e)
+		}
+	}
+	if len(res.Entries) > 0 {
+		return res, nil
+	}
+
+	// Balances at the start of the year must be closed already, so the
+	// cumulative balances at the year end are this year's activity alone.
+	opening, err := s.earnings(ctx, userID, yearStart)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, byAccount := range opening {
+		if len(byAccount) > 0 {
+			return Result{}, ErrEarlierYearOpen
+		}
+	}
+	netsByCurrency, err := s.earnings(ctx, userID, yearEnd)
+	if err != nil {
+		return Result{}, err
+	}
+	currencies := make([]string, 0, len(netsByCurrency))
+	for c := range netsByCurrency {
+		currencies = append(currencies, c)
+	}
+	sort.Strings(currencies)
+
+	drafts := make([]ledger.JournalEntry, 0, len(currencies))
+	for _, curr := range currencies {
+		re, err := s.accounts.EnsureRetainedEarningsAccount(ctx, userID, curr)
+		if err != nil {
+			return Result{}, err
+		}
+		draft, err := closingDraft(userID, year, yearEnd, curr, re.ID, netsByCurrency[curr])
+		if err != nil {
+			return Result{}, err
+		}
+		drafts = append(drafts, draft)
+	}
+	if len(drafts) == 0 {
+		return res, nil
+	}
+	created, itemErrs, err := s.journal.CreateEntriesBatch(ctx, drafts)
+	if err != nil {
+		return Result{}, err
+	}
+	if len(itemErrs) > 0 {
+		return Result{}, itemErrs[0].Err
+	}
+	res.Entries = created
+	res.Created = true
+	return res, nil
+}
+
+// closingDraft flips each account's net onto the opposite side and books the
+// difference to retained earnings so the entry balances.
+// earnings returns the nonzero revenue and expense balances at asOf, by
+// currency then account: the same per-account sums as the trial balance.
+func (s *service) earnings(ctx context.Context, userID uuid.UUID, asOf time.Time) (map[string]map[uuid.UUID]int64, error) {
+	nets, err := s.journal.TrialBalance(ctx, userID, &asOf)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]uuid.UUID, 0, len(nets))
+	for id := range nets {
+		ids = append(ids, id)
+	}
+	accs, err := s.reader.FetchAccounts(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	netsByCurrency := map[string]map[uuid.UUID]int64{}
+	for id, amt := range nets {
+		acc, ok := accs[id]
+		if !ok || (acc.Type != ledger.AccountTypeRevenue && acc.Type != ledger.AccountTypeExpense) {
+			continue
+		}
+		units, _ := amt.MinorUnits()
+		if units == 0 {
+			continue
+		}
+		if netsByCurrency[acc.Currency] == nil {
+			netsByCurrency[acc.Currency] = map[uuid.UUID]int64{}
+		}
+		netsByCurrency[acc.Currency][id] = units
+	}
+	return netsByCurrency, nil
+}
+
+func closingDraft(userID uuid.UUID, year int, yearEnd time.Time, curr string, retainedEarnings uuid.UUID, nets map[uuid.UUID]int64) (ledger.JournalEntry, error) {
+	lines := ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(nets)+1)}
+	var total int64 // debits - credits of the closing lines
+	for accID, net := range nets {
+		side, units := ledger.SideCredit, net
+		if net < 0 {
+			side, units = ledger.SideDebit, -net
+			total += units
+		} else {
+			total -= units
+		}
+		amt, err := money.NewAmountFromMinorUnits(curr, units)
+		if err != nil {
+			return ledger.JournalEntry{}, err
+		}
+		id := uuid.New()
+		lines.ByID[id] = &ledger.JournalLine{ID: id, AccountID: accID, Side: side, Amount: amt}
+	}
+	if total != 0 {
+		side, units := ledger.SideCredit, total
+		if total < 0 {
+			side, units = ledger.SideDebit, -total
+		}
+		amt, err := money.NewAmountFromMinorUnits(curr, units)
+		if err != nil {
+			return ledger.JournalEntry{}, err
+		}
+		id := uuid.New()
+		lines.ByID[id] = &ledger.JournalLine{ID: id, AccountID: retainedEarnings, Side: side, Amount: amt}
+	}
+	md := meta.Metadata{MetaKind: KindYearEnd, MetaFiscalYear: strconv.Itoa(year)}
+	return ledger.JournalEntry{
+		UserID:   userID,
+		Date:     yearEnd,
+		Currency: curr,
+		Memo:     "year-end close " + strconv.Itoa(year),
+		Category: ledger.CategoryGeneral,
+		Metadata: md,
+		Lines:    lines,
+	}, nil
+}
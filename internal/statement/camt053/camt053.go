@@ -0,0 +1,300 @@
+// Package camt053 parses ISO 20022 camt.053 (BankToCustomerStatement) XML.
+// Elements are matched by local name, so any camt.053 message version whose
+// structure follows 001.02 onwards is accepted.
+package camt053
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+// Format is the statement.Statement format name for camt.053 files.
+const Format = "camt053"
+
+// MetaBankTxCode carries the ISO bank transaction code (domain/family/sub-family).
+const MetaBankTxCode = "tracker.bank_tx_code"
+
+type document struct {
+	Statements []stmt `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type stmt struct {
+	Acct struct {
+		IBAN  string `xml:"Id>IBAN"`
+		Other string `xml:"Id>Othr>Id"`
+		Ccy   string `xml:"Ccy"`
+	} `xml:"Acct"`
+	Balances []balance `xml:"Bal"`
+	Entries  []entry   `xml:"Ntry"`
+}
+
+type amount struct {
+	Value string `xml:",chardata"`
+	Ccy   string `xml:"Ccy,attr"`
+}
+
+type date struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+type balance struct {
+	Code      string `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	Dt        date   `xml:"Dt"`
+}
+
+type entry struct {
+	Amt       amount `xml:"Amt"`
+	CdtDbtInd string `xml:"CdtDbtInd"`
+	// Sts is plain text up to 001.07 and <Sts><Cd> from 001.08.
+	Sts struct {
+		Text string `xml:",chardata"`
+		Cd   string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookgDt     date   `xml:"BookgDt"`
+	ValDt       date   `xml:"ValDt"`
+	AcctSvcrRef string `xml:"AcctSvcrRef"`
+	BkTxCd      struct {
+		Domain    string `xml:"Domn>Cd"`
+		Family    string `xml:"Domn>Fmly>Cd"`
+		SubFamily string `xml:"Domn>Fmly>SubFmlyCd"`
+	} `xml:"BkTxCd"`
+	Details      []txDetails `xml:"NtryDtls>TxDtls"`
+	AddtlNtryInf string      `xml:"AddtlNtryInf"`
+}
+
+type txDetails struct {
+	EndToEndID   string   `xml:"Refs>EndToEndId"`
+	AcctSvcrRef  string   `xml:"Refs>AcctSvcrRef"`
+	Debtor       string   `xml:"RltdPties>Dbtr>Nm"`
+	DebtorPty    string   `xml:"RltdPties>Dbtr>Pty>Nm"`
+	Creditor     string   `xml:"RltdPties>Cdtr>Nm"`
+	CreditorPty  string   `xml:"RltdPties>Cdtr>Pty>Nm"`
+	Unstructured []string `xml:"RmtInf>Ustrd"`
+	AddtlTxInf   string   `xml:"AddtlTxInf"`
+}
+
+// Parse reads every statement in a camt.053 document.
+func Parse(r io.Reader) ([]statement.Statement, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("camt053: %w", err)
+	}
+	if len(doc.Statements) == 0 {
+		return nil, errors.New("camt053: no BkToCstmrStmt/Stmt found")
+	}
+	out := make([]statement.Statement, 0, len(doc.Statements))
+	for i, s := range doc.Statements {
+		st, err := toStatement(s)
+		if err != nil {
+			return nil, fmt.Errorf("camt053: statement %d: %w", i+1, err)
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func toStatement(s stmt) (statement.Statement, error) {
+	st := statement.Statement{Format: Format, Account: s.Acct.IBAN, Currency: strings.ToUpper(s.Acct.Ccy), Transactions: make([]statement.Transaction, 0, len(s.Entries))}
+	if st.Account == "" {
+		st.Account = s.Acct.Other
+	}
+	if st.Currency == "" && len(s.Balances) > 0 {
+		st.Currency = strings.ToUpper(s.Balances[0].Amt.Ccy)
+	}
+	if st.Currency == "" {
+		return statement.Statement{}, errors.New("no account currency")
+	}
+	for i, e := range s.Entries {
+		tx, err := toTransaction(e, st.Currency)
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		st.Transactions = append(st.Transactions, tx)
+	}
+	var opening, closing *balance
+	for i := range s.Balances {
+		b := &s.Balances[i]
+		switch strings.ToUpper(b.Code) {
+		case "OPBD":
+			opening = b
+		case "PRCD":
+			if opening == nil {
+				opening = b
+			}
+		case "CLBD":
+			closing = b
+		}
+	}
+	if opening != nil {
+		amt, day, err := opening.parse(st.Currency)
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("opening balance: %w", err)
+		}
+		st.Opening = &statement.Balance{AsOf: statement.OpeningAsOf(day, st.Transactions), Amount: amt}
+	}
+	if closing != nil {
+		amt, day, err := closing.parse(st.Currency)
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("closing balance: %w", err)
+		}
+		st.Closing = &statement.Balance{AsOf: statement.EndOfDay(day), Amount: amt}
+	}
+	return st, nil
+}
+
+func (b balance) parse(curr string) (money.Amount, time.Time, error) {
+	amt, err := signedAmount(b.Amt, b.CdtDbtInd, curr)
+	if err != nil {
+		return money.Amount{}, time.Time{}, err
+	}
+	day, err := b.Dt.parse()
+	if err != nil {
+		return money.Amount{}, time.Time{}, err
+	}
+	return amt, day, nil
+}
+
+func toTransaction(e entry, curr string) (statement.Transaction, error) {
+	amt, err := signedAmount(e.Amt, e.CdtDbtInd, curr)
+	if err != nil {
+		return statement.Transaction{}, err
+	}
+	day, err := e.BookgDt.parse()
+	if err != nil {
+		if day, err = e.ValDt.parse(); err != nil {
+			return statement.Transaction{}, errors.New("missing booking date")
+		}
+	}
+	status := strings.ToUpper(strings.TrimSpace(e.Sts.Cd))
+	if status == "" {
+		status = strings.ToUpper(strings.TrimSpace(e.Sts.Text))
+	}
+	md := map[string]string{}
+	ref := e.AcctSvcrRef
+	var e2e, parties, remittance []string
+	for _, d := range e.Details {
+		if ref == "" {
+			ref = d.AcctSvcrRef
+		}
+		if id := strings.TrimSpace(d.EndToEndID); id != "" && !strings.EqualFold(id, "NOTPROVIDED") {
+			e2e = append(e2e, id)
+		}
+		// Name the other party: the payer on credits, the payee on debits.
+		name := first(d.Creditor, d.CreditorPty)
+		if amt.IsPos() {
+			name = first(d.Debtor, d.DebtorPty)
+		}
+		if name != "" {
+			parties = append(parties, name)
+		}
+		remittance = append(remittance, d.Unstructured...)
+		if len(d.Unstructured) == 0 && d.AddtlTxInf != "" {
+			remittance = append(remittance, d.AddtlTxInf)
+		}
+	}
+	if ref != "" {
+		md[statement.MetaBankRef] = ref
+	}
+	if len(e2e) > 0 {
+		md[statement.MetaEndToEndID] = joinLimited(e2e)
+	}
+	if c := e.BkTxCd; c.Domain != "" {
+		md[MetaBankTxCode] = strings.Join([]string{c.Domain, c.Family, c.SubFamily}, "/")
+	}
+	desc := strings.Join(append(dedupe(parties), remittance...), " ")
+	if desc == "" {
+		desc = e.AddtlNtryInf
+	}
+	return statement.Transaction{
+		ID:          e.AcctSvcrRef,
+		Date:        day,
+		Amount:      amt,
+		Description: strings.Join(strings.Fields(desc), " "),
+		Pending:     status != "" && status != "BOOK",
+		Metadata:    md,
+	}, nil
+}
+
+func signedAmount(a amount, ind, curr string) (money.Amount, error) {
+	if a.Ccy != "" && !strings.EqualFold(a.Ccy, curr) {
+		return money.Amount{}, fmt.Errorf("amount in %s on a %s account", a.Ccy, curr)
+	}
+	amt, err := money.ParseAmount(curr, strings.TrimSpace(a.Value))
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", a.Value)
+	}
+	switch strings.ToUpper(ind) {
+	case "CRDT":
+		return amt.Abs().RoundToCurr(), nil
+	case "DBIT":
+		return amt.Abs().Neg().RoundToCurr(), nil
+	default:
+		return money.Amount{}, fmt.Errorf("invalid CdtDbtInd %q", ind)
+	}
+}
+
+func (d date) parse() (time.Time, error) {
+	if d.Dt != "" {
+		return time.ParseInLocation("2006-01-02", strings.TrimSpace(d.Dt), time.UTC)
+	}
+	if d.DtTm != "" {
+		s := strings.TrimSpace(d.DtTm)
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+			if t, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date %q", s)
+	}
+	return time.Time{}, errors.New("missing date")
+}
+
+func first(vals ...string) string {
+	for _, v := range vals {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func dedupe(vals []string) []string {
+	seen := map[string]bool{}
+	out := vals[:0]
+	for _, v := range vals {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// joinLimited comma-joins values, dropping those that would exceed the metadata value limit.
+func joinLimited(vals []string) string {
+	var b strings.Builder
+	for _, v := range vals {
+		if b.Len() > 0 {
+			if b.Len()+1+len(v) > meta.MaxValLen {
+				break
+			}
+			b.WriteByte(',')
+		} else if len(v) > meta.MaxValLen {
+			v = v[:meta.MaxValLen]
+		}
+		b.WriteString(v)
+	}
+	return b.String()
+}
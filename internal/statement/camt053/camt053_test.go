@@ -0,0 +1,103 @@
+package camt053
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+const doc = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+ <BkToCstmrStmt>
+  <GrpHdr><MsgId>MSG1</MsgId><CreDtTm>2025-02-01T06:00:00</CreDtTm></GrpHdr>
+  <Stmt>
+   <Id>STMT-2025-01</Id>
+   <Acct><Id><IBAN>DE89370400440532013000</IBAN></Id><Ccy>EUR</Ccy></Acct>
+   <Bal><Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp><Amt Ccy="EUR">1000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-02</Dt></Dt></Bal>
+   <Bal><Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp><Amt Ccy="EUR">1250.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Dt><Dt>2025-01-31</Dt></Dt></Bal>
+   <Ntry>
+    <Amt Ccy="EUR">300.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+    <BookgDt><Dt>2025-01-02</Dt></BookgDt><ValDt><Dt>2025-01-02</Dt></ValDt>
+    <AcctSvcrRef>BANKREF-1</AcctSvcrRef>
+    <BkTxCd><Domn><Cd>PMNT</Cd><Fmly><Cd>RCDT</Cd><SubFmlyCd>ESCT</SubFmlyCd></Fmly></Domn></BkTxCd>
+    <NtryDtls><TxDtls>
+     <Refs><EndToEndId>INV-2025-001</EndToEndId></Refs>
+     <RltdPties><Dbtr><Pty><Nm>Acme GmbH</Nm></Pty></Dbtr><Cdtr><Pty><Nm>Us</Nm></Pty></Cdtr></RltdPties>
+     <RmtInf><Ustrd>Invoice 2025-001</Ustrd></RmtInf>
+    </TxDtls></NtryDtls>
+   </Ntry>
+   <Ntry>
+    <Amt Ccy="EUR">50.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts>
+    <BookgDt><DtTm>2025-01-15T10:30:00+01:00</DtTm></BookgDt>
+    <AcctSvcrRef>BANKREF-2</AcctSvcrRef>
+    <NtryDtls><TxDtls>
+     <Refs><EndToEndId>NOTPROVIDED</EndToEndId></Refs>
+     <RltdPties><Cdtr><Nm>Stadtwerke</Nm></Cdtr></RltdPties>
+    </TxDtls></NtryDtls>
+    <AddtlNtryInf>SEPA direct debit</AddtlNtryInf>
+   </Ntry>
+   <Ntry>
+    <Amt Ccy="EUR">20.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts>
+    <BookgDt><Dt>2025-01-31</Dt></BookgDt>
+    <AddtlNtryInf>Card authorisation</AddtlNtryInf>
+   </Ntry>
+  </Stmt>
+ </BkToCstmrStmt>
+</Document>`
+
+func TestParse(t *testing.T) {
+	stmts, err := Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(stmts))
+	}
+	st := stmts[0]
+	if st.Format != Format || st.Account != "DE89370400440532013000" || st.Currency != "EUR" || len(st.Transactions) != 3 {
+		t.Fatalf("unexpected statement: %+v", st)
+	}
+	a, b, c := st.Transactions[0], st.Transactions[1], st.Transactions[2]
+	if a.ID != "BANKREF-1" || a.Amount.Decimal().String() != "300.00" || a.Description != "Acme GmbH Invoice 2025-001" || a.Pending {
+		t.Fatalf("unexpected first transaction: %+v", a)
+	}
+	if a.Metadata[statement.MetaEndToEndID] != "INV-2025-001" || a.Metadata[statement.MetaBankRef] != "BANKREF-1" || a.Metadata[MetaBankTxCode] != "PMNT/RCDT/ESCT" {
+		t.Fatalf("unexpected first metadata: %v", a.Metadata)
+	}
+	if b.Amount.Decimal().String() != "-50.00" || b.Description != "Stadtwerke" || !b.Date.Equal(time.Date(2025, 1, 15, 9, 30, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected second transaction: %+v", b)
+	}
+	if _, ok := b.Metadata[statement.MetaEndToEndID]; ok {
+		t.Fatalf("NOTPROVIDED end-to-end id should be dropped: %v", b.Metadata)
+	}
+	if !c.Pending || c.Description != "Card authorisation" {
+		t.Fatalf("unexpected pending transaction: %+v", c)
+	}
+	// The opening balance is dated on the first booking day, so it holds before that day.
+	if st.Opening == nil || st.Opening.Amount.Decimal().String() != "1000.00" || !st.Opening.AsOf.Equal(statement.EndOfDay(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))) {
+		t.Fatalf("unexpected opening balance: %+v", st.Opening)
+	}
+	if st.Closing == nil || st.Closing.Amount.Decimal().String() != "1250.00" || !st.Closing.AsOf.Equal(statement.EndOfDay(time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC))) {
+		t.Fatalf("unexpected closing balance: %+v", st.Closing)
+	}
+}
+
+func TestParseDebitBalanceAndErrors(t *testing.T) {
+	in := strings.Replace(doc, `<Amt Ccy="EUR">1250.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>`, `<Amt Ccy="EUR">75.10</Amt><CdtDbtInd>DBIT</CdtDbtInd>`, 1)
+	stmts, err := Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stmts[0].Closing.Amount.Decimal().String(); got != "-75.10" {
+		t.Fatalf("expected overdrawn closing balance, got %s", got)
+	}
+	if _, err := Parse(strings.NewReader(`<Document><BkToCstmrStmt></BkToCstmrStmt></Document>`)); err == nil {
+		t.Fatal("expected error for a document without statements")
+	}
+	bad := strings.Replace(doc, `<Amt Ccy="EUR">50.00</Amt>`, `<Amt Ccy="USD">50.00</Amt>`, 1)
+	if _, err := Parse(strings.NewReader(bad)); err == nil {
+		t.Fatal("expected error for an entry in another currency")
+	}
+}
@@ -0,0 +1,337 @@
+// Package mt940 parses SWIFT MT940 customer statements. Files may hold several
+// messages, with or without the SWIFT {1:}{2:}{4: block envelope. Field :86:
+// is read in the German structured form (?20 subfields with EREF+/SVWZ+ tags),
+// the /EREF/.../REMI/ tag form, or as free text.
+package mt940
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+// Format is the statement.Statement format name for MT940 files.
+const Format = "mt940"
+
+// MetaTxnType carries the SWIFT transaction type code of :61:, e.g. ntrf.
+const MetaTxnType = "tracker.txn_type"
+
+type field struct {
+	tag   string
+	value string
+}
+
+// Parse reads every statement in an MT940 file.
+func Parse(r io.Reader) ([]statement.Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := tokenize(data)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out []statement.Statement
+		cur *statement.Statement
+		// openingDay becomes an instant once the statement's lines are read.
+		openingDay time.Time
+		last       *statement.Transaction
+	)
+	flush := func() {
+		if cur == nil {
+			return
+		}
+		if cur.Opening != nil {
+			cur.Opening.AsOf = statement.OpeningAsOf(openingDay, cur.Transactions)
+		}
+		out = append(out, *cur)
+		cur, last = nil, nil
+	}
+	for _, f := range fields {
+		if f.tag == "20" {
+			flush()
+			cur = &statement.Statement{Format: Format, Transactions: make([]statement.Transaction, 0)}
+			continue
+		}
+		if cur == nil {
+			return nil, fmt.Errorf("mt940: field :%s: before :20:", f.tag)
+		}
+		switch f.tag {
+		case "25":
+			cur.Account = strings.TrimSpace(f.value)
+		case "60F", "60M":
+			day, amt, err := parseBalance(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("mt940: :%s: %w", f.tag, err)
+			}
+			cur.Currency = amt.Curr().Code()
+			cur.Opening = &statement.Balance{Amount: amt}
+			openingDay = day
+		case "62F", "62M":
+			day, amt, err := parseBalance(f.value)
+			if err != nil {
+				return nil, fmt.Errorf("mt940: :%s: %w", f.tag, err)
+			}
+			if cur.Currency == "" {
+				cur.Currency = amt.Curr().Code()
+			}
+			cur.Closing = &statement.Balance{AsOf: statement.EndOfDay(day), Amount: amt}
+		case "61":
+			if cur.Currency == "" {
+				return nil, errors.New("mt940: :61: before opening balance :60F:")
+			}
+			tx, err := parseLine(f.value, cur.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("mt940: :61: %w", err)
+			}
+			cur.Transactions = append(cur.Transactions, tx)
+			last = &cur.Transactions[len(cur.Transactions)-1]
+		case "86":
+			if last == nil {
+				continue
+			}
+			e2e, desc := parseInfo(f.value)
+			if e2e != "" {
+				last.Metadata[statement.MetaEndToEndID] = e2e
+			}
+			if desc != "" {
+				last.Description = desc
+			}
+			last = nil
+		}
+	}
+	flush()
+	if len(out) == 0 {
+		return nil, errors.New("mt940: no statement found")
+	}
+	for i, st := range out {
+		if st.Currency == "" {
+			return nil, fmt.Errorf("mt940: statement %d has no opening balance", i+1)
+		}
+	}
+	return out, nil
+}
+
+var fieldStart = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+
+// tokenize splits the text blocks of a file into fields. Lines that do not
+// start a field continue the previous one.
+func tokenize(data []byte) ([]field, error) {
+	var out []field
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		// Strip the SWIFT envelope: basic/application header blocks before the
+		// text block and the trailer after it.
+		if i := strings.Index(line, "{4:"); i >= 0 {
+			line = line[i+3:]
+		}
+		if strings.HasPrefix(line, "-}") || line == "-" {
+			continue
+		}
+		if strings.HasPrefix(line, "{") {
+			continue
+		}
+		if m := fieldStart.FindStringSubmatch(line); m != nil {
+			out = append(out, field{tag: m[1], value: line[len(m[0]):]})
+			continue
+		}
+		if len(out) > 0 && line != "" {
+			out[len(out)-1].value += "\n" + line
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// parseBalance reads a :60F:/:62F: balance: D/C mark, YYMMDD, currency, amount.
+func parseBalance(s string) (time.Time, money.Amount, error) {
+	s = strings.TrimSpace(s)
+	if len(s) < 11 {
+		return time.Time{}, money.Amount{}, fmt.Errorf("invalid balance %q", s)
+	}
+	day, err := time.ParseInLocation("060102", s[1:7], time.UTC)
+	if err != nil {
+		return time.Time{}, money.Amount{}, fmt.Errorf("invalid date in %q", s)
+	}
+	amt, err := parseAmount(s[7:10], s[10:])
+	if err != nil {
+		return time.Time{}, money.Amount{}, err
+	}
+	switch s[0] {
+	case 'C':
+	case 'D':
+		amt = amt.Neg()
+	default:
+		return time.Time{}, money.Amount{}, fmt.Errorf("invalid debit/credit mark in %q", s)
+	}
+	return day, amt, nil
+}
+
+// statementLine matches :61:: value date, optional entry date, mark, optional
+// funds code, amount, type code, customer reference, //bank reference and a
+// supplementary details line.
+var statementLine = regexp.MustCompile(`^(\d{6})(\d{4})?(R?[CD])([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})([^/\n]*?)(?://([^\n]*))?(?:\n(.*))?$`)
+
+func parseLine(s, curr string) (statement.Transaction, error) {
+	m := statementLine.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return statement.Transaction{}, fmt.Errorf("invalid statement line %q", s)
+	}
+	valueDate, err := time.ParseInLocation("060102", m[1], time.UTC)
+	if err != nil {
+		return statement.Transaction{}, fmt.Errorf("invalid value date %q", m[1])
+	}
+	date := valueDate
+	if m[2] != "" {
+		month, _ := strconv.Atoi(m[2][:2])
+		day, _ := strconv.Atoi(m[2][2:])
+		year := valueDate.Year()
+		// The entry date has no year; it can fall across a year end from the value date.
+		switch {
+		case month == 12 && valueDate.Month() == time.January:
+			year--
+		case month == 1 && valueDate.Month() == time.December:
+			year++
+		}
+		date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if date.Month() != time.Month(month) {
+			return statement.Transaction{}, fmt.Errorf("invalid entry date %q", m[2])
+		}
+	}
+	amt, err := parseAmount(curr, m[5])
+	if err != nil {
+		return statement.Transaction{}, err
+	}
+	// Credits and reversed debits are money in.
+	if m[3] == "D" || m[3] == "RC" {
+		amt = amt.Neg()
+	}
+	md := map[string]string{MetaTxnType: strings.ToLower(m[6])}
+	if ref := strings.TrimSpace(m[8]); ref != "" {
+		md[statement.MetaBankRef] = ref
+	}
+	return statement.Transaction{Date: date, Amount: amt, Description: strings.TrimSpace(m[9]), Metadata: md}, nil
+}
+
+func parseAmount(curr, s string) (money.Amount, error) {
+	a, err := money.ParseAmount(curr, strings.Replace(strings.TrimSpace(s), ",", ".", 1))
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return a.RoundToCurr(), nil
+}
+
+// parseInfo extracts the end-to-end reference and a description from :86:.
+func parseInfo(s string) (e2e, desc string) {
+	switch {
+	case len(s) > 3 && strings.Contains(s, "?2"):
+		e2e, desc = germanInfo(strings.ReplaceAll(s, "\n", ""))
+	case strings.HasPrefix(s, "/"):
+		e2e, desc = tagInfo(strings.ReplaceAll(s, "\n", ""))
+	default:
+		desc = s
+	}
+	if strings.EqualFold(e2e, "NOTPROVIDED") {
+		e2e = ""
+	}
+	return e2e, strings.Join(strings.Fields(desc), " ")
+}
+
+var (
+	germanField = regexp.MustCompile(`\?(\d{2})`)
+	sepaTag     = regexp.MustCompile(`(EREF|KREF|MREF|CRED|DEBT|COAM|OAMT|SVWZ|ABWA|ABWE|IBAN|BIC)\+`)
+)
+
+// germanInfo reads the ZKA structured form: ?00 posting text, ?20-?29 and
+// ?60-?63 purpose, ?32-?33 counterparty name.
+func germanInfo(s string) (e2e, desc string) {
+	var purpose, name, posting strings.Builder
+	locs := germanField.FindAllStringSubmatchIndex(s, -1)
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		code, _ := strconv.Atoi(s[loc[2]:loc[3]])
+		val := s[loc[1]:end]
+		switch {
+		case code == 0:
+			posting.WriteString(val)
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			purpose.WriteString(val)
+		case code == 32 || code == 33:
+			name.WriteString(val)
+		}
+	}
+	text := purpose.String()
+	remittance := text
+	tags := sepaTag.FindAllStringSubmatchIndex(text, -1)
+	if len(tags) > 0 {
+		remittance = ""
+		for i, loc := range tags {
+			end := len(text)
+			if i+1 < len(tags) {
+				end = tags[i+1][0]
+			}
+			val := strings.TrimSpace(text[loc[1]:end])
+			switch text[loc[2]:loc[3]] {
+			case "EREF":
+				e2e = val
+			case "SVWZ":
+				remittance = val
+			}
+		}
+	}
+	desc = strings.TrimSpace(name.String() + " " + remittance)
+	if desc == "" {
+		desc = posting.String()
+	}
+	return e2e, desc
+}
+
+var swiftTag = regexp.MustCompile(`/(EREF|PREF|MARF|CSID|CNTP|REMI|PURP|ORDP|BENM|NAME|ADDR|IBAN|BIC|ISDT|RTRN|TRCD|ULTC|ULTD|BUSP)/`)
+
+// tagInfo reads the /TAG/value form used by many European banks.
+func tagInfo(s string) (e2e, desc string) {
+	var name, remittance string
+	locs := swiftTag.FindAllStringSubmatchIndex(s, -1)
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		val := strings.Trim(s[loc[1]:end], "/ ")
+		switch s[loc[2]:loc[3]] {
+		case "EREF":
+			e2e = val
+		case "NAME":
+			name = val
+		case "CNTP":
+			// IBAN/BIC/name/city
+			if parts := strings.Split(val, "/"); len(parts) >= 3 && name == "" {
+				name = parts[2]
+			}
+		case "REMI":
+			// USTD//text or STRD/type/issuer/reference
+			val = strings.TrimPrefix(val, "USTD//")
+			val = strings.TrimPrefix(val, "STRD/")
+			remittance = strings.ReplaceAll(val, "/", " ")
+		}
+	}
+	return e2e, strings.TrimSpace(name + " " + remittance)
+}
@@ -0,0 +1,99 @@
+package mt940
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+const german = "{1:F01COBADEFFAXXX0000000000}{2:O9401200250201COBADEFFAXXX00000000002502011200N}{4:\r\n" +
+	":20:STARTUMSE\r\n" +
+	":25:37040044/0532013000\r\n" +
+	":28C:00001/001\r\n" +
+	":60F:C241231EUR1000,00\r\n" +
+	":61:2501020102CR300,00NTRFNONREF//BANK-1\r\n" +
+	":86:166?00GUTSCHRIFT?100599?20EREF+INV-2025-001?21SVWZ+Invoice 2025-?22001?30COBADEFF?31DE02120300000000202051?32ACME GMBH\r\n" +
+	":61:2501021231D50,00NDDTNONREF\r\n" +
+	"Stadtwerke\r\n" +
+	":62F:C250131EUR1250,00\r\n" +
+	"-}\r\n"
+
+const tagged = `:20:940S250201
+:25:NL91ABNA0417164300
+:28C:1
+:60F:D250131USD10,00
+:61:250203D12,50NMSC//REF-A
+:86:/EREF/E2E-9//CNTP/NL02INGB0001234567/INGBNL2A/Coffee Bar/Amsterdam//REMI/USTD//Flat white/
+:61:250204RD5,00NRTI
+:86:Refund of card fee
+:62M:D250204USD17,50
+`
+
+func TestParseGerman(t *testing.T) {
+	stmts, err := Parse(strings.NewReader(german))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(stmts))
+	}
+	st := stmts[0]
+	if st.Format != Format || st.Account != "37040044/0532013000" || st.Currency != "EUR" || len(st.Transactions) != 2 {
+		t.Fatalf("unexpected statement: %+v", st)
+	}
+	a, b := st.Transactions[0], st.Transactions[1]
+	if a.Amount.Decimal().String() != "300.00" || a.Description != "ACME GMBH Invoice 2025-001" || !a.Date.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected first transaction: %+v", a)
+	}
+	if a.ID != "" || a.Metadata[statement.MetaEndToEndID] != "INV-2025-001" || a.Metadata[statement.MetaBankRef] != "BANK-1" || a.Metadata[MetaTxnType] != "ntrf" {
+		t.Fatalf("unexpected first metadata: %v", a.Metadata)
+	}
+	// Entry date in December for a January value date belongs to the previous year.
+	if b.Amount.Decimal().String() != "-50.00" || b.Description != "Stadtwerke" || !b.Date.Equal(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected second transaction: %+v", b)
+	}
+	if st.Opening == nil || st.Opening.Amount.Decimal().String() != "1000.00" || !st.Opening.AsOf.Equal(statement.EndOfDay(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC))) {
+		t.Fatalf("unexpected opening balance: %+v", st.Opening)
+	}
+	if st.Closing == nil || st.Closing.Amount.Decimal().String() != "1250.00" || !st.Closing.AsOf.Equal(statement.EndOfDay(time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC))) {
+		t.Fatalf("unexpected closing balance: %+v", st.Closing)
+	}
+}
+
+func TestParseTagged(t *testing.T) {
+	stmts, err := Parse(strings.NewReader(tagged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := stmts[0]
+	if st.Account != "NL91ABNA0417164300" || st.Currency != "USD" || len(st.Transactions) != 2 {
+		t.Fatalf("unexpected statement: %+v", st)
+	}
+	a, b := st.Transactions[0], st.Transactions[1]
+	if a.Amount.Decimal().String() != "-12.50" || a.Description != "Coffee Bar Flat white" || a.Metadata[statement.MetaEndToEndID] != "E2E-9" {
+		t.Fatalf("unexpected first transaction: %+v", a)
+	}
+	// A reversed debit is money in.
+	if b.Amount.Decimal().String() != "5.00" || b.Description != "Refund of card fee" {
+		t.Fatalf("unexpected second transaction: %+v", b)
+	}
+	if st.Opening.Amount.Decimal().String() != "-10.00" || st.Closing.Amount.Decimal().String() != "-17.50" {
+		t.Fatalf("unexpected balances: %+v %+v", st.Opening, st.Closing)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, in := range map[string]string{
+		"empty":       "",
+		"no :20:":     ":25:123\n:60F:C250101EUR1,00\n",
+		"no balance":  ":20:X\n:25:123\n:61:250101C1,00NTRF\n",
+		"bad line":    ":20:X\n:60F:C250101EUR1,00\n:61:garbage\n",
+		"bad balance": ":20:X\n:60F:X250101EUR1,00\n",
+	} {
+		if _, err := Parse(strings.NewReader(in)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+// Package ofx parses OFX/QFX bank and credit card statements. OFX 1.x files
+// are SGML whose leaf elements have no closing tags; OFX 2.x files are XML.
+// Both are read by the same tolerant tokenizer.
+package ofx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/statement"
+)
+
+// Format is the statement.Statement format name for OFX files.
+const Format = "ofx"
+
+// Metadata keys set on parsed transactions.
+const (
+	MetaTxnType     = "tracker.txn_type"
+	MetaCheckNumber = "tracker.check_number"
+)
+
+// Parse reads every bank and credit card statement in an OFX file.
+func Parse(r io.Reader) ([]statement.Statement, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseTree(data)
+	if err != nil {
+		return nil, err
+	}
+	var out []statement.Statement
+	for _, rs := range root.all("STMTRS", "CCSTMTRS") {
+		st, err := toStatement(rs)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("ofx: no bank or credit card statement found")
+	}
+	return out, nil
+}
+
+func toStatement(rs *node) (statement.Statement, error) {
+	curr := strings.ToUpper(rs.text("CURDEF"))
+	if curr == "" {
+		return statement.Statement{}, errors.New("ofx: statement has no CURDEF")
+	}
+	st := statement.Statement{Format: Format, Currency: curr, Transactions: make([]statement.Transaction, 0)}
+	if acct := rs.child("BANKACCTFROM"); acct != nil {
+		st.Account = acct.text("ACCTID")
+	} else if acct := rs.child("CCACCTFROM"); acct != nil {
+		st.Account = acct.text("ACCTID")
+	}
+	if lb := rs.child("LEDGERBAL"); lb != nil {
+		amt, err := parseAmount(curr, lb.text("BALAMT"))
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("ofx: LEDGERBAL: %w", err)
+		}
+		asOf, err := ParseDate(lb.text("DTASOF"))
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("ofx: LEDGERBAL: %w", err)
+		}
+		st.Closing = &statement.Balance{AsOf: statement.EndOfDay(asOf), Amount: amt}
+	}
+	list := rs.child("BANKTRANLIST")
+	if list == nil {
+		return st, nil
+	}
+	for i, tr := range list.children {
+		if tr.name != "STMTTRN" {
+			continue
+		}
+		tx, err := toTransaction(curr, tr)
+		if err != nil {
+			return statement.Statement{}, fmt.Errorf("ofx: STMTTRN %d: %w", i+1, err)
+		}
+		st.Transactions = append(st.Transactions, tx)
+	}
+	return st, nil
+}
+
+func toTransaction(curr string, tr *node) (statement.Transaction, error) {
+	date, err := ParseDate(tr.text("DTPOSTED"))
+	if err != nil {
+		return statement.Transaction{}, err
+	}
+	amt, err := parseAmount(curr, tr.text("TRNAMT"))
+	if err != nil {
+		return statement.Transaction{}, err
+	}
+	name := tr.text("NAME")
+	if name == "" {
+		if p := tr.child("PAYEE"); p != nil {
+			name = p.text("NAME")
+		}
+	}
+	desc := name
+	if m := tr.text("MEMO"); m != "" && !strings.EqualFold(m, name) {
+		desc = strings.TrimSpace(name + " " + m)
+	}
+	md := map[string]string{}
+	if v := tr.text("TRNTYPE"); v != "" {
+		md[MetaTxnType] = strings.ToLower(v)
+	}
+	if v := tr.text("CHECKNUM"); v != "" {
+		md[MetaCheckNumber] = v
+	}
+	if v := tr.text("REFNUM"); v != "" {
+		md[statement.MetaBankRef] = v
+	}
+	return statement.Transaction{ID: tr.text("FITID"), Date: date, Amount: amt, Description: desc, Metadata: md}, nil
+}
+
+// ParseDate reads an OFX datetime: YYYYMMDD[HHMMSS[.XXX]][[+-]offset[:TZ]].
+// Times without an offset are taken as UTC.
+func ParseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	raw := s
+	offset := 0
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		tz := strings.TrimSuffix(s[i+1:], "]")
+		if j := strings.IndexByte(tz, ':'); j >= 0 {
+			tz = tz[:j]
+		}
+		h, err := strconv.ParseFloat(tz, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid date %q", raw)
+		}
+		offset = int(h * 3600)
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s = s[:i]
+	}
+	var layout string
+	switch len(s) {
+	case 8:
+		layout = "20060102"
+	case 12:
+		layout = "200601021504"
+	case 14:
+		layout = "20060102150405"
+	default:
+		return time.Time{}, fmt.Errorf("invalid date %q", raw)
+	}
+	t, err := time.ParseInLocation(layout, s, time.FixedZone("", offset))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", raw)
+	}
+	return t.UTC(), nil
+}
+
+// parseAmount reads an OFX amount; some banks write a decimal comma.
+func parseAmount(curr, s string) (money.Amount, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, ".") {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	s = strings.TrimPrefix(s, "+")
+	a, err := money.ParseAmount(curr, s)
+	if err != nil {
+		return money.Amount{}, fmt.Errorf("invalid amount %q", s)
+	}
+	return a.RoundToCurr(), nil
+}
+
+// node is an element of the OFX document; leaf elements carry text.
+type node struct {
+	name     string
+	value    string
+	children []*node
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *node) text(name string) string {
+	if c := n.child(name); c != nil {
+		return c.value
+	}
+	return ""
+}
+
+// all returns descendants with any of the names, in document order, without
+// descending into matches.
+func (n *node) all(names ...string) []*node {
+	var out []*node
+	for _, c := range n.children {
+		matched := false
+		for _, name := range names {
+			if c.name == name {
+				out = append(out, c)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			out = append(out, c.all(names...)...)
+		}
+	}
+	return out
+}
+
+// parseTree builds the element tree from the <OFX> element on. The OFX 1.x
+// header block and XML prolog are skipped. An element followed by text is a
+// leaf whether or not it is closed; a closing tag closes the nearest open
+// element of that name. Elements left open inside it were empty leaves, so
+// whatever was nested under them is moved back up to their parent.
+func parseTree(data []byte) (*node, error) {
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return nil, errors.New("ofx: missing <OFX> element")
+	}
+	body := data[start:]
+	if !utf8.Valid(body) {
+		// OFX 1.x files are commonly Windows-1252/Latin-1; map bytes to runes.
+		rs := make([]rune, len(body))
+		for i, b := range body {
+			rs[i] = rune(b)
+		}
+		body = []byte(string(rs))
+	}
+	root := &node{}
+	stack := []*node{root}
+	s := string(body)
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			break
+		}
+		s = s[lt:]
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			return nil, errors.New("ofx: unterminated tag")
+		}
+		tag := strings.TrimSpace(s[1:gt])
+		s = s[gt+1:]
+		switch {
+		case tag == "" || tag[0] == '?' || tag[0] == '!':
+			continue
+		case tag[0] == '/':
+			name := strings.ToUpper(strings.TrimSpace(tag[1:]))
+			for i := len(stack) - 1; i > 0; i-- {
+				if stack[i].name != name {
+					continue
+				}
+				for j := len(stack) - 1; j > i; j-- {
+					empty := stack[j]
+					stack[j-1].children = append(stack[j-1].children, empty.children...)
+					empty.children = nil
+				}
+				stack = stack[:i]
+				break
+			}
+			continue
+		}
+		selfClosing := strings.HasSuffix(tag, "/")
+		name := strings.ToUpper(strings.Fields(strings.TrimSuffix(tag, "/"))[0])
+		n := &node{name: name}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, n)
+		if selfClosing {
+			continue
+		}
+		next := strings.IndexByte(s, '<')
+		if next < 0 {
+			next = len(s)
+		}
+		if text := strings.TrimSpace(s[:next]); text != "" {
+			n.value = html.UnescapeString(text)
+			s = s[next:]
+			// Consume an explicit close for the leaf (OFX 2.x).
+			if rest := strings.TrimPrefix(s, "</"); len(rest) < len(s) {
+				if end := strings.IndexByte(rest, '>'); end >= 0 && strings.EqualFold(strings.TrimSpace(rest[:end]), name) {
+					s = rest[end+1:]
+				}
+			}
+			continue
+		}
+		stack = append(stack, n)
+	}
+	if len(root.children) == 0 {
+		return nil, errors.New("ofx: empty document")
+	}
+	return root, nil
+}
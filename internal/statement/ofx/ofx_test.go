@@ -0,0 +1,86 @@
+package ofx
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sgml = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+CHARSET:1252
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20250201</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS>
+<CURDEF>GBP
+<BANKACCTFROM><BANKID>123456<ACCTID>12345678<ACCTTYPE>CHECKING</BANKACCTFROM>
+<BANKTRANLIST><DTSTART>20250101<DTEND>20250131
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20250105120000[-5:EST]<TRNAMT>-12.50<FITID>A1<NAME>CAFE &amp; CO<MEMO></STMTTRN>
+<STMTTRN><TRNTYPE>CHECK<DTPOSTED>20250110<TRNAMT>-100,00<FITID>A2<CHECKNUM>1001<NAME>Landlord<MEMO>Rent Jan</STMTTRN>
+</BANKTRANLIST>
+<LEDGERBAL><BALAMT>887.50<DTASOF>20250131</LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+const xml = `<?xml version="1.0" encoding="UTF-8"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1><CCSTMTTRNRS><TRNUID>1</TRNUID><CCSTMTRS>
+    <CURDEF>USD</CURDEF>
+    <CCACCTFROM><ACCTID>4111</ACCTID></CCACCTFROM>
+    <BANKTRANLIST>
+      <STMTTRN><TRNTYPE>DEBIT</TRNTYPE><DTPOSTED>20250302</DTPOSTED><TRNAMT>-40.00</TRNAMT><FITID>X9</FITID><PAYEE><NAME>Grocer</NAME></PAYEE><MEMO></MEMO></STMTTRN>
+    </BANKTRANLIST>
+    <LEDGERBAL><BALAMT>-40.00</BALAMT><DTASOF>20250331235959.000[0:GMT]</DTASOF></LEDGERBAL>
+  </CCSTMTRS></CCSTMTTRNRS></CREDITCARDMSGSRSV1>
+</OFX>`
+
+func TestParseSGML(t *testing.T) {
+	stmts, err := Parse(strings.NewReader(sgml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stmts) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(stmts))
+	}
+	st := stmts[0]
+	if st.Currency != "GBP" || st.Account != "12345678" || len(st.Transactions) != 2 {
+		t.Fatalf("unexpected statement: %+v", st)
+	}
+	a, b := st.Transactions[0], st.Transactions[1]
+	if a.ID != "A1" || a.Description != "CAFE & CO" || a.Amount.Decimal().String() != "-12.50" || !a.Date.Equal(time.Date(2025, 1, 5, 17, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected first transaction: %+v", a)
+	}
+	if b.Description != "Landlord Rent Jan" || b.Amount.Decimal().String() != "-100.00" || b.Metadata[MetaCheckNumber] != "1001" || b.Metadata[MetaTxnType] != "check" {
+		t.Fatalf("unexpected second transaction: %+v", b)
+	}
+	if st.Closing == nil || st.Closing.Amount.Decimal().String() != "887.50" || st.Closing.AsOf.Day() != 31 || st.Closing.AsOf.Hour() != 23 {
+		t.Fatalf("unexpected closing balance: %+v", st.Closing)
+	}
+}
+
+func TestParseXMLCreditCard(t *testing.T) {
+	stmts, err := Parse(strings.NewReader(xml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := stmts[0]
+	if st.Currency != "USD" || st.Account != "4111" || len(st.Transactions) != 1 {
+		t.Fatalf("unexpected statement: %+v", st)
+	}
+	if tx := st.Transactions[0]; tx.ID != "X9" || tx.Description != "Grocer" || tx.Amount.Decimal().String() != "-40.00" {
+		t.Fatalf("unexpected transaction: %+v", tx)
+	}
+	if st.Closing == nil || st.Closing.Amount.Decimal().String() != "-40.00" {
+		t.Fatalf("unexpected closing balance: %+v", st.Closing)
+	}
+}
+
+func TestParseRejectsNonOFX(t *testing.T) {
+	if _, err := Parse(strings.NewReader("date,amount\n")); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,90 @@
+// Package statement defines a format-neutral bank statement. Format parsers
+// live in subpackages and fill in the same shape so the importer does not need
+// to know where a statement came from.
+package statement
+
+import (
+	"io"
+	"time"
+
+	"github.com/govalues/money"
+)
+
+// Metadata keys shared by formats that carry these references.
+const (
+	// MetaBankRef is the bank's own reference for the transaction.
+	MetaBankRef = "tracker.bank_ref"
+	// MetaEndToEndID is the payer's end-to-end reference (SEPA EndToEndId, EREF).
+	MetaEndToEndID = "tracker.end_to_end_id"
+)
+
+// Parser reads every statement in a file of one format.
+type Parser interface {
+	Parse(r io.Reader) ([]Statement, error)
+}
+
+// ParserFunc adapts a parse function to Parser.
+type ParserFunc func(r io.Reader) ([]Statement, error)
+
+// Parse calls f(r).
+func (f ParserFunc) Parse(r io.Reader) ([]Statement, error) { return f(r) }
+
+// Statement is one account's statement for a date range.
+type Statement struct {
+	// Format names the source format, e.g. ofx.
+	Format string
+	// Account is the bank's identifier for the account (account number or IBAN).
+	Account  string
+	Currency string
+	// Opening and Closing are the balances the bank reports, when present.
+	Opening      *Balance
+	Closing      *Balance
+	Transactions []Transaction
+}
+
+// Balance is a bank-reported account balance.
+type Balance struct {
+	// AsOf is the instant the balance holds at: postings dated at or before it are included.
+	AsOf time.Time
+	// Amount is signed from the account holder's view: negative means overdrawn or owed.
+	Amount money.Amount
+}
+
+// Transaction is one statement line.
+type Transaction struct {
+	// ID is the bank's unique identifier for the line (OFX FITID, camt.053
+	// AcctSvcrRef). Leave it empty when the format does not guarantee uniqueness.
+	ID   string
+	Date time.Time
+	// Amount is signed from the account holder's view: positive is money in.
+	Amount money.Amount
+	// Description is the payee and narrative text.
+	Description string
+	// Pending marks lines the bank has not booked yet; importers skip them.
+	Pending bool
+	// Metadata holds format-specific references under tracker.* keys.
+	Metadata map[string]string
+}
+
+// EndOfDay returns the last instant of t's UTC day, for balances reported per day.
+func EndOfDay(t time.Time) time.Time {
+	y, m, d := t.UTC().Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond)
+}
+
+// OpeningAsOf returns the instant an opening balance dated day holds at. Banks
+// date it either on the first statement day (balance before that day's
+// postings) or on the previous closing day; a date before the first
+// transaction is read as the latter.
+func OpeningAsOf(day time.Time, txns []Transaction) time.Time {
+	first := time.Time{}
+	for _, tx := range txns {
+		if first.IsZero() || tx.Date.Before(first) {
+			first = tx.Date
+		}
+	}
+	if !first.IsZero() && EndOfDay(day).Before(first) {
+		return EndOfDay(day)
+	}
+	return EndOfDay(day).AddDate(0, 0, -1)
+}
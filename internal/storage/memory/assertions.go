@@ -2,14 +2,48 @@ package memory
 
 import (
 	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/assertion"
+	"github.com/tinoosan/ledger/internal/service/audit"
+	"github.com/tinoosan/ledger/internal/service/budget"
+	"github.com/tinoosan/ledger/internal/service/fx"
+	"github.com/tinoosan/ledger/internal/service/imports"
 	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/period"
+	"github.com/tinoosan/ledger/internal/service/reconciliation"
+	"github.com/tinoosan/ledger/internal/service/rules"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+	"github.com/tinoosan/ledger/internal/service/webhook"
 )
 
 // Compile-time interface assertions documenting which interfaces Store satisfies.
 var (
 	// Service layer repos and writers
-	_ journal.Repo   = (*Store)(nil)
-	_ journal.Writer = (*Store)(nil)
-	_ account.Repo   = (*Store)(nil)
-	_ account.Writer = (*Store)(nil)
+	_ journal.Repo          = (*Store)(nil)
+	_ journal.Writer        = (*Store)(nil)
+	_ journal.BalanceRepo   = (*Store)(nil)
+	_ account.Repo          = (*Store)(nil)
+	_ account.Writer        = (*Store)(nil)
+	_ period.Repo           = (*Store)(nil)
+	_ period.Writer         = (*Store)(nil)
+	_ fx.Repo               = (*Store)(nil)
+	_ fx.Writer             = (*Store)(nil)
+	_ budget.Repo           = (*Store)(nil)
+	_ budget.Writer         = (*Store)(nil)
+	_ schedule.Repo         = (*Store)(nil)
+	_ schedule.Writer       = (*Store)(nil)
+	_ schedule.Locker       = (*Store)(nil)
+	_ rules.Repo            = (*Store)(nil)
+	_ rules.Writer          = (*Store)(nil)
+	_ imports.Repo          = (*Store)(nil)
+	_ imports.Writer        = (*Store)(nil)
+	_ reconciliation.Repo   = (*Store)(nil)
+	_ reconciliation.Writer = (*Store)(nil)
+	_ assertion.Repo        = (*Store)(nil)
+	_ assertion.Writer      = (*Store)(nil)
+	_ audit.Repo            = (*Store)(nil)
+	_ audit.Writer          = (*Store)(nil)
+	_ webhook.Repo          = (*Store)(nil)
+	_ webhook.Writer        = (*Store)(nil)
+
+	// Batch transactions
 )
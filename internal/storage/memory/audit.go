@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/audit"
+)
+
+// AppendAuditRecord appends rec to the log, numbering it after the last record.
+func (s *Store) AppendAuditRecord(_ context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Seq = int64(len(s.auditLog)) + 1
+	s.auditLog = append(s.auditLog, rec)
+	return rec, nil
+}
+
+// ListAuditRecords returns the records matching f in Seq order.
+func (s *Store) ListAuditRecords(_ context.Context, f audit.Filter) ([]ledger.AuditRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.AuditRecord, 0)
+	for _, rec := range s.auditLog {
+		switch {
+		case rec.UserID != f.UserID, rec.Seq <= f.AfterSeq:
+			continue
+		case f.Entity != "" && rec.Entity != f.Entity:
+			continue
+		case f.EntityID != uuid.Nil && rec.EntityID != f.EntityID:
+			continue
+		case f.Actor != "" && rec.Actor != f.Actor:
+			continue
+		case f.From != nil && rec.CreatedAt.Before(*f.From):
+			continue
+		case f.To != nil && rec.CreatedAt.After(*f.To):
+			continue
+		}
+		out = append(out, rec)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
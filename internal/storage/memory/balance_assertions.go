@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListAssertions returns all balance assertions for a user.
+func (s *Store) ListAssertions(_ context.Context, userID uuid.UUID) ([]ledger.BalanceAssertion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.BalanceAssertion, 0)
+	for _, a := range s.assertionsByID {
+		if a.UserID == userID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// GetAssertion returns a user's balance assertion by ID.
+func (s *Store) GetAssertion(_ context.Context, userID, assertionID uuid.UUID) (ledger.BalanceAssertion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.assertionsByID[assertionID]
+	if !ok || a.UserID != userID {
+		return ledger.BalanceAssertion{}, errs.ErrNotFound
+	}
+	return a, nil
+}
+
+// CreateAssertion persists a new balance assertion.
+func (s *Store) CreateAssertion(_ context.Context, a ledger.BalanceAssertion) (ledger.BalanceAssertion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.assertionsByID[a.ID] = a
+	s.versionByUser[a.UserID]++
+	return a, nil
+}
+
+// DeleteAssertion removes a user's balance assertion.
+func (s *Store) DeleteAssertion(_ context.Context, userID, assertionID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a, ok := s.assertionsByID[assertionID]; !ok || a.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.assertionsByID, assertionID)
+	s.versionByUser[userID]++
+	return nil
+}
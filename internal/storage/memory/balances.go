@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// accountBalance is an account's materialized net (debits - credits) in its
+// currency: the running total and the net posted on each UTC day.
+type accountBalance struct {
+	userID   uuid.UUID
+	currency string
+	net      int64
+	// days holds the net per UTC day, sorted by day.
+	days []dayNet
+}
+
+type dayNet struct {
+	day time.Time
+	net int64
+}
+
+// utcDay truncates t to midnight UTC.
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// signedMinor returns a line's amount in minor units, negative for credits.
+func signedMinor(ln *ledger.JournalLine) int64 {
+	units, _ := ln.Amount.MinorUnits()
+	if ln.Side == ledger.SideCredit {
+		return -units
+	}
+	return units
+}
+
+// applyBalancesLocked adds the lines of e to the materialized balances.
+// Caller must hold s.mu (write lock).
+func (s *Store) applyBalancesLocked(e ledger.JournalEntry) {
+	day := utcDay(e.Date)
+	for _, ln := range e.Lines.ByID {
+		b, ok := s.balancesByAccount[ln.AccountID]
+		if !ok {
+			b = &accountBalance{userID: e.UserID, currency: ln.Amount.Curr().Code()}
+			s.balancesByAccount[ln.AccountID] = b
+		}
+		delta := signedMinor(ln)
+		b.net += delta
+		i := sort.Search(len(b.days), func(i int) bool { return !b.days[i].day.Before(day) })
+		if i < len(b.days) && b.days[i].day.Equal(day) {
+			b.days[i].net += delta
+			continue
+		}
+		b.days = append(b.days, dayNet{})
+		copy(b.days[i+1:], b.days[i:])
+		b.days[i] = dayNet{day: day, net: delta}
+	}
+}
+
+// AccountBalances returns the net of each of the user's accounts with lines
+// dated up to asOf (nil means all), limited to accountIDs when not empty.
+// Whole days come from the materialized balances; only the entries of asOf's
+// own day are read to cover the part of it up to asOf.
+func (s *Store) AccountBalances(_ context.Context, userID uuid.UUID, accountIDs []uuid.UUID, asOf *time.Time) (map[uuid.UUID]money.Amount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	wanted := func(id uuid.UUID) bool { return true }
+	if len(accountIDs) > 0 {
+		set := make(map[uuid.UUID]struct{}, len(accountIDs))
+		for _, id := range accountIDs {
+			set[id] = struct{}{}
+		}
+		wanted = func(id uuid.UUID) bool { _, ok := set[id]; return ok }
+	}
+	type net struct {
+		currency string
+		minor    int64
+	}
+	nets := make(map[uuid.UUID]net)
+	var dayStart time.Time
+	if asOf != nil {
+		dayStart = utcDay(*asOf)
+	}
+	for id, b := range s.balancesByAccount {
+		if b.userID != userID || !wanted(id) {
+			continue
+		}
+		if asOf == nil {
+			nets[id] = net{currency: b.currency, minor: b.net}
+			continue
+		}
+		// Days before asOf's day count in full.
+		var minor int64
+		found := false
+		for _, d := range b.days {
+			if !d.day.Before(dayStart) {
+				break
+			}
+			minor += d.net
+			found = true
+		}
+		if found {
+			nets[id] = net{currency: b.currency, minor: minor}
+		}
+	}
+	if asOf != nil {
+		keys := s.entryIndexByUser[userID]
+		i := sort.Search(len(keys), func(i int) bool { return !keys[i].Date.Before(dayStart) })
+		for ; i < len(keys) && !keys[i].Date.After(*asOf); i++ {
+			e, ok := s.entriesByID[keys[i].ID]
+			if !ok {
+				continue
+			}
+			for _, ln := range e.Lines.ByID {
+				if !wanted(ln.AccountID) {
+					continue
+				}
+				n := nets[ln.AccountID]
+				if n.currency == "" {
+					n.currency = ln.Amount.Curr().Code()
+				}
+				n.minor += signedMinor(ln)
+				nets[ln.AccountID] = n
+			}
+		}
+	}
+	out := make(map[uuid.UUID]money.Amount, len(nets))
+	for id, n := range nets {
+		amt, err := money.NewAmountFromMinorUnits(n.currency, n.minor)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = amt
+	}
+	return out, nil
+}
+
+// RebuildBalances recomputes the materialized balances of userID's accounts
+// from their entries, or of every account when userID is uuid.Nil.
+func (s *Store) RebuildBalances(_ context.Context, userID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, b := range s.balancesByAccount {
+		if userID == uuid.Nil || b.userID == userID {
+			delete(s.balancesByAccount, id)
+		}
+	}
+	for u, keys := range s.entryIndexByUser {
+		if userID != uuid.Nil && u != userID {
+			continue
+		}
+		for _, k := range keys {
+			if e, ok := s.entriesByID[k.ID]; ok {
+				s.applyBalancesLocked(*e)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListBudgets returns all budgets for a user.
+func (s *Store) ListBudgets(_ context.Context, userID uuid.UUID) ([]ledger.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Budget, 0)
+	for _, b := range s.budgetsByID {
+		if b.UserID == userID {
+			out = append(out, b)
+		}
+	}
+	return out, nil
+}
+
+// GetBudget returns a user's budget by ID.
+func (s *Store) GetBudget(_ context.Context, userID, budgetID uuid.UUID) (ledger.Budget, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.budgetsByID[budgetID]
+	if !ok || b.UserID != userID {
+		return ledger.Budget{}, errs.ErrNotFound
+	}
+	return b, nil
+}
+
+// CreateBudget persists a new budget.
+func (s *Store) CreateBudget(_ context.Context, b ledger.Budget) (ledger.Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budgetsByID[b.ID] = b
+	return b, nil
+}
+
+// UpdateBudget persists changes to a budget.
+func (s *Store) UpdateBudget(_ context.Context, b ledger.Budget) (ledger.Budget, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.budgetsByID[b.ID]; !ok || cur.UserID != b.UserID {
+		return ledger.Budget{}, errs.ErrNotFound
+	}
+	s.budgetsByID[b.ID] = b
+	return b, nil
+}
+
+// DeleteBudget removes a user's budget.
+func (s *Store) DeleteBudget(_ context.Context, userID, budgetID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.budgetsByID[budgetID]; !ok || b.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.budgetsByID, budgetID)
+	return nil
+}
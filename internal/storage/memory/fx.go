@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// fxKey identifies a rate by user, currency pair and effective day.
+type fxKey struct {
+	UserID uuid.UUID
+	Base   string
+	Quote  string
+	Date   time.Time
+}
+
+// ListRates returns a user's exchange rates; empty base/quote match any currency.
+func (s *Store) ListRates(_ context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.FXRate, 0)
+	for k, r := range s.fxRates {
+		if k.UserID != userID || (base != "" && k.Base != base) || (quote != "" && k.Quote != quote) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// UpsertRates stores rates, keeping the ID of a rate replaced for the same day.
+func (s *Store) UpsertRates(_ context.Context, rates []ledger.FXRate) ([]ledger.FXRate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]ledger.FXRate, 0, len(rates))
+	for _, r := range rates {
+		k := fxKey{UserID: r.UserID, Base: r.Base(), Quote: r.Quote(), Date: r.Date}
+		if prev, ok := s.fxRates[k]; ok {
+			r.ID = prev.ID
+		}
+		s.fxRates[k] = r
+		out = append(out, r)
+	}
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListImportProfiles returns a user's CSV import profiles ordered by name.
+func (s *Store) ListImportProfiles(_ context.Context, userID uuid.UUID) ([]ledger.ImportProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.ImportProfile, 0)
+	for _, p := range s.importProfilesByID {
+		if p.UserID == userID {
+			out = append(out, cloneImportProfile(p))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Name != out[j].Name {
+			return out[i].Name < out[j].Name
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+// GetImportProfile returns a user's import profile by ID.
+func (s *Store) GetImportProfile(_ context.Context, userID, profileID uuid.UUID) (ledger.ImportProfile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.importProfilesByID[profileID]
+	if !ok || p.UserID != userID {
+		return ledger.ImportProfile{}, errs.ErrNotFound
+	}
+	return cloneImportProfile(p), nil
+}
+
+// CreateImportProfile persists a new import profile.
+func (s *Store) CreateImportProfile(_ context.Context, p ledger.ImportProfile) (ledger.ImportProfile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.importProfilesByID[p.ID] = cloneImportProfile(p)
+	return p, nil
+}
+
+// DeleteImportProfile removes a user's import profile.
+func (s *Store) DeleteImportProfile(_ context.Context, userID, profileID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.importProfilesByID[profileID]; !ok || p.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.importProfilesByID, profileID)
+	return nil
+}
+
+func cloneImportProfile(p ledger.ImportProfile) ledger.ImportProfile {
+	cloned := p
+	cloned.Mapping.MemoColumns = append([]string(nil), p.Mapping.MemoColumns...)
+	return cloned
+}
+
+// inputHashKey is the metadata key the imports service stamps with a
+// transaction's input hash; each hash is posted at most once per user.
+const inputHashKey = "tracker.input_hash"
+
+// EntriesByInputHash maps those of hashes the user's entries carry to the entry IDs.
+func (s *Store) EntriesByInputHash(_ context.Context, userID uuid.UUID, hashes []string) (map[string]uuid.UUID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make(map[string]uuid.UUID)
+	for _, h := range hashes {
+		if id, ok := s.inputHashesByUser[userID][h]; ok {
+			out[h] = id
+		}
+	}
+	return out, nil
+}
+
+// indexInputHashLocked records e's input hash, failing with errs.ErrConflict
+// when another entry of the user carries it. Caller must hold s.mu (write lock).
+func (s *Store) indexInputHashLocked(e ledger.JournalEntry) error {
+	h := e.Metadata[inputHashKey]
+	if h == "" {
+		return nil
+	}
+	byHash := s.inputHashesByUser[e.UserID]
+	if byHash == nil {
+		byHash = make(map[string]uuid.UUID)
+		s.inputHashesByUser[e.UserID] = byHash
+	}
+	if id, taken := byHash[h]; taken && id != e.ID {
+		return errs.ErrConflict
+	}
+	byHash[h] = e.ID
+	return nil
+}
+
+// unindexInputHashLocked drops e's input hash. Caller must hold s.mu (write lock).
+func (s *Store) unindexInputHashLocked(e ledger.JournalEntry) {
+	if h := e.Metadata[inputHashKey]; h != "" && s.inputHashesByUser[e.UserID][h] == e.ID {
+		delete(s.inputHashesByUser[e.UserID], h)
+	}
+}
@@ -4,14 +4,15 @@ package memory
 // It keeps code paths easy to follow while allowing us to plug in a real DB later.
 import (
 	"context"
+	"errors"
 	"sort"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/journal"
 )
 
 // entryKey tracks ordering for entries per user: sorted asc by (Date, ID)
@@ -29,18 +30,70 @@ type Store struct {
 	entriesByID  map[uuid.UUID]*ledger.JournalEntry
 	// Per-user sorted index of entries for efficient ordered scans and paging
 	entryIndexByUser map[uuid.UUID][]entryKey
+	// Materialized balances by account, updated with every entry created
+	balancesByAccount map[uuid.UUID]*accountBalance
 	// Idempotency: userID -> key -> entryID
 	idempotencyByUser map[uuid.UUID]map[string]uuid.UUID
+	// Imported entries: userID -> input hash -> entryID
+	inputHashesByUser map[uuid.UUID]map[string]uuid.UUID
+	// Accounting periods by ID
+	periodsByID map[uuid.UUID]ledger.Period
+	// Exchange rates keyed by (user, pair, day)
+	fxRates map[fxKey]ledger.FXRate
+	// Budgets by ID
+	budgetsByID map[uuid.UUID]ledger.Budget
+	// Recurring entry schedules by ID
+	schedulesByID map[uuid.UUID]ledger.Schedule
+	// Schedules locked by a run in progress
+	scheduleLocks map[uuid.UUID]struct{}
+	// Categorization rules by ID
+	rulesByID map[uuid.UUID]ledger.Rule
+	// CSV import profiles by ID
+	importProfilesByID map[uuid.UUID]ledger.ImportProfile
+	// Bank reconciliations by ID
+	reconciliationsByID map[uuid.UUID]ledger.Reconciliation
+	// Balance assertions by ID
+	assertionsByID map[uuid.UUID]ledger.BalanceAssertion
+	// Audit records in append order; never modified
+	auditLog []ledger.AuditRecord
+	// Outbox of ledger events not yet fanned out, in Seq order, appended by the
+	// writes that raise them and trimmed as they are dispatched
+	outbox []ledger.Event
+	// Seq of the last event appended to the outbox
+	lastEventSeq int64
+	// Dispatched events by ID, kept while deliveries may still send them
+	dispatchedEvents map[uuid.UUID]ledger.Event
+	// Webhook endpoints and deliveries by ID
+	webhooksByID   map[uuid.UUID]ledger.WebhookEndpoint
+	deliveriesByID map[uuid.UUID]ledger.WebhookDelivery
+	// Per-user write counter, bumped by every write to a user's accounts,
+	// entries, periods or assertions; a batch commits only if it is unchanged
+	versionByUser map[uuid.UUID]uint64
 }
 
 // New constructs an empty in-memory store.
 func New() *Store {
 	return &Store{
-		userSet:           make(map[uuid.UUID]struct{}),
-		accountsByID:      make(map[uuid.UUID]ledger.Account),
-		entriesByID:       make(map[uuid.UUID]*ledger.JournalEntry),
-		entryIndexByUser:  make(map[uuid.UUID][]entryKey),
-		idempotencyByUser: make(map[uuid.UUID]map[string]uuid.UUID),
+		userSet:             make(map[uuid.UUID]struct{}),
+		accountsByID:        make(map[uuid.UUID]ledger.Account),
+		entriesByID:         make(map[uuid.UUID]*ledger.JournalEntry),
+		entryIndexByUser:    make(map[uuid.UUID][]entryKey),
+		balancesByAccount:   make(map[uuid.UUID]*accountBalance),
+		idempotencyByUser:   make(map[uuid.UUID]map[string]uuid.UUID),
+		inputHashesByUser:   make(map[uuid.UUID]map[string]uuid.UUID),
+		periodsByID:         make(map[uuid.UUID]ledger.Period),
+		fxRates:             make(map[fxKey]ledger.FXRate),
+		budgetsByID:         make(map[uuid.UUID]ledger.Budget),
+		schedulesByID:       make(map[uuid.UUID]ledger.Schedule),
+		scheduleLocks:       make(map[uuid.UUID]struct{}),
+		rulesByID:           make(map[uuid.UUID]ledger.Rule),
+		importProfilesByID:  make(map[uuid.UUID]ledger.ImportProfile),
+		reconciliationsByID: make(map[uuid.UUID]ledger.Reconciliation),
+		assertionsByID:      make(map[uuid.UUID]ledger.BalanceAssertion),
+		dispatchedEvents:    make(map[uuid.UUID]ledger.Event),
+		webhooksByID:        make(map[uuid.UUID]ledger.WebhookEndpoint),
+		deliveriesByID:      make(map[uuid.UUID]ledger.WebhookDelivery),
+		versionByUser:       make(map[uuid.UUID]uint64),
 	}
 }
 
@@ -57,19 +110,43 @@ func cloneAccount(a ledger.Account) ledger.Account {
 func cloneEntry(e ledger.JournalEntry) ledger.JournalEntry {
 	cloned := e
 	cloned.Metadata = e.Metadata.Clone()
+	cloned.Relations = append([]ledger.EntryRelation(nil), e.Relations...)
 	return cloned
 }
 
 // Seed helpers for local dev/tests.
-func (s *Store) SeedUser(u ledger.User)       { s.mu.Lock(); s.userSet[u.ID] = struct{}{}; s.mu.Unlock() }
-func (s *Store) SeedAccount(a ledger.Account) { s.mu.Lock(); s.accountsByID[a.ID] = a; s.mu.Unlock() }
+func (s *Store) SeedUser(u ledger.User) { s.mu.Lock(); s.userSet[u.ID] = struct{}{}; s.mu.Unlock() }
+func (s *Store) SeedAccount(a ledger.Account) {
+	s.mu.Lock()
+	s.accountsByID[a.ID] = a
+	s.versionByUser[a.UserID]++
+	s.mu.Unlock()
+}
 func (s *Store) Reset() {
 	s.mu.Lock()
 	s.userSet = map[uuid.UUID]struct{}{}
 	s.accountsByID = map[uuid.UUID]ledger.Account{}
 	s.entriesByID = map[uuid.UUID]*ledger.JournalEntry{}
 	s.entryIndexByUser = map[uuid.UUID][]entryKey{}
+	s.balancesByAccount = map[uuid.UUID]*accountBalance{}
 	s.idempotencyByUser = map[uuid.UUID]map[string]uuid.UUID{}
+	s.inputHashesByUser = map[uuid.UUID]map[string]uuid.UUID{}
+	s.periodsByID = map[uuid.UUID]ledger.Period{}
+	s.fxRates = map[fxKey]ledger.FXRate{}
+	s.budgetsByID = map[uuid.UUID]ledger.Budget{}
+	s.schedulesByID = map[uuid.UUID]ledger.Schedule{}
+	s.scheduleLocks = map[uuid.UUID]struct{}{}
+	s.rulesByID = map[uuid.UUID]ledger.Rule{}
+	s.importProfilesByID = map[uuid.UUID]ledger.ImportProfile{}
+	s.reconciliationsByID = map[uuid.UUID]ledger.Reconciliation{}
+	s.assertionsByID = map[uuid.UUID]ledger.BalanceAssertion{}
+	s.auditLog = nil
+	s.outbox = nil
+	s.lastEventSeq = 0
+	s.dispatchedEvents = map[uuid.UUID]ledger.Event{}
+	s.webhooksByID = map[uuid.UUID]ledger.WebhookEndpoint{}
+	s.deliveriesByID = map[uuid.UUID]ledger.WebhookDelivery{}
+	s.versionByUser = map[uuid.UUID]uint64{}
 	s.mu.Unlock()
 }
 
@@ -100,11 +177,21 @@ func (s *Store) FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.
 func (s *Store) CreateJournalEntry(_ context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	return s.createEntryLocked(entry)
+}
+
+// createEntryLocked stores a copy of entry. Caller must hold s.mu (write lock).
+func (s *Store) createEntryLocked(entry ledger.JournalEntry) (ledger.JournalEntry, error) {
 	// store shallow copy
-	e := entry
-	e.Metadata = entry.Metadata.Clone()
+	e := cloneEntry(entry)
+	if err := s.indexInputHashLocked(e); err != nil {
+		return ledger.JournalEntry{}, err
+	}
 	s.entriesByID[e.ID] = &e
+	s.versionByUser[e.UserID]++
 	s.insertEntryIndexLocked(e.UserID, entryKey{Date: e.Date, ID: e.ID})
+	s.applyBalancesLocked(e)
+	s.emitLocked(ledger.EntryEvents(e)...)
 	return cloneEntry(e), nil
 }
 
@@ -112,15 +199,123 @@ func (s *Store) CreateJournalEntry(_ context.Context, entry ledger.JournalEntry)
 func (s *Store) UpdateJournalEntry(_ context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.entriesByID[entry.ID]; !ok {
+	return s.updateEntryLocked(entry)
+}
+
+// updateEntryLocked replaces a stored entry. Caller must hold s.mu (write lock).
+func (s *Store) updateEntryLocked(entry ledger.JournalEntry) (ledger.JournalEntry, error) {
+	prev, ok := s.entriesByID[entry.ID]
+	if !ok {
 		return ledger.JournalEntry{}, errs.ErrNotFound
 	}
-	e := entry
-	e.Metadata = entry.Metadata.Clone()
+	e := cloneEntry(entry)
+	s.unindexInputHashLocked(*prev)
+	if err := s.indexInputHashLocked(e); err != nil {
+		_ = s.indexInputHashLocked(*prev)
+		return ledger.JournalEntry{}, err
+	}
 	s.entriesByID[entry.ID] = &e
+	s.versionByUser[e.UserID]++
 	return cloneEntry(e), nil
 }
 
+// ReverseJournalEntry creates rev (and corr when set), records upd's reversed
+// state on the original entry and links it to them atomically, failing with
+// errs.ErrConflict when the stored entry's reversed state no longer matches
+// orig. Only the reversed state and the new links are taken from upd, so
+// links added since orig was read are kept.
+func (s *Store) ReverseJournalEntry(_ context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cur, ok := s.entriesByID[orig.ID]
+	if !ok || cur.UserID != orig.UserID {
+		return ledger.JournalEntry{}, errs.ErrNotFound
+	}
+	if cur.IsReversed != orig.IsReversed {
+		return ledger.JournalEntry{}, errs.ErrConflict
+	}
+	for id, ln := range cur.Lines.ByID {
+		if o, ok := orig.Lines.ByID[id]; !ok || o.ReversedMinor() != ln.ReversedMinor() {
+			return ledger.JournalEntry{}, errs.ErrConflict
+		}
+	}
+	next := cloneEntry(*cur)
+	next.IsReversed = upd.IsReversed
+	next.Lines = upd.Lines
+	next.Relations = append(next.Relations, ledger.EntryRelation{Kind: ledger.RelationReversedBy, EntryID: rev.ID})
+	// The correcting entry goes first: it is the one that may clash on an
+	// input hash, and nothing is written yet if it does.
+	if corr != nil {
+		if _, err := s.createEntryLocked(*corr); err != nil {
+			return ledger.JournalEntry{}, err
+		}
+		next.Relations = append(next.Relations, ledger.EntryRelation{Kind: ledger.RelationReclassifiedTo, EntryID: corr.ID})
+	}
+	created, err := s.createEntryLocked(rev)
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	if _, err := s.updateEntryLocked(next); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	return created, nil
+}
+
+// AddEntryRelations records links atomically, skipping those already recorded.
+func (s *Store) AddEntryRelations(_ context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLinksLocked(userID, links); err != nil {
+		return err
+	}
+	for _, l := range links {
+		e := s.entriesByID[l.From]
+		if e.HasRelation(l.Kind, l.To) {
+			continue
+		}
+		next := cloneEntry(*e)
+		next.Relations = append(next.Relations, ledger.EntryRelation{Kind: l.Kind, EntryID: l.To})
+		s.entriesByID[l.From] = &next
+	}
+	s.versionByUser[userID]++
+	return nil
+}
+
+// RemoveEntryRelations deletes links atomically.
+func (s *Store) RemoveEntryRelations(_ context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkLinksLocked(userID, links); err != nil {
+		return err
+	}
+	for _, l := range links {
+		next := cloneEntry(*s.entriesByID[l.From])
+		kept := next.Relations[:0]
+		for _, r := range next.Relations {
+			if r.Kind != l.Kind || r.EntryID != l.To {
+				kept = append(kept, r)
+			}
+		}
+		next.Relations = kept
+		s.entriesByID[l.From] = &next
+	}
+	s.versionByUser[userID]++
+	return nil
+}
+
+// checkLinksLocked fails with errs.ErrNotFound unless both ends of every link
+// are entries of userID. Caller must hold s.mu.
+func (s *Store) checkLinksLocked(userID uuid.UUID, links []journal.EntryLink) error {
+	for _, l := range links {
+		for _, id := range []uuid.UUID{l.From, l.To} {
+			if e, ok := s.entriesByID[id]; !ok || e.UserID != userID {
+				return errs.ErrNotFound
+			}
+		}
+	}
+	return nil
+}
+
 // EntriesByUserID returns all entries for a user.
 func (s *Store) EntriesByUserID(_ context.Context, userID uuid.UUID) ([]ledger.JournalEntry, error) {
 	s.mu.RLock()
@@ -140,6 +335,41 @@ func (s *Store) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.Jou
 	return s.EntriesByUserID(ctx, userID)
 }
 
+// QueryEntries returns a page of a user's entries matching f in (Date, ID)
+// order. It binary-searches the per-user index for the cursor and From and
+// stops at To or once Limit entries match, so only the page's window is read.
+func (s *Store) QueryEntries(_ context.Context, f journal.EntryFilter) ([]ledger.JournalEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := s.entryIndexByUser[f.UserID]
+	i := 0
+	if f.After != nil {
+		i = sort.Search(len(keys), func(i int) bool {
+			return f.After.Before(journal.EntryKey{Date: keys[i].Date, ID: keys[i].ID})
+		})
+	}
+	if f.From != nil {
+		if j := sort.Search(len(keys), func(j int) bool { return !keys[j].Date.Before(*f.From) }); j > i {
+			i = j
+		}
+	}
+	out := make([]ledger.JournalEntry, 0)
+	for ; i < len(keys); i++ {
+		if f.To != nil && keys[i].Date.After(*f.To) {
+			break
+		}
+		e, ok := s.entriesByID[keys[i].ID]
+		if !ok || !f.Matches(*e) {
+			continue
+		}
+		out = append(out, cloneEntry(*e))
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out, nil
+}
+
 // EntryByID returns a single entry for a user.
 // GetEntry returns a single entry for a user.
 func (s *Store) GetEntry(_ context.Context, userID, entryID uuid.UUID) (ledger.JournalEntry, error) {
@@ -179,6 +409,7 @@ func (s *Store) CreateAccount(_ context.Context, a ledger.Account) (ledger.Accou
 	defer s.mu.Unlock()
 	ca := cloneAccount(a)
 	s.accountsByID[a.ID] = ca
+	s.versionByUser[a.UserID]++
 	return cloneAccount(ca), nil
 }
 
@@ -199,7 +430,10 @@ func (s *Store) UpdateAccount(_ context.Context, a ledger.Account) (ledger.Accou
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	ca := cloneAccount(a)
+	before := s.accountsByID[a.ID]
 	s.accountsByID[a.ID] = ca
+	s.versionByUser[a.UserID]++
+	s.emitLocked(ledger.AccountEvents(before, ca)...)
 	return cloneAccount(ca), nil
 }
 
@@ -243,52 +477,173 @@ func (s *Store) SaveIdempotencyKey(ctx context.Context, userID uuid.UUID, key st
 	return s.SaveEntryIdempotencyKey(ctx, userID, key, entryID)
 }
 
-// Batch transaction support (copy-on-write for created entities)
+// Batch transaction support (copy-on-write for created entities).
+// The embedded Store is a snapshot the batch reads and writes, so services run
+// on a batchTx see their own uncommitted changes; Commit replays the creates
+// onto the real store.
 type batchTx struct {
-	s        *Store
-	accounts []ledger.Account
-	entries  []ledger.JournalEntry
+	*Store
+	s *Store
+	// userID owns the batch; version is their write version at the snapshot.
+	userID     uuid.UUID
+	version    uint64
+	accounts   []ledger.Account
+	entries    []ledger.JournalEntry
+	assertions []ledger.BalanceAssertion
+	audit      []ledger.AuditRecord
+}
+
+// BeginTx starts a batch over a snapshot of userID's data; the batch only
+// reads and writes that user's records.
+func (s *Store) BeginTx(_ context.Context, userID uuid.UUID) (*batchTx, error) {
+	v, version := s.snapshot(userID)
+	return &batchTx{Store: v, s: s, userID: userID, version: version, accounts: []ledger.Account{}, entries: []ledger.JournalEntry{}}, nil
 }
 
-func (s *Store) BeginTx(_ context.Context) (*batchTx, error) {
-	return &batchTx{s: s, accounts: []ledger.Account{}, entries: []ledger.JournalEntry{}}, nil
+// snapshot copies the state a batch of userID reads: the user, their
+// accounts, entries, input hashes, balances, periods and assertions. It also
+// returns the user's write version the copy was taken at.
+func (s *Store) snapshot(userID uuid.UUID) (*Store, uint64) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v := New()
+	if _, ok := s.userSet[userID]; ok {
+		v.userSet[userID] = struct{}{}
+	}
+	for id, a := range s.accountsByID {
+		if a.UserID == userID {
+			v.accountsByID[id] = cloneAccount(a)
+		}
+	}
+	// Entries are replaced, never mutated, on update, so the pointers can be shared.
+	keys := s.entryIndexByUser[userID]
+	v.entryIndexByUser[userID] = append([]entryKey(nil), keys...)
+	for _, k := range keys {
+		if e, ok := s.entriesByID[k.ID]; ok {
+			v.entriesByID[k.ID] = e
+		}
+	}
+	v.inputHashesByUser[userID] = make(map[string]uuid.UUID, len(s.inputHashesByUser[userID]))
+	for h, id := range s.inputHashesByUser[userID] {
+		v.inputHashesByUser[userID][h] = id
+	}
+	for id, b := range s.balancesByAccount {
+		if b.userID != userID {
+			continue
+		}
+		cb := *b
+		cb.days = append([]dayNet(nil), b.days...)
+		v.balancesByAccount[id] = &cb
+	}
+	for id, p := range s.periodsByID {
+		if p.UserID == userID {
+			v.periodsByID[id] = p
+		}
+	}
+	for id, a := range s.assertionsByID {
+		if a.UserID == userID {
+			v.assertionsByID[id] = a
+		}
+	}
+	return v, s.versionByUser[userID]
 }
 
-func (tx *batchTx) CreateAccount(_ context.Context, a ledger.Account) (ledger.Account, error) {
+func (tx *batchTx) CreateAccount(ctx context.Context, a ledger.Account) (ledger.Account, error) {
 	tx.accounts = append(tx.accounts, a)
-	return a, nil
+	return tx.Store.CreateAccount(ctx, a)
 }
 
-func (tx *batchTx) CreateJournalEntry(_ context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+func (tx *batchTx) UpdateAccount(_ context.Context, _ ledger.Account) (ledger.Account, error) {
+	return ledger.Account{}, errBatchUpdate
+}
+
+func (tx *batchTx) CreateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
 	tx.entries = append(tx.entries, e)
-	return e, nil
+	return tx.Store.CreateJournalEntry(ctx, e)
+}
+
+func (tx *batchTx) UpdateJournalEntry(_ context.Context, _ ledger.JournalEntry) (ledger.JournalEntry, error) {
+	return ledger.JournalEntry{}, errBatchUpdate
+}
+
+func (tx *batchTx) ReverseJournalEntry(_ context.Context, _, _, _ ledger.JournalEntry, _ *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	return ledger.JournalEntry{}, errBatchUpdate
+}
+
+func (tx *batchTx) AddEntryRelations(_ context.Context, _ uuid.UUID, _ []journal.EntryLink) error {
+	return errBatchUpdate
+}
+
+func (tx *batchTx) RemoveEntryRelations(_ context.Context, _ uuid.UUID, _ []journal.EntryLink) error {
+	return errBatchUpdate
+}
+
+func (tx *batchTx) CreateAssertion(ctx context.Context, a ledger.BalanceAssertion) (ledger.BalanceAssertion, error) {
+	tx.assertions = append(tx.assertions, a)
+	return tx.Store.CreateAssertion(ctx, a)
+}
+
+func (tx *batchTx) DeleteAssertion(_ context.Context, _, _ uuid.UUID) error {
+	return errBatchUpdate
+}
+
+// AppendAuditRecord holds rec until Commit, which numbers it in the log.
+func (tx *batchTx) AppendAuditRecord(_ context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error) {
+	tx.audit = append(tx.audit, rec)
+	return rec, nil
 }
 
 func (tx *batchTx) Commit(_ context.Context) error {
 	tx.s.mu.Lock()
 	defer tx.s.mu.Unlock()
-	// Check account conflicts and apply
+	// Any write to the user's data since the snapshot may invalidate what the
+	// batch checked against it, e.g. a path taken or an assertion broken.
+	if tx.s.versionByUser[tx.userID] != tx.version {
+		return errs.ErrConflict
+	}
 	for _, a := range tx.accounts {
-		for _, existing := range tx.s.accountsByID {
-			if existing.UserID == a.UserID && existing.Currency == a.Currency && strings.EqualFold(existing.Path(), a.Path()) {
-				return errs.ErrConflict
-			}
+		if a.UserID != tx.userID {
+			return errs.ErrForbidden
+		}
+	}
+	for _, e := range tx.entries {
+		if e.UserID != tx.userID {
+			return errs.ErrForbidden
+		}
+	}
+	for _, a := range tx.assertions {
+		if a.UserID != tx.userID {
+			return errs.ErrForbidden
 		}
 	}
 	for _, a := range tx.accounts {
-		ca := cloneAccount(a)
-		tx.s.accountsByID[a.ID] = ca
+		tx.s.accountsByID[a.ID] = cloneAccount(a)
 	}
 	for _, e := range tx.entries {
 		ce := cloneEntry(e)
+		_ = tx.s.indexInputHashLocked(ce)
 		tx.s.entriesByID[e.ID] = &ce
 		tx.s.insertEntryIndexLocked(e.UserID, entryKey{Date: e.Date, ID: e.ID})
+		tx.s.applyBalancesLocked(ce)
+		tx.s.emitLocked(ledger.EntryEvents(ce)...)
 	}
+	for _, a := range tx.assertions {
+		tx.s.assertionsByID[a.ID] = a
+	}
+	for _, rec := range tx.audit {
+		rec.Seq = int64(len(tx.s.auditLog)) + 1
+		tx.s.auditLog = append(tx.s.auditLog, rec)
+	}
+	tx.s.versionByUser[tx.userID]++
 	return nil
 }
 
 func (tx *batchTx) Rollback(_ context.Context) error { return nil }
 
+// errBatchUpdate rejects changes to existing records inside a batch, which
+// only replays creates on commit.
+var errBatchUpdate = errors.New("memory: updates are not supported in a batch transaction")
+
 // insertEntryIndexLocked inserts k into the per-user sorted index, keeping order asc by (Date, ID).
 // Caller must hold s.mu (write lock).
 func (s *Store) insertEntryIndexLocked(userID uuid.UUID, k entryKey) {
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListPeriods returns all accounting periods for a user.
+func (s *Store) ListPeriods(_ context.Context, userID uuid.UUID) ([]ledger.Period, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Period, 0)
+	for _, p := range s.periodsByID {
+		if p.UserID == userID {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// GetPeriod returns a user's period by ID.
+func (s *Store) GetPeriod(_ context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.periodsByID[periodID]
+	if !ok || p.UserID != userID {
+		return ledger.Period{}, errs.ErrNotFound
+	}
+	return p, nil
+}
+
+// CreatePeriod persists a new period.
+func (s *Store) CreatePeriod(_ context.Context, p ledger.Period) (ledger.Period, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.periodsByID[p.ID] = p
+	s.versionByUser[p.UserID]++
+	return p, nil
+}
+
+// UpdatePeriod persists status changes to a period.
+func (s *Store) UpdatePeriod(_ context.Context, p ledger.Period) (ledger.Period, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.periodsByID[p.ID]; !ok {
+		return ledger.Period{}, errs.ErrNotFound
+	}
+	s.periodsByID[p.ID] = p
+	s.versionByUser[p.UserID]++
+	return p, nil
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListReconciliations returns a user's reconciliations ordered by statement date.
+func (s *Store) ListReconciliations(_ context.Context, userID uuid.UUID) ([]ledger.Reconciliation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Reconciliation, 0)
+	for _, r := range s.reconciliationsByID {
+		if r.UserID == userID {
+			out = append(out, cloneReconciliation(r))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StatementDate.Equal(out[j].StatementDate) {
+			return out[i].StatementDate.Before(out[j].StatementDate)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+// GetReconciliation returns a user's reconciliation by ID.
+func (s *Store) GetReconciliation(_ context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.reconciliationsByID[reconciliationID]
+	if !ok || r.UserID != userID {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	return cloneReconciliation(r), nil
+}
+
+// CreateReconciliation persists a new reconciliation with its statement lines.
+func (s *Store) CreateReconciliation(_ context.Context, r ledger.Reconciliation) (ledger.Reconciliation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reconciliationsByID[r.ID] = cloneReconciliation(r)
+	return cloneReconciliation(r), nil
+}
+
+// UpdateReconciliation replaces a reconciliation and sets the status of the given journal lines.
+func (s *Store) UpdateReconciliation(_ context.Context, r ledger.Reconciliation, lineStatus map[uuid.UUID]ledger.LineStatus) (ledger.Reconciliation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.reconciliationsByID[r.ID]; !ok || old.UserID != r.UserID {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if len(lineStatus) > 0 {
+		s.setLineStatusLocked(r.UserID, lineStatus)
+	}
+	s.reconciliationsByID[r.ID] = cloneReconciliation(r)
+	return cloneReconciliation(r), nil
+}
+
+// setLineStatusLocked updates journal line statuses. Entries handed out by
+// reads share their line maps, so affected entries get fresh copies.
+func (s *Store) setLineStatusLocked(userID uuid.UUID, lineStatus map[uuid.UUID]ledger.LineStatus) {
+	for _, k := range s.entryIndexByUser[userID] {
+		e, ok := s.entriesByID[k.ID]
+		if !ok {
+			continue
+		}
+		touched := false
+		for id := range e.Lines.ByID {
+			if _, ok := lineStatus[id]; ok {
+				touched = true
+				break
+			}
+		}
+		if !touched {
+			continue
+		}
+		ne := *e
+		ne.Lines = ledger.JournalLines{ByID: make(map[uuid.UUID]*ledger.JournalLine, len(e.Lines.ByID))}
+		for id, ln := range e.Lines.ByID {
+			nl := *ln
+			if st, ok := lineStatus[id]; ok {
+				nl.Status = st
+			}
+			ne.Lines.ByID[id] = &nl
+		}
+		s.entriesByID[k.ID] = &ne
+		s.versionByUser[ne.UserID]++
+	}
+}
+
+func cloneReconciliation(r ledger.Reconciliation) ledger.Reconciliation {
+	cloned := r
+	cloned.Lines = append([]ledger.StatementLine(nil), r.Lines...)
+	return cloned
+}
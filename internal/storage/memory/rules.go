@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+)
+
+// ListRules returns all categorization rules for a user.
+func (s *Store) ListRules(_ context.Context, userID uuid.UUID) ([]ledger.Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Rule, 0)
+	for _, r := range s.rulesByID {
+		if r.UserID == userID {
+			out = append(out, cloneRule(r))
+		}
+	}
+	return out, nil
+}
+
+// GetRule returns a user's rule by ID.
+func (s *Store) GetRule(_ context.Context, userID, ruleID uuid.UUID) (ledger.Rule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	r, ok := s.rulesByID[ruleID]
+	if !ok || r.UserID != userID {
+		return ledger.Rule{}, errs.ErrNotFound
+	}
+	return cloneRule(r), nil
+}
+
+// CreateRule persists a new rule.
+func (s *Store) CreateRule(_ context.Context, r ledger.Rule) (ledger.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rulesByID[r.ID] = cloneRule(r)
+	return r, nil
+}
+
+// UpdateRule replaces a rule.
+func (s *Store) UpdateRule(_ context.Context, r ledger.Rule) (ledger.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.rulesByID[r.ID]; !ok || cur.UserID != r.UserID {
+		return ledger.Rule{}, errs.ErrNotFound
+	}
+	s.rulesByID[r.ID] = cloneRule(r)
+	return r, nil
+}
+
+// DeleteRule removes a user's rule.
+func (s *Store) DeleteRule(_ context.Context, userID, ruleID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.rulesByID[ruleID]; !ok || r.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.rulesByID, ruleID)
+	return nil
+}
+
+func cloneRule(r ledger.Rule) ledger.Rule {
+	cloned := r
+	if r.Match.Metadata != nil {
+		cloned.Match.Metadata = meta.New(r.Match.Metadata)
+	}
+	if r.Actions.Metadata != nil {
+		cloned.Actions.Metadata = meta.New(r.Actions.Metadata)
+	}
+	return cloned
+}
@@ -0,0 +1,125 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// ListSchedules returns all schedules for a user.
+func (s *Store) ListSchedules(_ context.Context, userID uuid.UUID) ([]ledger.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Schedule, 0)
+	for _, sc := range s.schedulesByID {
+		if sc.UserID == userID {
+			out = append(out, cloneSchedule(sc))
+		}
+	}
+	return out, nil
+}
+
+// GetSchedule returns a user's schedule by ID.
+func (s *Store) GetSchedule(_ context.Context, userID, scheduleID uuid.UUID) (ledger.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sc, ok := s.schedulesByID[scheduleID]
+	if !ok || sc.UserID != userID {
+		return ledger.Schedule{}, errs.ErrNotFound
+	}
+	return cloneSchedule(sc), nil
+}
+
+// ListDueSchedules returns active schedules of all users due at or before now.
+func (s *Store) ListDueSchedules(_ context.Context, now time.Time) ([]ledger.Schedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.Schedule, 0)
+	for _, sc := range s.schedulesByID {
+		if sc.Active && sc.NextRun != nil && !sc.NextRun.After(now) {
+			out = append(out, cloneSchedule(sc))
+		}
+	}
+	return out, nil
+}
+
+// CreateSchedule persists a new schedule.
+func (s *Store) CreateSchedule(_ context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schedulesByID[sc.ID] = cloneSchedule(sc)
+	return sc, nil
+}
+
+// UpdateSchedule replaces a schedule.
+func (s *Store) UpdateSchedule(_ context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cur, ok := s.schedulesByID[sc.ID]; !ok || cur.UserID != sc.UserID {
+		return ledger.Schedule{}, errs.ErrNotFound
+	}
+	s.schedulesByID[sc.ID] = cloneSchedule(sc)
+	return sc, nil
+}
+
+// AdvanceSchedule sets a schedule's NextRun and LastError if NextRun is still
+// from; a schedule edited or deleted meanwhile is left alone.
+func (s *Store) AdvanceSchedule(_ context.Context, userID, scheduleID uuid.UUID, from, next *time.Time, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sc, ok := s.schedulesByID[scheduleID]
+	if !ok || sc.UserID != userID || !sameTime(sc.NextRun, from) {
+		return nil
+	}
+	if next != nil {
+		t := *next
+		next = &t
+	}
+	sc.NextRun = next
+	sc.LastError = lastError
+	s.schedulesByID[scheduleID] = sc
+	return nil
+}
+
+// TryLockSchedule marks a schedule as being run until unlock is called.
+func (s *Store) TryLockSchedule(_ context.Context, scheduleID uuid.UUID) (func(), bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, busy := s.scheduleLocks[scheduleID]; busy {
+		return nil, false, nil
+	}
+	s.scheduleLocks[scheduleID] = struct{}{}
+	return func() {
+		s.mu.Lock()
+		delete(s.scheduleLocks, scheduleID)
+		s.mu.Unlock()
+	}, true, nil
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// DeleteSchedule removes a user's schedule.
+func (s *Store) DeleteSchedule(_ context.Context, userID, scheduleID uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sc, ok := s.schedulesByID[scheduleID]; !ok || sc.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.schedulesByID, scheduleID)
+	return nil
+}
+
+func cloneSchedule(sc ledger.Schedule) ledger.Schedule {
+	cloned := sc
+	cloned.Template.Metadata = sc.Template.Metadata.Clone()
+	cloned.Template.Lines = append([]ledger.JournalLine(nil), sc.Template.Lines...)
+	return cloned
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// emitLocked appends events to the outbox, numbering them after the last one.
+// Caller must hold s.mu (write lock).
+func (s *Store) emitLocked(events ...ledger.Event) {
+	for _, ev := range events {
+		s.lastEventSeq++
+		ev.Seq = s.lastEventSeq
+		s.outbox = append(s.outbox, ev)
+	}
+}
+
+// ListUndispatchedEvents returns outbox events not yet fanned out, in Seq order.
+func (s *Store) ListUndispatchedEvents(_ context.Context, limit int) ([]ledger.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := len(s.outbox)
+	if limit > 0 && limit < n {
+		n = limit
+	}
+	return append(make([]ledger.Event, 0, n), s.outbox[:n]...), nil
+}
+
+// GetEvent returns an outbox event by ID.
+func (s *Store) GetEvent(_ context.Context, id uuid.UUID) (ledger.Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ev, ok := s.dispatchedEvents[id]; ok {
+		return ev, nil
+	}
+	for _, ev := range s.outbox {
+		if ev.ID == id {
+			return ev, nil
+		}
+	}
+	return ledger.Event{}, errs.ErrNotFound
+}
+
+// MarkEventDispatched stores the event's deliveries and takes it off the
+// outbox. The event is kept only if a delivery will send it.
+func (s *Store) MarkEventDispatched(_ context.Context, eventID uuid.UUID, deliveries []ledger.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Events are dispatched in Seq order, so this is almost always the first.
+	i := 0
+	for i < len(s.outbox) && s.outbox[i].ID != eventID {
+		i++
+	}
+	if i == len(s.outbox) {
+		return nil
+	}
+	ev := s.outbox[i]
+	if i == 0 {
+		// Reslice rather than shift; the next append that grows the outbox
+		// copies only the pending events.
+		s.outbox[0] = ledger.Event{}
+		s.outbox = s.outbox[1:]
+	} else {
+		s.outbox = append(s.outbox[:i], s.outbox[i+1:]...)
+	}
+	for _, d := range deliveries {
+		s.deliveriesByID[d.ID] = d
+	}
+	if len(deliveries) > 0 {
+		s.dispatchedEvents[eventID] = ev
+	}
+	return nil
+}
+
+// CreateWebhookEndpoint persists a new endpoint.
+func (s *Store) CreateWebhookEndpoint(_ context.Context, ep ledger.WebhookEndpoint) (ledger.WebhookEndpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep.EventTypes = append([]ledger.EventType(nil), ep.EventTypes...)
+	s.webhooksByID[ep.ID] = ep
+	return ep, nil
+}
+
+// ListWebhookEndpoints returns a user's endpoints, oldest first.
+func (s *Store) ListWebhookEndpoints(_ context.Context, userID uuid.UUID) ([]ledger.WebhookEndpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.WebhookEndpoint, 0)
+	for _, ep := range s.webhooksByID {
+		if ep.UserID == userID {
+			ep.EventTypes = append([]ledger.EventType(nil), ep.EventTypes...)
+			out = append(out, ep)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out, nil
+}
+
+// GetWebhookEndpoint returns a user's endpoint by ID.
+func (s *Store) GetWebhookEndpoint(_ context.Context, userID, id uuid.UUID) (ledger.WebhookEndpoint, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ep, ok := s.webhooksByID[id]
+	if !ok || ep.UserID != userID {
+		return ledger.WebhookEndpoint{}, errs.ErrNotFound
+	}
+	ep.EventTypes = append([]ledger.EventType(nil), ep.EventTypes...)
+	return ep, nil
+}
+
+// DeleteWebhookEndpoint removes a user's endpoint; its deliveries are kept.
+func (s *Store) DeleteWebhookEndpoint(_ context.Context, userID, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ep, ok := s.webhooksByID[id]
+	if !ok || ep.UserID != userID {
+		return errs.ErrNotFound
+	}
+	delete(s.webhooksByID, id)
+	return nil
+}
+
+// ListWebhookDeliveries returns a user's deliveries, newest first.
+func (s *Store) ListWebhookDeliveries(_ context.Context, userID uuid.UUID, status ledger.DeliveryStatus) ([]ledger.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]ledger.WebhookDelivery, 0)
+	for _, d := range s.deliveriesByID {
+		if d.UserID == userID && (status == "" || d.Status == status) {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.After(out[j].CreatedAt)
+		}
+		return out[i].ID.String() > out[j].ID.String()
+	})
+	return out, nil
+}
+
+// GetWebhookDelivery returns a user's delivery by ID.
+func (s *Store) GetWebhookDelivery(_ context.Context, userID, id uuid.UUID) (ledger.WebhookDelivery, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.deliveriesByID[id]
+	if !ok || d.UserID != userID {
+		return ledger.WebhookDelivery{}, errs.ErrNotFound
+	}
+	return d, nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due by now, oldest due first,
+// leasing them until the given time.
+func (s *Store) ClaimDueDeliveries(_ context.Context, now, until time.Time, limit int) ([]ledger.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := make([]ledger.WebhookDelivery, 0)
+	for _, d := range s.deliveriesByID {
+		if d.Status == ledger.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID.String() < due[j].ID.String()
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+	for _, d := range due {
+		d.NextAttemptAt = until
+		s.deliveriesByID[d.ID] = d
+	}
+	return due, nil
+}
+
+// UpdateWebhookDelivery replaces a delivery's state.
+func (s *Store) UpdateWebhookDelivery(_ context.Context, d ledger.WebhookDelivery) (ledger.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.deliveriesByID[d.ID]; !ok {
+		return ledger.WebhookDelivery{}, errs.ErrNotFound
+	}
+	s.deliveriesByID[d.ID] = d
+	return d, nil
+}
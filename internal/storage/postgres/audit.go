@@ -0,0 +1,88 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/audit"
+)
+
+// --- Audit log ---
+
+// AppendAuditRecord inserts rec; the database assigns its sequence number.
+func (s *Store) AppendAuditRecord(ctx context.Context, rec ledger.AuditRecord) (ledger.AuditRecord, error) {
+	err := s.db.QueryRow(ctx, `
+        insert into audit_log (id, user_id, actor, request_id, operation, entity, entity_id, before, after, created_at)
+        values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+        returning seq
+    `, rec.ID, rec.UserID, rec.Actor, rec.RequestID, string(rec.Operation), string(rec.Entity), rec.EntityID,
+		nullJSON(rec.Before), nullJSON(rec.After), rec.CreatedAt).Scan(&rec.Seq)
+	if err != nil {
+		return ledger.AuditRecord{}, err
+	}
+	return rec, nil
+}
+
+// ListAuditRecords returns the records matching f in sequence order.
+func (s *Store) ListAuditRecords(ctx context.Context, f audit.Filter) ([]ledger.AuditRecord, error) {
+	where := []string{"user_id = $1", "seq > $2"}
+	args := []any{f.UserID, f.AfterSeq}
+	add := func(col string, v any) {
+		args = append(args, v)
+		where = append(where, col+" $"+strconv.Itoa(len(args)))
+	}
+	if f.Entity != "" {
+		add("entity =", string(f.Entity))
+	}
+	if f.EntityID != uuid.Nil {
+		add("entity_id =", f.EntityID)
+	}
+	if f.Actor != "" {
+		add("actor =", f.Actor)
+	}
+	if f.From != nil {
+		add("created_at >=", *f.From)
+	}
+	if f.To != nil {
+		add("created_at <=", *f.To)
+	}
+	q := `
+        select seq, id, user_id, actor, request_id, operation, entity, entity_id, before, after, created_at
+        from audit_log
+        where ` + strings.Join(where, " and ") + `
+        order by seq asc`
+	if f.Limit > 0 {
+		q += " limit " + strconv.Itoa(f.Limit)
+	}
+	rows, err := s.db.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.AuditRecord, 0)
+	for rows.Next() {
+		var rec ledger.AuditRecord
+		var op, entity string
+		var before, after []byte
+		if err := rows.Scan(&rec.Seq, &rec.ID, &rec.UserID, &rec.Actor, &rec.RequestID, &op, &entity, &rec.EntityID, &before, &after, &rec.CreatedAt); err != nil {
+			return nil, err
+		}
+		rec.Operation = ledger.AuditOperation(op)
+		rec.Entity = ledger.AuditEntity(entity)
+		rec.Before, rec.After = before, after
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+// nullJSON maps an empty snapshot to SQL NULL.
+func nullJSON(b []byte) any {
+	if len(b) == 0 {
+		return nil
+	}
+	return string(b)
+}
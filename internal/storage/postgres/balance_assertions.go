@@ -0,0 +1,100 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Balance assertions ---
+
+const assertionColumns = `id, user_id, account_id, assert_date, currency, amount_minor, note`
+
+// ListAssertions returns all balance assertions for a user ordered by date.
+func (s *Store) ListAssertions(ctx context.Context, userID uuid.UUID) ([]ledger.BalanceAssertion, error) {
+	rows, err := s.db.Query(ctx, `
+        select `+assertionColumns+`
+        from balance_assertions
+        where user_id = $1
+        order by assert_date asc, id asc
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.BalanceAssertion, 0)
+	for rows.Next() {
+		a, err := scanAssertion(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// GetAssertion fetches a single balance assertion by id for a user.
+func (s *Store) GetAssertion(ctx context.Context, userID, assertionID uuid.UUID) (ledger.BalanceAssertion, error) {
+	a, err := scanAssertion(s.db.QueryRow(ctx, `
+        select `+assertionColumns+`
+        from balance_assertions
+        where id = $1 and user_id = $2
+    `, assertionID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.BalanceAssertion{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.BalanceAssertion{}, err
+	}
+	return a, nil
+}
+
+// CreateAssertion inserts a balance assertion row.
+func (s *Store) CreateAssertion(ctx context.Context, a ledger.BalanceAssertion) (ledger.BalanceAssertion, error) {
+	minor, _ := a.Amount.MinorUnits()
+	_, err := s.db.Exec(ctx, `
+        insert into balance_assertions (`+assertionColumns+`)
+        values ($1,$2,$3,$4,$5,$6,$7)
+    `, a.ID, a.UserID, a.AccountID, a.Date, a.Amount.Curr().Code(), minor, a.Note)
+	if err != nil {
+		return ledger.BalanceAssertion{}, err
+	}
+	return a, nil
+}
+
+// DeleteAssertion removes a balance assertion.
+func (s *Store) DeleteAssertion(ctx context.Context, userID, assertionID uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from balance_assertions where id = $1 and user_id = $2`, assertionID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+func scanAssertion(row pgx.Row) (ledger.BalanceAssertion, error) {
+	var a ledger.BalanceAssertion
+	var day time.Time
+	var curr string
+	var minor int64
+	if err := row.Scan(&a.ID, &a.UserID, &a.AccountID, &day, &curr, &minor, &a.Note); err != nil {
+		return ledger.BalanceAssertion{}, err
+	}
+	amt, err := money.NewAmountFromMinorUnits(curr, minor)
+	if err != nil {
+		return ledger.BalanceAssertion{}, err
+	}
+	y, m, d := day.Date()
+	a.Date = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	a.Amount = amt
+	return a, nil
+}
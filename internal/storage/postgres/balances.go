@@ -0,0 +1,139 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Materialized balances ---
+
+// utcDay truncates t to midnight UTC.
+func utcDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// applyBalances adds the lines of e to the account balances within the
+// caller's transaction.
+func applyBalances(ctx context.Context, ex pgx.Tx, e ledger.JournalEntry) error {
+	day := utcDay(e.Date)
+	for _, ln := range e.Lines.ByID {
+		minor, _ := ln.Amount.MinorUnits()
+		if ln.Side == ledger.SideCredit {
+			minor = -minor
+		}
+		curr := strings.ToUpper(ln.Amount.Curr().Code())
+		if _, err := ex.Exec(ctx, `
+            insert into account_balances (account_id, user_id, currency, net_minor)
+            values ($1,$2,$3,$4)
+            on conflict (account_id) do update set net_minor = account_balances.net_minor + excluded.net_minor
+        `, ln.AccountID, e.UserID, curr, minor); err != nil {
+			return err
+		}
+		if _, err := ex.Exec(ctx, `
+            insert into account_daily_balances (account_id, day, user_id, currency, net_minor)
+            values ($1,$2,$3,$4,$5)
+            on conflict (account_id, day) do update set net_minor = account_daily_balances.net_minor + excluded.net_minor
+        `, ln.AccountID, day, e.UserID, curr, minor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccountBalances returns the net of each of the user's accounts with lines
+// dated up to asOf (nil means all), limited to accountIDs when not empty.
+// Whole days come from account_daily_balances; only the lines of asOf's own
+// day are read to cover the part of it up to asOf.
+func (s *Store) AccountBalances(ctx context.Context, userID uuid.UUID, accountIDs []uuid.UUID, asOf *time.Time) (map[uuid.UUID]money.Amount, error) {
+	var ids any
+	if len(accountIDs) > 0 {
+		ids = accountIDs
+	}
+	var rows pgx.Rows
+	var err error
+	if asOf == nil {
+		rows, err = s.db.Query(ctx, `
+            select account_id, currency, net_minor
+            from account_balances
+            where user_id = $1 and ($2::uuid[] is null or account_id = any($2))
+        `, userID, ids)
+	} else {
+		rows, err = s.db.Query(ctx, `
+            select account_id, currency, sum(net_minor)::bigint
+            from (
+                select account_id, currency, net_minor
+                from account_daily_balances
+                where user_id = $1 and ($2::uuid[] is null or account_id = any($2)) and day < $3::date
+                union all
+                select l.account_id, coalesce(l.currency, e.currency),
+                    case when l.side = 'debit' then l.amount_minor else -l.amount_minor end
+                from entries e
+                join entry_lines l on l.entry_id = e.id
+                where e.user_id = $1 and ($2::uuid[] is null or l.account_id = any($2)) and e.date >= $3 and e.date <= $4
+            ) nets
+            group by account_id, currency
+        `, userID, ids, utcDay(*asOf), *asOf)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[uuid.UUID]money.Amount)
+	for rows.Next() {
+		var id uuid.UUID
+		var curr string
+		var minor int64
+		if err := rows.Scan(&id, &curr, &minor); err != nil {
+			return nil, err
+		}
+		amt, err := money.NewAmountFromMinorUnits(strings.TrimSpace(curr), minor)
+		if err != nil {
+			return nil, err
+		}
+		out[id] = amt
+	}
+	return out, rows.Err()
+}
+
+// RebuildBalances recomputes the materialized balances of userID's accounts
+// from their entry lines, or of every account when userID is uuid.Nil.
+func (s *Store) RebuildBalances(ctx context.Context, userID uuid.UUID) error {
+	var uid any
+	if userID != uuid.Nil {
+		uid = userID
+	}
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	for _, q := range []string{
+		`delete from account_daily_balances where $1::uuid is null or user_id = $1`,
+		`delete from account_balances where $1::uuid is null or user_id = $1`,
+		`insert into account_daily_balances (account_id, day, user_id, currency, net_minor)
+         select l.account_id, (e.date at time zone 'UTC')::date, e.user_id, coalesce(l.currency, e.currency),
+             sum(case when l.side = 'debit' then l.amount_minor else -l.amount_minor end)
+         from entries e
+         join entry_lines l on l.entry_id = e.id
+         where $1::uuid is null or e.user_id = $1
+         group by 1, 2, 3, 4`,
+		`insert into account_balances (account_id, user_id, currency, net_minor)
+         select account_id, user_id, currency, sum(net_minor)
+         from account_daily_balances
+         where $1::uuid is null or user_id = $1
+         group by 1, 2, 3`,
+	} {
+		if _, err := tx.Exec(ctx, q, uid); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Budgets ---
+
+const budgetColumns = `id, user_id, path, period, start_at, currency, amount_minor`
+
+// ListBudgets returns all budgets for a user ordered by start and path.
+func (s *Store) ListBudgets(ctx context.Context, userID uuid.UUID) ([]ledger.Budget, error) {
+	rows, err := s.db.Query(ctx, `
+        select `+budgetColumns+`
+        from budgets
+        where user_id = $1
+        order by start_at asc, path asc
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Budget, 0)
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
+
+// GetBudget fetches a single budget by id for a user.
+func (s *Store) GetBudget(ctx context.Context, userID, budgetID uuid.UUID) (ledger.Budget, error) {
+	b, err := scanBudget(s.db.QueryRow(ctx, `
+        select `+budgetColumns+`
+        from budgets
+        where id = $1 and user_id = $2
+    `, budgetID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Budget{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	return b, nil
+}
+
+// CreateBudget inserts a budget row.
+func (s *Store) CreateBudget(ctx context.Context, b ledger.Budget) (ledger.Budget, error) {
+	minor, _ := b.Amount.MinorUnits()
+	_, err := s.db.Exec(ctx, `
+        insert into budgets (`+budgetColumns+`)
+        values ($1,$2,$3,$4,$5,$6,$7)
+    `, b.ID, b.UserID, b.Path, string(b.Period), b.Start, b.Amount.Curr().Code(), minor)
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	return b, nil
+}
+
+// UpdateBudget updates the amount of a budget.
+func (s *Store) UpdateBudget(ctx context.Context, b ledger.Budget) (ledger.Budget, error) {
+	minor, _ := b.Amount.MinorUnits()
+	ct, err := s.db.Exec(ctx, `
+        update budgets
+        set amount_minor=$1
+        where id=$2 and user_id=$3
+    `, minor, b.ID, b.UserID)
+	if err != nil {
+		return ledger.Budget{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.Budget{}, errs.ErrNotFound
+	}
+	return b, nil
+}
+
+// DeleteBudget removes a budget row.
+func (s *Store) DeleteBudget(ctx context.Context, userID, budgetID uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from budgets where id=$1 and user_id=$2`, budgetID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+func scanBudget(row pgx.Row) (ledger.Budget, error) {
+	var b ledger.Budget
+	var period, curr string
+	var minor int64
+	if err := row.Scan(&b.ID, &b.UserID, &b.Path, &period, &b.Start, &curr, &minor); err != nil {
+		return ledger.Budget{}, err
+	}
+	amt, err := money.NewAmountFromMinorUnits(curr, minor)
+	if err != nil {
+		return ledger.Budget{}, fmt.Errorf("budget amount: %w", err)
+	}
+	b.Period = ledger.BudgetPeriod(period)
+	b.Start = b.Start.UTC()
+	b.Amount = amt
+	return b, nil
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Exchange rates ---
+
+// ListRates returns a user's exchange rates; empty base/quote match any currency.
+func (s *Store) ListRates(ctx context.Context, userID uuid.UUID, base, quote string) ([]ledger.FXRate, error) {
+	rows, err := s.db.Query(ctx, `
+        select id, user_id, base, quote, rate_date, rate::text
+        from fx_rates
+        where user_id = $1 and ($2 = '' or base = $2) and ($3 = '' or quote = $3)
+        order by base, quote, rate_date asc
+    `, userID, base, quote)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.FXRate, 0)
+	for rows.Next() {
+		var r ledger.FXRate
+		var b, q, rate string
+		if err := rows.Scan(&r.ID, &r.UserID, &b, &q, &r.Date, &rate); err != nil {
+			return nil, err
+		}
+		if r.Rate, err = money.ParseExchRate(b, q, rate); err != nil {
+			return nil, fmt.Errorf("parse exchange rate: %w", err)
+		}
+		r.Date = r.Date.UTC()
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// UpsertRates inserts rates in a transaction, replacing the rate for an existing (user, pair, day).
+func (s *Store) UpsertRates(ctx context.Context, rates []ledger.FXRate) ([]ledger.FXRate, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	out := make([]ledger.FXRate, 0, len(rates))
+	for _, r := range rates {
+		if err := tx.QueryRow(ctx, `
+            insert into fx_rates (id, user_id, base, quote, rate_date, rate)
+            values ($1,$2,$3,$4,$5,$6::numeric)
+            on conflict (user_id, base, quote, rate_date) do update set rate = excluded.rate
+            returning id
+        `, r.ID, r.UserID, r.Base(), r.Quote(), r.Date, r.Rate.Decimal().String()).Scan(&r.ID); err != nil {
+			return nil, fmt.Errorf("upsert rate: %w", err)
+		}
+		out = append(out, r)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- CSV import profiles ---
+
+const importProfileColumns = `id, user_id, name, counter_account_id, mapping`
+
+// csvMappingJSON is the jsonb form of a profile's column mapping.
+type csvMappingJSON struct {
+	Delimiter       string            `json:"delimiter,omitempty"`
+	HasHeader       bool              `json:"has_header,omitempty"`
+	SkipRows        int               `json:"skip_rows,omitempty"`
+	DateColumn      string            `json:"date_column"`
+	DateFormat      string            `json:"date_format,omitempty"`
+	AmountColumn    string            `json:"amount_column,omitempty"`
+	Sign            ledger.AmountSign `json:"sign,omitempty"`
+	InflowColumn    string            `json:"inflow_column,omitempty"`
+	OutflowColumn   string            `json:"outflow_column,omitempty"`
+	DecimalComma    bool              `json:"decimal_comma,omitempty"`
+	MemoColumns     []string          `json:"memo_columns,omitempty"`
+	ReferenceColumn string            `json:"reference_column,omitempty"`
+}
+
+// ListImportProfiles returns a user's CSV import profiles ordered by name.
+func (s *Store) ListImportProfiles(ctx context.Context, userID uuid.UUID) ([]ledger.ImportProfile, error) {
+	rows, err := s.db.Query(ctx, `select `+importProfileColumns+` from import_profiles where user_id = $1 order by name asc, id asc`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.ImportProfile, 0)
+	for rows.Next() {
+		p, err := scanImportProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetImportProfile fetches a single import profile by id for a user.
+func (s *Store) GetImportProfile(ctx context.Context, userID, profileID uuid.UUID) (ledger.ImportProfile, error) {
+	p, err := scanImportProfile(s.db.QueryRow(ctx, `select `+importProfileColumns+` from import_profiles where id = $1 and user_id = $2`, profileID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.ImportProfile{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.ImportProfile{}, err
+	}
+	return p, nil
+}
+
+// EntriesByInputHash maps those of hashes the user's entries carry as
+// tracker.input_hash to the entry IDs, using ux_entries_user_input_hash.
+func (s *Store) EntriesByInputHash(ctx context.Context, userID uuid.UUID, hashes []string) (map[string]uuid.UUID, error) {
+	out := make(map[string]uuid.UUID)
+	if len(hashes) == 0 {
+		return out, nil
+	}
+	rows, err := s.db.Query(ctx, `
+        select metadata->>'tracker.input_hash', id
+        from entries
+        where user_id = $1 and metadata ? 'tracker.input_hash' and metadata->>'tracker.input_hash' = any($2)
+    `, userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h string
+		var id uuid.UUID
+		if err := rows.Scan(&h, &id); err != nil {
+			return nil, err
+		}
+		out[h] = id
+	}
+	return out, rows.Err()
+}
+
+// CreateImportProfile inserts an import profile row.
+func (s *Store) CreateImportProfile(ctx context.Context, p ledger.ImportProfile) (ledger.ImportProfile, error) {
+	m, err := json.Marshal(csvMappingJSON(p.Mapping))
+	if err != nil {
+		return ledger.ImportProfile{}, err
+	}
+	_, err = s.db.Exec(ctx, `
+        insert into import_profiles (`+importProfileColumns+`)
+        values ($1,$2,$3,$4,$5)
+    `, p.ID, p.UserID, p.Name, p.CounterAccountID, m)
+	if err != nil {
+		return ledger.ImportProfile{}, err
+	}
+	return p, nil
+}
+
+// DeleteImportProfile removes an import profile row.
+func (s *Store) DeleteImportProfile(ctx context.Context, userID, profileID uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from import_profiles where id=$1 and user_id=$2`, profileID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+func scanImportProfile(row pgx.Row) (ledger.ImportProfile, error) {
+	var p ledger.ImportProfile
+	var mb []byte
+	if err := row.Scan(&p.ID, &p.UserID, &p.Name, &p.CounterAccountID, &mb); err != nil {
+		return ledger.ImportProfile{}, err
+	}
+	var m csvMappingJSON
+	if err := json.Unmarshal(mb, &m); err != nil {
+		return ledger.ImportProfile{}, fmt.Errorf("import profile mapping: %w", err)
+	}
+	p.Mapping = ledger.CSVMapping(m)
+	return p, nil
+}
@@ -0,0 +1,82 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Periods ---
+
+// ListPeriods returns all accounting periods for a user ordered by start.
+func (s *Store) ListPeriods(ctx context.Context, userID uuid.UUID) ([]ledger.Period, error) {
+	rows, err := s.db.Query(ctx, `
+        select id, user_id, name, start_at, end_at, status, closed_at
+        from periods
+        where user_id = $1
+        order by start_at asc
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Period, 0)
+	for rows.Next() {
+		var p ledger.Period
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.Start, &p.End, &p.Status, &p.ClosedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// GetPeriod fetches a single period by id for a user.
+func (s *Store) GetPeriod(ctx context.Context, userID, periodID uuid.UUID) (ledger.Period, error) {
+	var p ledger.Period
+	err := s.db.QueryRow(ctx, `
+        select id, user_id, name, start_at, end_at, status, closed_at
+        from periods
+        where id = $1 and user_id = $2
+    `, periodID, userID).Scan(&p.ID, &p.UserID, &p.Name, &p.Start, &p.End, &p.Status, &p.ClosedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Period{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	return p, nil
+}
+
+// CreatePeriod inserts a period row.
+func (s *Store) CreatePeriod(ctx context.Context, p ledger.Period) (ledger.Period, error) {
+	_, err := s.db.Exec(ctx, `
+        insert into periods (id, user_id, name, start_at, end_at, status, closed_at)
+        values ($1,$2,$3,$4,$5,$6,$7)
+    `, p.ID, p.UserID, p.Name, p.Start, p.End, p.Status, p.ClosedAt)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	return p, nil
+}
+
+// UpdatePeriod updates the status fields of a period.
+func (s *Store) UpdatePeriod(ctx context.Context, p ledger.Period) (ledger.Period, error) {
+	ct, err := s.db.Exec(ctx, `
+        update periods
+        set status=$1, closed_at=$2
+        where id=$3 and user_id=$4
+    `, p.Status, p.ClosedAt, p.ID, p.UserID)
+	if err != nil {
+		return ledger.Period{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.Period{}, errs.ErrNotFound
+	}
+	return p, nil
+}
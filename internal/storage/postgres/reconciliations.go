@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/govalues/money"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Bank reconciliations ---
+
+const reconciliationColumns = `id, user_id, account_id, statement_date, currency, opening_balance_minor, statement_balance_minor, status, reconciled_balance_minor, finished_at`
+
+// ListReconciliations returns a user's reconciliations with their lines, ordered by statement date.
+func (s *Store) ListReconciliations(ctx context.Context, userID uuid.UUID) ([]ledger.Reconciliation, error) {
+	rows, err := s.db.Query(ctx, `select `+reconciliationColumns+` from reconciliations where user_id = $1 order by statement_date asc, id asc`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Reconciliation, 0)
+	for rows.Next() {
+		r, err := scanReconciliation(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadStatementLines(ctx, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetReconciliation fetches a single reconciliation with its lines.
+func (s *Store) GetReconciliation(ctx context.Context, userID, reconciliationID uuid.UUID) (ledger.Reconciliation, error) {
+	r, err := scanReconciliation(s.db.QueryRow(ctx, `select `+reconciliationColumns+` from reconciliations where id = $1 and user_id = $2`, reconciliationID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	out := []ledger.Reconciliation{r}
+	if err := s.loadStatementLines(ctx, out); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	return out[0], nil
+}
+
+// CreateReconciliation inserts a reconciliation and its lines in a transaction.
+func (s *Store) CreateReconciliation(ctx context.Context, r ledger.Reconciliation) (ledger.Reconciliation, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	opening, _ := r.OpeningBalance.MinorUnits()
+	closing, _ := r.StatementBalance.MinorUnits()
+	if _, err := tx.Exec(ctx, `
+        insert into reconciliations (`+reconciliationColumns+`)
+        values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+    `, r.ID, r.UserID, r.AccountID, r.StatementDate, r.StatementBalance.Curr().Code(), opening, closing, r.Status, reconciledMinor(r), r.FinishedAt); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if err := insertStatementLines(ctx, tx, r); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	return r, nil
+}
+
+// UpdateReconciliation rewrites a reconciliation and its lines and sets the
+// status of the given journal lines, all in one transaction.
+func (s *Store) UpdateReconciliation(ctx context.Context, r ledger.Reconciliation, lineStatus map[uuid.UUID]ledger.LineStatus) (ledger.Reconciliation, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	opening, _ := r.OpeningBalance.MinorUnits()
+	ct, err := tx.Exec(ctx, `
+        update reconciliations
+        set opening_balance_minor=$1, status=$2, reconciled_balance_minor=$3, finished_at=$4
+        where id=$5 and user_id=$6
+    `, opening, r.Status, reconciledMinor(r), r.FinishedAt, r.ID, r.UserID)
+	if err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.Reconciliation{}, errs.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `delete from reconciliation_lines where reconciliation_id = $1`, r.ID); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if err := insertStatementLines(ctx, tx, r); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	for id, st := range lineStatus {
+		ct, err := tx.Exec(ctx, `
+            update entry_lines l set status = $1
+            from entries e
+            where l.id = $2 and l.entry_id = e.id and e.user_id = $3
+        `, st, id, r.UserID)
+		if err != nil {
+			return ledger.Reconciliation{}, err
+		}
+		if ct.RowsAffected() == 0 {
+			return ledger.Reconciliation{}, errs.ErrNotFound
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	return r, nil
+}
+
+func insertStatementLines(ctx context.Context, tx pgx.Tx, r ledger.Reconciliation) error {
+	for i, sl := range r.Lines {
+		minor, _ := sl.Amount.MinorUnits()
+		if _, err := tx.Exec(ctx, `
+            insert into reconciliation_lines (id, reconciliation_id, position, date, amount_minor, description, reference, journal_line_id)
+            values ($1,$2,$3,$4,$5,$6,$7,$8)
+        `, sl.ID, r.ID, i, sl.Date, minor, sl.Description, sl.Reference, sl.JournalLineID); err != nil {
+			return fmt.Errorf("insert statement line: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadStatementLines fills in the lines of rs, which share a currency per reconciliation.
+func (s *Store) loadStatementLines(ctx context.Context, rs []ledger.Reconciliation) error {
+	if len(rs) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(rs))
+	idx := make(map[uuid.UUID]*ledger.Reconciliation, len(rs))
+	for i := range rs {
+		rs[i].Lines = make([]ledger.StatementLine, 0)
+		ids = append(ids, rs[i].ID)
+		idx[rs[i].ID] = &rs[i]
+	}
+	rows, err := s.db.Query(ctx, `
+        select id, reconciliation_id, date, amount_minor, description, reference, journal_line_id
+        from reconciliation_lines
+        where reconciliation_id = any($1)
+        order by reconciliation_id, position asc
+    `, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sl ledger.StatementLine
+		var rid uuid.UUID
+		var minor int64
+		if err := rows.Scan(&sl.ID, &rid, &sl.Date, &minor, &sl.Description, &sl.Reference, &sl.JournalLineID); err != nil {
+			return err
+		}
+		r := idx[rid]
+		if r == nil {
+			continue
+		}
+		if sl.Amount, err = money.NewAmountFromMinorUnits(r.StatementBalance.Curr().Code(), minor); err != nil {
+			return err
+		}
+		sl.Date = sl.Date.UTC()
+		r.Lines = append(r.Lines, sl)
+	}
+	return rows.Err()
+}
+
+func scanReconciliation(row pgx.Row) (ledger.Reconciliation, error) {
+	var r ledger.Reconciliation
+	var curr string
+	var opening, closing int64
+	var reconciled *int64
+	var finishedAt *time.Time
+	if err := row.Scan(&r.ID, &r.UserID, &r.AccountID, &r.StatementDate, &curr, &opening, &closing, &r.Status, &reconciled, &finishedAt); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	var err error
+	if r.OpeningBalance, err = money.NewAmountFromMinorUnits(curr, opening); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if r.StatementBalance, err = money.NewAmountFromMinorUnits(curr, closing); err != nil {
+		return ledger.Reconciliation{}, err
+	}
+	if reconciled != nil {
+		amt, err := money.NewAmountFromMinorUnits(curr, *reconciled)
+		if err != nil {
+			return ledger.Reconciliation{}, err
+		}
+		r.ReconciledBalance = &amt
+	}
+	r.StatementDate = r.StatementDate.UTC()
+	r.FinishedAt = finishedAt
+	return r, nil
+}
+
+func reconciledMinor(r ledger.Reconciliation) *int64 {
+	if r.ReconciledBalance == nil {
+		return nil
+	}
+	m, _ := r.ReconciledBalance.MinorUnits()
+	return &m
+}
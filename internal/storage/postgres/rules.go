@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Categorization rules ---
+
+const ruleColumns = `id, user_id, name, priority, active, stop, match, actions`
+
+// ruleMatchJSON and ruleActionsJSON are the jsonb forms of the rule parts.
+type ruleMatchJSON struct {
+	MemoPattern     string            `json:"memo_pattern,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	MinAmountMinor  *int64            `json:"min_amount_minor,omitempty"`
+	MaxAmountMinor  *int64            `json:"max_amount_minor,omitempty"`
+	SourceAccountID *uuid.UUID        `json:"source_account_id,omitempty"`
+}
+
+type ruleActionsJSON struct {
+	Category         ledger.Category   `json:"category,omitempty"`
+	CounterAccountID *uuid.UUID        `json:"counter_account_id,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// ListRules returns all rules for a user ordered by priority.
+func (s *Store) ListRules(ctx context.Context, userID uuid.UUID) ([]ledger.Rule, error) {
+	rows, err := s.db.Query(ctx, `select `+ruleColumns+` from rules where user_id = $1 order by priority asc, name asc, id asc`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Rule, 0)
+	for rows.Next() {
+		r, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// GetRule fetches a single rule by id for a user.
+func (s *Store) GetRule(ctx context.Context, userID, ruleID uuid.UUID) (ledger.Rule, error) {
+	r, err := scanRule(s.db.QueryRow(ctx, `select `+ruleColumns+` from rules where id = $1 and user_id = $2`, ruleID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Rule{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.Rule{}, err
+	}
+	return r, nil
+}
+
+// CreateRule inserts a rule row.
+func (s *Store) CreateRule(ctx context.Context, r ledger.Rule) (ledger.Rule, error) {
+	m, a, err := marshalRuleParts(r)
+	if err != nil {
+		return ledger.Rule{}, err
+	}
+	_, err = s.db.Exec(ctx, `
+        insert into rules (`+ruleColumns+`)
+        values ($1,$2,$3,$4,$5,$6,$7,$8)
+    `, r.ID, r.UserID, r.Name, r.Priority, r.Active, r.Stop, m, a)
+	if err != nil {
+		return ledger.Rule{}, err
+	}
+	return r, nil
+}
+
+// UpdateRule replaces the mutable fields of a rule.
+func (s *Store) UpdateRule(ctx context.Context, r ledger.Rule) (ledger.Rule, error) {
+	m, a, err := marshalRuleParts(r)
+	if err != nil {
+		return ledger.Rule{}, err
+	}
+	ct, err := s.db.Exec(ctx, `
+        update rules
+        set name=$1, priority=$2, active=$3, stop=$4, match=$5, actions=$6
+        where id=$7 and user_id=$8
+    `, r.Name, r.Priority, r.Active, r.Stop, m, a, r.ID, r.UserID)
+	if err != nil {
+		return ledger.Rule{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.Rule{}, errs.ErrNotFound
+	}
+	return r, nil
+}
+
+// DeleteRule removes a rule row.
+func (s *Store) DeleteRule(ctx context.Context, userID, ruleID uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from rules where id=$1 and user_id=$2`, ruleID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+func scanRule(row pgx.Row) (ledger.Rule, error) {
+	var r ledger.Rule
+	var mb, ab []byte
+	if err := row.Scan(&r.ID, &r.UserID, &r.Name, &r.Priority, &r.Active, &r.Stop, &mb, &ab); err != nil {
+		return ledger.Rule{}, err
+	}
+	var m ruleMatchJSON
+	var a ruleActionsJSON
+	if err := json.Unmarshal(mb, &m); err != nil {
+		return ledger.Rule{}, fmt.Errorf("rule match: %w", err)
+	}
+	if err := json.Unmarshal(ab, &a); err != nil {
+		return ledger.Rule{}, fmt.Errorf("rule actions: %w", err)
+	}
+	r.Match = ledger.RuleMatch(m)
+	r.Actions = ledger.RuleActions(a)
+	return r, nil
+}
+
+func marshalRuleParts(r ledger.Rule) (match, actions []byte, err error) {
+	if match, err = json.Marshal(ruleMatchJSON(r.Match)); err != nil {
+		return nil, nil, err
+	}
+	if actions, err = json.Marshal(ruleActionsJSON(r.Actions)); err != nil {
+		return nil, nil, err
+	}
+	return match, actions, nil
+}
@@ -0,0 +1,214 @@
+package postgres
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/meta"
+)
+
+// --- Schedules ---
+
+const scheduleColumns = `id, user_id, name, rule, start_at, until_at, template, active, next_run_at, last_error`
+
+// scheduleTemplateJSON is the jsonb form of ledger.ScheduleTemplate.
+type scheduleTemplateJSON struct {
+	Currency string             `json:"currency"`
+	Memo     string             `json:"memo"`
+	Category ledger.Category    `json:"category"`
+	Metadata meta.Metadata      `json:"metadata,omitempty"`
+	Lines    []scheduleLineJSON `json:"lines"`
+}
+
+type scheduleLineJSON struct {
+	AccountID   uuid.UUID   `json:"account_id"`
+	Side        ledger.Side `json:"side"`
+	AmountMinor int64       `json:"amount_minor"`
+	// Currency and ExchangeRate are set for cross-currency lines only.
+	Currency     *string `json:"currency,omitempty"`
+	ExchangeRate *string `json:"exchange_rate,omitempty"`
+}
+
+// ListSchedules returns all schedules for a user.
+func (s *Store) ListSchedules(ctx context.Context, userID uuid.UUID) ([]ledger.Schedule, error) {
+	return s.querySchedules(ctx, `select `+scheduleColumns+` from schedules where user_id = $1 order by name asc, id asc`, userID)
+}
+
+// ListDueSchedules returns active schedules of all users due at or before now.
+func (s *Store) ListDueSchedules(ctx context.Context, now time.Time) ([]ledger.Schedule, error) {
+	return s.querySchedules(ctx, `select `+scheduleColumns+` from schedules where active and next_run_at <= $1 order by next_run_at asc`, now)
+}
+
+// GetSchedule fetches a single schedule by id for a user.
+func (s *Store) GetSchedule(ctx context.Context, userID, scheduleID uuid.UUID) (ledger.Schedule, error) {
+	sc, err := scanSchedule(s.db.QueryRow(ctx, `select `+scheduleColumns+` from schedules where id = $1 and user_id = $2`, scheduleID, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Schedule{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	return sc, nil
+}
+
+// CreateSchedule inserts a schedule row.
+func (s *Store) CreateSchedule(ctx context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	tpl, err := marshalTemplate(sc.Template)
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	_, err = s.db.Exec(ctx, `
+        insert into schedules (`+scheduleColumns+`)
+        values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
+    `, sc.ID, sc.UserID, sc.Name, sc.Rule, sc.Start, sc.Until, tpl, sc.Active, sc.NextRun, sc.LastError)
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	return sc, nil
+}
+
+// UpdateSchedule replaces the mutable fields of a schedule.
+func (s *Store) UpdateSchedule(ctx context.Context, sc ledger.Schedule) (ledger.Schedule, error) {
+	tpl, err := marshalTemplate(sc.Template)
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	ct, err := s.db.Exec(ctx, `
+        update schedules
+        set name=$1, rule=$2, start_at=$3, until_at=$4, template=$5, active=$6, next_run_at=$7, last_error=$8
+        where id=$9 and user_id=$10
+    `, sc.Name, sc.Rule, sc.Start, sc.Until, tpl, sc.Active, sc.NextRun, sc.LastError, sc.ID, sc.UserID)
+	if err != nil {
+		return ledger.Schedule{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.Schedule{}, errs.ErrNotFound
+	}
+	return sc, nil
+}
+
+// AdvanceSchedule sets a schedule's next run and last error if next_run_at is
+// still from; a schedule edited or deleted meanwhile is left alone.
+func (s *Store) AdvanceSchedule(ctx context.Context, userID, scheduleID uuid.UUID, from, next *time.Time, lastError string) error {
+	_, err := s.db.Exec(ctx, `
+        update schedules
+        set next_run_at=$1, last_error=$2
+        where id=$3 and user_id=$4 and next_run_at is not distinct from $5
+    `, next, lastError, scheduleID, userID, from)
+	return err
+}
+
+// TryLockSchedule takes a session advisory lock on the schedule without
+// waiting. The lock is held on a dedicated connection until unlock, and is
+// released by the server if this process dies. Inside a batch Tx it is a
+// transaction lock released at commit or rollback.
+func (s *Store) TryLockSchedule(ctx context.Context, scheduleID uuid.UUID) (func(), bool, error) {
+	key := int64(binary.BigEndian.Uint64(scheduleID[:8]))
+	var ok bool
+	if s.pool == nil {
+		err := s.db.QueryRow(ctx, `select pg_try_advisory_xact_lock($1)`, key).Scan(&ok)
+		return func() {}, ok, err
+	}
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := conn.QueryRow(ctx, `select pg_try_advisory_lock($1)`, key).Scan(&ok); err != nil || !ok {
+		conn.Release()
+		return nil, false, err
+	}
+	return func() {
+		_, _ = conn.Exec(context.Background(), `select pg_advisory_unlock($1)`, key)
+		conn.Release()
+	}, true, nil
+}
+
+// DeleteSchedule removes a schedule row; entries it posted are kept.
+func (s *Store) DeleteSchedule(ctx context.Context, userID, scheduleID uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from schedules where id=$1 and user_id=$2`, scheduleID, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+func (s *Store) querySchedules(ctx context.Context, sql string, args ...any) ([]ledger.Schedule, error) {
+	rows, err := s.db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Schedule, 0)
+	for rows.Next() {
+		sc, err := scanSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sc)
+	}
+	return out, rows.Err()
+}
+
+func scanSchedule(row pgx.Row) (ledger.Schedule, error) {
+	var sc ledger.Schedule
+	var tpl []byte
+	if err := row.Scan(&sc.ID, &sc.UserID, &sc.Name, &sc.Rule, &sc.Start, &sc.Until, &tpl, &sc.Active, &sc.NextRun, &sc.LastError); err != nil {
+		return ledger.Schedule{}, err
+	}
+	var err error
+	if sc.Template, err = unmarshalTemplate(tpl); err != nil {
+		return ledger.Schedule{}, err
+	}
+	sc.Start = sc.Start.UTC()
+	for _, t := range []*time.Time{sc.Until, sc.NextRun} {
+		if t != nil {
+			*t = t.UTC()
+		}
+	}
+	return sc, nil
+}
+
+func marshalTemplate(t ledger.ScheduleTemplate) ([]byte, error) {
+	out := scheduleTemplateJSON{Currency: t.Currency, Memo: t.Memo, Category: t.Category, Metadata: t.Metadata, Lines: make([]scheduleLineJSON, 0, len(t.Lines))}
+	for _, ln := range t.Lines {
+		minor, _ := ln.Amount.MinorUnits()
+		l := scheduleLineJSON{AccountID: ln.AccountID, Side: ln.Side, AmountMinor: minor}
+		if curr := ln.Amount.Curr().Code(); curr != t.Currency {
+			l.Currency = &curr
+		}
+		if ln.Rate != nil {
+			r := ln.Rate.Decimal().String()
+			l.ExchangeRate = &r
+		}
+		out.Lines = append(out.Lines, l)
+	}
+	return json.Marshal(out)
+}
+
+func unmarshalTemplate(b []byte) (ledger.ScheduleTemplate, error) {
+	var in scheduleTemplateJSON
+	if err := json.Unmarshal(b, &in); err != nil {
+		return ledger.ScheduleTemplate{}, fmt.Errorf("schedule template: %w", err)
+	}
+	t := ledger.ScheduleTemplate{Currency: in.Currency, Memo: in.Memo, Category: in.Category, Metadata: in.Metadata, Lines: make([]ledger.JournalLine, 0, len(in.Lines))}
+	for _, l := range in.Lines {
+		ln := ledger.JournalLine{AccountID: l.AccountID, Side: l.Side}
+		if err := scanLineAmount(&ln, in.Currency, l.AmountMinor, 0, l.Currency, l.ExchangeRate); err != nil {
+			return ledger.ScheduleTemplate{}, err
+		}
+		t.Lines = append(t.Lines, ln)
+	}
+	return t, nil
+}
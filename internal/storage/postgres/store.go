@@ -16,17 +16,31 @@ import (
 	"github.com/google/uuid"
 	"github.com/govalues/money"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
 	"github.com/tinoosan/ledger/internal/meta"
+	"github.com/tinoosan/ledger/internal/service/journal"
 )
 
 // Store holds a pgx connection pool and implements the read/write interfaces
 // used across the service layer. All methods are safe for concurrent use.
 type Store struct {
 	pool *pgxpool.Pool
+	// db runs every statement: the pool, or the transaction of a batch Tx.
+	db querier
+}
+
+// querier is the subset of pgx shared by pools and transactions. Begin on a
+// transaction opens a savepoint, so methods that run their own transaction
+// nest inside a batch.
+type querier interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 }
 
 // Open establishes a pgx pool using the provided connection string.
@@ -44,7 +58,7 @@ func Open(ctx context.Context, dsn string) (*Store, error) {
 		pool.Close()
 		return nil, err
 	}
-	return &Store{pool: pool}, nil
+	return &Store{pool: pool, db: pool}, nil
 }
 
 // Close releases the underlying pool.
@@ -60,7 +74,7 @@ func (s *Store) Ready(ctx context.Context) error { return s.pool.Ping(ctx) }
 // SeedDev inserts a single user and three accounts (Opening Balances, Cash, Income)
 // for quick local testing. It is idempotent per run due to fresh UUIDs.
 func (s *Store) SeedDev(ctx context.Context) (ledger.User, []ledger.Account, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return ledger.User{}, nil, err
 	}
@@ -96,7 +110,7 @@ func (s *Store) FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.
 	if len(ids) == 0 {
 		return map[uuid.UUID]ledger.Account{}, nil
 	}
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.db.Query(ctx, `
         select id, user_id, name, currency, type, "group", vendor, metadata, system, active
         from accounts
         where user_id = $1 and id = any($2)
@@ -125,7 +139,7 @@ func (s *Store) FetchAccounts(ctx context.Context, userID uuid.UUID, ids []uuid.
 
 // ListAccounts returns all accounts for a user.
 func (s *Store) ListAccounts(ctx context.Context, userID uuid.UUID) ([]ledger.Account, error) {
-	rows, err := s.pool.Query(ctx, `
+	rows, err := s.db.Query(ctx, `
         select id, user_id, name, currency, type, "group", vendor, metadata, system, active
         from accounts
         where user_id = $1
@@ -157,7 +171,7 @@ func (s *Store) ListAccounts(ctx context.Context, userID uuid.UUID) ([]ledger.Ac
 func (s *Store) GetAccount(ctx context.Context, userID, accountID uuid.UUID) (ledger.Account, error) {
 	var a ledger.Account
 	var mdBytes []byte
-	err := s.pool.QueryRow(ctx, `
+	err := s.db.QueryRow(ctx, `
         select id, user_id, name, currency, type, "group", vendor, metadata, system, active
         from accounts
         where id = $1 and user_id = $2
@@ -185,7 +199,7 @@ func (s *Store) CreateAccount(ctx context.Context, a ledger.Account) (ledger.Acc
 		return ledger.Account{}, err
 	}
 	md, _ := a.Metadata.MarshalStableJSON()
-	_, err := s.pool.Exec(ctx, `
+	_, err := s.db.Exec(ctx, `
         insert into accounts (id, user_id, name, currency, type, "group", vendor, metadata, system, active)
         values ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10)
     `, a.ID, a.UserID, a.Name, strings.ToUpper(a.Currency), a.Type, strings.ToLower(a.Group), a.Vendor, md, a.System, a.Active)
@@ -201,30 +215,55 @@ func (s *Store) UpdateAccount(ctx context.Context, a ledger.Account) (ledger.Acc
 		return ledger.Account{}, err
 	}
 	md, _ := a.Metadata.MarshalStableJSON()
-	ct, err := s.pool.Exec(ctx, `
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return ledger.Account{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	// Lock the row so the outbox event compares against the state it replaces.
+	if _, err := tx.Exec(ctx, `select 1 from accounts where id=$1 and user_id=$2 for update`, a.ID, a.UserID); err != nil {
+		return ledger.Account{}, err
+	}
+	before, err := (&Store{db: tx}).GetAccount(ctx, a.UserID, a.ID)
+	if err != nil {
+		return ledger.Account{}, err
+	}
+	if _, err := tx.Exec(ctx, `
         update accounts
         set name=$1, "group"=$2, vendor=$3, metadata=$4, active=$5
         where id=$6 and user_id=$7
-    `, a.Name, strings.ToLower(a.Group), a.Vendor, md, a.Active, a.ID, a.UserID)
-	if err != nil {
+    `, a.Name, strings.ToLower(a.Group), a.Vendor, md, a.Active, a.ID, a.UserID); err != nil {
 		return ledger.Account{}, err
 	}
-	if ct.RowsAffected() == 0 {
-		return ledger.Account{}, errs.ErrNotFound
+	after := before
+	after.Name, after.Group, after.Vendor, after.Metadata, after.Active = a.Name, strings.ToLower(a.Group), a.Vendor, a.Metadata, a.Active
+	if err := insertEvents(ctx, tx, ledger.AccountEvents(before, after)); err != nil {
+		return ledger.Account{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ledger.Account{}, err
 	}
 	return a, nil
 }
 
 // --- Entry reads ---
 
+const entryColumns = `id, user_id, date, currency, memo, category, metadata, is_reversed`
+
 // ListEntries returns entries for a user with lines populated.
 func (s *Store) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.JournalEntry, error) {
-	rows, err := s.pool.Query(ctx, `
-        select id, user_id, date, currency, memo, category, metadata, is_reversed
+	return s.selectEntries(ctx, `
+        select `+entryColumns+`
         from entries
         where user_id = $1
         order by date asc, id asc
     `, userID)
+}
+
+// selectEntries runs a query over entries returning entryColumns and loads
+// the lines and relations of the rows, keeping the query's order.
+func (s *Store) selectEntries(ctx context.Context, sql string, args ...any) ([]ledger.JournalEntry, error) {
+	rows, err := s.db.Query(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -251,8 +290,8 @@ func (s *Store) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.Jou
 		return entries, nil
 	}
 	// Load lines for these entries
-	lineRows, err := s.pool.Query(ctx, `
-        select id, entry_id, account_id, side, amount_minor
+	lineRows, err := s.db.Query(ctx, `
+        select id, entry_id, account_id, side, amount_minor, currency, exchange_rate::text, status, reversed_minor
         from entry_lines
         where entry_id = any($1)
         order by id asc
@@ -269,29 +308,90 @@ func (s *Store) ListEntries(ctx context.Context, userID uuid.UUID) ([]ledger.Jou
 	for lineRows.Next() {
 		var id, entryID, accountID uuid.UUID
 		var side string
-		var minor int64
-		if err := lineRows.Scan(&id, &entryID, &accountID, &side, &minor); err != nil {
+		var minor, reversed int64
+		var curr, rate *string
+		var status string
+		if err := lineRows.Scan(&id, &entryID, &accountID, &side, &minor, &curr, &rate, &status, &reversed); err != nil {
 			return nil, err
 		}
 		e := idx[entryID]
 		if e == nil {
 			continue
 		}
-		amt, _ := money.NewAmountFromMinorUnits(e.Currency, minor)
-		ln := &ledger.JournalLine{ID: id, EntryID: entryID, AccountID: accountID, Side: ledger.Side(side), Amount: amt, Metadata: nil}
+		ln := &ledger.JournalLine{ID: id, EntryID: entryID, AccountID: accountID, Side: ledger.Side(side), Metadata: nil, Status: ledger.LineStatus(status)}
+		if err := scanLineAmount(ln, e.Currency, minor, reversed, curr, rate); err != nil {
+			return nil, err
+		}
 		if e.Lines.ByID == nil {
 			e.Lines.ByID = map[uuid.UUID]*ledger.JournalLine{}
 		}
 		e.Lines.ByID[id] = ln
 	}
-	return entries, lineRows.Err()
+	if err := lineRows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadRelations(ctx, idx); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// QueryEntries returns a page of a user's entries matching f in (date, id)
+// order. The cursor and date range are compared as (date, id) against
+// ix_entries_user_date_id, so a page reads only the rows it returns.
+func (s *Store) QueryEntries(ctx context.Context, f journal.EntryFilter) ([]ledger.JournalEntry, error) {
+	args := []any{f.UserID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := []string{"e.user_id = $1"}
+	if f.From != nil {
+		where = append(where, "e.date >= "+arg(*f.From))
+	}
+	if f.To != nil {
+		where = append(where, "e.date <= "+arg(*f.To))
+	}
+	if f.After != nil {
+		where = append(where, "(e.date, e.id) > ("+arg(f.After.Date)+", "+arg(f.After.ID)+")")
+	}
+	if f.Currency != "" {
+		where = append(where, "e.currency = "+arg(strings.ToUpper(f.Currency)))
+	}
+	if f.Category != "" {
+		where = append(where, "lower(e.category) = lower("+arg(f.Category)+")")
+	}
+	if f.Memo != "" {
+		where = append(where, "lower(e.memo) = lower("+arg(f.Memo)+")")
+	}
+	if f.IsReversed != nil {
+		where = append(where, "e.is_reversed = "+arg(*f.IsReversed))
+	}
+	if f.AccountID != uuid.Nil {
+		where = append(where, "exists (select 1 from entry_lines l where l.entry_id = e.id and l.account_id = "+arg(f.AccountID)+")")
+	}
+	if f.Relation != "" || f.RelatedTo != uuid.Nil {
+		rel := []string{"r.entry_id = e.id"}
+		if f.Relation != "" {
+			rel = append(rel, "r.kind = "+arg(string(f.Relation)))
+		}
+		if f.RelatedTo != uuid.Nil {
+			rel = append(rel, "r.related_entry_id = "+arg(f.RelatedTo))
+		}
+		where = append(where, "exists (select 1 from entry_relations r where "+strings.Join(rel, " and ")+")")
+	}
+	sql := `select ` + entryColumns + ` from entries e where ` + strings.Join(where, " and ") + ` order by e.date asc, e.id asc`
+	if f.Limit > 0 {
+		sql += " limit " + arg(f.Limit)
+	}
+	return s.selectEntries(ctx, sql, args...)
 }
 
 // GetEntry returns an entry by id for a user with lines populated.
 func (s *Store) GetEntry(ctx context.Context, userID, entryID uuid.UUID) (ledger.JournalEntry, error) {
 	var e ledger.JournalEntry
 	var mdBytes []byte
-	err := s.pool.QueryRow(ctx, `
+	err := s.db.QueryRow(ctx, `
         select id, user_id, date, currency, memo, category, metadata, is_reversed
         from entries
         where id = $1 and user_id = $2
@@ -309,8 +409,8 @@ func (s *Store) GetEntry(ctx context.Context, userID, entryID uuid.UUID) (ledger
 		}
 	}
 	e.Lines = ledger.JournalLines{ByID: map[uuid.UUID]*ledger.JournalLine{}}
-	rows, err := s.pool.Query(ctx, `
-        select id, account_id, side, amount_minor
+	rows, err := s.db.Query(ctx, `
+        select id, account_id, side, amount_minor, currency, exchange_rate::text, status, reversed_minor
         from entry_lines
         where entry_id = $1
         order by id asc
@@ -322,24 +422,90 @@ func (s *Store) GetEntry(ctx context.Context, userID, entryID uuid.UUID) (ledger
 	for rows.Next() {
 		var id, accountID uuid.UUID
 		var side string
-		var minor int64
-		if err := rows.Scan(&id, &accountID, &side, &minor); err != nil {
+		var minor, reversed int64
+		var curr, rate *string
+		var status string
+		if err := rows.Scan(&id, &accountID, &side, &minor, &curr, &rate, &status, &reversed); err != nil {
+			return ledger.JournalEntry{}, err
+		}
+		ln := &ledger.JournalLine{ID: id, EntryID: entryID, AccountID: accountID, Side: ledger.Side(side), Status: ledger.LineStatus(status)}
+		if err := scanLineAmount(ln, e.Currency, minor, reversed, curr, rate); err != nil {
 			return ledger.JournalEntry{}, err
 		}
-		amt, _ := money.NewAmountFromMinorUnits(e.Currency, minor)
-		e.Lines.ByID[id] = &ledger.JournalLine{ID: id, EntryID: entryID, AccountID: accountID, Side: ledger.Side(side), Amount: amt}
+		e.Lines.ByID[id] = ln
 	}
 	if err := rows.Err(); err != nil {
 		return ledger.JournalEntry{}, err
 	}
+	if err := s.loadRelations(ctx, map[uuid.UUID]*ledger.JournalEntry{e.ID: &e}); err != nil {
+		return ledger.JournalEntry{}, err
+	}
 	return e, nil
 }
 
+// loadRelations fills in the relations of the indexed entries.
+func (s *Store) loadRelations(ctx context.Context, idx map[uuid.UUID]*ledger.JournalEntry) error {
+	ids := make([]uuid.UUID, 0, len(idx))
+	for id := range idx {
+		ids = append(ids, id)
+	}
+	rows, err := s.db.Query(ctx, `
+        select entry_id, kind, related_entry_id
+        from entry_relations
+        where entry_id = any($1)
+        order by entry_id, position
+    `, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entryID, relatedID uuid.UUID
+		var kind string
+		if err := rows.Scan(&entryID, &kind, &relatedID); err != nil {
+			return err
+		}
+		if e := idx[entryID]; e != nil {
+			e.Relations = append(e.Relations, ledger.EntryRelation{Kind: ledger.RelationKind(kind), EntryID: relatedID})
+		}
+	}
+	return rows.Err()
+}
+
+// scanLineAmount rebuilds a line's amount, reversed part and optional exchange rate.
+// Lines without a stored currency are denominated in the entry currency.
+func scanLineAmount(ln *ledger.JournalLine, entryCurr string, minor, reversed int64, curr, rate *string) error {
+	lineCurr := entryCurr
+	if curr != nil && *curr != "" {
+		lineCurr = strings.TrimSpace(*curr)
+	}
+	amt, err := money.NewAmountFromMinorUnits(lineCurr, minor)
+	if err != nil {
+		return err
+	}
+	ln.Amount = amt
+	if reversed > 0 {
+		r, err := money.NewAmountFromMinorUnits(lineCurr, reversed)
+		if err != nil {
+			return err
+		}
+		ln.Reversed = &r
+	}
+	if rate != nil {
+		r, err := money.ParseExchRate(lineCurr, entryCurr, *rate)
+		if err != nil {
+			return fmt.Errorf("parse exchange rate: %w", err)
+		}
+		ln.Rate = &r
+	}
+	return nil
+}
+
 // --- Entry writes ---
 
 // CreateJournalEntry inserts an entry + its lines in a transaction.
 func (s *Store) CreateJournalEntry(ctx context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return ledger.JournalEntry{}, err
 	}
@@ -353,21 +519,206 @@ func (s *Store) CreateJournalEntry(ctx context.Context, entry ledger.JournalEntr
 	return entry, nil
 }
 
-// UpdateJournalEntry updates fields of an entry (currently used to mark reversed).
+// UpdateJournalEntry updates fields of an entry, the reversed part of its
+// lines and its relations (used to record reversals and links).
 func (s *Store) UpdateJournalEntry(ctx context.Context, entry ledger.JournalEntry) (ledger.JournalEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := updateEntry(ctx, tx, entry); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	return entry, nil
+}
+
+// ReverseJournalEntry creates rev (and corr when set), records upd's reversed
+// state on the original entry and links it to them in one transaction. The
+// original row is locked first, so a concurrent reversal waits and then fails
+// with errs.ErrConflict instead of reversing the same remainder twice. Only the
+// reversed state and the new relation rows are written, so links added since
+// orig was read are kept.
+func (s *Store) ReverseJournalEntry(ctx context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	var isReversed bool
+	err = tx.QueryRow(ctx, `select is_reversed from entries where id=$1 and user_id=$2 for update`, orig.ID, orig.UserID).Scan(&isReversed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.JournalEntry{}, errs.ErrNotFound
+	}
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	if isReversed != orig.IsReversed {
+		return ledger.JournalEntry{}, errs.ErrConflict
+	}
+	rows, err := tx.Query(ctx, `select id, reversed_minor from entry_lines where entry_id=$1`, orig.ID)
+	if err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	changed := false
+	for rows.Next() {
+		var id uuid.UUID
+		var reversed int64
+		if err := rows.Scan(&id, &reversed); err != nil {
+			rows.Close()
+			return ledger.JournalEntry{}, err
+		}
+		if ln, ok := orig.Lines.ByID[id]; !ok || ln.ReversedMinor() != reversed {
+			changed = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	if changed {
+		return ledger.JournalEntry{}, errs.ErrConflict
+	}
+	if err := createEntry(ctx, tx, rev); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	links := []journal.EntryLink{{From: orig.ID, Kind: ledger.RelationReversedBy, To: rev.ID}}
+	if corr != nil {
+		if err := createEntry(ctx, tx, *corr); err != nil {
+			return ledger.JournalEntry{}, err
+		}
+		links = append(links, journal.EntryLink{From: orig.ID, Kind: ledger.RelationReclassifiedTo, To: corr.ID})
+	}
+	if _, err := tx.Exec(ctx, `update entries set is_reversed=$1 where id=$2`, upd.IsReversed, orig.ID); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	for _, ln := range upd.Lines.ByID {
+		if _, err := tx.Exec(ctx, `
+            update entry_lines set reversed_minor=$1
+            where id=$2 and entry_id=$3 and reversed_minor <> $1
+        `, ln.ReversedMinor(), ln.ID, orig.ID); err != nil {
+			return ledger.JournalEntry{}, err
+		}
+	}
+	if err := addRelations(ctx, tx, links); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return ledger.JournalEntry{}, err
+	}
+	return rev, nil
+}
+
+// AddEntryRelations records links in one transaction, skipping those already
+// recorded. The entries are locked first so concurrent writes to their
+// relations apply one after the other.
+func (s *Store) AddEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := lockLinkedEntries(ctx, tx, userID, links); err != nil {
+		return err
+	}
+	if err := addRelations(ctx, tx, links); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// RemoveEntryRelations deletes links in one transaction.
+func (s *Store) RemoveEntryRelations(ctx context.Context, userID uuid.UUID, links []journal.EntryLink) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := lockLinkedEntries(ctx, tx, userID, links); err != nil {
+		return err
+	}
+	for _, l := range links {
+		if _, err := tx.Exec(ctx, `
+            delete from entry_relations where entry_id=$1 and kind=$2 and related_entry_id=$3
+        `, l.From, string(l.Kind), l.To); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// lockLinkedEntries locks both ends of every link, in id order so two link
+// writes cannot deadlock, and fails with errs.ErrNotFound unless all of them
+// are entries of userID.
+func lockLinkedEntries(ctx context.Context, ex pgx.Tx, userID uuid.UUID, links []journal.EntryLink) error {
+	ids := make(map[uuid.UUID]struct{}, 2*len(links))
+	for _, l := range links {
+		ids[l.From] = struct{}{}
+		ids[l.To] = struct{}{}
+	}
+	list := make([]uuid.UUID, 0, len(ids))
+	for id := range ids {
+		list = append(list, id)
+	}
+	var n int
+	if err := ex.QueryRow(ctx, `
+        select count(*) from (
+            select id from entries where id = any($1) and user_id = $2 order by id for update
+        ) locked
+    `, list, userID).Scan(&n); err != nil {
+		return err
+	}
+	if n != len(list) {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+// addRelations appends each link not yet recorded after its entry's last relation.
+func addRelations(ctx context.Context, ex pgx.Tx, links []journal.EntryLink) error {
+	for _, l := range links {
+		if _, err := ex.Exec(ctx, `
+            insert into entry_relations (entry_id, position, kind, related_entry_id)
+            select $1, coalesce(max(position) + 1, 0), $2, $3
+            from entry_relations where entry_id = $1
+            having not coalesce(bool_or(kind = $2 and related_entry_id = $3), false)
+        `, l.From, string(l.Kind), l.To); err != nil {
+			return fmt.Errorf("insert relation: %w", err)
+		}
+	}
+	return nil
+}
+
+// updateEntry writes the mutable fields, reversed amounts and relations of an
+// entry within the provided executor.
+func updateEntry(ctx context.Context, ex pgx.Tx, entry ledger.JournalEntry) error {
 	md, _ := entry.Metadata.MarshalStableJSON()
-	ct, err := s.pool.Exec(ctx, `
+	ct, err := ex.Exec(ctx, `
         update entries
         set memo=$1, category=$2, metadata=$3, is_reversed=$4
         where id=$5 and user_id=$6
     `, entry.Memo, entry.Category, md, entry.IsReversed, entry.ID, entry.UserID)
 	if err != nil {
-		return ledger.JournalEntry{}, err
+		return err
 	}
 	if ct.RowsAffected() == 0 {
-		return ledger.JournalEntry{}, errs.ErrNotFound
+		return errs.ErrNotFound
 	}
-	return entry, nil
+	for _, ln := range entry.Lines.ByID {
+		if _, err := ex.Exec(ctx, `
+            update entry_lines set reversed_minor=$1
+            where id=$2 and entry_id=$3 and reversed_minor <> $1
+        `, ln.ReversedMinor(), ln.ID, entry.ID); err != nil {
+			return err
+		}
+	}
+	if _, err := ex.Exec(ctx, `delete from entry_relations where entry_id=$1`, entry.ID); err != nil {
+		return err
+	}
+	return insertRelations(ctx, ex, entry)
 }
 
 // --- Idempotency ---
@@ -375,7 +726,7 @@ func (s *Store) UpdateJournalEntry(ctx context.Context, entry ledger.JournalEntr
 // GetEntryByIdempotencyKey resolves an entry by idempotency key for the user.
 func (s *Store) GetEntryByIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) (ledger.JournalEntry, bool, error) {
 	var id uuid.UUID
-	err := s.pool.QueryRow(ctx, `
+	err := s.db.QueryRow(ctx, `
         select entry_id from entry_idempotency where user_id=$1 and key=$2
     `, userID, key).Scan(&id)
 	if errors.Is(err, pgx.ErrNoRows) {
@@ -393,7 +744,7 @@ func (s *Store) GetEntryByIdempotencyKey(ctx context.Context, userID uuid.UUID,
 
 // SaveIdempotencyKey stores a mapping from (user,key) to entry id.
 func (s *Store) SaveIdempotencyKey(ctx context.Context, userID uuid.UUID, key string, entryID uuid.UUID) error {
-	_, err := s.pool.Exec(ctx, `
+	_, err := s.db.Exec(ctx, `
         insert into entry_idempotency (user_id, key, entry_id)
         values ($1,$2,$3)
         on conflict (user_id, key) do nothing
@@ -405,15 +756,19 @@ func (s *Store) SaveIdempotencyKey(ctx context.Context, userID uuid.UUID, key st
 
 // BeginTx creates a batch transaction wrapper used by service batch endpoints.
 func (s *Store) BeginTx(ctx context.Context) (*Tx, error) {
-	tx, err := s.pool.Begin(ctx)
+	tx, err := s.db.Begin(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return &Tx{tx: tx}, nil
+	return &Tx{Store: &Store{db: tx}, tx: tx}, nil
 }
 
-// Tx wraps a pgx.Tx and implements the minimal methods used in batch flows.
-type Tx struct{ tx pgx.Tx }
+// Tx wraps a pgx.Tx. Its embedded Store runs every read and write inside the
+// transaction, so services built on a Tx see their own uncommitted changes.
+type Tx struct {
+	*Store
+	tx pgx.Tx
+}
 
 func (t *Tx) CreateAccount(ctx context.Context, a ledger.Account) (ledger.Account, error) {
 	if err := a.Metadata.Validate(); err != nil {
@@ -446,17 +801,49 @@ func createEntry(ctx context.Context, ex pgx.Tx, e ledger.JournalEntry) error {
         insert into entries (id, user_id, date, currency, memo, category, metadata, is_reversed)
         values ($1,$2,$3,$4,$5,$6,$7,$8)
     `, e.ID, e.UserID, e.Date, strings.ToUpper(e.Currency), e.Memo, e.Category, md, e.IsReversed); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.ConstraintName == "ux_entries_user_input_hash" {
+			return fmt.Errorf("%w: input hash already imported", errs.ErrConflict)
+		}
 		return err
 	}
 	// lines
 	for _, ln := range e.Lines.ByID {
 		minor, _ := ln.Amount.MinorUnits()
+		// Currency and rate are only stored for cross-currency lines.
+		var curr, rate *string
+		if c := ln.Amount.Curr().Code(); !strings.EqualFold(c, e.Currency) {
+			curr = &c
+		}
+		if ln.Rate != nil {
+			r := ln.Rate.Decimal().String()
+			rate = &r
+		}
 		if _, err := ex.Exec(ctx, `
-            insert into entry_lines (id, entry_id, account_id, side, amount_minor)
-            values ($1,$2,$3,$4,$5)
-        `, ln.ID, e.ID, ln.AccountID, ln.Side, minor); err != nil {
+            insert into entry_lines (id, entry_id, account_id, side, amount_minor, currency, exchange_rate, status, reversed_minor)
+            values ($1,$2,$3,$4,$5,$6,$7::numeric,$8,$9)
+        `, ln.ID, e.ID, ln.AccountID, ln.Side, minor, curr, rate, ln.ClearStatus(), ln.ReversedMinor()); err != nil {
 			return fmt.Errorf("insert line: %w", err)
 		}
 	}
+	if err := applyBalances(ctx, ex, e); err != nil {
+		return fmt.Errorf("apply balances: %w", err)
+	}
+	if err := insertRelations(ctx, ex, e); err != nil {
+		return err
+	}
+	return insertEvents(ctx, ex, ledger.EntryEvents(e))
+}
+
+// insertRelations stores the relations of e in order.
+func insertRelations(ctx context.Context, ex pgx.Tx, e ledger.JournalEntry) error {
+	for i, r := range e.Relations {
+		if _, err := ex.Exec(ctx, `
+            insert into entry_relations (entry_id, position, kind, related_entry_id)
+            values ($1,$2,$3,$4)
+        `, e.ID, i, string(r.Kind), r.EntryID); err != nil {
+			return fmt.Errorf("insert relation: %w", err)
+		}
+	}
 	return nil
 }
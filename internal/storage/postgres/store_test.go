@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -11,7 +12,18 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/govalues/money"
+	"github.com/tinoosan/ledger/internal/errs"
 	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/audit"
+	"github.com/tinoosan/ledger/internal/service/journal"
+	"github.com/tinoosan/ledger/internal/service/schedule"
+)
+
+// The API wires optional features by type assertion; keep the store in step.
+var (
+	_ schedule.Repo   = (*Store)(nil)
+	_ schedule.Writer = (*Store)(nil)
+	_ schedule.Locker = (*Store)(nil)
 )
 
 func getTestDSN(t *testing.T) string {
@@ -66,7 +78,7 @@ func truncateAll(t *testing.T, dsn string) {
 		t.Fatalf("open for truncate: %v", err)
 	}
 	defer s.Close()
-	_, _ = s.pool.Exec(ctx, `truncate table entry_idempotency, entry_lines, entries, accounts, users cascade`)
+	_, _ = s.pool.Exec(ctx, `truncate table webhook_deliveries, webhook_endpoints, outbox_events, audit_log, account_daily_balances, account_balances, balance_assertions, reconciliation_lines, reconciliations, import_profiles, rules, schedules, budgets, fx_rates, periods, entry_idempotency, entry_relations, entry_lines, entries, accounts, users cascade`)
 }
 
 func TestStore_AccountsAndEntries(t *testing.T) {
@@ -146,11 +158,108 @@ func TestStore_AccountsAndEntries(t *testing.T) {
 		t.Fatalf("expected >=1 entry")
 	}
 
-	// Update entry (mark reversed)
+	// Update entry (mark reversed by a second entry and link both ways)
+	rev := newBalancedEntry(user.ID, a2.ID, a1.ID, amt)
+	rev.Relations = []ledger.EntryRelation{{Kind: ledger.RelationReverses, EntryID: created.ID}}
+	if _, err := s.CreateJournalEntry(ctx, rev); err != nil {
+		t.Fatalf("create reversal: %v", err)
+	}
 	gotE.IsReversed = true
+	gotE.Relations = []ledger.EntryRelation{{Kind: ledger.RelationReversedBy, EntryID: rev.ID}}
 	if _, err := s.UpdateJournalEntry(ctx, gotE); err != nil {
 		t.Fatalf("update entry: %v", err)
 	}
+	if again, err := s.GetEntry(ctx, user.ID, created.ID); err != nil || !again.HasRelation(ledger.RelationReversedBy, rev.ID) || len(again.Relations) != 1 {
+		t.Fatalf("relations not persisted: %v %+v", err, again.Relations)
+	}
+	link := []journal.EntryLink{{From: created.ID, Kind: ledger.RelationRelated, To: rev.ID}}
+	for i := 0; i < 2; i++ {
+		if err := s.AddEntryRelations(ctx, user.ID, link); err != nil {
+			t.Fatalf("add relation: %v", err)
+		}
+	}
+	if again, _ := s.GetEntry(ctx, user.ID, created.ID); len(again.Relations) != 2 || !again.HasRelation(ledger.RelationRelated, rev.ID) {
+		t.Fatalf("related link not added once: %+v", again.Relations)
+	}
+	if err := s.RemoveEntryRelations(ctx, user.ID, link); err != nil {
+		t.Fatalf("remove relation: %v", err)
+	}
+	if again, _ := s.GetEntry(ctx, user.ID, created.ID); len(again.Relations) != 1 {
+		t.Fatalf("related link not removed: %+v", again.Relations)
+	}
+	if err := s.AddEntryRelations(ctx, uuid.New(), link); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("another user's link expected ErrNotFound, got %v", err)
+	}
+
+	// Balances: maintained by the entry writes and equal after a rebuild
+	for _, rebuild := range []bool{false, true} {
+		if rebuild {
+			if err := s.RebuildBalances(ctx, user.ID); err != nil {
+				t.Fatalf("rebuild balances: %v", err)
+			}
+		}
+		nets, err := s.AccountBalances(ctx, user.ID, nil, nil)
+		if err != nil {
+			t.Fatalf("balances: %v", err)
+		}
+		if n, _ := nets[a1.ID].MinorUnits(); len(nets) != 2 || n != 0 {
+			t.Fatalf("unexpected balances (rebuild=%v): %v", rebuild, nets)
+		}
+		// Dates are stored to the microsecond, possibly rounded up.
+		asOf := created.Date.Add(time.Microsecond)
+		nets, err = s.AccountBalances(ctx, user.ID, []uuid.UUID{a1.ID}, &asOf)
+		if err != nil {
+			t.Fatalf("balances as of: %v", err)
+		}
+		if n, _ := nets[a1.ID].MinorUnits(); len(nets) != 1 || n != 1234 {
+			t.Fatalf("unexpected balances as of (rebuild=%v): %v", rebuild, nets)
+		}
+	}
+
+	// Entry queries: keyset pages in (date, id) order with filters
+	first, err := s.QueryEntries(ctx, journal.EntryFilter{UserID: user.ID, AccountID: a1.ID, Limit: 1})
+	if err != nil || len(first) != 1 || first[0].ID != created.ID || len(first[0].Lines.ByID) != 2 {
+		t.Fatalf("query first page: %v %+v", err, first)
+	}
+	next, err := s.QueryEntries(ctx, journal.EntryFilter{UserID: user.ID, AccountID: a1.ID, Limit: 1, After: &journal.EntryKey{Date: first[0].Date, ID: first[0].ID}})
+	if err != nil || len(next) != 1 || next[0].ID != rev.ID || !next[0].HasRelation(ledger.RelationReverses, created.ID) {
+		t.Fatalf("query next page: %v %+v", err, next)
+	}
+	reversed := true
+	if got, err := s.QueryEntries(ctx, journal.EntryFilter{UserID: user.ID, IsReversed: &reversed, Memo: "TEST-ENTRY", Relation: ledger.RelationReversedBy}); err != nil || len(got) != 1 || got[0].ID != created.ID {
+		t.Fatalf("query filtered: %v %+v", err, got)
+	}
+
+	// Outbox: the writes above raised events in the same transactions
+	events, err := s.ListUndispatchedEvents(ctx, 100)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	seen := map[ledger.EventType]int{}
+	for _, ev := range events {
+		seen[ev.Type]++
+	}
+	if seen[ledger.EventAccountUpdated] != 1 || seen[ledger.EventEntryCreated] != 2 || seen[ledger.EventEntryReversed] != 1 {
+		t.Fatalf("unexpected outbox events: %v", seen)
+	}
+	if err := s.MarkEventDispatched(ctx, events[0].ID, nil); err != nil {
+		t.Fatalf("mark dispatched: %v", err)
+	}
+	if rest, _ := s.ListUndispatchedEvents(ctx, 100); len(rest) != len(events)-1 {
+		t.Fatalf("expected %d undispatched events, got %d", len(events)-1, len(rest))
+	}
+
+	// Audit log: appended in order, filtered by entity
+	for _, op := range []ledger.AuditOperation{ledger.AuditCreate, ledger.AuditReverse} {
+		rec := ledger.AuditRecord{ID: uuid.New(), UserID: user.ID, Actor: "tester", Operation: op, Entity: ledger.AuditEntityEntry, EntityID: created.ID, After: []byte(`{"id":"x"}`), CreatedAt: time.Now().UTC()}
+		if _, err := s.AppendAuditRecord(ctx, rec); err != nil {
+			t.Fatalf("append audit: %v", err)
+		}
+	}
+	recs, err := s.ListAuditRecords(ctx, audit.Filter{UserID: user.ID, EntityID: created.ID, Limit: 10})
+	if err != nil || len(recs) != 2 || recs[1].Operation != ledger.AuditReverse || recs[0].Seq >= recs[1].Seq || recs[0].Before != nil {
+		t.Fatalf("list audit: %v %+v", err, recs)
+	}
 
 	// Idempotency mapping
 	key := "test-key-1"
@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+
+	"github.com/tinoosan/ledger/internal/errs"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+// --- Outbox ---
+
+// insertEvents writes events to the outbox within the caller's transaction.
+func insertEvents(ctx context.Context, ex pgx.Tx, events []ledger.Event) error {
+	for _, ev := range events {
+		if _, err := ex.Exec(ctx, `
+            insert into outbox_events (id, user_id, type, entity_id, payload, created_at)
+            values ($1,$2,$3,$4,$5,$6)
+        `, ev.ID, ev.UserID, string(ev.Type), ev.EntityID, []byte(ev.Data), ev.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const eventColumns = `seq, id, user_id, type, entity_id, payload, created_at`
+
+func scanEvent(row pgx.Row) (ledger.Event, error) {
+	var ev ledger.Event
+	var typ string
+	var data []byte
+	if err := row.Scan(&ev.Seq, &ev.ID, &ev.UserID, &typ, &ev.EntityID, &data, &ev.CreatedAt); err != nil {
+		return ledger.Event{}, err
+	}
+	ev.Type = ledger.EventType(typ)
+	ev.Data = data
+	return ev, nil
+}
+
+// ListUndispatchedEvents returns outbox events not yet fanned out, in seq order.
+func (s *Store) ListUndispatchedEvents(ctx context.Context, limit int) ([]ledger.Event, error) {
+	rows, err := s.db.Query(ctx, `
+        select `+eventColumns+`
+        from outbox_events
+        where dispatched_at is null
+        order by seq asc
+        limit $1
+    `, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.Event, 0)
+	for rows.Next() {
+		ev, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// GetEvent returns an outbox event by ID.
+func (s *Store) GetEvent(ctx context.Context, id uuid.UUID) (ledger.Event, error) {
+	ev, err := scanEvent(s.db.QueryRow(ctx, `select `+eventColumns+` from outbox_events where id=$1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.Event{}, errs.ErrNotFound
+	}
+	return ev, err
+}
+
+// MarkEventDispatched inserts the event's deliveries and stamps it dispatched
+// in one transaction. The row lock makes a second dispatcher skip the event.
+func (s *Store) MarkEventDispatched(ctx context.Context, eventID uuid.UUID, deliveries []ledger.WebhookDelivery) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	ct, err := tx.Exec(ctx, `update outbox_events set dispatched_at=now() where id=$1 and dispatched_at is null`, eventID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return nil
+	}
+	for _, d := range deliveries {
+		if _, err := tx.Exec(ctx, `
+            insert into webhook_deliveries (id, user_id, event_id, event_type, endpoint_id, status, attempts, next_attempt_at, created_at)
+            values ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+        `, d.ID, d.UserID, d.EventID, string(d.EventType), d.EndpointID, string(d.Status), d.Attempts, d.NextAttemptAt, d.CreatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// --- Webhook endpoints ---
+
+// CreateWebhookEndpoint inserts an endpoint.
+func (s *Store) CreateWebhookEndpoint(ctx context.Context, ep ledger.WebhookEndpoint) (ledger.WebhookEndpoint, error) {
+	types := make([]string, len(ep.EventTypes))
+	for i, t := range ep.EventTypes {
+		types[i] = string(t)
+	}
+	if _, err := s.db.Exec(ctx, `
+        insert into webhook_endpoints (id, user_id, url, event_types, secret, created_at)
+        values ($1,$2,$3,$4,$5,$6)
+    `, ep.ID, ep.UserID, ep.URL, types, ep.Secret, ep.CreatedAt); err != nil {
+		return ledger.WebhookEndpoint{}, err
+	}
+	return ep, nil
+}
+
+const endpointColumns = `id, user_id, url, event_types, secret, created_at`
+
+func scanEndpoint(row pgx.Row) (ledger.WebhookEndpoint, error) {
+	var ep ledger.WebhookEndpoint
+	var types []string
+	if err := row.Scan(&ep.ID, &ep.UserID, &ep.URL, &types, &ep.Secret, &ep.CreatedAt); err != nil {
+		return ledger.WebhookEndpoint{}, err
+	}
+	for _, t := range types {
+		ep.EventTypes = append(ep.EventTypes, ledger.EventType(t))
+	}
+	return ep, nil
+}
+
+// ListWebhookEndpoints returns a user's endpoints, oldest first.
+func (s *Store) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]ledger.WebhookEndpoint, error) {
+	rows, err := s.db.Query(ctx, `
+        select `+endpointColumns+`
+        from webhook_endpoints
+        where user_id=$1
+        order by created_at asc, id asc
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make([]ledger.WebhookEndpoint, 0)
+	for rows.Next() {
+		ep, err := scanEndpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ep)
+	}
+	return out, rows.Err()
+}
+
+// GetWebhookEndpoint returns a user's endpoint by ID.
+func (s *Store) GetWebhookEndpoint(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookEndpoint, error) {
+	ep, err := scanEndpoint(s.db.QueryRow(ctx, `select `+endpointColumns+` from webhook_endpoints where id=$1 and user_id=$2`, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.WebhookEndpoint{}, errs.ErrNotFound
+	}
+	return ep, err
+}
+
+// DeleteWebhookEndpoint removes a user's endpoint; its deliveries are kept.
+func (s *Store) DeleteWebhookEndpoint(ctx context.Context, userID, id uuid.UUID) error {
+	ct, err := s.db.Exec(ctx, `delete from webhook_endpoints where id=$1 and user_id=$2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if ct.RowsAffected() == 0 {
+		return errs.ErrNotFound
+	}
+	return nil
+}
+
+// --- Webhook deliveries ---
+
+const deliveryColumns = `id, user_id, event_id, event_type, endpoint_id, status, attempts, next_attempt_at, last_error, last_status_code, delivered_at, created_at`
+
+func scanDelivery(row pgx.Row) (ledger.WebhookDelivery, error) {
+	var d ledger.WebhookDelivery
+	var typ, status string
+	if err := row.Scan(&d.ID, &d.UserID, &d.EventID, &typ, &d.EndpointID, &status, &d.Attempts, &d.NextAttemptAt,
+		&d.LastError, &d.LastStatusCode, &d.DeliveredAt, &d.CreatedAt); err != nil {
+		return ledger.WebhookDelivery{}, err
+	}
+	d.EventType = ledger.EventType(typ)
+	d.Status = ledger.DeliveryStatus(status)
+	return d, nil
+}
+
+func collectDeliveries(rows pgx.Rows) ([]ledger.WebhookDelivery, error) {
+	defer rows.Close()
+	out := make([]ledger.WebhookDelivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ListWebhookDeliveries returns a user's deliveries, newest first.
+func (s *Store) ListWebhookDeliveries(ctx context.Context, userID uuid.UUID, status ledger.DeliveryStatus) ([]ledger.WebhookDelivery, error) {
+	rows, err := s.db.Query(ctx, `
+        select `+deliveryColumns+`
+        from webhook_deliveries
+        where user_id=$1 and ($2 = '' or status = $2)
+        order by created_at desc, id desc
+    `, userID, string(status))
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+// GetWebhookDelivery returns a user's delivery by ID.
+func (s *Store) GetWebhookDelivery(ctx context.Context, userID, id uuid.UUID) (ledger.WebhookDelivery, error) {
+	d, err := scanDelivery(s.db.QueryRow(ctx, `select `+deliveryColumns+` from webhook_deliveries where id=$1 and user_id=$2`, id, userID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ledger.WebhookDelivery{}, errs.ErrNotFound
+	}
+	return d, err
+}
+
+// ClaimDueDeliveries leases pending deliveries due by now until the given
+// time. Rows locked by another dispatcher are skipped.
+func (s *Store) ClaimDueDeliveries(ctx context.Context, now, until time.Time, limit int) ([]ledger.WebhookDelivery, error) {
+	rows, err := s.db.Query(ctx, `
+        update webhook_deliveries d
+        set next_attempt_at = $2
+        from (
+            select id from webhook_deliveries
+            where status = 'pending' and next_attempt_at <= $1
+            order by next_attempt_at asc, id asc
+            limit $3
+            for update skip locked
+        ) due
+        where d.id = due.id
+        returning d.id, d.user_id, d.event_id, d.event_type, d.endpoint_id, d.status, d.attempts, d.next_attempt_at,
+            d.last_error, d.last_status_code, d.delivered_at, d.created_at
+    `, now, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	return collectDeliveries(rows)
+}
+
+// UpdateWebhookDelivery stores a delivery's state after an attempt or redeliver.
+func (s *Store) UpdateWebhookDelivery(ctx context.Context, d ledger.WebhookDelivery) (ledger.WebhookDelivery, error) {
+	ct, err := s.db.Exec(ctx, `
+        update webhook_deliveries
+        set status=$1, attempts=$2, next_attempt_at=$3, last_error=$4, last_status_code=$5, delivered_at=$6
+        where id=$7 and user_id=$8
+    `, string(d.Status), d.Attempts, d.NextAttemptAt, d.LastError, d.LastStatusCode, d.DeliveredAt, d.ID, d.UserID)
+	if err != nil {
+		return ledger.WebhookDelivery{}, err
+	}
+	if ct.RowsAffected() == 0 {
+		return ledger.WebhookDelivery{}, errs.ErrNotFound
+	}
+	return d, nil
+}
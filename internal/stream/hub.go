@@ -0,0 +1,180 @@
+// Package stream fans out ledger changes to live subscribers in this process.
+// The journal and account writers are wrapped so every committed change is
+// published to a Hub, which numbers events, keeps the latest in a bounded
+// buffer for resuming, and delivers each one to the subscribers of its user.
+package stream
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/tinoosan/ledger/internal/ledger"
+	"github.com/tinoosan/ledger/internal/service/account"
+	"github.com/tinoosan/ledger/internal/service/journal"
+)
+
+// DefaultBufferSize is how many recent events a Hub keeps for resuming.
+const DefaultBufferSize = 1024
+
+// subscriberQueue bounds the events waiting for a subscriber. A subscriber
+// that falls this far behind is dropped and resumes from the buffer.
+const subscriberQueue = 64
+
+// Hub numbers published events and delivers them to subscribers.
+type Hub struct {
+	mu   sync.Mutex
+	size int
+	// buf holds the latest events in Seq order.
+	buf  []ledger.Event
+	seq  int64
+	subs map[*Subscription]struct{}
+}
+
+// NewHub returns a Hub buffering up to size events; size <= 0 uses DefaultBufferSize.
+func NewHub(size int) *Hub {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Hub{size: size, subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives one user's events until it is closed, either by
+// Close or by the Hub when the subscriber falls behind.
+type Subscription struct {
+	hub    *Hub
+	userID uuid.UUID
+	ch     chan ledger.Event
+	closed bool
+}
+
+// C delivers events in Seq order; it is closed when the subscription ends.
+func (s *Subscription) C() <-chan ledger.Event { return s.ch }
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.dropLocked(s)
+}
+
+// Publish numbers events with the Hub's sequence, buffers them and delivers
+// them to their users' subscribers.
+func (h *Hub) Publish(events ...ledger.Event) {
+	if len(events) == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, ev := range events {
+		h.seq++
+		ev.Seq = h.seq
+		h.buf = append(h.buf, ev)
+		if len(h.buf) > h.size {
+			h.buf = h.buf[len(h.buf)-h.size:]
+		}
+		for sub := range h.subs {
+			if sub.userID != ev.UserID {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+				h.dropLocked(sub)
+			}
+		}
+	}
+}
+
+// Subscribe starts delivering userID's events published from now on. When
+// after is positive it also returns the buffered events with a greater Seq,
+// and complete reports whether the buffer still held every one of them; it is
+// false when older events were evicted or after comes from a previous process.
+func (h *Hub) Subscribe(userID uuid.UUID, after int64) (sub *Subscription, backlog []ledger.Event, complete bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	sub = &Subscription{hub: h, userID: userID, ch: make(chan ledger.Event, subscriberQueue)}
+	h.subs[sub] = struct{}{}
+	if after <= 0 {
+		return sub, nil, true
+	}
+	complete = after <= h.seq && (len(h.buf) == 0 || h.buf[0].Seq <= after+1)
+	for _, ev := range h.buf {
+		if ev.Seq > after && ev.UserID == userID {
+			backlog = append(backlog, ev)
+		}
+	}
+	return sub, backlog, complete
+}
+
+// Close ends every subscription, as when the process shuts down.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		h.dropLocked(sub)
+	}
+}
+
+// dropLocked removes sub and closes its channel. Caller must hold h.mu.
+func (h *Hub) dropLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subs, sub)
+	close(sub.ch)
+}
+
+// JournalWriter wraps w so the events of every entry it creates are published
+// to h once the write has returned.
+func JournalWriter(w journal.Writer, h *Hub) journal.Writer { return journalWriter{Writer: w, hub: h} }
+
+type journalWriter struct {
+	journal.Writer
+	hub *Hub
+}
+
+func (w journalWriter) CreateJournalEntry(ctx context.Context, e ledger.JournalEntry) (ledger.JournalEntry, error) {
+	created, err := w.Writer.CreateJournalEntry(ctx, e)
+	if err == nil {
+		w.hub.Publish(ledger.EntryEvents(created)...)
+	}
+	return created, err
+}
+
+func (w journalWriter) ReverseJournalEntry(ctx context.Context, orig, upd, rev ledger.JournalEntry, corr *ledger.JournalEntry) (ledger.JournalEntry, error) {
+	created, err := w.Writer.ReverseJournalEntry(ctx, orig, upd, rev, corr)
+	if err == nil {
+		w.hub.Publish(ledger.EntryEvents(created)...)
+		if corr != nil {
+			w.hub.Publish(ledger.EntryEvents(*corr)...)
+		}
+	}
+	return created, err
+}
+
+// AccountWriter wraps w so account updates are published to h; repo supplies
+// the account as it was before the update.
+func AccountWriter(w account.Writer, repo account.Repo, h *Hub) account.Writer {
+	return accountWriter{Writer: w, repo: repo, hub: h}
+}
+
+type accountWriter struct {
+	account.Writer
+	repo account.Repo
+	hub  *Hub
+}
+
+func (w accountWriter) UpdateAccount(ctx context.Context, a ledger.Account) (ledger.Account, error) {
+	before, err := w.repo.GetAccount(ctx, a.UserID, a.ID)
+	if err != nil {
+		return ledger.Account{}, err
+	}
+	updated, err := w.Writer.UpdateAccount(ctx, a)
+	if err == nil {
+		w.hub.Publish(ledger.AccountEvents(before, updated)...)
+	}
+	return updated, err
+}
@@ -0,0 +1,44 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/tinoosan/ledger/internal/ledger"
+)
+
+func TestHubResumeReportsEvictedEvents(t *testing.T) {
+	h := NewHub(3)
+	user, other := uuid.New(), uuid.New()
+	for i := 0; i < 5; i++ {
+		h.Publish(ledger.Event{UserID: user}, ledger.Event{UserID: other})
+	}
+	// Seqs 8..10 remain buffered; 10 is the last.
+	_, backlog, complete := h.Subscribe(user, 8)
+	if !complete || len(backlog) != 1 || backlog[0].Seq != 9 {
+		t.Fatalf("resume after 8: complete=%v backlog=%+v", complete, backlog)
+	}
+	if _, _, complete := h.Subscribe(user, 5); complete {
+		t.Fatal("resume after an evicted event should be incomplete")
+	}
+	if _, _, complete := h.Subscribe(user, 11); complete {
+		t.Fatal("resume after an unknown event should be incomplete")
+	}
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	h := NewHub(0)
+	user := uuid.New()
+	sub, _, _ := h.Subscribe(user, 0)
+	for i := 0; i <= subscriberQueue; i++ {
+		h.Publish(ledger.Event{UserID: user})
+	}
+	n := 0
+	for range sub.C() {
+		n++
+	}
+	if n != subscriberQueue {
+		t.Fatalf("expected %d queued events before the drop, got %d", subscriberQueue, n)
+	}
+	sub.Close() // closing again is a no-op
+}